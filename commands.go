@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/config"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/migrations"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/services"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// 命令行子命令，例如：
+//
+//	config check
+//	migrate status|up|down
+//	migrate to 0003
+//	rollup rebuild 2024-01-01 2024-12-31
+//	rollup check 2024-01-01 2024-01-31
+//	search reindex
+func runCommand(db *gorm.DB, args []string) error {
+	switch args[0] {
+	case "migrate":
+		return runMigrateCommand(db, args[1:])
+	case "rollup":
+		return runRollupCommand(db, args[1:])
+	case "search":
+		return runSearchCommand(db, args[1:])
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+// 校验配置并输出生效的配置（隐藏密码和密钥），配置有误时返回全部错误
+func runConfigCommand(cfg *config.Config, args []string) error {
+	if len(args) != 1 || args[0] != "check" {
+		return fmt.Errorf("usage: config check")
+	}
+	validateErr := cfg.Validate()
+	data, err := yaml.Marshal(cfg.Redacted())
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(data))
+	if validateErr != nil {
+		return fmt.Errorf("config is invalid:\n%w", validateErr)
+	}
+	fmt.Println("config is valid")
+	return nil
+}
+
+func runMigrateCommand(db *gorm.DB, args []string) error {
+	usage := fmt.Errorf("usage: migrate status|up|down|to <version>")
+	if len(args) == 0 {
+		return usage
+	}
+
+	migrator := migrations.New(db)
+	switch {
+	case args[0] == "status" && len(args) == 1:
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.Applied {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%s  %-40s %s\n", status.Version, status.Name, appliedAt)
+		}
+		return nil
+	case args[0] == "up" && len(args) == 1:
+		return migrator.Up()
+	case args[0] == "down" && len(args) == 1:
+		return migrator.Down()
+	case args[0] == "to" && len(args) == 2:
+		return migrator.To(args[1])
+	}
+	return usage
+}
+
+func runRollupCommand(db *gorm.DB, args []string) error {
+	if len(args) != 3 || (args[0] != "rebuild" && args[0] != "check") {
+		return fmt.Errorf("usage: rollup rebuild|check <startDate> <endDate>")
+	}
+	startDate, endDate, err := utils.DateRange(args[1], args[2], utils.PlantLocation())
+	if err != nil {
+		return err
+	}
+
+	rollupService, _ := services.NewQualityRollupService(db)
+	if args[0] == "rebuild" {
+		if err := rollupService.Rebuild(startDate, endDate); err != nil {
+			return err
+		}
+		fmt.Printf("quality rollup rebuilt for %s ~ %s\n", args[1], args[2])
+		return nil
+	}
+
+	result, err := rollupService.Check(startDate, endDate)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		return err
+	}
+	if !result.Consistent {
+		return fmt.Errorf("quality rollup is inconsistent with raw data")
+	}
+	return nil
+}
+
+func runSearchCommand(db *gorm.DB, args []string) error {
+	if len(args) != 1 || args[0] != "reindex" {
+		return fmt.Errorf("usage: search reindex")
+	}
+	searchService, _ := services.NewSearchService(db)
+	err := searchService.Reindex(func(done, total int64) error {
+		fmt.Printf("\rindexed %d/%d", done, total)
+		return nil
+	})
+	fmt.Println()
+	if err != nil {
+		return err
+	}
+	fmt.Println("search index rebuilt")
+	return nil
+}
@@ -1,54 +1,143 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
-	"strings"
 	"time"
 
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/config"
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/databases"
-	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/health"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/logging"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/metrics"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/migrations"
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/routes"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/services"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/tracing"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 )
 
 func main() {
-	// Load .env file
-	if strings.ToLower(os.Getenv("PRODUCTION")) == "true" {
-		time.Sleep(10 * time.Second)
-	} else {
-		if err := godotenv.Load(); err != nil {
-			fmt.Println("Error loading .env file:", err)
-			return
+	// 加载并校验配置
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Println("Error loading config:", err)
+		os.Exit(1)
+	}
+
+	// config 子命令只检查配置，不连接数据库
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if err := runConfigCommand(cfg, os.Args[2:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := cfg.Validate(); err != nil {
+		fmt.Println("Invalid config:", err)
+		os.Exit(1)
+	}
+	if err := utils.ConfigureTimezones(cfg.Timezone.Plant, cfg.Timezone.DB); err != nil {
+		fmt.Println("Invalid config:", err)
+		os.Exit(1)
+	}
+	services.ConfigureSecretKey(cfg.Secret)
+	services.ConfigureExports(cfg.Storage.ExportDir, cfg.Storage.ExportSyncMaxRows)
+	services.ConfigureJobs(cfg.Jobs)
+
+	// JSON 日志输出到标准输出，配置 LOG_FILE 时写入文件并按大小轮转
+	logCloser := logging.Setup(cfg.Log)
+	defer logCloser.Close()
+	services.ConfigureReports(cfg.Reports)
+	services.ConfigureBatches(cfg.Quality)
+	metrics.ConfigureDefectReasons(cfg.Quality.DefectReasons)
+
+	// 链路追踪，TRACING_EXPORTER=none（默认）时不采集
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		slog.Error("setup tracing failed", "error", err)
+		os.Exit(1)
+	}
+
+	// Init DB：数据库尚未就绪（例如与数据库容器同时启动）时按退避重试
+	DB_CONN, err = databases.Connect(cfg.DB)
+	if err != nil {
+		slog.Error("connect database failed", "error", err)
+		os.Exit(1)
+	}
+
+	// 命令行子命令，执行完毕后退出
+	if len(os.Args) > 1 {
+		if err := runCommand(DB_CONN, os.Args[1:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// 启动时执行未执行的数据库迁移，AUTO_MIGRATE=false 时需通过 migrate up 手动执行
+	if cfg.DB.AutoMigrate {
+		if err := migrations.New(DB_CONN).Up(); err != nil {
+			slog.Error("migrate database failed", "error", err)
+			os.Exit(1)
 		}
 	}
 
-	// Init DB
-	DB_CONN = databases.InitDB(os.Getenv("DB_HOST"), os.Getenv("DB_USER"), os.Getenv("DB_PASS"), os.Getenv("DB_PORT"), os.Getenv("DB_NAME"))
-	models.Migrate(DB_CONN)
 	checkAdmin(DB_CONN)
 
-	// Log - 只输出到控制台，不写入文件
-	// f, err := os.Create(os.Getenv("LOG_FILE"))
-	// if err != nil {
-	// 	fmt.Println("LOG_FILE error", err)
-	// 	return
-	// }
-	// defer f.Close()
-
-	// f, err = os.OpenFile(os.Getenv("LOG_FILE"), os.O_WRONLY|os.O_APPEND, os.ModeAppend)
-	// if err != nil {
-	// 	fmt.Println("err", err)
-	// }
-	// gin.DefaultWriter = io.MultiWriter(f, os.Stdout)
-	gin.DefaultWriter = os.Stdout // 只输出到控制台
+	// 首次部署或工厂时区变更时根据已有的原始数据生成质量预聚合
+	rollupService, _ := services.NewQualityRollupService(DB_CONN)
+	if err := rollupService.Backfill(); err != nil {
+		slog.Error("backfill quality rollup failed", "error", err)
+	}
+
+	// 首次部署时在后台为已有数据生成搜索索引（任务由下方启动的工作协程执行）
+	searchService, _ := services.NewSearchService(DB_CONN)
+	if _, err := searchService.Backfill(); err != nil {
+		slog.Error("backfill search index failed", "error", err)
+	}
 
 	// Init godi
 	InitGodi()
 
-	// Init gin
-	r := gin.Default()
+	// 后台任务工作协程，上次停止时中断的任务重新执行
+	jobWorkers := services.StartJobWorkers(DB_CONN)
+	// 定时报表：到期的计划加入任务队列
+	reportScheduler := services.StartReportScheduler(DB_CONN)
+
+	// 就绪检查，其他子系统可在启动时通过 health.Register 追加
+	health.Register("database", health.DatabaseCheck(DB_CONN, cfg.Health.DBMaxLatency))
+	health.Register("migrations", health.MigrationsCheck(DB_CONN))
+	health.Register("export_disk", health.DiskSpaceCheck(cfg.Storage.ExportDir, uint64(cfg.Health.MinFreeDiskMB)<<20))
+
+	metrics.TrackOpenPallets(DB_CONN)
+
+	// Init gin：访问日志由 logging 中间件记录
+	r := gin.New()
+	r.Use(gin.Recovery())
 	routes.RegisterRoute(r, SERVICE_CONTAINER)
-	r.Run(fmt.Sprintf("%s:%s", os.Getenv("HTTP_HOST"), os.Getenv("HTTP_PORT")))
+	serveErr := serve(cfg.HTTP, r)
+
+	// 处理中的请求和任务结束后再关闭数据库连接，并导出剩余的 span
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reportScheduler.Stop()
+	if err := jobWorkers.Stop(ctx); err != nil {
+		slog.Warn("stop job workers failed", "error", err)
+	}
+	if sqlDB, err := DB_CONN.DB(); err == nil {
+		sqlDB.Close()
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Warn("flush traces failed", "error", err)
+	}
+	if serveErr != nil {
+		slog.Error("server error", "error", serveErr)
+		logCloser.Close()
+		os.Exit(1)
+	}
 }
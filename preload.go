@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"log/slog"
 
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/services"
@@ -25,61 +26,17 @@ func checkAdmin(db *gorm.DB) error {
 	}
 
 	result = tb.Create(&user)
-	fmt.Println(result.RowsAffected)
 	if result.Error != nil {
 		return result.Error
 	}
+	slog.Info("admin user created")
 	return nil
 }
 
 func InitGodi() {
 	SERVICE_CONTAINER = godi.New()
 
-	if err := SERVICE_CONTAINER.Register(&services.APIService{}, services.NewAPIService, DB_CONN); err != nil {
-		panic(err)
-	}
-
-	if err := SERVICE_CONTAINER.Register(&services.PalletService{}, services.NewPalletService, DB_CONN); err != nil {
-		panic(err)
-	}
-
-	if err := SERVICE_CONTAINER.Register(&services.ProductLineService{}, services.NewProductLineService, DB_CONN); err != nil {
-		panic(err)
-	}
-
-	if err := SERVICE_CONTAINER.Register(&services.ProductModelService{}, services.NewProductModelService, DB_CONN); err != nil {
-		panic(err)
-	}
-
-	if err := SERVICE_CONTAINER.Register(&services.ProductService{}, services.NewProductService, DB_CONN); err != nil {
-		panic(err)
-	}
-
-	if err := SERVICE_CONTAINER.Register(&services.ProductionPlanService{}, services.NewProductionPlanService, DB_CONN); err != nil {
-		panic(err)
-	}
-
-	if err := SERVICE_CONTAINER.Register(&services.SupplierService{}, services.NewSupplierService, DB_CONN); err != nil {
-		panic(err)
-	}
-
-	if err := SERVICE_CONTAINER.Register(&services.UserService{}, services.NewUserService, DB_CONN); err != nil {
-		panic(err)
-	}
-
-	if err := SERVICE_CONTAINER.Register(&services.JwtService{}, services.NewJWTService); err != nil {
-		panic(err)
-	}
-
-	if err := SERVICE_CONTAINER.Register(&services.KeyManagementService{}, services.NewKeyManagementService); err != nil {
-		panic(err)
-	}
-
-	if err := SERVICE_CONTAINER.Register(&services.QualityStatsService{}, services.NewQualityStatsService, DB_CONN); err != nil {
-		panic(err)
-	}
-
-	if err := SERVICE_CONTAINER.Register(&services.DataReportService{}, services.NewDataReportService, DB_CONN); err != nil {
+	if err := services.RegisterAll(SERVICE_CONTAINER, DB_CONN); err != nil {
 		panic(err)
 	}
 }
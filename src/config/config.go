@@ -0,0 +1,449 @@
+// Package config 服务配置：默认值 < YAML 文件 < .env 文件 < 环境变量，加载后统一校验
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultConfigFile = "config.yaml"
+
+type Config struct {
+	Production bool           `yaml:"production"`
+	Secret     string         `yaml:"secret"` // JWT 签名密钥
+	HTTP       HTTPConfig     `yaml:"http"`
+	DB         DBConfig       `yaml:"db"`
+	Timezone   TimezoneConfig `yaml:"timezone"`
+	Storage    StorageConfig  `yaml:"storage"`
+	Health     HealthConfig   `yaml:"health"`
+	Log        LogConfig      `yaml:"log"`
+	Tracing    TracingConfig  `yaml:"tracing"`
+	Jobs       JobsConfig     `yaml:"jobs"`
+	Reports    ReportsConfig  `yaml:"reports"`
+	Quality    QualityConfig  `yaml:"quality"`
+}
+
+type HTTPConfig struct {
+	Host              string        `yaml:"host"`
+	Port              int           `yaml:"port"`
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout"`
+	ReadTimeout       time.Duration `yaml:"readTimeout"`
+	WriteTimeout      time.Duration `yaml:"writeTimeout"`
+	IdleTimeout       time.Duration `yaml:"idleTimeout"`
+	// 收到退出信号后等待处理中请求完成的最长时间
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+}
+
+type DBConfig struct {
+	Driver      string `yaml:"driver"` // mysql/postgres/sqlite
+	Host        string `yaml:"host"`
+	Port        int    `yaml:"port"`
+	User        string `yaml:"user"`
+	Password    string `yaml:"password"`
+	Name        string `yaml:"name"` // 数据库名，sqlite 时为文件路径
+	AutoMigrate bool   `yaml:"autoMigrate"`
+	// 启动时连接失败的重试次数，以及首次重试的等待时间（之后每次翻倍，不超过 MaxRetryBackoff）
+	ConnectRetries  int           `yaml:"connectRetries"`
+	RetryBackoff    time.Duration `yaml:"retryBackoff"`
+	MaxRetryBackoff time.Duration `yaml:"maxRetryBackoff"`
+	// 连接池，0 表示不限制
+	MaxOpenConns    int           `yaml:"maxOpenConns"`
+	MaxIdleConns    int           `yaml:"maxIdleConns"`
+	ConnMaxLifetime time.Duration `yaml:"connMaxLifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"connMaxIdleTime"`
+}
+
+type StorageConfig struct {
+	ExportDir string `yaml:"exportDir"` // 导出文件和任务产物的存放目录
+	// 记录数超过该值的原始记录导出转为后台生成文件
+	ExportSyncMaxRows int `yaml:"exportSyncMaxRows"`
+}
+
+// 就绪检查阈值
+type HealthConfig struct {
+	DBMaxLatency  time.Duration `yaml:"dbMaxLatency"`  // 数据库 Ping 耗时上限，0 表示不限制
+	MinFreeDiskMB int           `yaml:"minFreeDiskMB"` // 导出目录所在磁盘的最小剩余空间
+}
+
+// 日志：File 为空时输出到标准输出，否则写入文件并按大小轮转
+type LogConfig struct {
+	Level      string        `yaml:"level"` // debug/info/warn/error
+	File       string        `yaml:"file"`
+	MaxSizeMB  int           `yaml:"maxSizeMB"`  // 单个文件达到该大小后轮转
+	MaxBackups int           `yaml:"maxBackups"` // 保留的旧文件数，0 表示不限制
+	MaxAgeDays int           `yaml:"maxAgeDays"` // 旧文件保留天数，0 表示不限制
+	Compress   bool          `yaml:"compress"`   // 是否 gzip 压缩旧文件
+	SlowQuery  time.Duration `yaml:"slowQuery"`  // 超过该耗时的 SQL 以 warn 级别记录，0 表示不记录慢查询
+}
+
+// OpenTelemetry 链路追踪，Exporter 为 none 时不采集
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter"`    // none/otlp/stdout
+	Endpoint    string  `yaml:"endpoint"`    // OTLP/HTTP 地址，例如 http://otel-collector:4318
+	ServiceName string  `yaml:"serviceName"` // 上报的 service.name
+	SampleRatio float64 `yaml:"sampleRatio"` // 根 span 的采样比例，0~1
+}
+
+// 后台任务：工作协程数、轮询间隔和失败重试
+type JobsConfig struct {
+	Workers         int           `yaml:"workers"`         // 工作协程数，0 表示不在本进程执行任务
+	PollInterval    time.Duration `yaml:"pollInterval"`    // 没有待执行任务时的轮询间隔
+	MaxAttempts     int           `yaml:"maxAttempts"`     // 最多执行次数（含首次）
+	RetryBackoff    time.Duration `yaml:"retryBackoff"`    // 首次重试的等待时间，之后每次翻倍
+	MaxRetryBackoff time.Duration `yaml:"maxRetryBackoff"` // 重试等待时间上限
+	StaleTimeout    time.Duration `yaml:"staleTimeout"`    // 执行中的任务超过该时间没有心跳时重新排队（进程崩溃）
+}
+
+// 定时报表：PDF 字体、本地目录投递和邮件发送
+type ReportsConfig struct {
+	FontPath  string     `yaml:"fontPath"`  // PDF 使用的 TrueType 字体（需包含中文字形），为空时不能生成 PDF
+	FolderDir string     `yaml:"folderDir"` // 本地目录投递的根目录，计划中的目标为其下的子目录
+	SMTP      SMTPConfig `yaml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+// 质量管控
+type QualityConfig struct {
+	BatchHoldPolicy string   `yaml:"batchHoldPolicy"` // 产线上报冻结批次的产品或托盘时：warn（接收并提示）/reject（拒绝）
+	DefectReasons   []string `yaml:"defectReasons"`   // 不良品指标按原因计数的已知不良原因，其他原因计为 other
+}
+
+type TimezoneConfig struct {
+	Plant string `yaml:"plant"` // 工厂时区
+	DB    string `yaml:"db"`    // 数据库存储时区
+}
+
+func Default() *Config {
+	return &Config{
+		HTTP: HTTPConfig{
+			Port:              8080,
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      60 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		DB: DBConfig{
+			Driver:          "mysql",
+			Host:            "localhost",
+			Port:            3306,
+			AutoMigrate:     true,
+			ConnectRetries:  10,
+			RetryBackoff:    time.Second,
+			MaxRetryBackoff: 30 * time.Second,
+			MaxOpenConns:    25,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+		},
+		Timezone: TimezoneConfig{Plant: "Asia/Shanghai", DB: "UTC"},
+		Storage:  StorageConfig{ExportDir: "data/exports", ExportSyncMaxRows: 50000},
+		Health:   HealthConfig{DBMaxLatency: 500 * time.Millisecond, MinFreeDiskMB: 1024},
+		Tracing:  TracingConfig{Exporter: "none", ServiceName: "hisense-vmi-dataserver", SampleRatio: 1},
+		Reports:  ReportsConfig{FolderDir: "data/reports", SMTP: SMTPConfig{Port: 587}},
+		Jobs:     JobsConfig{Workers: 2, PollInterval: time.Second, MaxAttempts: 3, RetryBackoff: 10 * time.Second, MaxRetryBackoff: 10 * time.Minute, StaleTimeout: 2 * time.Minute},
+		Quality:  QualityConfig{BatchHoldPolicy: "warn", DefectReasons: []string{"端子变形", "铭牌不良", "外观不良", "轴承噪音"}},
+		Log:      LogConfig{Level: "info", MaxSizeMB: 100, MaxBackups: 10, MaxAgeDays: 30, SlowQuery: 200 * time.Millisecond},
+	}
+}
+
+// 加载配置：YAML 文件路径由 CONFIG_FILE 指定，未指定时读取当前目录下存在的 config.yaml；
+// .env 不覆盖已存在的环境变量
+func Load() (*Config, error) {
+	cfg := Default()
+
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		if _, err := os.Stat(defaultConfigFile); err == nil {
+			path = defaultConfigFile
+		}
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load .env file: %w", err)
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// 环境变量覆盖，变量名与旧版保持一致
+func (c *Config) applyEnv() error {
+	var errs []error
+	str := func(name string, target *string) {
+		if value, ok := os.LookupEnv(name); ok {
+			*target = value
+		}
+	}
+	integer := func(name string, target *int) {
+		if value, ok := os.LookupEnv(name); ok && value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid integer %q", name, value))
+				return
+			}
+			*target = parsed
+		}
+	}
+	boolean := func(name string, target *bool) {
+		if value, ok := os.LookupEnv(name); ok && value != "" {
+			parsed, err := strconv.ParseBool(strings.ToLower(value))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid boolean %q", name, value))
+				return
+			}
+			*target = parsed
+		}
+	}
+	number := func(name string, target *float64) {
+		if value, ok := os.LookupEnv(name); ok && value != "" {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid number %q", name, value))
+				return
+			}
+			*target = parsed
+		}
+	}
+	list := func(name string, target *[]string) {
+		if value, ok := os.LookupEnv(name); ok {
+			*target = nil
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					*target = append(*target, item)
+				}
+			}
+		}
+	}
+	duration := func(name string, target *time.Duration) {
+		if value, ok := os.LookupEnv(name); ok && value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid duration %q", name, value))
+				return
+			}
+			*target = parsed
+		}
+	}
+
+	boolean("PRODUCTION", &c.Production)
+	str("SECRET", &c.Secret)
+	str("HTTP_HOST", &c.HTTP.Host)
+	integer("HTTP_PORT", &c.HTTP.Port)
+	duration("HTTP_READ_HEADER_TIMEOUT", &c.HTTP.ReadHeaderTimeout)
+	duration("HTTP_READ_TIMEOUT", &c.HTTP.ReadTimeout)
+	duration("HTTP_WRITE_TIMEOUT", &c.HTTP.WriteTimeout)
+	duration("HTTP_IDLE_TIMEOUT", &c.HTTP.IdleTimeout)
+	duration("HTTP_SHUTDOWN_TIMEOUT", &c.HTTP.ShutdownTimeout)
+	str("DB_DRIVER", &c.DB.Driver)
+	str("DB_HOST", &c.DB.Host)
+	integer("DB_PORT", &c.DB.Port)
+	str("DB_USER", &c.DB.User)
+	str("DB_PASS", &c.DB.Password)
+	str("DB_NAME", &c.DB.Name)
+	boolean("AUTO_MIGRATE", &c.DB.AutoMigrate)
+	integer("DB_CONNECT_RETRIES", &c.DB.ConnectRetries)
+	duration("DB_RETRY_BACKOFF", &c.DB.RetryBackoff)
+	duration("DB_MAX_RETRY_BACKOFF", &c.DB.MaxRetryBackoff)
+	integer("DB_MAX_OPEN_CONNS", &c.DB.MaxOpenConns)
+	integer("DB_MAX_IDLE_CONNS", &c.DB.MaxIdleConns)
+	duration("DB_CONN_MAX_LIFETIME", &c.DB.ConnMaxLifetime)
+	duration("DB_CONN_MAX_IDLE_TIME", &c.DB.ConnMaxIdleTime)
+	str("PLANT_TIMEZONE", &c.Timezone.Plant)
+	str("DB_TIMEZONE", &c.Timezone.DB)
+	str("EXPORT_DIR", &c.Storage.ExportDir)
+	integer("EXPORT_SYNC_MAX_ROWS", &c.Storage.ExportSyncMaxRows)
+	duration("HEALTH_DB_MAX_LATENCY", &c.Health.DBMaxLatency)
+	integer("HEALTH_MIN_FREE_DISK_MB", &c.Health.MinFreeDiskMB)
+	str("LOG_LEVEL", &c.Log.Level)
+	str("LOG_FILE", &c.Log.File)
+	integer("LOG_MAX_SIZE_MB", &c.Log.MaxSizeMB)
+	integer("LOG_MAX_BACKUPS", &c.Log.MaxBackups)
+	integer("LOG_MAX_AGE_DAYS", &c.Log.MaxAgeDays)
+	boolean("LOG_COMPRESS", &c.Log.Compress)
+	duration("LOG_SLOW_QUERY", &c.Log.SlowQuery)
+	str("TRACING_EXPORTER", &c.Tracing.Exporter)
+	str("TRACING_ENDPOINT", &c.Tracing.Endpoint)
+	str("TRACING_SERVICE_NAME", &c.Tracing.ServiceName)
+	number("TRACING_SAMPLE_RATIO", &c.Tracing.SampleRatio)
+	integer("JOB_WORKERS", &c.Jobs.Workers)
+	duration("JOB_POLL_INTERVAL", &c.Jobs.PollInterval)
+	integer("JOB_MAX_ATTEMPTS", &c.Jobs.MaxAttempts)
+	duration("JOB_RETRY_BACKOFF", &c.Jobs.RetryBackoff)
+	duration("JOB_MAX_RETRY_BACKOFF", &c.Jobs.MaxRetryBackoff)
+	duration("JOB_STALE_TIMEOUT", &c.Jobs.StaleTimeout)
+	str("REPORT_FONT_PATH", &c.Reports.FontPath)
+	str("REPORT_FOLDER_DIR", &c.Reports.FolderDir)
+	str("SMTP_HOST", &c.Reports.SMTP.Host)
+	integer("SMTP_PORT", &c.Reports.SMTP.Port)
+	str("SMTP_USERNAME", &c.Reports.SMTP.Username)
+	str("SMTP_PASSWORD", &c.Reports.SMTP.Password)
+	str("SMTP_FROM", &c.Reports.SMTP.From)
+	str("BATCH_HOLD_POLICY", &c.Quality.BatchHoldPolicy)
+	list("DEFECT_REASONS", &c.Quality.DefectReasons)
+	return errors.Join(errs...)
+}
+
+// 校验配置，返回全部错误
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.HTTP.Port <= 0 || c.HTTP.Port > 65535 {
+		errs = append(errs, fmt.Errorf("HTTP_PORT: %d is out of range", c.HTTP.Port))
+	}
+	if c.HTTP.ReadHeaderTimeout < 0 || c.HTTP.ReadTimeout < 0 || c.HTTP.WriteTimeout < 0 || c.HTTP.IdleTimeout < 0 {
+		errs = append(errs, fmt.Errorf("HTTP timeouts must not be negative"))
+	}
+	if c.HTTP.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("HTTP_SHUTDOWN_TIMEOUT must be positive"))
+	}
+
+	c.DB.Driver = strings.ToLower(c.DB.Driver)
+	switch c.DB.Driver {
+	case "mysql", "postgres":
+		if c.DB.Host == "" {
+			errs = append(errs, fmt.Errorf("DB_HOST is required for %s", c.DB.Driver))
+		}
+		if c.DB.Port <= 0 || c.DB.Port > 65535 {
+			errs = append(errs, fmt.Errorf("DB_PORT: %d is out of range", c.DB.Port))
+		}
+		if c.DB.User == "" {
+			errs = append(errs, fmt.Errorf("DB_USER is required for %s", c.DB.Driver))
+		}
+	case "sqlite":
+		if c.Timezone.DB != "UTC" {
+			errs = append(errs, fmt.Errorf("DB_TIMEZONE must be UTC for sqlite"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("DB_DRIVER: unsupported driver %q, expected mysql, postgres or sqlite", c.DB.Driver))
+	}
+	if c.DB.Name == "" {
+		errs = append(errs, fmt.Errorf("DB_NAME is required"))
+	}
+	if c.DB.ConnectRetries < 0 {
+		errs = append(errs, fmt.Errorf("DB_CONNECT_RETRIES must not be negative"))
+	}
+	if c.DB.RetryBackoff <= 0 || c.DB.MaxRetryBackoff < c.DB.RetryBackoff {
+		errs = append(errs, fmt.Errorf("DB_RETRY_BACKOFF must be positive and not exceed DB_MAX_RETRY_BACKOFF"))
+	}
+	if c.DB.MaxOpenConns < 0 || c.DB.MaxIdleConns < 0 || c.DB.ConnMaxLifetime < 0 || c.DB.ConnMaxIdleTime < 0 {
+		errs = append(errs, fmt.Errorf("DB connection pool settings must not be negative"))
+	}
+	if c.DB.MaxOpenConns > 0 && c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		errs = append(errs, fmt.Errorf("DB_MAX_IDLE_CONNS must not exceed DB_MAX_OPEN_CONNS"))
+	}
+
+	if _, err := time.LoadLocation(c.Timezone.Plant); err != nil || c.Timezone.Plant == "" {
+		errs = append(errs, fmt.Errorf("PLANT_TIMEZONE: invalid timezone %q", c.Timezone.Plant))
+	}
+	if _, err := time.LoadLocation(c.Timezone.DB); err != nil || c.Timezone.DB == "" {
+		errs = append(errs, fmt.Errorf("DB_TIMEZONE: invalid timezone %q", c.Timezone.DB))
+	}
+
+	if c.Storage.ExportDir == "" {
+		errs = append(errs, fmt.Errorf("EXPORT_DIR is required"))
+	}
+	if c.Storage.ExportSyncMaxRows < 0 {
+		errs = append(errs, fmt.Errorf("EXPORT_SYNC_MAX_ROWS must not be negative"))
+	}
+	if c.Health.DBMaxLatency < 0 || c.Health.MinFreeDiskMB < 0 {
+		errs = append(errs, fmt.Errorf("health check thresholds must not be negative"))
+	}
+
+	c.Log.Level = strings.ToLower(c.Log.Level)
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("LOG_LEVEL: unsupported level %q, expected debug, info, warn or error", c.Log.Level))
+	}
+	if c.Log.MaxSizeMB <= 0 || c.Log.MaxBackups < 0 || c.Log.MaxAgeDays < 0 || c.Log.SlowQuery < 0 {
+		errs = append(errs, fmt.Errorf("LOG_MAX_SIZE_MB must be positive and other log settings must not be negative"))
+	}
+
+	c.Tracing.Exporter = strings.ToLower(c.Tracing.Exporter)
+	switch c.Tracing.Exporter {
+	case "none", "otlp", "stdout":
+	default:
+		errs = append(errs, fmt.Errorf("TRACING_EXPORTER: unsupported exporter %q, expected none, otlp or stdout", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("TRACING_SAMPLE_RATIO must be between 0 and 1"))
+	}
+
+	if c.Jobs.Workers < 0 || c.Jobs.PollInterval <= 0 || c.Jobs.MaxAttempts <= 0 || c.Jobs.StaleTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("JOB_WORKERS must not be negative, JOB_POLL_INTERVAL, JOB_MAX_ATTEMPTS and JOB_STALE_TIMEOUT must be positive"))
+	}
+	if c.Jobs.RetryBackoff <= 0 || c.Jobs.MaxRetryBackoff < c.Jobs.RetryBackoff {
+		errs = append(errs, fmt.Errorf("JOB_RETRY_BACKOFF must be positive and not exceed JOB_MAX_RETRY_BACKOFF"))
+	}
+
+	if c.Reports.FolderDir == "" {
+		errs = append(errs, fmt.Errorf("REPORT_FOLDER_DIR is required"))
+	}
+	if c.Reports.FontPath != "" {
+		if _, err := os.Stat(c.Reports.FontPath); err != nil {
+			errs = append(errs, fmt.Errorf("REPORT_FONT_PATH: %w", err))
+		}
+	}
+	if c.Reports.SMTP.Host != "" {
+		if c.Reports.SMTP.Port <= 0 || c.Reports.SMTP.Port > 65535 {
+			errs = append(errs, fmt.Errorf("SMTP_PORT: %d is out of range", c.Reports.SMTP.Port))
+		}
+		if c.Reports.SMTP.From == "" {
+			errs = append(errs, fmt.Errorf("SMTP_FROM is required when SMTP_HOST is set"))
+		}
+	}
+
+	c.Quality.BatchHoldPolicy = strings.ToLower(c.Quality.BatchHoldPolicy)
+	if c.Quality.BatchHoldPolicy != "warn" && c.Quality.BatchHoldPolicy != "reject" {
+		errs = append(errs, fmt.Errorf("BATCH_HOLD_POLICY: unsupported policy %q, expected warn or reject", c.Quality.BatchHoldPolicy))
+	}
+
+	if c.Production && (c.Secret == "" || c.Secret == "secret") {
+		errs = append(errs, fmt.Errorf("SECRET must be set to a non-default value in production"))
+	}
+	return errors.Join(errs...)
+}
+
+// 用于 config check 输出的配置副本，密码和密钥已隐藏
+func (c *Config) Redacted() Config {
+	redacted := *c
+	if redacted.Secret != "" {
+		redacted.Secret = "******"
+	}
+	if redacted.DB.Password != "" {
+		redacted.DB.Password = "******"
+	}
+	if redacted.Reports.SMTP.Password != "" {
+		redacted.Reports.SMTP.Password = "******"
+	}
+	return redacted
+}
+
+func (c HTTPConfig) Addr() string {
+	return fmt.Sprintf("%s:%d", c.Host, c.Port)
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	yaml := "http:\n  port: 9090\ndb:\n  driver: postgres\n  host: db.internal\n  user: vmi\n  name: vmi\n  retryBackoff: 2s\n"
+	if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("DB_HOST", "db.override")
+	t.Setenv("AUTO_MIGRATE", "false")
+	t.Setenv("DEFECT_REASONS", "划伤, 异响,,")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.HTTP.Port != 9090 || cfg.DB.Driver != "postgres" || cfg.DB.RetryBackoff != 2*time.Second {
+		t.Errorf("YAML values not applied: %+v", cfg)
+	}
+	if cfg.DB.Host != "db.override" || cfg.DB.AutoMigrate {
+		t.Errorf("environment must override YAML: host=%s autoMigrate=%v", cfg.DB.Host, cfg.DB.AutoMigrate)
+	}
+	if strings.Join(cfg.Quality.DefectReasons, "|") != "划伤|异响" {
+		t.Errorf("DEFECT_REASONS = %q", cfg.Quality.DefectReasons)
+	}
+	if cfg.Timezone.Plant != "Asia/Shanghai" || cfg.DB.ConnectRetries != 10 {
+		t.Errorf("defaults not kept: %+v", cfg)
+	}
+}
+
+func TestLoadRejectsMalformedEnv(t *testing.T) {
+	t.Setenv("CONFIG_FILE", os.DevNull)
+	t.Setenv("HTTP_PORT", "eighty")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "HTTP_PORT") {
+		t.Fatalf("expected HTTP_PORT error, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.Production = true
+	cfg.DB.Driver = "oracle"
+	cfg.Timezone.Plant = "Mars/Olympus"
+	cfg.Quality.BatchHoldPolicy = "ignore"
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{"DB_DRIVER", "DB_NAME", "PLANT_TIMEZONE", "BATCH_HOLD_POLICY", "SECRET"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %s in %v", want, err)
+		}
+	}
+
+	cfg = Default()
+	cfg.DB.Driver = "sqlite"
+	cfg.DB.Name = ":memory:"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("sqlite config should be valid: %v", err)
+	}
+	cfg.Timezone.DB = "Asia/Shanghai"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("sqlite requires DB_TIMEZONE=UTC")
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Default()
+	cfg.Secret = "s3cret"
+	cfg.DB.Password = "p4ss"
+	redacted := cfg.Redacted()
+	if redacted.Secret == cfg.Secret || redacted.DB.Password == cfg.DB.Password {
+		t.Fatal("secrets must be hidden")
+	}
+	if cfg.Secret != "s3cret" {
+		t.Fatal("Redacted must not modify the original")
+	}
+}
@@ -5,6 +5,7 @@ type IProductionController interface {
 	DeleteProductLine()
 	AddPallet()
 	AddProduct()
+	GetSampling()
 	RegisterProductLine()
 	AuthenticateProductLine()
 }
@@ -39,6 +40,14 @@ type IManagementController interface {
 	GetPallets()
 	GetPallet()
 
+	AddShift()
+	DeleteShift()
+	GetShifts()
+	UpdateShift()
+	AddHoliday()
+	DeleteHoliday()
+	GetHolidays()
+
 	GetProducts()
 	GetProduct()
 
@@ -55,10 +64,49 @@ type IManagementController interface {
 	UpdateUser()
 
 	GetQualityStats()
+	GetQualityAggregate()
+	RebuildQualityRollup()
+	CheckQualityRollup()
 
 	GetDefectReport()
 	GetInspectionReport()
 	GetCostReport()
+	ExportDefectReport()
+	ExportInspectionReport()
+	ExportCostReport()
+	ExportProducts()
+	ExportDefects()
+	GetJobs()
+	GetJob()
+	CancelJob()
+	DeleteJob()
+	DownloadJobArtifact()
+	AddReportSchedule()
+	GetReportSchedules()
+	GetReportSchedule()
+	UpdateReportSchedule()
+	DeleteReportSchedule()
+	RunReportSchedule()
+	GetReportRuns()
+	Search()
+	ReindexSearch()
+	TraceProduct()
+	TraceBatch()
+	TracePallet()
+	AddBatch()
+	GetBatches()
+	GetBatch()
+	HoldBatch()
+	ReleaseBatch()
+	DisposeBatch()
+	GetBatchDispositions()
+	GetBatchSampling()
+	AddSamplingPlan()
+	GetSamplingPlans()
+	GetSamplingPlan()
+	UpdateSamplingPlan()
+	DeleteSamplingPlan()
+	ResetSamplingPlan()
 
 	Login()
 }
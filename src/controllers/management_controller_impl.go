@@ -1,10 +1,14 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
 	"time"
 
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/search"
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/services"
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
 	"github.com/dreamskynl/godi"
@@ -25,22 +29,42 @@ type ManagementController struct {
 	jwtService            services.IJwtService
 	qualityStatsService   services.IQualityStatsService
 	dataReportService     services.IDataReportService
+	shiftCalendarService  services.IShiftCalendarService
+	qualityRollupService  services.IQualityRollupService
+	reportExportService   services.IReportExportService
+	exportService         services.IExportService
+	jobService            services.IJobService
+	reportScheduleService services.IReportScheduleService
+	searchService         services.ISearchService
+	traceService          services.ITraceService
+	batchService          services.IBatchService
+	samplingService       services.ISamplingService
 }
 
 func NewManagementController(ctx *gin.Context, sc godi.IGoDI) IManagementController {
 	return &ManagementController{
 		ctx:                   ctx,
-		supplierService:       sc.MustResolve(&services.SupplierService{}).(*services.SupplierService),
-		productModelService:   sc.MustResolve(&services.ProductModelService{}).(*services.ProductModelService),
-		productionPlanService: sc.MustResolve(&services.ProductionPlanService{}).(*services.ProductionPlanService),
-		productLineService:    sc.MustResolve(&services.ProductLineService{}).(*services.ProductLineService),
-		palletService:         sc.MustResolve(&services.PalletService{}).(*services.PalletService),
-		productService:        sc.MustResolve(&services.ProductService{}).(*services.ProductService),
-		apiService:            sc.MustResolve(&services.APIService{}).(*services.APIService),
-		userService:           sc.MustResolve(&services.UserService{}).(*services.UserService),
+		supplierService:       sc.MustResolve(&services.SupplierService{}).(*services.SupplierService).WithContext(ctx.Request.Context()),
+		productModelService:   sc.MustResolve(&services.ProductModelService{}).(*services.ProductModelService).WithContext(ctx.Request.Context()),
+		productionPlanService: sc.MustResolve(&services.ProductionPlanService{}).(*services.ProductionPlanService).WithContext(ctx.Request.Context()),
+		productLineService:    sc.MustResolve(&services.ProductLineService{}).(*services.ProductLineService).WithContext(ctx.Request.Context()),
+		palletService:         sc.MustResolve(&services.PalletService{}).(*services.PalletService).WithContext(ctx.Request.Context()),
+		productService:        sc.MustResolve(&services.ProductService{}).(*services.ProductService).WithContext(ctx.Request.Context()),
+		apiService:            sc.MustResolve(&services.APIService{}).(*services.APIService).WithContext(ctx.Request.Context()),
+		userService:           sc.MustResolve(&services.UserService{}).(*services.UserService).WithContext(ctx.Request.Context()),
 		jwtService:            sc.MustResolve(&services.JwtService{}).(*services.JwtService),
-		qualityStatsService:   sc.MustResolve(&services.QualityStatsService{}).(*services.QualityStatsService),
-		dataReportService:     sc.MustResolve(&services.DataReportService{}).(*services.DataReportService),
+		qualityStatsService:   sc.MustResolve(&services.QualityStatsService{}).(*services.QualityStatsService).WithContext(ctx.Request.Context()),
+		dataReportService:     sc.MustResolve(&services.DataReportService{}).(*services.DataReportService).WithContext(ctx.Request.Context()),
+		shiftCalendarService:  sc.MustResolve(&services.ShiftCalendarService{}).(*services.ShiftCalendarService).WithContext(ctx.Request.Context()),
+		qualityRollupService:  sc.MustResolve(&services.QualityRollupService{}).(*services.QualityRollupService).WithContext(ctx.Request.Context()),
+		reportExportService:   sc.MustResolve(&services.ReportExportService{}).(*services.ReportExportService).WithContext(ctx.Request.Context()),
+		exportService:         sc.MustResolve(&services.ExportService{}).(*services.ExportService).WithContext(ctx.Request.Context()),
+		jobService:            sc.MustResolve(&services.JobService{}).(*services.JobService).WithContext(ctx.Request.Context()),
+		reportScheduleService: sc.MustResolve(&services.ReportScheduleService{}).(*services.ReportScheduleService).WithContext(ctx.Request.Context()),
+		searchService:         sc.MustResolve(&services.SearchService{}).(*services.SearchService).WithContext(ctx.Request.Context()),
+		traceService:          sc.MustResolve(&services.TraceService{}).(*services.TraceService).WithContext(ctx.Request.Context()),
+		batchService:          sc.MustResolve(&services.BatchService{}).(*services.BatchService).WithContext(ctx.Request.Context()),
+		samplingService:       sc.MustResolve(&services.SamplingService{}).(*services.SamplingService).WithContext(ctx.Request.Context()),
 	}
 }
 
@@ -86,7 +110,7 @@ func (mc *ManagementController) GetSuppliers() {
 	paginateParamsMap := utils.StructToMap(paginateParams)
 	suppliers, pageResult, err := mc.supplierService.GetSuppliers(queryParamsMap, paginateParamsMap)
 	if err != nil {
-		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		mc.listError(err)
 		return
 	}
 	mc.ctx.JSON(200, gin.H{"data": suppliers, "pagination": pageResult, "message": "success"})
@@ -168,7 +192,7 @@ func (mc *ManagementController) GetProductModels() {
 	paginateParamsMap := utils.StructToMap(paginateParams)
 	productModels, pageResult, err := mc.productModelService.GetProductModels(queryParamsMap, paginateParamsMap)
 	if err != nil {
-		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		mc.listError(err)
 		return
 	}
 	mc.ctx.JSON(200, gin.H{"data": productModels, "pagination": pageResult, "message": "success"})
@@ -250,7 +274,7 @@ func (mc *ManagementController) GetProductionPlans() {
 	paginateParamsMap := utils.StructToMap(paginateParams)
 	productionPlans, pageResult, err := mc.productionPlanService.GetProductionPlans(queryParamsMap, paginateParamsMap)
 	if err != nil {
-		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		mc.listError(err)
 		return
 	}
 	mc.ctx.JSON(200, gin.H{"data": productionPlans, "pagination": pageResult, "message": "success"})
@@ -332,7 +356,7 @@ func (mc *ManagementController) GetProductLines() {
 	paginateParamsMap := utils.StructToMap(paginateParams)
 	productLines, pageResult, err := mc.productLineService.GetProductLines(queryParamsMap, paginateParamsMap)
 	if err != nil {
-		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		mc.listError(err)
 		return
 	}
 	mc.ctx.JSON(200, gin.H{"data": productLines, "pagination": pageResult, "message": "success"})
@@ -394,7 +418,7 @@ func (mc *ManagementController) GetPallets() {
 	paginateParamsMap := utils.StructToMap(paginateParams)
 	pallets, pageResult, err := mc.palletService.GetPallets(queryParamsMap, paginateParamsMap)
 	if err != nil {
-		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		mc.listError(err)
 		return
 	}
 	mc.ctx.JSON(200, gin.H{"data": pallets, "pagination": pageResult, "message": "success"})
@@ -414,11 +438,149 @@ func (mc *ManagementController) GetPallet() {
 	mc.ctx.JSON(200, gin.H{"data": pallet, "message": "success"})
 }
 
+func (mc *ManagementController) AddShift() {
+	var form models.Shift
+	if err := mc.ctx.ShouldBindJSON(&form); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := form.Validate(); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := mc.shiftCalendarService.CreateShift(&form); err != nil {
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	mc.ctx.JSON(201, gin.H{"data": form, "message": "success"})
+}
+
+func (mc *ManagementController) DeleteShift() {
+	var form IDsField
+	if err := mc.ctx.ShouldBindJSON(&form); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := mc.shiftCalendarService.DeleteShifts(form.IDs); err != nil {
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"message": "success"})
+}
+
+func (mc *ManagementController) GetShifts() {
+	var queryParams struct {
+		ProductLineID uint `form:"productLineId"` // 产线ID
+	}
+	if err := mc.ctx.ShouldBindQuery(&queryParams); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	queryParamsMap := make(map[string]interface{})
+	if queryParams.ProductLineID > 0 {
+		queryParamsMap["product_line_id"] = queryParams.ProductLineID
+	}
+	shifts, err := mc.shiftCalendarService.GetShifts(queryParamsMap)
+	if err != nil {
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"data": shifts, "message": "success"})
+}
+
+func (mc *ManagementController) UpdateShift() {
+	var form models.Shift
+	if err := mc.ctx.ShouldBindJSON(&form); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	shift, err := mc.shiftCalendarService.GetShift(int64(form.ID))
+	if err != nil || shift.ID == 0 {
+		mc.ctx.JSON(404, gin.H{"error": "shift not found"})
+		return
+	}
+
+	// 以合并后的班次进行校验
+	merged := *shift
+	if form.Name != "" {
+		merged.Name = form.Name
+	}
+	if form.StartTime != "" {
+		merged.StartTime = form.StartTime
+	}
+	if form.EndTime != "" {
+		merged.EndTime = form.EndTime
+	}
+	if err := merged.Validate(); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	shiftMap := utils.StructToMap(form)
+	if err := mc.shiftCalendarService.UpdateShift(shift, shiftMap); err != nil {
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"data": form, "message": "success"})
+}
+
+func (mc *ManagementController) AddHoliday() {
+	var form struct {
+		ProductLineID *uint  `json:"productLineId"`
+		Date          string `json:"date" binding:"required"` // YYYY-MM-DD
+		Name          string `json:"name"`
+	}
+	if err := mc.ctx.ShouldBindJSON(&form); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	// 假日为工厂时区的日期
+	date, err := utils.ParseDate(form.Date, utils.PlantLocation())
+	if err != nil {
+		mc.ctx.JSON(400, gin.H{"error": "invalid date format, expected YYYY-MM-DD"})
+		return
+	}
+
+	holiday := models.Holiday{
+		ProductLineID: form.ProductLineID,
+		Date:          utils.DateColumn(date),
+		Name:          form.Name,
+	}
+	if err := mc.shiftCalendarService.CreateHoliday(&holiday); err != nil {
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	mc.ctx.JSON(201, gin.H{"data": holiday, "message": "success"})
+}
+
+func (mc *ManagementController) DeleteHoliday() {
+	var form IDsField
+	if err := mc.ctx.ShouldBindJSON(&form); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := mc.shiftCalendarService.DeleteHolidays(form.IDs); err != nil {
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"message": "success"})
+}
+
+func (mc *ManagementController) GetHolidays() {
+	holidays, err := mc.shiftCalendarService.GetHolidays(map[string]interface{}{})
+	if err != nil {
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"data": holidays, "message": "success"})
+}
+
 func (mc *ManagementController) GetProducts() {
 	var queryParams struct {
 		StartTime     string `form:"startTime"`     // 开始时间 YYYY-MM-DD HH:MM:SS
 		EndTime       string `form:"endTime"`       // 结束时间 YYYY-MM-DD HH:MM:SS
-		Search        string `form:"search"`        // 综合模糊查询（托盘SN、产品SN、产品型号SAP）
+		Search        string `form:"search"`        // 综合模糊查询（托盘SN、产品SN、物料编码）
 		Description   string `form:"description"`   // 产品型号描述模糊查询
 		ProductLineID uint   `form:"productLineId"` // 产线ID
 		PalletID      uint   `form:"palletId"`      // 托盘ID
@@ -439,39 +601,44 @@ func (mc *ManagementController) GetProducts() {
 	queryParamsMap := make(map[string]interface{})
 
 	// 时间范围查询
+	// 时间按工厂时区解析，避免以字符串直接比较数据库存储时区下的时间
 	var sqlHandlers []func(*gorm.DB) *gorm.DB
 	if queryParams.StartTime != "" {
+		startTime, err := time.ParseInLocation("2006-01-02 15:04:05", queryParams.StartTime, utils.PlantLocation())
+		if err != nil {
+			mc.ctx.JSON(400, gin.H{"error": "Invalid startTime format, expected YYYY-MM-DD HH:MM:SS"})
+			return
+		}
 		sqlHandlers = append(sqlHandlers, func(db *gorm.DB) *gorm.DB {
-			return db.Where("products.created_at >= ?", queryParams.StartTime)
+			return db.Where("products.created_at >= ?", utils.InDB(startTime))
 		})
 	}
 	if queryParams.EndTime != "" {
+		endTime, err := time.ParseInLocation("2006-01-02 15:04:05", queryParams.EndTime, utils.PlantLocation())
+		if err != nil {
+			mc.ctx.JSON(400, gin.H{"error": "Invalid endTime format, expected YYYY-MM-DD HH:MM:SS"})
+			return
+		}
 		sqlHandlers = append(sqlHandlers, func(db *gorm.DB) *gorm.DB {
-			return db.Where("products.created_at <= ?", queryParams.EndTime)
-		})
-	}
-
-	// 综合模糊查询（托盘SN、产品SN、产品型号SAP）
-	needsProductModelJoin := queryParams.Search != "" || queryParams.Description != ""
-	needsPalletJoin := queryParams.Search != ""
-
-	if needsProductModelJoin || needsPalletJoin {
-		sqlHandlers = append(sqlHandlers, func(db *gorm.DB) *gorm.DB {
-			if needsProductModelJoin {
-				db = db.Joins("LEFT JOIN product_models ON products.product_model_id = product_models.id")
-			}
-			if needsPalletJoin {
-				db = db.Joins("LEFT JOIN pallets ON products.pallet_id = pallets.id")
-			}
-			return db
+			return db.Where("products.created_at <= ?", utils.InDB(endTime))
 		})
 	}
 
+	// 综合查询（托盘SN、产品SN、物料编码）：先从搜索索引取各表的候选记录，再按原文本确认命中的是 SN
 	if queryParams.Search != "" {
 		sqlHandlers = append(sqlHandlers, func(db *gorm.DB) *gorm.DB {
+			index := db.Session(&gorm.Session{NewDB: true})
 			searchPattern := "%" + queryParams.Search + "%"
-			return db.Where("products.sn LIKE ? OR pallets.sn LIKE ? OR product_models.sap LIKE ?",
-				searchPattern, searchPattern, searchPattern)
+			return db.Where(
+				"(products.id IN (?) AND products.sn LIKE ?)"+
+					" OR products.pallet_id IN (?)"+
+					" OR products.product_model_id IN (?)",
+				search.CandidateQuery(index, models.SearchTypeProduct, queryParams.Search), searchPattern,
+				index.Model(&models.Pallet{}).Select("id").
+					Where("id IN (?) AND sn LIKE ?", search.CandidateQuery(index, models.SearchTypePallet, queryParams.Search), searchPattern),
+				index.Model(&models.ProductModel{}).Select("id").
+					Where("id IN (?) AND sn LIKE ?", search.CandidateQuery(index, models.SearchTypeModel, queryParams.Search), searchPattern),
+			)
 		})
 	}
 
@@ -479,7 +646,8 @@ func (mc *ManagementController) GetProducts() {
 	if queryParams.Description != "" {
 		sqlHandlers = append(sqlHandlers, func(db *gorm.DB) *gorm.DB {
 			descPattern := "%" + queryParams.Description + "%"
-			return db.Where("product_models.description LIKE ?", descPattern)
+			return db.Joins("LEFT JOIN product_models ON products.product_model_id = product_models.id").
+				Where("product_models.description LIKE ?", descPattern)
 		})
 	}
 
@@ -509,7 +677,7 @@ func (mc *ManagementController) GetProducts() {
 	paginateParamsMap := utils.StructToMap(paginateParams)
 	products, pageResult, err := mc.productService.GetProducts(queryParamsMap, paginateParamsMap, sqlHandlers...)
 	if err != nil {
-		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		mc.listError(err)
 		return
 	}
 	mc.ctx.JSON(200, gin.H{"data": products, "pagination": pageResult, "message": "success"})
@@ -571,7 +739,7 @@ func (mc *ManagementController) GetApis() {
 	paginateParamsMap := utils.StructToMap(paginateParams)
 	apis, pageResult, err := mc.apiService.GetAPIs(queryParamsMap, paginateParamsMap)
 	if err != nil {
-		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		mc.listError(err)
 		return
 	}
 	mc.ctx.JSON(200, gin.H{"data": apis, "pagination": pageResult, "message": "success"})
@@ -653,7 +821,7 @@ func (mc *ManagementController) GetUsers() {
 	paginateParamsMap := utils.StructToMap(paginateParams)
 	users, pageResult, err := mc.userService.GetUsers(queryParamsMap, paginateParamsMap)
 	if err != nil {
-		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		mc.listError(err)
 		return
 	}
 	mc.ctx.JSON(200, gin.H{"data": users, "pagination": pageResult, "message": "success"})
@@ -736,24 +904,32 @@ func (mc *ManagementController) GetQualityStats() {
 		return
 	}
 
-	// 解析日期
-	startDate, err := time.Parse("2006-01-02", query.StartDate)
+	// 按请求时区（默认工厂时区）解析日期，结束日期取次日零点
+	location, err := utils.ResolveLocation(query.TZ)
 	if err != nil {
-		mc.ctx.JSON(400, gin.H{"error": "Invalid start date format, expected YYYY-MM-DD"})
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
-
-	endDate, err := time.Parse("2006-01-02", query.EndDate)
+	startDate, endDate, err := utils.DateRange(query.StartDate, query.EndDate, location)
 	if err != nil {
-		mc.ctx.JSON(400, gin.H{"error": "Invalid end date format, expected YYYY-MM-DD"})
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 调整结束日期到当天的23:59:59
-	endDate = endDate.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+	options, err := query.ToOptions()
+	if err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if options.Comparison.Compare != models.CompareNone {
+		if _, _, err := options.Comparison.BaselineRange(startDate, endDate); err != nil {
+			mc.ctx.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
 	// 获取统计数据
-	stats, err := mc.qualityStatsService.GetQualityStats(startDate, endDate)
+	stats, err := mc.qualityStatsService.GetQualityStats(startDate, endDate, options)
 	if err != nil {
 		mc.ctx.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -765,25 +941,124 @@ func (mc *ManagementController) GetQualityStats() {
 	})
 }
 
+func (mc *ManagementController) GetQualityAggregate() {
+	var query models.QualityAggregateQuery
+	if err := mc.ctx.ShouldBindQuery(&query); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 按请求时区（默认工厂时区）解析日期，结束日期取次日零点
+	location, err := utils.ResolveLocation(query.TZ)
+	if err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	startDate, endDate, err := utils.DateRange(query.StartDate, query.EndDate, location)
+	if err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验维度、指标及粒度
+	spec := query.ToSpec()
+	if err := spec.Validate(); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := mc.qualityStatsService.Aggregate(startDate, endDate, spec)
+	if err != nil {
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	mc.ctx.JSON(200, gin.H{
+		"data":    result,
+		"message": "success",
+	})
+}
+
+func (mc *ManagementController) RebuildQualityRollup() {
+	var form models.RollupRangeQuery
+	if err := mc.ctx.ShouldBindJSON(&form); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	startDate, endDate, err := utils.DateRange(form.StartDate, form.EndDate, utils.PlantLocation())
+	if err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if form.Async {
+		job, err := mc.jobService.Enqueue(models.JobKindRollupRebuild, form, 0)
+		if err != nil {
+			mc.ctx.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		mc.ctx.JSON(202, gin.H{"data": job, "message": "rebuild queued"})
+		return
+	}
+
+	if err := mc.qualityRollupService.Rebuild(startDate, endDate); err != nil {
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"message": "success"})
+}
+
+func (mc *ManagementController) CheckQualityRollup() {
+	var query models.RollupRangeQuery
+	if err := mc.ctx.ShouldBindQuery(&query); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	startDate, endDate, err := utils.DateRange(query.StartDate, query.EndDate, utils.PlantLocation())
+	if err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := mc.qualityRollupService.Check(startDate, endDate)
+	if err != nil {
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"data": result, "message": "success"})
+}
+
 func (mc *ManagementController) GetDefectReport() {
 	var query models.DefectReportQuery
 	if err := mc.ctx.ShouldBindQuery(&query); err != nil {
 		mc.ctx.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
+	if _, err := utils.ResolveLocation(query.TZ); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := mc.validateComparison(query.ComparisonQuery, query.StartDate, query.EndDate, query.TZ); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 
 	// 获取不合格报表数据
 	report, err := mc.dataReportService.GetDefectReport(&query)
 	if err != nil {
-		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		mc.listError(err)
 		return
 	}
 
-	mc.ctx.JSON(200, gin.H{
+	response := gin.H{
 		"data":       report.Items,
 		"pagination": report.Pagination,
 		"message":    "success",
-	})
+	}
+	if report.Comparison != nil {
+		response["comparison"] = report.Comparison
+	}
+	mc.ctx.JSON(200, response)
 }
 
 func (mc *ManagementController) GetInspectionReport() {
@@ -792,6 +1067,14 @@ func (mc *ManagementController) GetInspectionReport() {
 		mc.ctx.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
+	if _, err := utils.ResolveLocation(query.TZ); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := mc.validateComparison(query.ComparisonQuery, query.StartDate, query.EndDate, query.TZ); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 
 	// 获取检测报表数据
 	report, err := mc.dataReportService.GetInspectionReport(&query)
@@ -800,11 +1083,15 @@ func (mc *ManagementController) GetInspectionReport() {
 		return
 	}
 
-	mc.ctx.JSON(200, gin.H{
+	response := gin.H{
 		"data":       report.Items,
 		"pagination": report.Pagination,
 		"message":    "success",
-	})
+	}
+	if report.Comparison != nil {
+		response["comparison"] = report.Comparison
+	}
+	mc.ctx.JSON(200, response)
 }
 
 func (mc *ManagementController) GetCostReport() {
@@ -813,6 +1100,14 @@ func (mc *ManagementController) GetCostReport() {
 		mc.ctx.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
+	if _, err := utils.ResolveLocation(query.TZ); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := mc.validateComparison(query.ComparisonQuery, query.StartDate, query.EndDate, query.TZ); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 
 	// 获取检测费用报表数据
 	report, err := mc.dataReportService.GetCostReport(&query)
@@ -821,11 +1116,833 @@ func (mc *ManagementController) GetCostReport() {
 		return
 	}
 
-	mc.ctx.JSON(200, gin.H{
+	response := gin.H{
 		"data":       report.Items,
 		"pagination": report.Pagination,
 		"message":    "success",
-	})
+	}
+	if report.Comparison != nil {
+		response["comparison"] = report.Comparison
+	}
+	mc.ctx.JSON(200, response)
+}
+
+func (mc *ManagementController) ExportDefectReport() {
+	var query models.DefectReportQuery
+	var format models.ExportFormatQuery
+	if err := mc.bindExportQuery(&query, &format); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := utils.ResolveLocation(query.TZ); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	table, err := mc.reportExportService.DefectReportTable(&query)
+	if err != nil {
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	mc.writeReport(table, format.Format, query.StartDate, query.EndDate)
+}
+
+func (mc *ManagementController) ExportInspectionReport() {
+	var query models.InspectionReportQuery
+	var format models.ExportFormatQuery
+	if err := mc.bindExportQuery(&query, &format); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := utils.ResolveLocation(query.TZ); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	table, err := mc.reportExportService.InspectionReportTable(&query)
+	if err != nil {
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	mc.writeReport(table, format.Format, query.StartDate, query.EndDate)
+}
+
+func (mc *ManagementController) ExportCostReport() {
+	var query models.CostReportQuery
+	var format models.ExportFormatQuery
+	if err := mc.bindExportQuery(&query, &format); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := utils.ResolveLocation(query.TZ); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	table, err := mc.reportExportService.CostReportTable(&query)
+	if err != nil {
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	mc.writeReport(table, format.Format, query.StartDate, query.EndDate)
+}
+
+// 绑定报表筛选条件和导出格式
+func (mc *ManagementController) bindExportQuery(query interface{}, format *models.ExportFormatQuery) error {
+	if err := mc.ctx.ShouldBindQuery(query); err != nil {
+		return err
+	}
+	if err := mc.ctx.ShouldBindQuery(format); err != nil {
+		return err
+	}
+	return format.Validate()
+}
+
+// 以附件形式写出报表，文件名按 RFC 5987 编码以支持中文
+func (mc *ManagementController) writeReport(table *models.ReportTable, format, startDate, endDate string) {
+	contentType := "text/csv; charset=utf-8"
+	if format == models.ExportFormatXLSX {
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	fileName := table.FileName(startDate, endDate, format)
+	mc.ctx.Header("Content-Type", contentType)
+	mc.ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"report.%s\"; filename*=UTF-8''%s", format, url.PathEscape(fileName)))
+	mc.ctx.Status(200)
+	if err := mc.reportExportService.Write(mc.ctx.Writer, table, format); err != nil {
+		// 响应头已发送，只能中断连接
+		mc.ctx.Error(err)
+		mc.ctx.Abort()
+	}
+}
+
+func (mc *ManagementController) ExportProducts() {
+	mc.exportRecords(models.ExportKindProducts)
+}
+
+func (mc *ManagementController) ExportDefects() {
+	mc.exportRecords(models.ExportKindDefects)
+}
+
+// 记录数不超过同步上限时直接流式返回，否则（或 async=true）转为后台导出并返回 202
+func (mc *ManagementController) exportRecords(kind string) {
+	var query models.RecordExportQuery
+	if err := mc.ctx.ShouldBindQuery(&query); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := query.Validate(); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	location, err := utils.ResolveLocation(query.TZ)
+	if err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	for _, date := range []string{query.StartDate, query.EndDate} {
+		if date == "" {
+			continue
+		}
+		if _, err := utils.ParseDate(date, location); err != nil {
+			mc.ctx.JSON(400, gin.H{"error": fmt.Sprintf("invalid date %q, expected YYYY-MM-DD", date)})
+			return
+		}
+	}
+
+	total, async, err := mc.exportService.CountRecords(kind, &query)
+	if err != nil {
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if async {
+		job, err := mc.exportService.StartExport(kind, &query, total)
+		if err != nil {
+			mc.ctx.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		mc.ctx.JSON(202, gin.H{"data": job, "message": "export queued"})
+		return
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if query.Format == models.ExportFormatNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	fileName := fmt.Sprintf("%s_%s.%s", kind, time.Now().In(location).Format("20060102150405"), query.Format)
+	mc.ctx.Header("Content-Type", contentType)
+	mc.ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", fileName))
+	mc.ctx.Status(200)
+	// 客户端断开后请求 ctx 取消，停止读取数据库
+	if _, err := mc.exportService.StreamRecords(mc.ctx.Request.Context(), kind, &query, mc.ctx.Writer); err != nil {
+		mc.ctx.Error(err)
+		mc.ctx.Abort()
+	}
+}
+
+func (mc *ManagementController) GetJobs() {
+	var query models.JobQuery
+	var paginateParams models.PaginationQuery
+	if err := mc.ctx.ShouldBindQuery(&query); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := query.Validate(); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := mc.ctx.ShouldBindQuery(&paginateParams); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	jobs, pageResult, err := mc.jobService.GetJobs(&query, utils.StructToMap(paginateParams))
+	if err != nil {
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"data": jobs, "pagination": pageResult, "message": "success"})
+}
+
+func (mc *ManagementController) GetJob() {
+	var uriParams IDField
+	if err := mc.ctx.ShouldBindUri(&uriParams); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	job, err := mc.jobService.GetJob(uriParams.ID)
+	if err != nil {
+		mc.jobError(err)
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"data": job, "message": "success"})
+}
+
+func (mc *ManagementController) CancelJob() {
+	var uriParams IDField
+	if err := mc.ctx.ShouldBindUri(&uriParams); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	job, err := mc.jobService.CancelJob(uriParams.ID)
+	if err != nil {
+		mc.jobError(err)
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"data": job, "message": "success"})
+}
+
+func (mc *ManagementController) DeleteJob() {
+	var uriParams IDField
+	if err := mc.ctx.ShouldBindUri(&uriParams); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := mc.jobService.DeleteJob(uriParams.ID); err != nil {
+		mc.jobError(err)
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"message": "success"})
+}
+
+func (mc *ManagementController) DownloadJobArtifact() {
+	var uriParams IDField
+	if err := mc.ctx.ShouldBindUri(&uriParams); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	path, fileName, err := mc.jobService.JobArtifact(uriParams.ID)
+	if err != nil {
+		mc.jobError(err)
+		return
+	}
+	mc.ctx.FileAttachment(path, fileName)
+}
+
+func (mc *ManagementController) jobError(err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		mc.ctx.JSON(404, gin.H{"error": "job not found"})
+	case errors.Is(err, services.ErrJobFinished), errors.Is(err, services.ErrJobNotFinished), errors.Is(err, services.ErrJobNoArtifact):
+		mc.ctx.JSON(409, gin.H{"error": err.Error()})
+	default:
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+	}
+}
+
+func (mc *ManagementController) reportScheduleError(err error) {
+	switch {
+	case errors.Is(err, services.ErrReportFontRequired):
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+	default:
+		mc.notFoundOr500(err, "report schedule not found")
+	}
+}
+
+func (mc *ManagementController) AddReportSchedule() {
+	var form models.ReportSchedule
+	if err := mc.ctx.ShouldBindJSON(&form); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := form.Validate(); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := mc.reportScheduleService.CreateSchedule(&form); err != nil {
+		mc.reportScheduleError(err)
+		return
+	}
+	mc.ctx.JSON(201, gin.H{"data": form, "message": "success"})
+}
+
+func (mc *ManagementController) GetReportSchedules() {
+	var paginateParams models.PaginationQuery
+	if err := mc.ctx.ShouldBindQuery(&paginateParams); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	schedules, pageResult, err := mc.reportScheduleService.GetSchedules(utils.StructToMap(paginateParams))
+	if err != nil {
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"data": schedules, "pagination": pageResult, "message": "success"})
+}
+
+func (mc *ManagementController) GetReportSchedule() {
+	var uriParams IDField
+	if err := mc.ctx.ShouldBindUri(&uriParams); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	schedule, err := mc.reportScheduleService.GetSchedule(uriParams.ID)
+	if err != nil {
+		mc.notFoundOr500(err, "report schedule not found")
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"data": schedule, "message": "success"})
+}
+
+func (mc *ManagementController) UpdateReportSchedule() {
+	var form models.ReportSchedule
+	if err := mc.ctx.ShouldBindJSON(&form); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	schedule, err := mc.reportScheduleService.GetSchedule(form.ID)
+	if err != nil || schedule.ID == 0 {
+		mc.ctx.JSON(404, gin.H{"error": "report schedule not found"})
+		return
+	}
+
+	// 以合并后的计划进行校验
+	merged := *schedule
+	for _, field := range []struct{ value, target *string }{
+		{&form.Name, &merged.Name}, {&form.Report, &merged.Report}, {&form.Cron, &merged.Cron}, {&form.Formats, &merged.Formats},
+		{&form.Channel, &merged.Channel}, {&form.Target, &merged.Target}, {&form.SupplierName, &merged.SupplierName},
+	} {
+		if *field.value != "" {
+			*field.target = *field.value
+		}
+	}
+	if form.Enabled != nil {
+		merged.Enabled = form.Enabled
+	}
+	if err := merged.Validate(); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := mc.reportScheduleService.UpdateSchedule(schedule, &merged, utils.StructToMap(form)); err != nil {
+		mc.reportScheduleError(err)
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"data": schedule, "message": "success"})
+}
+
+func (mc *ManagementController) DeleteReportSchedule() {
+	var form IDsField
+	if err := mc.ctx.ShouldBindJSON(&form); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := mc.reportScheduleService.DeleteSchedules(form.IDs); err != nil {
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"message": "success"})
+}
+
+// 立即生成并投递一次，返回 202 和后台任务
+func (mc *ManagementController) RunReportSchedule() {
+	var uriParams IDField
+	if err := mc.ctx.ShouldBindUri(&uriParams); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	job, err := mc.reportScheduleService.RunSchedule(uriParams.ID)
+	if err != nil {
+		mc.reportScheduleError(err)
+		return
+	}
+	mc.ctx.JSON(202, gin.H{"data": job, "message": "report queued"})
+}
+
+func (mc *ManagementController) GetReportRuns() {
+	var uriParams IDField
+	var paginateParams models.PaginationQuery
+	if err := mc.ctx.ShouldBindUri(&uriParams); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := mc.ctx.ShouldBindQuery(&paginateParams); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	runs, pageResult, err := mc.reportScheduleService.GetRuns(uriParams.ID, utils.StructToMap(paginateParams))
+	if err != nil {
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"data": runs, "pagination": pageResult, "message": "success"})
+}
+
+// 按关键字搜索产品、托盘、物料、供应商和生产计划
+func (mc *ManagementController) Search() {
+	var query models.SearchQuery
+	if err := mc.ctx.ShouldBindQuery(&query); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := query.Validate(); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	hits, err := mc.searchService.Search(&query)
+	if err != nil {
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"data": hits, "message": "success"})
+}
+
+// 在后台重建搜索索引
+func (mc *ManagementController) ReindexSearch() {
+	job, err := mc.jobService.Enqueue(models.JobKindSearchReindex, struct{}{}, 0)
+	if err != nil {
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	mc.ctx.JSON(202, gin.H{"data": job, "message": "success"})
+}
+
+// 序列号的追溯图：物料、供应商、批次、产线、托盘、同托盘产品、生产计划、全部检测记录和事件
+func (mc *ManagementController) TraceProduct() {
+	var uriParams struct {
+		SN string `uri:"sn" binding:"required"`
+	}
+	if err := mc.ctx.ShouldBindUri(&uriParams); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	result, err := mc.traceService.TraceProduct(uriParams.SN)
+	if err != nil {
+		mc.notFoundOr500(err, "product not found")
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"data": result, "message": "success"})
+}
+
+// 批次内的全部产品，可按物料 ID 限定
+func (mc *ManagementController) TraceBatch() {
+	var uriParams struct {
+		BatchNumber string `uri:"batchNumber" binding:"required"`
+	}
+	if err := mc.ctx.ShouldBindUri(&uriParams); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	var query struct {
+		ProductModelID *uint `form:"productModelId"`
+	}
+	if err := mc.ctx.ShouldBindQuery(&query); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	result, err := mc.traceService.TraceBatch(uriParams.BatchNumber, query.ProductModelID)
+	if err != nil {
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"data": result, "message": "success"})
+}
+
+// 托盘上的全部产品
+func (mc *ManagementController) TracePallet() {
+	var uriParams IDField
+	if err := mc.ctx.ShouldBindUri(&uriParams); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := mc.palletService.GetPallet(uriParams.ID); err != nil {
+		mc.notFoundOr500(err, "pallet not found")
+		return
+	}
+	result, err := mc.traceService.TracePallet(uriParams.ID)
+	if err != nil {
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"data": result, "message": "success"})
+}
+
+// 手动登记批次，status 为 on_hold 时登记后立即冻结（用于来料前预先冻结）
+func (mc *ManagementController) AddBatch() {
+	var form models.Batch
+	if err := mc.ctx.ShouldBindJSON(&form); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if form.Status != "" && form.Status != models.BatchStatusOpen && form.Status != models.BatchStatusOnHold {
+		mc.ctx.JSON(400, gin.H{"error": "status must be open or on_hold"})
+		return
+	}
+	if _, err := mc.productModelService.GetProductModel(int64(form.ProductModelID)); err != nil {
+		mc.notFoundOr500(err, "product model not found")
+		return
+	}
+	if err := mc.batchService.CreateBatch(&form, mc.ctx.GetString("identifier")); err != nil {
+		mc.batchError(err)
+		return
+	}
+	mc.ctx.JSON(201, gin.H{"data": form, "message": "success"})
+}
+
+func (mc *ManagementController) GetBatches() {
+	var queryParams struct {
+		ProductModelID uint   `form:"productModelId"`
+		Status         string `form:"status"`
+	}
+	var paginateParams models.PaginationQuery
+	if err := mc.ctx.ShouldBindQuery(&queryParams); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := mc.ctx.ShouldBindQuery(&paginateParams); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	queryParamsMap := utils.StructToMap(queryParams)
+	paginateParamsMap := utils.StructToMap(paginateParams)
+	batches, pageResult, err := mc.batchService.GetBatches(queryParamsMap, paginateParamsMap)
+	if err != nil {
+		mc.listError(err)
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"data": batches, "pagination": pageResult, "message": "success"})
+}
+
+// 批次详情，含检测汇总
+func (mc *ManagementController) GetBatch() {
+	var uriParams IDField
+	if err := mc.ctx.ShouldBindUri(&uriParams); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	batch, err := mc.batchService.GetBatch(uriParams.ID)
+	if err != nil {
+		mc.notFoundOr500(err, "batch not found")
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"data": batch, "message": "success"})
+}
+
+func (mc *ManagementController) HoldBatch() {
+	mc.transitionBatch(func(models.BatchActionForm) (string, error) { return models.BatchActionHold, nil })
+}
+
+func (mc *ManagementController) ReleaseBatch() {
+	mc.transitionBatch(func(models.BatchActionForm) (string, error) { return models.BatchActionRelease, nil })
+}
+
+// 质量判定：accept/reject/sort/concession
+func (mc *ManagementController) DisposeBatch() {
+	mc.transitionBatch(func(form models.BatchActionForm) (string, error) {
+		if !models.IsBatchDisposition(form.Disposition) {
+			return "", fmt.Errorf("invalid disposition %q, expected one of accept, reject, sort, concession", form.Disposition)
+		}
+		return form.Disposition, nil
+	})
+}
+
+// 批次操作，操作人为当前登录用户
+func (mc *ManagementController) transitionBatch(action func(form models.BatchActionForm) (string, error)) {
+	var uriParams IDField
+	if err := mc.ctx.ShouldBindUri(&uriParams); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	var form models.BatchActionForm
+	if err := mc.ctx.ShouldBindJSON(&form); err != nil && !errors.Is(err, io.EOF) {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	name, err := action(form)
+	if err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	batch, err := mc.batchService.Transition(uriParams.ID, name, form.Note, mc.ctx.GetString("identifier"))
+	if err != nil {
+		mc.batchError(err)
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"data": batch, "message": "success"})
+}
+
+// 批次的冻结和判定历史
+func (mc *ManagementController) GetBatchDispositions() {
+	var uriParams IDField
+	if err := mc.ctx.ShouldBindUri(&uriParams); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	dispositions, err := mc.batchService.GetDispositions(uriParams.ID)
+	if err != nil {
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"data": dispositions, "message": "success"})
+}
+
+// 批次的抽样方案和进度
+func (mc *ManagementController) GetBatchSampling() {
+	var uriParams IDField
+	if err := mc.ctx.ShouldBindUri(&uriParams); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	sampling, err := mc.samplingService.GetBatchSampling(uriParams.ID)
+	if err != nil {
+		mc.notFoundOr500(err, "batch sampling not found")
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"data": sampling, "message": "success"})
+}
+
+func (mc *ManagementController) AddSamplingPlan() {
+	var form models.SamplingPlan
+	if err := mc.ctx.ShouldBindJSON(&form); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := form.Validate(); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if !mc.samplingPlanTargetExists(&form) {
+		return
+	}
+	if err := mc.samplingService.CreatePlan(&form); err != nil {
+		mc.samplingPlanError(err)
+		return
+	}
+	mc.ctx.JSON(201, gin.H{"data": form, "message": "success"})
+}
+
+func (mc *ManagementController) GetSamplingPlans() {
+	var queryParams struct {
+		SupplierID     uint   `form:"supplierId"`
+		ProductModelID uint   `form:"productModelId"`
+		Severity       string `form:"severity"`
+	}
+	var paginateParams models.PaginationQuery
+	if err := mc.ctx.ShouldBindQuery(&queryParams); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := mc.ctx.ShouldBindQuery(&paginateParams); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	queryParamsMap := utils.StructToMap(queryParams)
+	paginateParamsMap := utils.StructToMap(paginateParams)
+	plans, pageResult, err := mc.samplingService.GetPlans(queryParamsMap, paginateParamsMap)
+	if err != nil {
+		mc.listError(err)
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"data": plans, "pagination": pageResult, "message": "success"})
+}
+
+func (mc *ManagementController) GetSamplingPlan() {
+	var uriParams IDField
+	if err := mc.ctx.ShouldBindUri(&uriParams); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	plan, err := mc.samplingService.GetPlan(uriParams.ID)
+	if err != nil {
+		mc.notFoundOr500(err, "sampling plan not found")
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"data": plan, "message": "success"})
+}
+
+// 修改方案的定义，严格度和转移规则的状态保持不变
+func (mc *ManagementController) UpdateSamplingPlan() {
+	var form models.SamplingPlan
+	if err := mc.ctx.ShouldBindJSON(&form); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	plan, err := mc.samplingService.GetPlan(form.ID)
+	if err != nil || plan.ID == 0 {
+		mc.ctx.JSON(404, gin.H{"error": "sampling plan not found"})
+		return
+	}
+
+	// 以合并后的方案进行校验
+	merged := *plan
+	if form.Name != "" {
+		merged.Name = form.Name
+	}
+	if form.SupplierID != nil {
+		merged.SupplierID = form.SupplierID
+	}
+	if form.ProductModelID != nil {
+		merged.ProductModelID = form.ProductModelID
+	}
+	if form.InspectionLevel != "" {
+		merged.InspectionLevel = form.InspectionLevel
+	}
+	if form.AQL != 0 {
+		merged.AQL = form.AQL
+	}
+	if err := merged.Validate(); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !mc.samplingPlanTargetExists(&merged) {
+		return
+	}
+	if err := mc.samplingService.UpdatePlan(plan, &merged, utils.StructToMap(form)); err != nil {
+		mc.samplingPlanError(err)
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"data": plan, "message": "success"})
+}
+
+func (mc *ManagementController) DeleteSamplingPlan() {
+	var form IDsField
+	if err := mc.ctx.ShouldBindJSON(&form); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := mc.samplingService.DeletePlans(form.IDs); err != nil {
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"message": "success"})
+}
+
+// 人工设置严格度，例如暂停抽样后整改完成恢复正常检验
+func (mc *ManagementController) ResetSamplingPlan() {
+	var uriParams IDField
+	if err := mc.ctx.ShouldBindUri(&uriParams); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	var form models.SamplingResetForm
+	if err := mc.ctx.ShouldBindJSON(&form); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := form.Validate(); err != nil {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	plan, err := mc.samplingService.ResetPlan(uriParams.ID, form.Severity)
+	if err != nil {
+		mc.notFoundOr500(err, "sampling plan not found")
+		return
+	}
+	mc.ctx.JSON(200, gin.H{"data": plan, "message": "success"})
+}
+
+// 方案所属的物料或供应商不存在时返回 404
+func (mc *ManagementController) samplingPlanTargetExists(plan *models.SamplingPlan) bool {
+	if plan.ProductModelID != nil {
+		if _, err := mc.productModelService.GetProductModel(int64(*plan.ProductModelID)); err != nil {
+			mc.notFoundOr500(err, "product model not found")
+			return false
+		}
+		return true
+	}
+	if _, err := mc.supplierService.GetSupplier(int64(*plan.SupplierID)); err != nil {
+		mc.notFoundOr500(err, "supplier not found")
+		return false
+	}
+	return true
+}
+
+func (mc *ManagementController) samplingPlanError(err error) {
+	if errors.Is(err, services.ErrSamplingPlanExists) {
+		mc.ctx.JSON(409, gin.H{"error": err.Error()})
+		return
+	}
+	mc.ctx.JSON(500, gin.H{"error": err.Error()})
+}
+
+func (mc *ManagementController) batchError(err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		mc.ctx.JSON(404, gin.H{"error": "batch not found"})
+	case errors.Is(err, services.ErrBatchExists), errors.Is(err, services.ErrInvalidBatchTransition):
+		mc.ctx.JSON(409, gin.H{"error": err.Error()})
+	default:
+		mc.ctx.JSON(500, gin.H{"error": err.Error()})
+	}
+}
+
+// 列表查询的分页、筛选参数（排序字段、游标、filter）无效时返回 400
+func (mc *ManagementController) listError(err error) {
+	if errors.Is(err, utils.ErrInvalidPagination) || errors.Is(err, utils.ErrInvalidFilter) {
+		mc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	mc.ctx.JSON(500, gin.H{"error": err.Error()})
+}
+
+func (mc *ManagementController) notFoundOr500(err error, message string) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		mc.ctx.JSON(404, gin.H{"error": message})
+		return
+	}
+	mc.ctx.JSON(500, gin.H{"error": err.Error()})
+}
+
+// 校验报表的对比参数，对比要求指定完整的日期区间
+func (mc *ManagementController) validateComparison(comparison models.ComparisonQuery, startDate, endDate, tz string) error {
+	if err := comparison.Validate(); err != nil {
+		return err
+	}
+	if comparison.Compare == models.CompareNone {
+		return nil
+	}
+	if startDate == "" || endDate == "" {
+		return fmt.Errorf("startDate and endDate are required when compare is set")
+	}
+	location, err := utils.ResolveLocation(tz)
+	if err != nil {
+		return err
+	}
+	start, end, err := utils.DateRange(startDate, endDate, location)
+	if err != nil {
+		return err
+	}
+	_, _, err = comparison.BaselineRange(start, end)
+	return err
 }
 
 func (mc *ManagementController) ImportProductionPlan() {
@@ -949,14 +2066,14 @@ func (mc *ManagementController) GetProductionPlansByDate() {
 	}
 
 	// 解析日期
-	date, err := time.Parse("2006-01-02", dateStr)
+	date, err := utils.ParseDate(dateStr, utils.PlantLocation())
 	if err != nil {
 		mc.ctx.JSON(400, gin.H{"error": "日期格式错误，应为 YYYY-MM-DD", "message": err.Error()})
 		return
 	}
 
-	// 调用服务层方法
-	plans, err := mc.productionPlanService.GetProductionPlansByDate(date)
+	// 调用服务层方法（bucketBy=production 时按生产日统计完成数）
+	plans, err := mc.productionPlanService.GetProductionPlansByDate(date, mc.ctx.Query("bucketBy"))
 	if err != nil {
 		mc.ctx.JSON(500, gin.H{"error": "查询失败", "message": err.Error()})
 		return
@@ -1,13 +1,18 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/metrics"
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/services"
 	"github.com/dreamskynl/godi"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type ProductionController struct {
@@ -18,6 +23,8 @@ type ProductionController struct {
 	productModelService   services.IProductModelService
 	productionPlanService services.IProductionPlanService
 	supplierService       services.ISupplierService
+	batchService          services.IBatchService
+	samplingService       services.ISamplingService
 	keyManagementService  services.IKeyManagementService
 	jwtService            services.IJwtService
 }
@@ -25,12 +32,14 @@ type ProductionController struct {
 func NewProductionController(ctx *gin.Context, sc godi.IGoDI) IProductionController {
 	return &ProductionController{
 		ctx:                   ctx,
-		productLineService:    sc.MustResolve(&services.ProductLineService{}).(*services.ProductLineService),
-		palletService:         sc.MustResolve(&services.PalletService{}).(*services.PalletService),
-		productService:        sc.MustResolve(&services.ProductService{}).(*services.ProductService),
-		productModelService:   sc.MustResolve(&services.ProductModelService{}).(*services.ProductModelService),
-		productionPlanService: sc.MustResolve(&services.ProductionPlanService{}).(*services.ProductionPlanService),
-		supplierService:       sc.MustResolve(&services.SupplierService{}).(*services.SupplierService),
+		productLineService:    sc.MustResolve(&services.ProductLineService{}).(*services.ProductLineService).WithContext(ctx.Request.Context()),
+		palletService:         sc.MustResolve(&services.PalletService{}).(*services.PalletService).WithContext(ctx.Request.Context()),
+		productService:        sc.MustResolve(&services.ProductService{}).(*services.ProductService).WithContext(ctx.Request.Context()),
+		productModelService:   sc.MustResolve(&services.ProductModelService{}).(*services.ProductModelService).WithContext(ctx.Request.Context()),
+		productionPlanService: sc.MustResolve(&services.ProductionPlanService{}).(*services.ProductionPlanService).WithContext(ctx.Request.Context()),
+		supplierService:       sc.MustResolve(&services.SupplierService{}).(*services.SupplierService).WithContext(ctx.Request.Context()),
+		batchService:          sc.MustResolve(&services.BatchService{}).(*services.BatchService).WithContext(ctx.Request.Context()),
+		samplingService:       sc.MustResolve(&services.SamplingService{}).(*services.SamplingService).WithContext(ctx.Request.Context()),
 		keyManagementService:  sc.MustResolve(&services.KeyManagementService{}).(*services.KeyManagementService),
 		jwtService:            sc.MustResolve(&services.JwtService{}).(*services.JwtService),
 	}
@@ -64,9 +73,10 @@ func (pc *ProductionController) DeleteProductLine() {
 
 func (pc *ProductionController) AddPallet() {
 	var form struct {
-		SN   string `json:"sn" binding:"required"`              // Pallet SN
-		SAP  string `json:"productModelSap" binding:"required"` // SAP Code
-		Goal int    `json:"goal" binding:"required"`
+		SN          string `json:"sn" binding:"required"`              // Pallet SN
+		SAP         string `json:"productModelSap" binding:"required"` // SAP Code
+		Goal        int    `json:"goal" binding:"required"`
+		BatchNumber string `json:"batchNumber"` // 可选，托盘装载的批次
 	}
 	if err := pc.ctx.ShouldBindJSON(&form); err != nil {
 		pc.ctx.JSON(400, gin.H{"error": err.Error()})
@@ -76,7 +86,6 @@ func (pc *ProductionController) AddPallet() {
 	// 从JWT token中获取产线ID
 	var productLineID *uint
 	if id, exists := pc.ctx.Get("id"); exists {
-		fmt.Println(id)
 		if lineID, ok := id.(int64); ok {
 			// 验证产线是否存在
 			_, err := pc.productLineService.GetProductLine(int64(lineID))
@@ -109,6 +118,23 @@ func (pc *ProductionController) AddPallet() {
 		productModelID = &modelID
 	}
 
+	// 检查批次是否冻结：指定批次时按配置警告或拒绝（拒绝在创建托盘的事务中检查），未指定时只提示该物料下冻结的批次
+	var warnings []string
+	if form.BatchNumber != "" {
+		if !services.BatchHoldRejects() && !pc.batchWarning(*productModelID, form.BatchNumber, form.SAP, &warnings) {
+			return
+		}
+	} else {
+		blocked, err := pc.batchService.GetBlockedBatches(*productModelID)
+		if err != nil {
+			pc.ctx.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		for _, batch := range blocked {
+			warnings = append(warnings, blockedBatchMessage(&batch, form.SAP))
+		}
+	}
+
 	// 创建托盘
 	pallet := models.Pallet{
 		SN:             form.SN,
@@ -117,12 +143,12 @@ func (pc *ProductionController) AddPallet() {
 		Goal:           form.Goal,
 	}
 
-	if err := pc.palletService.CreatePallet(&pallet); err != nil {
-		pc.ctx.JSON(500, gin.H{"error": err.Error()})
+	if err := pc.palletService.CreatePalletForBatch(&pallet, form.BatchNumber); err != nil {
+		pc.batchError(err, form.SAP)
 		return
 	}
 
-	pc.ctx.JSON(201, gin.H{"data": pallet, "message": "success"})
+	pc.created(pallet, warnings)
 }
 
 func (pc *ProductionController) AddProduct() {
@@ -151,6 +177,7 @@ func (pc *ProductionController) AddProduct() {
 	var productModelID *uint
 	var productLineID *uint
 	var productionPlanID *uint
+	modelLabel := "unknown"
 
 	if len(form.SN) >= 7 {
 		snPrefix := form.SN[:7] // 前7位
@@ -158,6 +185,7 @@ func (pc *ProductionController) AddProduct() {
 		if err == nil && productModel != nil {
 			modelID := uint(productModel.ID)
 			productModelID = &modelID
+			modelLabel = productModel.SN
 
 			// 2. 然后，进行可用生产计划查询
 			today := time.Now()
@@ -169,8 +197,16 @@ func (pc *ProductionController) AddProduct() {
 		}
 	}
 
+	// 3. 所属批次冻结或判退时按配置警告，拒绝在保存产品的事务中检查
+	var warnings []string
+	if productModelID != nil && batchNumber != "" && !services.BatchHoldRejects() {
+		if !pc.batchWarning(*productModelID, batchNumber, modelLabel, &warnings) {
+			return
+		}
+	}
+
 	var product models.Product
-	// 3. 最后，根据是否不良来区别创建产品记录
+	// 4. 最后，根据是否不良来区别创建产品记录
 	if !form.HasDefect {
 		// 对于正常产品，需要获取产线ID和托盘ID
 		if form.PalletID != 0 {
@@ -209,11 +245,92 @@ func (pc *ProductionController) AddProduct() {
 
 	// 保存产品记录
 	if err := pc.productService.CreateProduct(&product); err != nil {
+		pc.batchError(err, modelLabel)
+		return
+	}
+
+	// 按上报设备所属产线计数（不良品不关联产线）
+	lineLabel := "unknown"
+	if id, exists := pc.ctx.Get("id"); exists {
+		if lineID, ok := id.(int64); ok {
+			lineLabel = strconv.FormatInt(lineID, 10)
+		}
+	}
+	metrics.ProductsIngested.WithLabelValues(lineLabel, modelLabel).Inc()
+	if product.HasDefect {
+		metrics.ProductDefects.WithLabelValues(metrics.DefectReasonLabel(product.DefectReason)).Inc()
+	}
+
+	pc.created(product, warnings)
+}
+
+// 批次需要抽检的数量和接收数、拒收数及当前进度，没有适用的抽样方案时 data 为 null（全检）
+func (pc *ProductionController) GetSampling() {
+	var query models.SamplingQuery
+	if err := pc.ctx.ShouldBindQuery(&query); err != nil {
+		pc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := query.Validate(); err != nil {
+		pc.ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	productModel, err := pc.productModelService.GetProductModelBySN(query.ProductModelSAP)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			pc.ctx.JSON(404, gin.H{"error": "product model not found"})
+			return
+		}
 		pc.ctx.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
+	sampling, err := pc.samplingService.Instruct(productModel, query.BatchNumber, query.LotSize)
+	switch {
+	case errors.Is(err, services.ErrLotSizeRequired):
+		pc.ctx.JSON(400, gin.H{"error": err.Error()})
+	case err != nil:
+		pc.ctx.JSON(500, gin.H{"error": err.Error()})
+	case sampling == nil:
+		pc.ctx.JSON(200, gin.H{"data": nil, "message": "no sampling plan, inspect every unit"})
+	default:
+		pc.ctx.JSON(200, gin.H{"data": sampling, "message": "success"})
+	}
+}
 
-	pc.ctx.JSON(201, gin.H{"data": product, "message": "success"})
+// 批次冻结或判退时追加警告，查询失败时返回 500 并返回 false
+func (pc *ProductionController) batchWarning(productModelID uint, batchNumber, modelSN string, warnings *[]string) bool {
+	batch, err := pc.batchService.FindBatch(productModelID, batchNumber)
+	if err != nil {
+		pc.ctx.JSON(500, gin.H{"error": err.Error()})
+		return false
+	}
+	if batch != nil && batch.Blocked() {
+		*warnings = append(*warnings, blockedBatchMessage(batch, modelSN))
+	}
+	return true
+}
+
+// 写入产品或托盘的错误：批次按配置拒绝时返回 409，其余返回 500
+func (pc *ProductionController) batchError(err error, modelSN string) {
+	var blocked *services.BatchBlockedError
+	if errors.As(err, &blocked) {
+		pc.ctx.JSON(409, gin.H{"error": blockedBatchMessage(&blocked.Batch, modelSN)})
+		return
+	}
+	pc.ctx.JSON(500, gin.H{"error": err.Error()})
+}
+
+func blockedBatchMessage(batch *models.Batch, modelSN string) string {
+	return fmt.Sprintf("batch %s of product model %s is %s", strings.TrimSpace(batch.BatchNumber), modelSN, batch.Status)
+}
+
+// 创建成功的响应，有警告时附带 warnings
+func (pc *ProductionController) created(data interface{}, warnings []string) {
+	response := gin.H{"data": data, "message": "success"}
+	if len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	pc.ctx.JSON(201, response)
 }
 
 func (pc *ProductionController) RegisterProductLine() {
@@ -283,6 +400,7 @@ func (pc *ProductionController) AuthenticateProductLine() {
 
 	// 验证DeviceID和PublicKey的匹配性
 	if !pc.keyManagementService.ValidateDeviceIDAndPublicKey(form.DeviceID, form.PublicKey) {
+		metrics.DeviceAuthFailures.WithLabelValues(metrics.AuthFailureInvalidKey).Inc()
 		pc.ctx.JSON(401, gin.H{"error": "invalid device ID or public key"})
 		return
 	}
@@ -301,6 +419,7 @@ func (pc *ProductionController) AuthenticateProductLine() {
 	}
 
 	if len(productLines) == 0 {
+		metrics.DeviceAuthFailures.WithLabelValues(metrics.AuthFailureNotRegistered).Inc()
 		pc.ctx.JSON(404, gin.H{"error": "product line not found or not registered"})
 		return
 	}
@@ -2,21 +2,161 @@ package databases
 
 import (
 	"fmt"
+	"log/slog"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/config"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/logging"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/metrics"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/search"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/tracing"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func InitDB(host string, user string, password string, port string, dbName string) *gorm.DB {
-	// Connect to DB
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local", user, password, host, port, dbName)
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+// 按配置连接数据库，连接失败时按指数退避重试，重试耗尽后返回最后一次的错误
+func Connect(cfg config.DBConfig) (*gorm.DB, error) {
+	backoff := cfg.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		db, err := Open(cfg.Driver, cfg.Host, cfg.User, cfg.Password, strconv.Itoa(cfg.Port), cfg.Name)
+		if err == nil {
+			return db, configurePool(db, cfg)
+		}
+		if attempt > cfg.ConnectRetries {
+			return nil, fmt.Errorf("failed to connect to %s database %s after %d attempts: %w", cfg.Driver, cfg.Name, attempt, err)
+		}
+		slog.Warn("database not ready", "attempt", attempt, "attempts", cfg.ConnectRetries+1, "error", err, "retry_in", backoff.String())
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > cfg.MaxRetryBackoff {
+			backoff = cfg.MaxRetryBackoff
+		}
+	}
+}
+
+// 连接池设置；SQLite 内存数据库只能使用单个连接，保持 Open 中的设置
+func configurePool(db *gorm.DB, cfg config.DBConfig) error {
+	if db.Dialector.Name() == DriverSQLite && cfg.Name == ":memory:" {
+		return nil
+	}
+	sqlDB, err := db.DB()
 	if err != nil {
-		panic("failed to connect to database")
+		return err
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	return nil
+}
+
+// driver 为 mysql（默认）、postgres 或 sqlite；sqlite 时 dbName 为数据库文件路径，":memory:" 表示内存数据库
+func Open(driver string, host string, user string, password string, port string, dbName string) (*gorm.DB, error) {
+	// 时间字段统一按 DB_TIMEZONE（默认 UTC）读写，与进程所在时区无关
+	location := utils.DBLocation()
+	gormConfig := &gorm.Config{
+		NowFunc: func() time.Time { return time.Now().In(location) },
+		Logger:  logging.GormLogger{},
+	}
+
+	var dialector gorm.Dialector
+	switch strings.ToLower(driver) {
+	case "", DriverMySQL:
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=%s", user, password, host, port, dbName, url.QueryEscape(location.String()))
+		dialector = mysql.Open(dsn)
+	case DriverPostgres:
+		// 会话时区与存储时区一致，保证 to_char 等函数的结果与 MySQL 相同
+		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=%s", host, user, password, dbName, port, location.String())
+		dialector = postgres.Open(dsn)
+	case DriverSQLite:
+		if location.String() != "UTC" {
+			return nil, fmt.Errorf("sqlite requires DB_TIMEZONE=UTC, got %s", location)
+		}
+		dsn := dbName + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite"
+		dialector = sqlite.Open(dsn)
+	default:
+		return nil, fmt.Errorf("unsupported DB_DRIVER %q, expected mysql, postgres or sqlite", driver)
+	}
+
+	db, err := gorm.Open(dialector, gormConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	if err := db.Use(metrics.GormPlugin{}); err != nil {
+		return nil, err
+	}
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		return nil, err
+	}
+	if err := db.Use(search.GormPlugin{}); err != nil {
+		return nil, err
 	}
 
-	// 确保使用 UTF-8 字符集（SET NAMES 包含了 client、connection、results 三个变量）
-	db.Exec("SET NAMES utf8mb4")
+	switch db.Dialector.Name() {
+	case DriverMySQL:
+		// 确保使用 UTF-8 字符集（SET NAMES 包含了 client、connection、results 三个变量）
+		db.Exec("SET NAMES utf8mb4")
+	case DriverSQLite:
+		if err := registerUTCTimes(db); err != nil {
+			return nil, err
+		}
+		// 内存数据库每个连接相互独立，只能使用单个连接
+		if dbName == ":memory:" {
+			sqlDB, err := db.DB()
+			if err != nil {
+				return nil, err
+			}
+			sqlDB.SetMaxOpenConns(1)
+		}
+	}
 
-	return db
+	return db, nil
+}
+
+// SQLite 以文本保存时间并按文本比较，写入前将时间字段统一换算为 UTC（与 MySQL 驱动按 loc 换算的行为一致）
+func registerUTCTimes(db *gorm.DB) error {
+	if err := db.Callback().Create().Before("gorm:create").Register("sqlite:utc_times", utcTimes); err != nil {
+		return err
+	}
+	return db.Callback().Update().Before("gorm:update").Register("sqlite:utc_times", utcTimes)
+}
+
+func utcTimes(db *gorm.DB) {
+	if db.Statement.Schema == nil {
+		return
+	}
+	switch value := db.Statement.ReflectValue; value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			utcFields(db, reflect.Indirect(value.Index(i)))
+		}
+	case reflect.Struct:
+		utcFields(db, value)
+	}
+}
+
+func utcFields(db *gorm.DB, value reflect.Value) {
+	ctx := db.Statement.Context
+	for _, field := range db.Statement.Schema.Fields {
+		fieldValue, isZero := field.ValueOf(ctx, value)
+		if isZero {
+			continue
+		}
+		switch t := fieldValue.(type) {
+		case time.Time:
+			db.AddError(field.Set(ctx, value, t.UTC()))
+		case *time.Time:
+			if t != nil {
+				utc := t.UTC()
+				db.AddError(field.Set(ctx, value, &utc))
+			}
+		}
+	}
 }
@@ -0,0 +1,137 @@
+package databases
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 支持的数据库类型，由环境变量 DB_DRIVER 选择
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// Dialect 屏蔽各数据库之间的 SQL 差异，服务中的原生 SQL 通过它生成与数据库相关的片段
+type Dialect interface {
+	Name() string
+	// 将时间列截断到整点，结果为 "YYYY-MM-DD HH:00:00" 格式的字符串（数据库存储时区）
+	HourBucket(column string) string
+	// 将时间列截断到 minutes（60 的约数）分钟的整倍数，结果格式与 HourBucket 相同
+	MinuteBucket(column string, minutes int) string
+	// 时间列平移 minutes（SQL 整数表达式）分钟后的日期，结果为 "YYYY-MM-DD" 格式的字符串
+	ShiftedDate(column, minutes string) string
+	// 取字符串列中第一个分隔符之前的部分，不含分隔符时为整个字符串
+	SubstringBefore(column, separator string) string
+	// 建表选项，仅 MySQL 需要
+	TableOptions() string
+	// 带毫秒精度的时间列类型
+	TimestampType() string
+	// 索引列，prefixLength 为 MySQL 对长文本列建索引时使用的前缀长度
+	IndexColumn(column string, prefixLength int) string
+}
+
+// 根据连接所使用的驱动返回对应的方言
+func DialectOf(db *gorm.DB) Dialect {
+	switch db.Dialector.Name() {
+	case DriverPostgres:
+		return postgresDialect{}
+	case DriverSQLite:
+		return sqliteDialect{}
+	}
+	return mysqlDialect{}
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string { return DriverMySQL }
+
+func (mysqlDialect) HourBucket(column string) string {
+	return fmt.Sprintf("DATE_FORMAT(%s, '%%Y-%%m-%%d %%H:00:00')", column)
+}
+
+func (mysqlDialect) MinuteBucket(column string, minutes int) string {
+	return fmt.Sprintf("DATE_FORMAT(DATE_SUB(%s, INTERVAL MINUTE(%s) %% %d MINUTE), '%%Y-%%m-%%d %%H:%%i:00')", column, column, minutes)
+}
+
+func (mysqlDialect) ShiftedDate(column, minutes string) string {
+	return fmt.Sprintf("DATE_FORMAT(DATE_ADD(%s, INTERVAL (%s) MINUTE), '%%Y-%%m-%%d')", column, minutes)
+}
+
+func (mysqlDialect) SubstringBefore(column, separator string) string {
+	return fmt.Sprintf("SUBSTRING_INDEX(%s, %s, 1)", column, quote(separator))
+}
+
+func (mysqlDialect) TableOptions() string {
+	return "ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci"
+}
+
+func (mysqlDialect) TimestampType() string { return "datetime(3)" }
+
+func (mysqlDialect) IndexColumn(column string, prefixLength int) string {
+	if prefixLength > 0 {
+		return fmt.Sprintf("%s(%d)", column, prefixLength)
+	}
+	return column
+}
+
+// PostgreSQL 会话时区在连接串中设置为数据库存储时区，to_char 按该时区输出
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string { return DriverPostgres }
+
+func (postgresDialect) HourBucket(column string) string {
+	return fmt.Sprintf("to_char(%s, 'YYYY-MM-DD HH24:00:00')", column)
+}
+
+func (postgresDialect) MinuteBucket(column string, minutes int) string {
+	return fmt.Sprintf("to_char(date_trunc('hour', %s) + FLOOR(EXTRACT(MINUTE FROM %s) / %d) * %d * INTERVAL '1 minute', 'YYYY-MM-DD HH24:MI:00')", column, column, minutes, minutes)
+}
+
+func (postgresDialect) ShiftedDate(column, minutes string) string {
+	return fmt.Sprintf("to_char(%s + (%s) * INTERVAL '1 minute', 'YYYY-MM-DD')", column, minutes)
+}
+
+func (postgresDialect) SubstringBefore(column, separator string) string {
+	return fmt.Sprintf("SPLIT_PART(%s, %s, 1)", column, quote(separator))
+}
+
+func (postgresDialect) TableOptions() string { return "" }
+
+func (postgresDialect) TimestampType() string { return "timestamptz" }
+
+func (postgresDialect) IndexColumn(column string, prefixLength int) string { return column }
+
+// SQLite 以文本保存时间，strftime 会将带时区偏移的时间换算为 UTC，因此要求 DB_TIMEZONE 为 UTC
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string { return DriverSQLite }
+
+func (sqliteDialect) HourBucket(column string) string {
+	return fmt.Sprintf("strftime('%%Y-%%m-%%d %%H:00:00', %s)", column)
+}
+
+func (sqliteDialect) MinuteBucket(column string, minutes int) string {
+	return fmt.Sprintf("strftime('%%Y-%%m-%%d %%H:', %s) || printf('%%02d', CAST(strftime('%%M', %s) AS INTEGER) / %d * %d) || ':00'", column, column, minutes, minutes)
+}
+
+func (sqliteDialect) ShiftedDate(column, minutes string) string {
+	return fmt.Sprintf("date(%s, (%s) || ' minutes')", column, minutes)
+}
+
+func (sqliteDialect) SubstringBefore(column, separator string) string {
+	return fmt.Sprintf("CASE WHEN INSTR(%[1]s, %[2]s) > 0 THEN SUBSTR(%[1]s, 1, INSTR(%[1]s, %[2]s) - 1) ELSE %[1]s END", column, quote(separator))
+}
+
+func (sqliteDialect) TableOptions() string { return "" }
+
+func (sqliteDialect) TimestampType() string { return "datetime" }
+
+func (sqliteDialect) IndexColumn(column string, prefixLength int) string { return column }
+
+// 生成 SQL 字符串字面量
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,107 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 检查结果状态
+const (
+	StatusOK       = "ok"
+	StatusFailed   = "failed"
+	StatusShutdown = "shutting_down"
+)
+
+// Check 就绪检查，返回附加信息；返回错误表示检查未通过
+type Check func(ctx context.Context) (map[string]interface{}, error)
+
+type CheckResult struct {
+	Name      string                 `json:"name"`
+	Status    string                 `json:"status"`
+	LatencyMs float64                `json:"latencyMs"`
+	Error     string                 `json:"error,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+type Report struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// Registry 就绪检查注册表，各子系统在启动时注册自己的检查
+type Registry struct {
+	mu     sync.RWMutex
+	names  []string
+	checks map[string]Check
+}
+
+func NewRegistry() *Registry {
+	return &Registry{checks: make(map[string]Check)}
+}
+
+// 服务使用的注册表，/readyz 运行其中的全部检查
+var Default = NewRegistry()
+
+// 注册检查，同名检查会被替换
+func (r *Registry) Register(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.checks[name]; !exists {
+		r.names = append(r.names, name)
+	}
+	r.checks[name] = check
+}
+
+func Register(name string, check Check) {
+	Default.Register(name, check)
+}
+
+// 并发运行全部检查，结果按注册顺序排列；任一检查失败或服务正在停机时整体为未就绪
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	names := append([]string{}, r.names...)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = r.checks[name]
+	}
+	r.mu.RUnlock()
+
+	results := make([]CheckResult, len(names))
+	var wg sync.WaitGroup
+	for i := range names {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, names[i], checks[i])
+		}(i)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: results}
+	for _, result := range results {
+		if result.Status != StatusOK {
+			report.Status = StatusFailed
+		}
+	}
+	if !Ready() {
+		report.Status = StatusShutdown
+	}
+	return report
+}
+
+func runCheck(ctx context.Context, name string, check Check) CheckResult {
+	start := time.Now()
+	details, err := check(ctx)
+	result := CheckResult{
+		Name:      name,
+		Status:    StatusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		Details:   details,
+	}
+	if err != nil {
+		result.Status = StatusFailed
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,86 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/health"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/migrations"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/testutil"
+)
+
+func ready(t *testing.T) {
+	health.SetReady(true)
+	t.Cleanup(func() { health.SetReady(false) })
+}
+
+func TestRegistryReportsEveryCheck(t *testing.T) {
+	ready(t)
+	registry := health.NewRegistry()
+	registry.Register("ok", func(ctx context.Context) (map[string]interface{}, error) {
+		return map[string]interface{}{"answer": 42}, nil
+	})
+	registry.Register("broken", func(ctx context.Context) (map[string]interface{}, error) {
+		return nil, errors.New("boom")
+	})
+
+	report := registry.Run(context.Background())
+	if report.Status != health.StatusFailed || len(report.Checks) != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	if report.Checks[0].Name != "ok" || report.Checks[0].Status != health.StatusOK || report.Checks[0].Details["answer"] != 42 {
+		t.Errorf("first check = %+v", report.Checks[0])
+	}
+	if report.Checks[1].Status != health.StatusFailed || report.Checks[1].Error != "boom" {
+		t.Errorf("second check = %+v", report.Checks[1])
+	}
+
+	// 同名注册替换原检查，顺序不变
+	registry.Register("broken", func(ctx context.Context) (map[string]interface{}, error) { return nil, nil })
+	if report := registry.Run(context.Background()); report.Status != health.StatusOK || report.Checks[1].Name != "broken" {
+		t.Fatalf("report after replace = %+v", report)
+	}
+
+	health.SetReady(false)
+	if report := registry.Run(context.Background()); report.Status != health.StatusShutdown {
+		t.Fatalf("status during shutdown = %s", report.Status)
+	}
+}
+
+func TestDatabaseAndMigrationChecks(t *testing.T) {
+	db := testutil.NewDB(t)
+
+	if _, err := health.DatabaseCheck(db, time.Second)(context.Background()); err != nil {
+		t.Fatalf("database check: %v", err)
+	}
+	if _, err := health.MigrationsCheck(db)(context.Background()); err != nil {
+		t.Fatalf("migrations check on migrated database: %v", err)
+	}
+
+	if err := migrations.New(db).Down(); err != nil {
+		t.Fatal(err)
+	}
+	details, err := health.MigrationsCheck(db)(context.Background())
+	if err == nil {
+		t.Fatal("expected pending migration")
+	}
+	if pending := details["pending"].([]string); len(pending) != 1 {
+		t.Fatalf("pending = %v", pending)
+	}
+}
+
+func TestDiskSpaceCheck(t *testing.T) {
+	dir := t.TempDir() + "/not/created/yet"
+	details, err := health.DiskSpaceCheck(dir, 1)(context.Background())
+	if err != nil {
+		t.Fatalf("disk check: %v", err)
+	}
+	if details["freeBytes"].(uint64) == 0 {
+		t.Fatalf("details = %v", details)
+	}
+	if _, err := health.DiskSpaceCheck(dir, ^uint64(0))(context.Background()); err == nil {
+		t.Fatal("expected insufficient space")
+	}
+}
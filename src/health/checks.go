@@ -0,0 +1,89 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/migrations"
+	"gorm.io/gorm"
+)
+
+// 数据库连通性：Ping 失败或耗时超过 maxLatency 时未就绪
+func DatabaseCheck(db *gorm.DB, maxLatency time.Duration) Check {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		if err := sqlDB.PingContext(ctx); err != nil {
+			return nil, fmt.Errorf("ping failed: %w", err)
+		}
+		latency := time.Since(start)
+		stats := sqlDB.Stats()
+		details := map[string]interface{}{
+			"pingMs":          float64(latency.Microseconds()) / 1000,
+			"openConnections": stats.OpenConnections,
+			"inUse":           stats.InUse,
+		}
+		if maxLatency > 0 && latency > maxLatency {
+			return details, fmt.Errorf("ping took %s, exceeds %s", latency, maxLatency)
+		}
+		return details, nil
+	}
+}
+
+// 存在未执行的迁移时未就绪（AUTO_MIGRATE=false 且尚未手动执行 migrate up）
+func MigrationsCheck(db *gorm.DB) Check {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		pending, err := migrations.New(db.WithContext(ctx)).Pending()
+		if err != nil {
+			return nil, err
+		}
+		versions := make([]string, 0, len(pending))
+		for _, migration := range pending {
+			versions = append(versions, migration.Version+"_"+migration.Name)
+		}
+		details := map[string]interface{}{"pending": versions}
+		if len(versions) > 0 {
+			return details, fmt.Errorf("%d pending migration(s)", len(versions))
+		}
+		return details, nil
+	}
+}
+
+// 目录所在磁盘的剩余空间低于 minFreeBytes 时未就绪；目录尚未创建时检查最近的已存在的上级目录
+func DiskSpaceCheck(dir string, minFreeBytes uint64) Check {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		path, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		for {
+			if _, err := os.Stat(path); err == nil {
+				break
+			}
+			parent := filepath.Dir(path)
+			if parent == path {
+				break
+			}
+			path = parent
+		}
+		free, total, err := diskUsage(path)
+		if err != nil {
+			return nil, err
+		}
+		details := map[string]interface{}{
+			"path":       dir,
+			"freeBytes":  free,
+			"totalBytes": total,
+		}
+		if free < minFreeBytes {
+			return details, fmt.Errorf("only %d bytes free, requires %d", free, minFreeBytes)
+		}
+		return details, nil
+	}
+}
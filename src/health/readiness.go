@@ -0,0 +1,15 @@
+// Package health 服务的就绪状态
+package health
+
+import "sync/atomic"
+
+var ready atomic.Bool
+
+// 服务开始监听后置为就绪，收到退出信号后置为未就绪，负载均衡据此停止转发新请求
+func SetReady(value bool) {
+	ready.Store(value)
+}
+
+func Ready() bool {
+	return ready.Load()
+}
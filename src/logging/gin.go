@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 请求 ID 头，客户端传入时沿用，否则生成
+const RequestIDHeader = "X-Request-ID"
+
+// 为每个请求分配请求 ID，将带有请求 ID 的 logger 放入请求 context，请求结束后记录访问日志
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		c.Header(RequestIDHeader, requestID)
+		c.Set("requestId", requestID)
+
+		logger := slog.Default().With("request_id", requestID)
+		// 启用链路追踪时带上 trace_id，便于从日志跳转到链路
+		if span := trace.SpanContextFromContext(c.Request.Context()); span.IsValid() {
+			logger = logger.With("trace_id", span.TraceID().String())
+		}
+		c.Request = c.Request.WithContext(NewContext(c.Request.Context(), logger))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", status,
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"client_ip", c.ClientIP(),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, "errors", c.Errors.String())
+		}
+		// 认证中间件可能已向 logger 追加了设备或用户信息
+		logger = FromContext(c.Request.Context())
+		switch {
+		case status >= 500:
+			logger.Error("request", attrs...)
+		case status >= 400:
+			logger.Warn("request", attrs...)
+		default:
+			logger.Info("request", attrs...)
+		}
+	}
+}
+
+// 向当前请求的 logger 追加字段，之后的服务日志、SQL 日志和访问日志都会带上
+func With(c *gin.Context, args ...any) {
+	logger := FromContext(c.Request.Context()).With(args...)
+	c.Request = c.Request.WithContext(NewContext(c.Request.Context(), logger))
+}
+
+// 只接受长度不超过 64 的可见 ASCII 字符，避免日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
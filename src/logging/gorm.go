@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// GormLogger 将 SQL 日志写入 context 中的 logger：执行出错为 error，慢查询为 warn，其余为 debug
+type GormLogger struct{}
+
+func (l GormLogger) LogMode(logger.LogLevel) logger.Interface {
+	return l
+}
+
+func (GormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	FromContext(ctx).InfoContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (GormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	FromContext(ctx).WarnContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (GormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	FromContext(ctx).ErrorContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	elapsed := time.Since(begin)
+	log := FromContext(ctx)
+	level, msg := slog.LevelDebug, "sql"
+	if slowQuery > 0 && elapsed > slowQuery {
+		level, msg = slog.LevelWarn, "slow sql"
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		level, msg = slog.LevelError, "sql error"
+	}
+	if !log.Enabled(ctx, level) {
+		return
+	}
+
+	sql, rows := fc()
+	attrs := []any{"sql", sql, "rows", rows, "elapsed_ms", float64(elapsed.Microseconds()) / 1000}
+	if level == slog.LevelError {
+		attrs = append(attrs, "error", err.Error())
+	}
+	log.Log(ctx, level, msg, attrs...)
+}
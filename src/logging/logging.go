@@ -0,0 +1,88 @@
+// Package logging 结构化日志（slog JSON），请求日志携带请求 ID 和认证身份，并通过 context 传入服务和 GORM
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/config"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// 当前日志级别，可在运行中调整
+var level = new(slog.LevelVar)
+
+// 慢查询阈值，由 Setup 设置
+var slowQuery = 200 * time.Millisecond
+
+// 按配置创建 JSON 日志并设为默认日志，返回的 Closer 在退出前关闭日志文件
+func Setup(cfg config.LogConfig) io.Closer {
+	SetLevel(cfg.Level)
+	slowQuery = cfg.SlowQuery
+
+	var writer io.WriteCloser = nopCloser{os.Stdout}
+	if cfg.File != "" {
+		writer = &lumberjack.Logger{
+			Filename:   cfg.File,
+			MaxSize:    cfg.MaxSizeMB,
+			MaxBackups: cfg.MaxBackups,
+			MaxAge:     cfg.MaxAgeDays,
+			Compress:   cfg.Compress,
+		}
+	}
+	slog.SetDefault(New(writer))
+	return writer
+}
+
+// 输出到 w 的 JSON 日志，级别跟随当前配置
+func New(w io.Writer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}))
+}
+
+// 调整日志级别，对已创建的 logger 同样生效
+func SetLevel(name string) {
+	level.Set(ParseLevel(name))
+}
+
+// 解析日志级别名称，无法识别时为 info
+func ParseLevel(name string) slog.Level {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+type contextKey struct{}
+
+// 返回携带 logger 的 context
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// 取出 context 中的 logger，没有时返回默认日志
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+			return logger
+		}
+	}
+	return slog.Default()
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
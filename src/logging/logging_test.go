@@ -0,0 +1,110 @@
+package logging_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/databases"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// 默认日志改为写入缓冲区，返回读取已写入日志行的函数
+func capture(t *testing.T, level string) func() []map[string]interface{} {
+	t.Helper()
+	var buf bytes.Buffer
+	original := slog.Default()
+	slog.SetDefault(logging.New(&buf))
+	logging.SetLevel(level)
+	t.Cleanup(func() {
+		slog.SetDefault(original)
+		logging.SetLevel("info")
+	})
+	return func() []map[string]interface{} {
+		var entries []map[string]interface{}
+		scanner := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+		for scanner.Scan() {
+			var entry map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				t.Fatalf("log line is not JSON: %s", scanner.Text())
+			}
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+}
+
+func TestRequestLoggerPropagation(t *testing.T) {
+	entries := capture(t, "debug")
+	gin.SetMode(gin.TestMode)
+
+	db, err := databases.Open(databases.DriverSQLite, "", "", "", "", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	router := gin.New()
+	router.Use(logging.Middleware())
+	router.GET("/upload", func(c *gin.Context) {
+		logging.With(c, "device_id", "device-line-1")
+		db.WithContext(c.Request.Context()).Exec("SELECT 1")
+		db.WithContext(c.Request.Context()).Exec("SELECT * FROM missing_table")
+		c.JSON(500, gin.H{"error": "failed"})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/upload", nil)
+	req.Header.Set(logging.RequestIDHeader, "req-42")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	if got := recorder.Header().Get(logging.RequestIDHeader); got != "req-42" {
+		t.Fatalf("response request ID = %q, want req-42", got)
+	}
+
+	// SQL 日志和访问日志都带有请求 ID 和设备 ID
+	messages := map[string]map[string]interface{}{}
+	for _, entry := range entries() {
+		messages[entry["msg"].(string)] = entry
+	}
+	for _, msg := range []string{"sql", "sql error", "request"} {
+		entry, ok := messages[msg]
+		if !ok {
+			t.Fatalf("missing %q log entry in %v", msg, messages)
+		}
+		if entry["request_id"] != "req-42" || entry["device_id"] != "device-line-1" {
+			t.Errorf("%q entry = %v, want request_id and device_id", msg, entry)
+		}
+	}
+	if messages["request"]["level"] != "ERROR" || messages["request"]["route"] != "/upload" {
+		t.Errorf("access log = %v", messages["request"])
+	}
+}
+
+func TestRequestIDGeneratedWhenInvalid(t *testing.T) {
+	entries := capture(t, "warn")
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(logging.Middleware())
+	router.GET("/ok", func(c *gin.Context) { c.JSON(200, gin.H{}) })
+
+	req := httptest.NewRequest(http.MethodGet, "/ok", nil)
+	req.Header.Set(logging.RequestIDHeader, "bad id\n")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	if got := recorder.Header().Get(logging.RequestIDHeader); len(got) != 32 {
+		t.Fatalf("request ID = %q, want a generated ID", got)
+	}
+	// 级别为 warn 时不记录成功请求
+	if logged := entries(); len(logged) != 0 {
+		t.Fatalf("unexpected entries: %v", logged)
+	}
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const startTimeKey = "metrics:start_time"
+
+// GormPlugin 记录每条 SQL 语句的耗时
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "metrics"
+}
+
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	registrations := []func() error{
+		func() error { return callback.Create().Before("gorm:create").Register("metrics:before_create", before) },
+		func() error {
+			return callback.Create().After("gorm:create").Register("metrics:after_create", after("create"))
+		},
+		func() error { return callback.Query().Before("gorm:query").Register("metrics:before_query", before) },
+		func() error {
+			return callback.Query().After("gorm:query").Register("metrics:after_query", after("query"))
+		},
+		func() error { return callback.Update().Before("gorm:update").Register("metrics:before_update", before) },
+		func() error {
+			return callback.Update().After("gorm:update").Register("metrics:after_update", after("update"))
+		},
+		func() error { return callback.Delete().Before("gorm:delete").Register("metrics:before_delete", before) },
+		func() error {
+			return callback.Delete().After("gorm:delete").Register("metrics:after_delete", after("delete"))
+		},
+		func() error { return callback.Row().Before("gorm:row").Register("metrics:before_row", before) },
+		func() error { return callback.Row().After("gorm:row").Register("metrics:after_row", after("row")) },
+		func() error { return callback.Raw().Before("gorm:raw").Register("metrics:before_raw", before) },
+		func() error { return callback.Raw().After("gorm:raw").Register("metrics:after_raw", after("raw")) },
+	}
+	for _, register := range registrations {
+		if err := register(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func before(db *gorm.DB) {
+	db.InstanceSet(startTimeKey, time.Now())
+}
+
+func after(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(startTimeKey)
+		if !ok {
+			return
+		}
+		start, ok := value.(time.Time)
+		if !ok {
+			return
+		}
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		DBQueryDuration.WithLabelValues(operation, table).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,80 @@
+// Package metrics Prometheus 指标，由 /metrics 暴露
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// 服务使用的指标注册表
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Database statement latency by operation and table.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"operation", "table"})
+
+	ProductsIngested = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "products_ingested_total",
+		Help: "Products uploaded by production line devices, by line and product model.",
+	}, []string{"product_line", "product_model"})
+
+	ProductDefects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "product_defects_total",
+		Help: "Defective products uploaded by production line devices, by configured defect reason or \"other\".",
+	}, []string{"reason"})
+
+	DeviceAuthFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "device_auth_failures_total",
+		Help: "Failed production line device authentications, by reason.",
+	}, []string{"reason"})
+)
+
+// 设备认证失败原因
+const (
+	AuthFailureInvalidKey    = "invalid_key"    // DeviceID 与公钥不匹配
+	AuthFailureNotRegistered = "not_registered" // 产线未注册
+	AuthFailureInvalidToken  = "invalid_token"  // 令牌缺失或无效
+)
+
+// 未配置的不良原因统一计为 other：不良原因由产线自由填写，直接作为标签会使时间序列无限增长
+const DefectReasonOther = "other"
+
+var defectReasons = map[string]bool{}
+
+// 设置作为 reason 标签的已知不良原因，须在开始接收上报之前调用
+func ConfigureDefectReasons(reasons []string) {
+	defectReasons = make(map[string]bool, len(reasons))
+	for _, reason := range reasons {
+		defectReasons[reason] = true
+	}
+}
+
+// 不良原因对应的 reason 标签
+func DefectReasonLabel(reason string) string {
+	if defectReasons[reason] {
+		return reason
+	}
+	return DefectReasonOther
+}
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		DBQueryDuration,
+		ProductsIngested,
+		ProductDefects,
+		DeviceAuthFailures,
+		openPallets,
+	)
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+)
+
+var palletDB atomic.Pointer[gorm.DB]
+
+// 未装满（产品数小于目标数）的托盘数量，在每次采集时查询数据库
+var openPallets = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+	Name: "open_pallets",
+	Help: "Pallets whose product count is below their goal.",
+}, countOpenPallets)
+
+// 设置统计未装满托盘所用的数据库连接，未设置时指标为 0
+func TrackOpenPallets(db *gorm.DB) {
+	palletDB.Store(db)
+}
+
+func countOpenPallets() float64 {
+	db := palletDB.Load()
+	if db == nil {
+		return 0
+	}
+	var count int64
+	err := db.Table("pallets").
+		Where("pallets.deleted_at IS NULL").
+		Where("pallets.goal > (SELECT COUNT(*) FROM products WHERE products.pallet_id = pallets.id AND products.deleted_at IS NULL)").
+		Count(&count).Error
+	if err != nil {
+		return 0
+	}
+	return float64(count)
+}
@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/logging"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/metrics"
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/services"
 	"github.com/gin-gonic/gin"
@@ -47,6 +49,12 @@ func AuthorizeJWT(role models.JwtServiceRole) gin.HandlerFunc {
 			c.Set("identifier", claims.Identifier)
 			c.Set("role", role)
 			c.Set("id", claims.ID)
+			// 之后的日志带上产线设备或用户身份
+			if role == models.JwtServiceRoleProductionLine {
+				logging.With(c, "device_id", claims.Identifier, "product_line_id", claims.ID)
+			} else {
+				logging.With(c, "user", claims.Identifier, "user_id", claims.ID)
+			}
 			c.Next()
 		} else {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
@@ -57,6 +65,13 @@ func AuthorizeJWT(role models.JwtServiceRole) gin.HandlerFunc {
 	}
 }
 
+// 产线端认证，未通过（响应 401）时记录设备认证失败
 func AuthorizeProductionLineJWT() gin.HandlerFunc {
-	return AuthorizeJWT(models.JwtServiceRoleProductionLine)
+	authorize := AuthorizeJWT(models.JwtServiceRoleProductionLine)
+	return func(c *gin.Context) {
+		authorize(c)
+		if c.IsAborted() && c.Writer.Status() == http.StatusUnauthorized {
+			metrics.DeviceAuthFailures.WithLabelValues(metrics.AuthFailureInvalidToken).Inc()
+		}
+	}
 }
@@ -0,0 +1,169 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 初始表结构，对已通过 AutoMigrate 建表的数据库为无操作
+var initialSchema = Migration{
+	Version: "0001",
+	Name:    "initial_schema",
+	Up: func(tx *gorm.DB) error {
+		return createTables(tx, initialTables()...)
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropTables(tx, initialTables()...)
+	},
+}
+
+func initialTables() []interface{} {
+	return []interface{}{
+		&initialSupplier{},
+		&initialProductModel{},
+		&initialProductLine{},
+		&initialPallet{},
+		&initialProductionPlan{},
+		&initialProduct{},
+		&initialUser{},
+		&initialAPI{},
+	}
+}
+
+// 以下为建表时的表结构快照，与 models 中的模型相互独立，模型之后的变更须通过新的迁移完成
+// 供应商类型在 MySQL 旧库中为 enum，由 0008 统一为 varchar
+
+type initialSupplier struct {
+	ID        int64 `gorm:"primary_key"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	Name      string         `gorm:"type:char(64)"`
+	SAP       string         `gorm:"type:char(16)"`
+	Type      string         `gorm:"type:varchar(20)"`
+}
+
+func (initialSupplier) TableName() string { return "suppliers" }
+
+type initialProductModel struct {
+	ID          int64 `gorm:"primary_key"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+	SN          string         `gorm:"type:char(16)"`
+	PartNumber  string         `gorm:"type:char(32)"`
+	Description string         `gorm:"type:char(128)"`
+	SupplierID  *uint
+	Supplier    *initialSupplier `gorm:"foreignKey:SupplierID"`
+}
+
+func (initialProductModel) TableName() string { return "product_models" }
+
+type initialProductLine struct {
+	ID             int64 `gorm:"primary_key"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      gorm.DeletedAt `gorm:"index"`
+	Name           string         `gorm:"type:char(64)"`
+	PalletSnPrefix string         `gorm:"type:char(16)"`
+	DeviceID       string         `gorm:"type:char(64);unique"`
+	IsRegistered   bool           `gorm:"default:false"`
+	PublicKey      string         `gorm:"type:text"`
+}
+
+func (initialProductLine) TableName() string { return "product_lines" }
+
+type initialPallet struct {
+	ID             int64 `gorm:"primary_key"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      gorm.DeletedAt `gorm:"index"`
+	SN             string         `gorm:"type:char(32)"`
+	ProductModelID *uint
+	ProductModel   *initialProductModel `gorm:"foreignKey:ProductModelID"`
+	ProductLineID  *uint
+	ProductLine    *initialProductLine `gorm:"foreignKey:ProductLineID"`
+	Goal           int
+}
+
+func (initialPallet) TableName() string { return "pallets" }
+
+type initialProductionPlan struct {
+	ID              int64 `gorm:"primary_key"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	DeletedAt       gorm.DeletedAt `gorm:"index"`
+	MaterialCode    string         `gorm:"type:varchar(50)"`
+	PartNumber      string         `gorm:"type:varchar(50)"`
+	Type            string         `gorm:"type:varchar(20)"`
+	Manufacturer    string         `gorm:"type:varchar(100)"`
+	PlanDate        time.Time      `gorm:"type:date"`
+	ProductionLine  string         `gorm:"type:varchar(50)"`
+	TPlanned        int            `gorm:"type:int"`
+	TActual         int            `gorm:"type:int"`
+	TUnfinished     int            `gorm:"type:int"`
+	T1Planned       int            `gorm:"type:int"`
+	T1Actual        int            `gorm:"type:int"`
+	T1Unfinished    int            `gorm:"type:int"`
+	T2Planned       int            `gorm:"type:int"`
+	T2Actual        int            `gorm:"type:int"`
+	T2Unfinished    int            `gorm:"type:int"`
+	T3Planned       int            `gorm:"type:int"`
+	T3Actual        int            `gorm:"type:int"`
+	T3Unfinished    int            `gorm:"type:int"`
+	TotalPlanned    int            `gorm:"type:int"`
+	TotalInspected  int            `gorm:"type:int"`
+	TotalUnfinished int            `gorm:"type:int"`
+	AchievementRate float64        `gorm:"type:decimal(5,2)"`
+	SpecialNote     string         `gorm:"type:varchar(200)"`
+}
+
+func (initialProductionPlan) TableName() string { return "production_plans" }
+
+type initialProduct struct {
+	ID               int64 `gorm:"primary_key"`
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	DeletedAt        gorm.DeletedAt `gorm:"index"`
+	SN               string         `gorm:"type:char(32)"`
+	BatchNumber      string         `gorm:"type:char(8)"`
+	ProductModelID   *uint
+	ProductModel     *initialProductModel `gorm:"foreignKey:ProductModelID"`
+	ProductLineID    *uint
+	ProductLine      *initialProductLine `gorm:"foreignKey:ProductLineID"`
+	ProductionPlanID *uint
+	ProductionPlan   *initialProductionPlan `gorm:"foreignKey:ProductionPlanID"`
+	PalletID         *uint
+	Pallet           *initialPallet `gorm:"foreignKey:PalletID"`
+	HasDefect        bool           `gorm:"default:false"`
+	DefectReason     string         `gorm:"type:text"`
+}
+
+func (initialProduct) TableName() string { return "products" }
+
+type initialUser struct {
+	ID        int64 `gorm:"primary_key"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	Username  string         `gorm:"type:char(32)"`
+	Email     string         `gorm:"unique"`
+	Mobile    string         `gorm:"unique"`
+	Password  string
+	Active    bool
+}
+
+func (initialUser) TableName() string { return "users" }
+
+type initialAPI struct {
+	ID        int64 `gorm:"primary_key"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	Name      string         `gorm:"type:char(64)"`
+	AppID     string         `gorm:"type:char(32)"`
+	Secret    string
+}
+
+func (initialAPI) TableName() string { return "apis" }
@@ -0,0 +1,23 @@
+package migrations
+
+import "gorm.io/gorm"
+
+var productIndexList = []index{
+	// 复合索引：同时优化 JOIN 和日期过滤
+	{table: "products", name: "idx_products_model_created", columns: "product_model_id, created_at"},
+	// 优化 products 表的日期和缺陷查询 (quality_stats 接口)
+	{table: "products", name: "idx_products_created_defect", columns: "created_at, has_defect, defect_reason", prefixes: map[string]int{"defect_reason": 100}},
+	// 优化产品SN查询
+	{table: "products", name: "idx_products_sn", columns: "sn"},
+}
+
+var productIndexes = Migration{
+	Version: "0002",
+	Name:    "product_indexes",
+	Up: func(tx *gorm.DB) error {
+		return createIndexes(tx, productIndexList...)
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropIndexes(tx, productIndexList...)
+	},
+}
@@ -0,0 +1,23 @@
+package migrations
+
+import "gorm.io/gorm"
+
+var productModelIndexList = []index{
+	// 优化 product_models 的供应商关联查询
+	{table: "product_models", name: "idx_product_models_supplier", columns: "supplier_id"},
+	// 优化产品型号SN的精确查询 (生产控制器高频使用 GetProductModelBySN)
+	{table: "product_models", name: "idx_product_models_sn", columns: "sn"},
+	// 优化产品型号描述的前缀查询，LIKE '%xxx%' 仍需全表扫描
+	{table: "product_models", name: "idx_product_models_description", columns: "description"},
+}
+
+var productModelIndexes = Migration{
+	Version: "0003",
+	Name:    "product_model_indexes",
+	Up: func(tx *gorm.DB) error {
+		return createIndexes(tx, productModelIndexList...)
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropIndexes(tx, productModelIndexList...)
+	},
+}
@@ -0,0 +1,21 @@
+package migrations
+
+import "gorm.io/gorm"
+
+var palletAndPlanIndexList = []index{
+	// 优化托盘SN的模糊查询 (search 参数)
+	{table: "pallets", name: "idx_pallets_sn", columns: "sn"},
+	// 按日期查询生产计划
+	{table: "production_plans", name: "idx_production_plans_plan_date", columns: "plan_date"},
+}
+
+var palletAndPlanIndexes = Migration{
+	Version: "0004",
+	Name:    "pallet_and_plan_indexes",
+	Up: func(tx *gorm.DB) error {
+		return createIndexes(tx, palletAndPlanIndexList...)
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropIndexes(tx, palletAndPlanIndexList...)
+	},
+}
@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var shiftCalendar = Migration{
+	Version: "0005",
+	Name:    "shift_calendar",
+	Up: func(tx *gorm.DB) error {
+		return createTables(tx, &shiftCalendarShift{}, &shiftCalendarHoliday{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropTables(tx, &shiftCalendarShift{}, &shiftCalendarHoliday{})
+	},
+}
+
+type shiftCalendarShift struct {
+	ID            int64 `gorm:"primary_key"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     gorm.DeletedAt `gorm:"index"`
+	ProductLineID *uint
+	ProductLine   *initialProductLine `gorm:"foreignKey:ProductLineID"`
+	Name          string              `gorm:"type:char(32)"`
+	StartTime     string              `gorm:"type:char(5)"`
+	EndTime       string              `gorm:"type:char(5)"`
+	Sequence      int
+}
+
+func (shiftCalendarShift) TableName() string { return "shifts" }
+
+type shiftCalendarHoliday struct {
+	ID            int64 `gorm:"primary_key"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     gorm.DeletedAt `gorm:"index"`
+	ProductLineID *uint
+	Date          time.Time `gorm:"type:date"`
+	Name          string    `gorm:"type:char(64)"`
+}
+
+func (shiftCalendarHoliday) TableName() string { return "holidays" }
@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 预聚合表，数据由服务启动时的 Backfill 或 rollup rebuild 命令生成
+var qualityRollups = Migration{
+	Version: "0006",
+	Name:    "quality_rollups",
+	Up: func(tx *gorm.DB) error {
+		return createTables(tx, &qualityHourlyRollup{}, &qualityDailyRollup{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropTables(tx, &qualityHourlyRollup{}, &qualityDailyRollup{})
+	},
+}
+
+type qualityHourlyRollup struct {
+	ID             int64     `gorm:"primary_key"`
+	HourStart      time.Time `gorm:"uniqueIndex:idx_quality_hourly_rollup_key,priority:1"`
+	ProductLineID  uint      `gorm:"uniqueIndex:idx_quality_hourly_rollup_key,priority:2"`
+	ProductModelID uint      `gorm:"uniqueIndex:idx_quality_hourly_rollup_key,priority:3"`
+	BatchNumber    string    `gorm:"type:char(8);uniqueIndex:idx_quality_hourly_rollup_key,priority:4"`
+	DefectReason   string    `gorm:"type:varchar(191);uniqueIndex:idx_quality_hourly_rollup_key,priority:5"`
+	TotalCount     int64
+	DefectCount    int64
+}
+
+func (qualityHourlyRollup) TableName() string { return "quality_hourly_rollups" }
+
+type qualityDailyRollup struct {
+	ID             int64  `gorm:"primary_key"`
+	Day            string `gorm:"type:char(10);uniqueIndex:idx_quality_daily_rollup_key,priority:1"`
+	ProductLineID  uint   `gorm:"uniqueIndex:idx_quality_daily_rollup_key,priority:2"`
+	ProductModelID uint   `gorm:"uniqueIndex:idx_quality_daily_rollup_key,priority:3"`
+	BatchNumber    string `gorm:"type:char(8);uniqueIndex:idx_quality_daily_rollup_key,priority:4"`
+	DefectReason   string `gorm:"type:varchar(191);uniqueIndex:idx_quality_daily_rollup_key,priority:5"`
+	TotalCount     int64
+	DefectCount    int64
+}
+
+func (qualityDailyRollup) TableName() string { return "quality_daily_rollups" }
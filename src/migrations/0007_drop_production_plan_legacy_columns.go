@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/databases"
+	"gorm.io/gorm"
+)
+
+// 生产计划改为按 T~T+3 列存储后，旧版的 start_at/end_at 列不再使用
+var dropProductionPlanLegacyColumns = Migration{
+	Version: "0007",
+	Name:    "drop_production_plan_legacy_columns",
+	Up: func(tx *gorm.DB) error {
+		for _, column := range []string{"start_at", "end_at"} {
+			if !tx.Migrator().HasColumn("production_plans", column) {
+				continue
+			}
+			if err := tx.Migrator().DropColumn("production_plans", column); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		columnType := databases.DialectOf(tx).TimestampType()
+		for _, column := range []string{"start_at", "end_at"} {
+			if tx.Migrator().HasColumn("production_plans", column) {
+				continue
+			}
+			if err := tx.Exec("ALTER TABLE production_plans ADD COLUMN " + column + " " + columnType + " NULL").Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/databases"
+	"gorm.io/gorm"
+)
+
+// 供应商类型由 MySQL 专有的 enum 改为 varchar，取值改由模型校验；其他数据库建表时已是 varchar
+var supplierTypeVarchar = Migration{
+	Version: "0008",
+	Name:    "supplier_type_varchar",
+	Up: func(tx *gorm.DB) error {
+		if databases.DialectOf(tx).Name() != databases.DriverMySQL {
+			return nil
+		}
+		return tx.Exec("ALTER TABLE suppliers MODIFY COLUMN type varchar(20)").Error
+	},
+	Down: func(tx *gorm.DB) error {
+		if databases.DialectOf(tx).Name() != databases.DriverMySQL {
+			return nil
+		}
+		return tx.Exec("ALTER TABLE suppliers MODIFY COLUMN type enum('直接供应','贸易商')").Error
+	},
+}
@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 后台任务队列
+var jobs = Migration{
+	Version: "0009",
+	Name:    "jobs",
+	Up: func(tx *gorm.DB) error {
+		return createTables(tx, &jobsJob{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropTables(tx, &jobsJob{})
+	},
+}
+
+type jobsJob struct {
+	ID              int64     `gorm:"primary_key"`
+	Kind            string    `gorm:"type:varchar(64);index"`
+	Payload         string    `gorm:"type:text"`
+	Status          string    `gorm:"type:varchar(16);index:idx_job_status_run_at,priority:1"`
+	RunAt           time.Time `gorm:"index:idx_job_status_run_at,priority:2"`
+	Attempts        int
+	MaxAttempts     int
+	Done            int64
+	Total           int64
+	Error           string `gorm:"type:text"`
+	CancelRequested bool
+	WorkerID        string `gorm:"type:varchar(64)"`
+	HeartbeatAt     *time.Time
+	StartedAt       *time.Time
+	FinishedAt      *time.Time
+	ArtifactPath    string `gorm:"type:varchar(255)"`
+	ArtifactName    string `gorm:"type:varchar(255)"`
+	ArtifactSize    int64
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func (jobsJob) TableName() string { return "jobs" }
@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 定时报表计划和执行记录
+var reportSchedules = Migration{
+	Version: "0010",
+	Name:    "report_schedules",
+	Up: func(tx *gorm.DB) error {
+		return createTables(tx, &reportSchedule{}, &reportRun{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropTables(tx, &reportSchedule{}, &reportRun{})
+	},
+}
+
+type reportSchedule struct {
+	ID           int64 `gorm:"primary_key"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
+	Name         string         `gorm:"type:varchar(128)"`
+	Report       string         `gorm:"type:varchar(32)"`
+	Cron         string         `gorm:"type:varchar(64)"`
+	Formats      string         `gorm:"type:varchar(32)"`
+	Channel      string         `gorm:"type:varchar(16)"`
+	Target       string         `gorm:"type:varchar(512)"`
+	SupplierName string         `gorm:"type:varchar(64)"`
+	Enabled      *bool          `gorm:"not null;default:true"`
+	NextRunAt    *time.Time     `gorm:"index"`
+	LastRunAt    *time.Time
+}
+
+func (reportSchedule) TableName() string { return "report_schedules" }
+
+type reportRun struct {
+	ID          int64 `gorm:"primary_key"`
+	ScheduleID  int64 `gorm:"index"`
+	JobID       int64
+	Trigger     string `gorm:"type:varchar(16)"`
+	Status      string `gorm:"type:varchar(16)"`
+	PeriodStart string `gorm:"type:char(10)"`
+	PeriodEnd   string `gorm:"type:char(10)"`
+	Files       string `gorm:"type:varchar(512)"`
+	Error       string `gorm:"type:text"`
+	StartedAt   time.Time
+	FinishedAt  *time.Time
+}
+
+func (reportRun) TableName() string { return "report_runs" }
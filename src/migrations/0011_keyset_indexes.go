@@ -0,0 +1,20 @@
+package migrations
+
+import "gorm.io/gorm"
+
+var keysetIndexList = []index{
+	// 游标分页按 (created_at, id) 排序和定位，深分页不再扫描前面的记录
+	{table: "products", name: "idx_products_created_id", columns: "created_at, id"},
+	{table: "pallets", name: "idx_pallets_created_id", columns: "created_at, id"},
+}
+
+var keysetIndexes = Migration{
+	Version: "0011",
+	Name:    "keyset_indexes",
+	Up: func(tx *gorm.DB) error {
+		return createIndexes(tx, keysetIndexList...)
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropIndexes(tx, keysetIndexList...)
+	},
+}
@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// 搜索 n-gram 索引，已有数据由服务启动时的 Backfill 或 search reindex 命令生成
+var searchGrams = Migration{
+	Version: "0012",
+	Name:    "search_grams",
+	Up: func(tx *gorm.DB) error {
+		return createTables(tx, &searchGram{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropTables(tx, &searchGram{})
+	},
+}
+
+type searchGram struct {
+	Gram       string `gorm:"type:varchar(12);primaryKey"`
+	EntityType string `gorm:"type:varchar(16);primaryKey;index:idx_search_grams_entity,priority:1"`
+	EntityID   int64  `gorm:"primaryKey;autoIncrement:false;index:idx_search_grams_entity,priority:2"`
+}
+
+func (searchGram) TableName() string { return "search_grams" }
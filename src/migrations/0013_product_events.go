@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var traceIndexList = []index{
+	// 按批次、托盘反查产品
+	{table: "products", name: "idx_products_model_batch", columns: "product_model_id, batch_number"},
+	{table: "products", name: "idx_products_pallet", columns: "pallet_id"},
+}
+
+// 产品事件日志和追溯查询的索引
+var productEvents = Migration{
+	Version: "0013",
+	Name:    "product_events",
+	Up: func(tx *gorm.DB) error {
+		if err := createTables(tx, &productEvent{}); err != nil {
+			return err
+		}
+		return createIndexes(tx, traceIndexList...)
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := dropIndexes(tx, traceIndexList...); err != nil {
+			return err
+		}
+		return dropTables(tx, &productEvent{})
+	},
+}
+
+type productEvent struct {
+	ID        int64  `gorm:"primary_key"`
+	ProductID int64  `gorm:"index"`
+	SN        string `gorm:"type:char(32);index"`
+	Type      string `gorm:"type:varchar(16)"`
+	Changes   string `gorm:"type:text"` // JSON 格式的字段变化
+	CreatedAt time.Time
+}
+
+func (productEvent) TableName() string { return "product_events" }
@@ -0,0 +1,52 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 批次及其判定历史，并由已有产品记录生成批次
+var batches = Migration{
+	Version: "0014",
+	Name:    "batches",
+	Up: func(tx *gorm.DB) error {
+		if err := createTables(tx, &batchesBatch{}, &batchDisposition{}); err != nil {
+			return err
+		}
+		return tx.Exec(`INSERT INTO batches (product_model_id, batch_number, status, created_at, updated_at)
+			SELECT product_model_id, batch_number, 'open', MIN(created_at), MIN(created_at) FROM products
+			WHERE product_model_id IS NOT NULL AND batch_number <> '' AND deleted_at IS NULL
+			GROUP BY product_model_id, batch_number`).Error
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropTables(tx, &batchesBatch{}, &batchDisposition{})
+	},
+}
+
+type batchesBatch struct {
+	ID             int64 `gorm:"primary_key"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      gorm.DeletedAt       `gorm:"index"`
+	ProductModelID uint                 `gorm:"not null;uniqueIndex:idx_batches_model_number,priority:1"`
+	ProductModel   *initialProductModel `gorm:"foreignKey:ProductModelID"`
+	BatchNumber    string               `gorm:"type:char(8);not null;uniqueIndex:idx_batches_model_number,priority:2"`
+	Status         string               `gorm:"type:varchar(16);not null;default:open;index"`
+	Disposition    string               `gorm:"type:varchar(16)"`
+}
+
+func (batchesBatch) TableName() string { return "batches" }
+
+type batchDisposition struct {
+	ID         int64  `gorm:"primary_key"`
+	BatchID    int64  `gorm:"index"`
+	Action     string `gorm:"type:varchar(16)"`
+	FromStatus string `gorm:"type:varchar(16)"`
+	ToStatus   string `gorm:"type:varchar(16)"`
+	Note       string `gorm:"type:text"`
+	Operator   string `gorm:"type:varchar(64)"`
+	CreatedAt  time.Time
+}
+
+func (batchDisposition) TableName() string { return "batch_dispositions" }
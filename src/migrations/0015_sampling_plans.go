@@ -0,0 +1,64 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 来料抽样方案及批次的抽样进度
+var samplingPlans = Migration{
+	Version: "0015",
+	Name:    "sampling_plans",
+	Up: func(tx *gorm.DB) error {
+		return createTables(tx, &samplingPlan{}, &batchSampling{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropTables(tx, &samplingPlan{}, &batchSampling{})
+	},
+}
+
+type samplingPlan struct {
+	ID                  int64 `gorm:"primary_key"`
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	DeletedAt           gorm.DeletedAt       `gorm:"index"`
+	Name                string               `gorm:"type:varchar(128)"`
+	SupplierID          *uint                `gorm:"index"`
+	Supplier            *initialSupplier     `gorm:"foreignKey:SupplierID"`
+	ProductModelID      *uint                `gorm:"index"`
+	ProductModel        *initialProductModel `gorm:"foreignKey:ProductModelID"`
+	InspectionLevel     string               `gorm:"type:varchar(4)"`
+	AQL                 float64              `gorm:"type:decimal(6,3)"`
+	AllowReduced        *bool                `gorm:"not null;default:true"`
+	Enabled             *bool                `gorm:"not null;default:true"`
+	Severity            string               `gorm:"type:varchar(16);not null;default:normal"`
+	SwitchingScore      int
+	RecentResults       string `gorm:"type:varchar(8)"`
+	ConsecutiveAccepted int
+	TightenedRejections int
+}
+
+func (samplingPlan) TableName() string { return "sampling_plans" }
+
+type batchSampling struct {
+	ID         int64         `gorm:"primary_key"`
+	BatchID    int64         `gorm:"uniqueIndex"`
+	Batch      *batchesBatch `gorm:"foreignKey:BatchID"`
+	PlanID     int64         `gorm:"index"`
+	Plan       *samplingPlan `gorm:"foreignKey:PlanID"`
+	Severity   string        `gorm:"type:varchar(16)"`
+	LotSize    int
+	CodeLetter string `gorm:"type:varchar(2)"`
+	SampleSize int
+	Ac         int
+	Re         int
+	Inspected  int
+	Defects    int
+	Result     string `gorm:"type:varchar(16);not null;default:pending"`
+	DecidedAt  *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (batchSampling) TableName() string { return "batch_samplings" }
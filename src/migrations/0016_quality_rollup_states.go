@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 预聚合的校验结果和生成时的工厂时区
+var qualityRollupStates = Migration{
+	Version: "0016",
+	Name:    "quality_rollup_states",
+	Up: func(tx *gorm.DB) error {
+		return createTables(tx, &qualityRollupStaleRange{}, &qualityRollupState{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropTables(tx, &qualityRollupStaleRange{}, &qualityRollupState{})
+	},
+}
+
+type qualityRollupStaleRange struct {
+	ID        int64     `gorm:"primary_key"`
+	StartAt   time.Time `gorm:"index"`
+	EndAt     time.Time
+	CreatedAt time.Time
+}
+
+func (qualityRollupStaleRange) TableName() string { return "quality_rollup_stale_ranges" }
+
+type qualityRollupState struct {
+	ID            int64  `gorm:"primary_key"`
+	PlantTimezone string `gorm:"type:varchar(64)"`
+	UpdatedAt     time.Time
+}
+
+func (qualityRollupState) TableName() string { return "quality_rollup_states" }
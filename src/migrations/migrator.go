@@ -0,0 +1,199 @@
+package migrations
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration 一次版本化的结构或数据变更，Version 按字典序递增（例如 0001）
+type Migration struct {
+	Version string
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// MigrationRecord 对应 'migrations' 历史表，记录已执行的版本
+type MigrationRecord struct {
+	Version   string    `gorm:"type:varchar(32);primaryKey" json:"version"`
+	Name      string    `gorm:"type:varchar(128)" json:"name"`
+	AppliedAt time.Time `json:"appliedAt"`
+}
+
+func (MigrationRecord) TableName() string {
+	return "migrations"
+}
+
+type MigrationStatus struct {
+	Version   string     `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+}
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// 使用全部已注册的迁移创建 Migrator
+func New(db *gorm.DB) *Migrator {
+	return NewWithMigrations(db, All())
+}
+
+func NewWithMigrations(db *gorm.DB, migrations []Migration) *Migrator {
+	sorted := append([]Migration{}, migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	return &Migrator{db: db, migrations: sorted}
+}
+
+func (m *Migrator) ensureHistoryTable() error {
+	return m.db.AutoMigrate(&MigrationRecord{})
+}
+
+func (m *Migrator) applied() (map[string]MigrationRecord, error) {
+	if err := m.ensureHistoryTable(); err != nil {
+		return nil, fmt.Errorf("failed to create migrations table: %w", err)
+	}
+	var records []MigrationRecord
+	if err := m.db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[string]MigrationRecord, len(records))
+	for _, record := range records {
+		result[record.Version] = record
+	}
+	return result, nil
+}
+
+// 全部迁移及其执行状态，按版本排序
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if record, exists := applied[migration.Version]; exists {
+			status.Applied = true
+			appliedAt := record.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// 尚未执行的迁移
+func (m *Migrator) Pending() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, exists := applied[migration.Version]; !exists {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// 按版本顺序执行全部未执行的迁移
+func (m *Migrator) Up() error {
+	pending, err := m.Pending()
+	if err != nil {
+		return err
+	}
+	for _, migration := range pending {
+		if err := m.apply(migration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 回滚最近执行的一个迁移
+func (m *Migrator) Down() error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		if _, exists := applied[m.migrations[i].Version]; exists {
+			return m.revert(m.migrations[i])
+		}
+	}
+	return fmt.Errorf("no migration to roll back")
+}
+
+// 迁移到指定版本：执行不高于该版本的未执行迁移，回滚高于该版本的已执行迁移
+// version 为 0 表示回滚全部迁移
+func (m *Migrator) To(version string) error {
+	if version != "0" && m.find(version) == nil {
+		return fmt.Errorf("unknown migration version %q", version)
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, exists := applied[migration.Version]; exists && migration.Version > version {
+			if err := m.revert(migration); err != nil {
+				return err
+			}
+		}
+	}
+	for _, migration := range m.migrations {
+		if _, exists := applied[migration.Version]; !exists && migration.Version <= version {
+			if err := m.apply(migration); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) find(version string) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) apply(migration Migration) error {
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if err := migration.Up(tx); err != nil {
+			return err
+		}
+		return tx.Create(&MigrationRecord{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %s_%s failed: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+func (m *Migrator) revert(migration Migration) error {
+	if migration.Down == nil {
+		return fmt.Errorf("migration %s_%s can not be rolled back", migration.Version, migration.Name)
+	}
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if err := migration.Down(tx); err != nil {
+			return err
+		}
+		return tx.Delete(&MigrationRecord{Version: migration.Version}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("rollback of migration %s_%s failed: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package migrations
+
+import (
+	"strings"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/databases"
+	"gorm.io/gorm"
+)
+
+// 全部迁移，新增迁移时追加到末尾
+func All() []Migration {
+	return []Migration{
+		initialSchema,
+		productIndexes,
+		productModelIndexes,
+		palletAndPlanIndexes,
+		shiftCalendar,
+		qualityRollups,
+		dropProductionPlanLegacyColumns,
+		supplierTypeVarchar,
+		jobs,
+		reportSchedules,
+		keysetIndexes,
+		searchGrams,
+		productEvents,
+		batches,
+		samplingPlans,
+		qualityRollupStates,
+	}
+}
+
+// 建表选项由数据库方言决定（MySQL 为 InnoDB 引擎 + utf8mb4 字符集）
+func createTables(tx *gorm.DB, models ...interface{}) error {
+	if options := databases.DialectOf(tx).TableOptions(); options != "" {
+		tx = tx.Set("gorm:table_options", options)
+	}
+	for _, model := range models {
+		if err := tx.AutoMigrate(model); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 按与创建相反的顺序删除表
+func dropTables(tx *gorm.DB, models ...interface{}) error {
+	for i := len(models) - 1; i >= 0; i-- {
+		if err := tx.Migrator().DropTable(models[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 索引定义，columns 为建索引语句中的列部分，例如 "created_at, has_defect"
+// prefixes 为长文本列的前缀长度，仅 MySQL 使用
+type index struct {
+	table    string
+	name     string
+	columns  string
+	prefixes map[string]int
+}
+
+func (idx index) columnList(dialect databases.Dialect) string {
+	columns := strings.Split(idx.columns, ",")
+	for i, column := range columns {
+		column = strings.TrimSpace(column)
+		columns[i] = dialect.IndexColumn(column, idx.prefixes[column])
+	}
+	return strings.Join(columns, ", ")
+}
+
+// 创建索引，已存在的索引跳过（兼容曾手动执行 create_idx.sql 的数据库）
+func createIndexes(tx *gorm.DB, indexes ...index) error {
+	dialect := databases.DialectOf(tx)
+	for _, idx := range indexes {
+		if tx.Migrator().HasIndex(idx.table, idx.name) {
+			continue
+		}
+		if err := tx.Exec("CREATE INDEX " + idx.name + " ON " + idx.table + "(" + idx.columnList(dialect) + ")").Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dropIndexes(tx *gorm.DB, indexes ...index) error {
+	for _, idx := range indexes {
+		if !tx.Migrator().HasIndex(idx.table, idx.name) {
+			continue
+		}
+		if err := tx.Migrator().DropIndex(idx.table, idx.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
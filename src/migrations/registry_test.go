@@ -0,0 +1,36 @@
+package migrations_test
+
+import (
+	"testing"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/testutil"
+)
+
+// 迁移使用各自的表结构快照，模型新增的列须有对应的迁移
+func TestMigrationsCoverModelColumns(t *testing.T) {
+	db := testutil.NewDB(t)
+	for _, model := range []interface{}{
+		&models.Supplier{}, &models.ProductModel{}, &models.ProductLine{}, &models.Pallet{},
+		&models.ProductionPlan{}, &models.Product{}, &models.User{}, &models.API{},
+		&models.Shift{}, &models.Holiday{}, &models.QualityHourlyRollup{}, &models.QualityDailyRollup{},
+		&models.Job{}, &models.ReportSchedule{}, &models.ReportRun{}, &models.SearchGram{},
+		&models.ProductEvent{}, &models.Batch{}, &models.BatchDisposition{},
+		&models.SamplingPlan{}, &models.BatchSampling{},
+		&models.QualityRollupStaleRange{}, &models.QualityRollupState{},
+	} {
+		stmt := db.Model(model).Statement
+		if err := stmt.Parse(model); err != nil {
+			t.Fatal(err)
+		}
+		if !db.Migrator().HasTable(stmt.Schema.Table) {
+			t.Errorf("table %s not created by migrations", stmt.Schema.Table)
+			continue
+		}
+		for _, column := range stmt.Schema.DBNames {
+			if !db.Migrator().HasColumn(model, column) {
+				t.Errorf("column %s.%s not created by migrations", stmt.Schema.Table, column)
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// 批次状态
+const (
+	BatchStatusOpen     = "open"     // 未判定
+	BatchStatusOnHold   = "on_hold"  // 冻结
+	BatchStatusSorting  = "sorting"  // 挑选中
+	BatchStatusAccepted = "accepted" // 放行（含让步接收）
+	BatchStatusRejected = "rejected" // 判退
+)
+
+// 批次操作：冻结、解冻和质量判定
+const (
+	BatchActionHold       = "hold"
+	BatchActionRelease    = "release"
+	BatchActionAccept     = "accept"
+	BatchActionReject     = "reject"
+	BatchActionSort       = "sort"
+	BatchActionConcession = "concession" // 让步接收
+)
+
+// 质量判定
+var BatchDispositions = []string{BatchActionAccept, BatchActionReject, BatchActionSort, BatchActionConcession}
+
+func IsBatchDisposition(action string) bool {
+	return containsString(BatchDispositions, action)
+}
+
+// 各操作允许的原状态和目标状态，解冻恢复为冻结前的状态
+var batchTransitions = map[string]struct {
+	from []string
+	to   string
+}{
+	BatchActionHold:       {[]string{BatchStatusOpen, BatchStatusSorting, BatchStatusAccepted}, BatchStatusOnHold},
+	BatchActionRelease:    {[]string{BatchStatusOnHold}, ""},
+	BatchActionSort:       {[]string{BatchStatusOpen, BatchStatusOnHold, BatchStatusRejected}, BatchStatusSorting},
+	BatchActionAccept:     {[]string{BatchStatusOpen, BatchStatusOnHold, BatchStatusSorting}, BatchStatusAccepted},
+	BatchActionConcession: {[]string{BatchStatusOpen, BatchStatusOnHold, BatchStatusSorting, BatchStatusRejected}, BatchStatusAccepted},
+	BatchActionReject:     {[]string{BatchStatusOpen, BatchStatusOnHold, BatchStatusSorting, BatchStatusAccepted}, BatchStatusRejected},
+}
+
+// 操作后的状态，release 返回空字符串（由调用方恢复冻结前的状态）；操作不允许时返回错误
+func NextBatchStatus(status, action string) (string, error) {
+	transition, ok := batchTransitions[action]
+	if !ok {
+		return "", fmt.Errorf("invalid batch action %q", action)
+	}
+	if !containsString(transition.from, status) {
+		return "", fmt.Errorf("cannot %s a batch in status %s", action, status)
+	}
+	return transition.to, nil
+}
+
+// Batch 对应 'batches' 表，物料 + 批次号唯一；批次号为产品 SN 的第 8~11 位，由产线上报产品时自动创建
+type Batch struct {
+	ModelFields    `s2m:"-"`
+	ProductModelID uint          `gorm:"not null;uniqueIndex:idx_batches_model_number,priority:1" json:"productModelId" binding:"required"`
+	ProductModel   *ProductModel `gorm:"foreignKey:ProductModelID" json:"productModel,omitempty" s2m:"-"`
+	BatchNumber    string        `gorm:"type:char(8);not null;uniqueIndex:idx_batches_model_number,priority:2" json:"batchNumber" binding:"required"`
+	Status         string        `gorm:"type:varchar(16);not null;default:open;index" json:"status"`
+	Disposition    string        `gorm:"type:varchar(16)" json:"disposition,omitempty"` // 最近一次质量判定
+	Summary        *BatchSummary `gorm:"-" json:"summary,omitempty"`
+}
+
+// 冻结或判退的批次不应继续投产
+func (b *Batch) Blocked() bool {
+	return b.Status == BatchStatusOnHold || b.Status == BatchStatusRejected
+}
+
+// 批次的检测汇总，由产品记录统计
+type BatchSummary struct {
+	Inspected        int              `json:"inspected"`
+	Defects          int              `json:"defects"`
+	DefectRate       float64          `json:"defectRate"`                 // 百分比
+	FirstInspectedAt *time.Time       `json:"firstInspectedAt,omitempty"` // 以下仅批次详情返回
+	LastInspectedAt  *time.Time       `json:"lastInspectedAt,omitempty"`
+	DefectReasons    []DefectTypeItem `json:"defectReasons,omitempty"`
+}
+
+// BatchDisposition 对应 'batch_dispositions' 表，批次状态变更的历史
+type BatchDisposition struct {
+	ID         int64     `gorm:"primary_key" json:"id"`
+	BatchID    int64     `gorm:"index" json:"batchId"`
+	Action     string    `gorm:"type:varchar(16)" json:"action"`
+	FromStatus string    `gorm:"type:varchar(16)" json:"fromStatus"`
+	ToStatus   string    `gorm:"type:varchar(16)" json:"toStatus"`
+	Note       string    `gorm:"type:text" json:"note,omitempty"`
+	Operator   string    `gorm:"type:varchar(64)" json:"operator,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// 批次操作的请求参数，Disposition 仅用于质量判定接口
+type BatchActionForm struct {
+	Disposition string `json:"disposition"`
+	Note        string `json:"note"`
+}
+
+// 产线上报时冻结批次的处理方式
+const (
+	BatchHoldWarn   = "warn"   // 照常接收并在响应中返回 warnings
+	BatchHoldReject = "reject" // 拒绝上报，返回 409
+)
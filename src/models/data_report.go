@@ -8,11 +8,19 @@ type DefectReportQuery struct {
 	EndDate        string `form:"endDate" json:"endDate"`
 	SupplierID     *uint  `form:"supplierId" json:"supplierId"`
 	ProductModelSN string `form:"productModelSN" json:"productModelSN"`
+	BucketBy       string `form:"bucketBy" json:"bucketBy"` // calendar（默认）/production
+	TZ             string `form:"tz" json:"tz"`             // 时区，例如 Asia/Shanghai，默认工厂时区
 	PageNum        int    `form:"pageNum" json:"page"`
-	PageSize       int    `form:"pageSize" json:"pageSize"` // 移除最大值限制，允许-1表示导出全部
+	PageSize       int    `form:"pageSize" json:"pageSize"`   // 移除最大值限制，允许-1表示导出全部
+	Sort           string `form:"sort" json:"sort"`           // qualityDate（默认）/productSN/productModelSN/supplierName/batchNumber，前缀 - 表示降序
+	Cursor         string `form:"cursor" json:"cursor"`       // 游标分页，上一页返回的 nextCursor
+	Keyset         bool   `form:"keyset" json:"keyset"`       // 使用游标分页
+	WithTotal      bool   `form:"withTotal" json:"withTotal"` // 游标分页时是否统计总数
+	ComparisonQuery
 }
 
 type DefectReportItem struct {
+	ID             int64     `json:"-"`
 	SupplierName   string    `json:"supplierName"`
 	QualityDate    time.Time `json:"qualityDate"`
 	ProductSN      string    `json:"productSN"`
@@ -20,11 +28,15 @@ type DefectReportItem struct {
 	BatchNumber    string    `json:"batchNumber"`
 	DefectReason   string    `json:"defectReason"`
 	Description    string    `json:"description"`
+	ProductLineID  *uint     `json:"-"`
+	ProductionDay  string    `json:"productionDay,omitempty"` // 生产日（bucketBy=production 时返回）
+	Shift          string    `json:"shift,omitempty"`         // 班次（bucketBy=production 时返回）
 }
 
 type DefectReportResponse struct {
 	Items      []DefectReportItem `json:"items"`
 	Pagination PaginationResult   `json:"pagination"`
+	Comparison *QualityComparison `json:"comparison,omitempty"`
 }
 
 // 检测报表查询相关结构体
@@ -34,8 +46,11 @@ type InspectionReportQuery struct {
 	SupplierName   string `form:"supplierName" json:"supplierName"`     // 生产厂家
 	StartDate      string `form:"startDate" json:"startDate"`           // 开始日期
 	EndDate        string `form:"endDate" json:"endDate"`               // 结束日期
+	BucketBy       string `form:"bucketBy" json:"bucketBy"`             // calendar（默认）/production
+	TZ             string `form:"tz" json:"tz"`                         // 时区，例如 Asia/Shanghai，默认工厂时区
 	PageNum        int    `form:"pageNum" json:"page"`                  // 页码
 	PageSize       int    `form:"pageSize" json:"pageSize"`             // 页大小，-1表示导出全部
+	ComparisonQuery
 }
 
 type InspectionReportItem struct {
@@ -53,6 +68,7 @@ type InspectionReportItem struct {
 type InspectionReportResponse struct {
 	Items      []InspectionReportItem `json:"items"`
 	Pagination PaginationResult       `json:"pagination"`
+	Comparison *QualityComparison     `json:"comparison,omitempty"`
 }
 
 // 检测费用报表查询相关结构体
@@ -62,8 +78,11 @@ type CostReportQuery struct {
 	MotorType      string `form:"motorType" json:"motorType"`           // 电机类型(ProductModel.Description)
 	StartDate      string `form:"startDate" json:"startDate"`           // 开始日期
 	EndDate        string `form:"endDate" json:"endDate"`               // 结束日期
+	BucketBy       string `form:"bucketBy" json:"bucketBy"`             // calendar（默认）/production
+	TZ             string `form:"tz" json:"tz"`                         // 时区，例如 Asia/Shanghai，默认工厂时区
 	PageNum        int    `form:"pageNum" json:"page"`                  // 页码
 	PageSize       int    `form:"pageSize" json:"pageSize"`             // 页大小，-1表示导出全部
+	ComparisonQuery
 }
 
 type CostReportItem struct {
@@ -77,6 +96,7 @@ type CostReportItem struct {
 }
 
 type CostReportResponse struct {
-	Items      []CostReportItem `json:"items"`
-	Pagination PaginationResult `json:"pagination"`
+	Items      []CostReportItem   `json:"items"`
+	Pagination PaginationResult   `json:"pagination"`
+	Comparison *QualityComparison `json:"comparison,omitempty"`
 }
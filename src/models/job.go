@@ -0,0 +1,66 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// 后台任务类型
+const (
+	JobKindRecordExport  = "record_export"  // 原始记录导出
+	JobKindRollupRebuild = "rollup_rebuild" // 重建质量预聚合
+	JobKindReport        = "report"         // 生成并投递定时报表
+	JobKindSearchReindex = "search_reindex" // 重建搜索索引
+)
+
+// 后台任务状态
+const (
+	JobStatusQueued    = "queued"    // 等待执行（含等待重试）
+	JobStatusRunning   = "running"   // 执行中
+	JobStatusCompleted = "completed" // 执行成功
+	JobStatusFailed    = "failed"    // 重试次数用尽或不可重试的错误
+	JobStatusCanceled  = "canceled"  // 已取消
+)
+
+// Job 对应 'jobs' 表，由服务进程内的工作协程领取执行
+// RunAt 为最早可执行时间，失败重试时按退避时间推后；HeartbeatAt 为执行中的工作协程最后一次上报的时间
+type Job struct {
+	ID              int64      `gorm:"primary_key" json:"id"`
+	Kind            string     `gorm:"type:varchar(64);index" json:"kind"`
+	Payload         string     `gorm:"type:text" json:"payload"` // JSON 格式的任务参数
+	Status          string     `gorm:"type:varchar(16);index:idx_job_status_run_at,priority:1" json:"status"`
+	RunAt           time.Time  `gorm:"index:idx_job_status_run_at,priority:2" json:"runAt"`
+	Attempts        int        `json:"attempts"`
+	MaxAttempts     int        `json:"maxAttempts"`
+	Done            int64      `json:"done"`  // 已处理的数量
+	Total           int64      `json:"total"` // 总数，0 表示未知
+	Error           string     `gorm:"type:text" json:"error,omitempty"`
+	CancelRequested bool       `json:"cancelRequested"`
+	WorkerID        string     `gorm:"type:varchar(64)" json:"workerId,omitempty"`
+	HeartbeatAt     *time.Time `json:"heartbeatAt,omitempty"`
+	StartedAt       *time.Time `json:"startedAt,omitempty"`
+	FinishedAt      *time.Time `json:"finishedAt,omitempty"`
+	ArtifactPath    string     `gorm:"type:varchar(255)" json:"-"` // 产物在导出目录下的文件名
+	ArtifactName    string     `gorm:"type:varchar(255)" json:"artifactName,omitempty"`
+	ArtifactSize    int64      `json:"artifactSize,omitempty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+}
+
+func (j *Job) Finished() bool {
+	return j.Status == JobStatusCompleted || j.Status == JobStatusFailed || j.Status == JobStatusCanceled
+}
+
+// 任务列表查询
+type JobQuery struct {
+	Status string `form:"status"`
+	Kind   string `form:"kind"`
+}
+
+func (q *JobQuery) Validate() error {
+	switch q.Status {
+	case "", JobStatusQueued, JobStatusRunning, JobStatusCompleted, JobStatusFailed, JobStatusCanceled:
+		return nil
+	}
+	return fmt.Errorf("invalid status %q", q.Status)
+}
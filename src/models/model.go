@@ -13,14 +13,23 @@ type ModelFields struct {
 	DeletedAt gorm.DeletedAt `json:"deletedAt,omitempty" gorm:"index"`
 }
 
+// 未指定 pageSize 时的默认页大小（指定了 pageNum 或使用游标分页时）
+const DefaultPageSize = 20
+
 type PaginationQuery struct {
-	PageNum  int  `form:"pageNum" json:"pageNum"`
-	PageSize int  `form:"pageSize" json:"pageSize"`
-	Asc      bool `form:"asc" json:"asc"`
+	PageNum   int    `form:"pageNum" json:"pageNum"`
+	PageSize  int    `form:"pageSize" json:"pageSize"`
+	Asc       bool   `form:"asc" json:"asc"`
+	Filter    string `form:"filter" json:"filter"`       // 筛选条件，例如 hasDefect:eq:true;createdAt:gte:2025-01-01
+	Sort      string `form:"sort" json:"sort"`           // 排序字段，前缀 - 表示降序；为空时按创建时间排序
+	Cursor    string `form:"cursor" json:"cursor"`       // 上一页返回的 nextCursor
+	Keyset    bool   `form:"keyset" json:"keyset"`       // 使用游标分页（传入 cursor 时默认开启）
+	WithTotal bool   `form:"withTotal" json:"withTotal"` // 游标分页时是否统计总数
 }
 
 type PaginationResult struct {
-	Total    int `form:"total" json:"total"`
-	PageNum  int `form:"pageNum" json:"pageNum"`
-	PageSize int `form:"pageSize" json:"pageSize"`
+	Total      int    `form:"total" json:"total"` // 游标分页未统计总数时为 -1
+	PageNum    int    `form:"pageNum" json:"pageNum"`
+	PageSize   int    `form:"pageSize" json:"pageSize"`
+	NextCursor string `form:"nextCursor" json:"nextCursor,omitempty"` // 游标分页时下一页的游标，为空表示没有下一页
 }
@@ -1,5 +1,7 @@
 package models
 
+import "fmt"
+
 // Product 对应 'Product' 表
 type Product struct {
 	ModelFields      `s2m:"-"`
@@ -21,6 +23,37 @@ type Product struct {
 type QualityStatsQuery struct {
 	StartDate string `form:"startDate" json:"startDate" binding:"required"`
 	EndDate   string `form:"endDate" json:"endDate" binding:"required"`
+	BucketBy  string `form:"bucketBy" json:"bucketBy"` // calendar（默认）/production
+	TZ        string `form:"tz" json:"tz"`             // 时区，默认工厂时区
+	Fill      string `form:"fill" json:"fill"`         // 趋势中无数据日期的填充方式：zero（默认）/null
+	ComparisonQuery
+}
+
+// 趋势序列的填充方式
+const (
+	FillZero = "zero"
+	FillNull = "null"
+)
+
+// 质量统计选项，由 QualityStatsQuery 解析而来
+type QualityStatsOptions struct {
+	BucketBy   string
+	Fill       string
+	Comparison ComparisonQuery
+}
+
+func (q *QualityStatsQuery) ToOptions() (QualityStatsOptions, error) {
+	options := QualityStatsOptions{BucketBy: q.BucketBy, Fill: q.Fill, Comparison: q.ComparisonQuery}
+	if err := options.Comparison.Validate(); err != nil {
+		return options, err
+	}
+	if options.Fill == "" {
+		options.Fill = FillZero
+	}
+	if options.Fill != FillZero && options.Fill != FillNull {
+		return options, fmt.Errorf("invalid fill %q, expected %s or %s", options.Fill, FillZero, FillNull)
+	}
+	return options, nil
 }
 
 type QualityStatsResponse struct {
@@ -28,6 +61,7 @@ type QualityStatsResponse struct {
 	DefectTypeDistribution []DefectTypeItem      `json:"defectTypeDistribution"`
 	SupplierDefectTrend    []SupplierDefectTrend `json:"supplierDefectTrend"`
 	DefectTrendByType      DefectTrendByType     `json:"defectTrendByType"`
+	Comparison             *QualityComparison    `json:"comparison,omitempty"` // compare 参数不为空时返回
 }
 
 type QualityRateStats struct {
@@ -44,15 +78,17 @@ type DefectTypeItem struct {
 }
 
 type SupplierDefectTrend struct {
+	SupplierID   uint              `json:"supplierId"`
 	SupplierName string            `json:"supplierName"`
 	DailyData    []DailyDefectRate `json:"dailyData"`
 }
 
+// 无数据的日期在 fill=null 时各数值字段为 null
 type DailyDefectRate struct {
-	Date        string  `json:"date"`
-	DefectRate  float64 `json:"defectRate"`
-	TotalCount  int     `json:"totalCount"`
-	DefectCount int     `json:"defectCount"`
+	Date        string   `json:"date"`
+	DefectRate  *float64 `json:"defectRate"`
+	TotalCount  *int     `json:"totalCount"`
+	DefectCount *int     `json:"defectCount"`
 }
 
 type DefectTrendByType struct {
@@ -64,5 +100,5 @@ type DefectTrendByType struct {
 
 type DailyDefectCount struct {
 	Date  string `json:"date"`
-	Count int    `json:"count"`
+	Count *int   `json:"count"`
 }
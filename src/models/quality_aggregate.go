@@ -0,0 +1,148 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 聚合时间粒度
+const (
+	GranularityNone  = ""
+	GranularityHour  = "hour"
+	GranularityShift = "shift"
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+)
+
+// 聚合维度
+const (
+	DimensionSupplier     = "supplier"
+	DimensionSupplierID   = "supplier_id" // 供应商ID，未关联供应商时为空
+	DimensionSupplierType = "supplier_type"
+	DimensionProductLine  = "product_line"
+	DimensionProductModel = "product_model"
+	DimensionBatchNumber  = "batch_number"
+	DimensionDefectReason = "defect_reason"
+)
+
+// 聚合指标
+const (
+	MeasureTotalCount     = "total_count"
+	MeasureDefectCount    = "defect_count"
+	MeasureQualifiedCount = "qualified_count"
+	MeasureDefectRate     = "defect_rate"
+	MeasureQualityRate    = "quality_rate"
+)
+
+var AggregateGranularities = []string{GranularityNone, GranularityHour, GranularityShift, GranularityDay, GranularityWeek, GranularityMonth}
+
+var AggregateDimensions = []string{DimensionSupplier, DimensionSupplierID, DimensionSupplierType, DimensionProductLine, DimensionProductModel, DimensionBatchNumber, DimensionDefectReason}
+
+var AggregateMeasures = []string{MeasureTotalCount, MeasureDefectCount, MeasureQualifiedCount, MeasureDefectRate, MeasureQualityRate}
+
+// 通用聚合查询参数（dimensions、measures 以逗号分隔）
+type QualityAggregateQuery struct {
+	StartDate   string `form:"startDate" json:"startDate" binding:"required"`
+	EndDate     string `form:"endDate" json:"endDate" binding:"required"`
+	Granularity string `form:"granularity" json:"granularity"` // hour/shift/day/week/month，为空表示不按时间分桶
+	Dimensions  string `form:"dimensions" json:"dimensions"`   // 例如 supplier,product_line
+	Measures    string `form:"measures" json:"measures"`       // 为空表示返回全部指标
+	BucketBy    string `form:"bucketBy" json:"bucketBy"`       // calendar（默认）/production
+	TZ          string `form:"tz" json:"tz"`                   // 时区，默认工厂时区
+	Source      string `form:"source" json:"source"`           // rollup（默认，读取预聚合表）/raw（读取原始数据）
+}
+
+// 聚合规格，由 QualityAggregateQuery 解析而来
+type AggregateSpec struct {
+	Granularity string
+	BucketBy    string
+	Dimensions  []string
+	Measures    []string
+	Source      string
+}
+
+func (q *QualityAggregateQuery) ToSpec() AggregateSpec {
+	return AggregateSpec{
+		Granularity: strings.TrimSpace(q.Granularity),
+		BucketBy:    strings.TrimSpace(q.BucketBy),
+		Dimensions:  splitList(q.Dimensions),
+		Measures:    splitList(q.Measures),
+		Source:      strings.TrimSpace(q.Source),
+	}
+}
+
+// 校验粒度、维度和指标名称，未指定指标时补全为全部指标
+func (spec *AggregateSpec) Validate() error {
+	if !containsString(AggregateGranularities, spec.Granularity) {
+		return fmt.Errorf("invalid granularity %q, expected one of %s", spec.Granularity, strings.Join(AggregateGranularities[1:], ", "))
+	}
+
+	if spec.BucketBy == "" {
+		spec.BucketBy = BucketByCalendar
+	}
+	if spec.BucketBy != BucketByCalendar && spec.BucketBy != BucketByProduction {
+		return fmt.Errorf("invalid bucketBy %q, expected %s or %s", spec.BucketBy, BucketByCalendar, BucketByProduction)
+	}
+
+	if spec.Source == "" {
+		spec.Source = AggregateSourceRollup
+	}
+	if spec.Source != AggregateSourceRollup && spec.Source != AggregateSourceRaw {
+		return fmt.Errorf("invalid source %q, expected %s or %s", spec.Source, AggregateSourceRollup, AggregateSourceRaw)
+	}
+
+	seen := make(map[string]bool)
+	for _, dimension := range spec.Dimensions {
+		if !containsString(AggregateDimensions, dimension) {
+			return fmt.Errorf("invalid dimension %q, expected one of %s", dimension, strings.Join(AggregateDimensions, ", "))
+		}
+		if seen[dimension] {
+			return fmt.Errorf("duplicate dimension %q", dimension)
+		}
+		seen[dimension] = true
+	}
+
+	if len(spec.Measures) == 0 {
+		spec.Measures = append([]string{}, AggregateMeasures...)
+	}
+	for _, measure := range spec.Measures {
+		if !containsString(AggregateMeasures, measure) {
+			return fmt.Errorf("invalid measure %q, expected one of %s", measure, strings.Join(AggregateMeasures, ", "))
+		}
+	}
+	return nil
+}
+
+type QualityAggregateRow struct {
+	Bucket     string             `json:"bucket,omitempty"`
+	Dimensions map[string]string  `json:"dimensions"`
+	Measures   map[string]float64 `json:"measures"`
+}
+
+type QualityAggregateResponse struct {
+	Granularity string                `json:"granularity"`
+	BucketBy    string                `json:"bucketBy"`
+	Dimensions  []string              `json:"dimensions"`
+	Measures    []string              `json:"measures"`
+	Rows        []QualityAggregateRow `json:"rows"`
+}
+
+func splitList(s string) []string {
+	var result []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// 对比模式
+const (
+	CompareNone     = ""
+	ComparePrevious = "previous"  // 紧邻的上一个等长周期
+	CompareLastYear = "last_year" // 去年同期
+	CompareCustom   = "custom"    // 自定义基准区间
+)
+
+// 对比查询参数，嵌入质量统计和各报表的查询参数中
+type ComparisonQuery struct {
+	Compare           string `form:"compare" json:"compare"`                     // previous/last_year/custom，为空表示不对比
+	BaselineStartDate string `form:"baselineStartDate" json:"baselineStartDate"` // compare=custom 时必填，YYYY-MM-DD
+	BaselineEndDate   string `form:"baselineEndDate" json:"baselineEndDate"`     // compare=custom 时必填，YYYY-MM-DD
+}
+
+func (q *ComparisonQuery) Validate() error {
+	switch q.Compare {
+	case CompareNone, ComparePrevious, CompareLastYear:
+		return nil
+	case CompareCustom:
+		if q.BaselineStartDate == "" || q.BaselineEndDate == "" {
+			return fmt.Errorf("baselineStartDate and baselineEndDate are required when compare=custom")
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid compare %q, expected %s, %s or %s", q.Compare, ComparePrevious, CompareLastYear, CompareCustom)
+}
+
+// 根据当前区间 [start, end) 计算基准区间 [baselineStart, baselineEnd)
+func (q *ComparisonQuery) BaselineRange(start, end time.Time) (time.Time, time.Time, error) {
+	switch q.Compare {
+	case ComparePrevious:
+		days := 0
+		for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+			days++
+		}
+		return start.AddDate(0, 0, -days), start, nil
+	case CompareLastYear:
+		return start.AddDate(-1, 0, 0), end.AddDate(-1, 0, 0), nil
+	case CompareCustom:
+		baselineStart, err := time.ParseInLocation("2006-01-02", q.BaselineStartDate, start.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid baselineStartDate %q, expected YYYY-MM-DD", q.BaselineStartDate)
+		}
+		baselineEnd, err := time.ParseInLocation("2006-01-02", q.BaselineEndDate, start.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid baselineEndDate %q, expected YYYY-MM-DD", q.BaselineEndDate)
+		}
+		if baselineEnd.Before(baselineStart) {
+			return time.Time{}, time.Time{}, fmt.Errorf("baselineEndDate must not be before baselineStartDate")
+		}
+		return baselineStart, baselineEnd.AddDate(0, 0, 1), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("comparison is not enabled")
+}
+
+// 单个比率指标的对比结果，比率均为百分比，delta 为百分点
+type MetricComparison struct {
+	Current     float64  `json:"current"`
+	Baseline    float64  `json:"baseline"`
+	Delta       float64  `json:"delta"`
+	PctChange   *float64 `json:"pctChange"`   // 相对基准的变化百分比，基准为 0 时为 null
+	ZScore      float64  `json:"zScore"`      // 两比例 z 检验统计量
+	Significant bool     `json:"significant"` // |zScore| >= 1.96，即 95% 置信水平下差异显著
+}
+
+type DefectShareComparison struct {
+	Type string `json:"type"`
+	MetricComparison
+}
+
+type SupplierRateComparison struct {
+	SupplierID   uint   `json:"supplierId"`
+	SupplierName string `json:"supplierName"`
+	MetricComparison
+}
+
+type QualityComparison struct {
+	Mode                string                   `json:"mode"`
+	BaselineStartDate   string                   `json:"baselineStartDate"`
+	BaselineEndDate     string                   `json:"baselineEndDate"`
+	QualityRate         MetricComparison         `json:"qualityRate"`
+	DefectTypeShares    []DefectShareComparison  `json:"defectTypeShares"`
+	SupplierDefectRates []SupplierRateComparison `json:"supplierDefectRates"`
+}
@@ -0,0 +1,75 @@
+package models
+
+import "time"
+
+// QualityHourlyRollup 按小时预聚合的检测数据，HourStart 为数据库存储时区下的整点
+// ProductLineID、ProductModelID 为 0 表示产品未关联产线或型号
+type QualityHourlyRollup struct {
+	ID             int64     `gorm:"primary_key" json:"id"`
+	HourStart      time.Time `gorm:"uniqueIndex:idx_quality_hourly_rollup_key,priority:1" json:"hourStart"`
+	ProductLineID  uint      `gorm:"uniqueIndex:idx_quality_hourly_rollup_key,priority:2" json:"productLineId"`
+	ProductModelID uint      `gorm:"uniqueIndex:idx_quality_hourly_rollup_key,priority:3" json:"productModelId"`
+	BatchNumber    string    `gorm:"type:char(8);uniqueIndex:idx_quality_hourly_rollup_key,priority:4" json:"batchNumber"`
+	DefectReason   string    `gorm:"type:varchar(191);uniqueIndex:idx_quality_hourly_rollup_key,priority:5" json:"defectReason"`
+	TotalCount     int64     `json:"totalCount"`
+	DefectCount    int64     `json:"defectCount"`
+}
+
+// QualityDailyRollup 按工厂时区自然日预聚合的检测数据，Day 为 YYYY-MM-DD
+type QualityDailyRollup struct {
+	ID             int64  `gorm:"primary_key" json:"id"`
+	Day            string `gorm:"type:char(10);uniqueIndex:idx_quality_daily_rollup_key,priority:1" json:"day"`
+	ProductLineID  uint   `gorm:"uniqueIndex:idx_quality_daily_rollup_key,priority:2" json:"productLineId"`
+	ProductModelID uint   `gorm:"uniqueIndex:idx_quality_daily_rollup_key,priority:3" json:"productModelId"`
+	BatchNumber    string `gorm:"type:char(8);uniqueIndex:idx_quality_daily_rollup_key,priority:4" json:"batchNumber"`
+	DefectReason   string `gorm:"type:varchar(191);uniqueIndex:idx_quality_daily_rollup_key,priority:5" json:"defectReason"`
+	TotalCount     int64  `json:"totalCount"`
+	DefectCount    int64  `json:"defectCount"`
+}
+
+// QualityRollupStaleRange 校验发现预聚合与原始数据不一致的时刻区间 [StartAt, EndAt)，
+// 与之重叠的报表和统计改为读取原始数据，重建或再次校验一致后删除
+type QualityRollupStaleRange struct {
+	ID        int64     `gorm:"primary_key" json:"id"`
+	StartAt   time.Time `gorm:"index" json:"startAt"`
+	EndAt     time.Time `json:"endAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// QualityRollupState 生成预聚合时使用的工厂时区（只有一行），日预聚合按该时区分日，
+// 服务启动时与当前配置不一致则全量重建
+type QualityRollupState struct {
+	ID            int64     `gorm:"primary_key" json:"id"`
+	PlantTimezone string    `gorm:"type:varchar(64)" json:"plantTimezone"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// 预聚合数据的来源
+const (
+	AggregateSourceRollup = "rollup" // 预聚合表（默认）
+	AggregateSourceRaw    = "raw"    // 原始 products 表
+)
+
+// 预聚合重建/校验请求，日期按工厂时区解析
+type RollupRangeQuery struct {
+	StartDate string `form:"startDate" json:"startDate" binding:"required"`
+	EndDate   string `form:"endDate" json:"endDate" binding:"required"`
+	Async     bool   `form:"-" json:"async,omitempty"` // 重建时加入后台任务队列
+}
+
+// 某个小时内预聚合与原始数据不一致的记录
+type RollupDiscrepancy struct {
+	HourStart         time.Time `json:"hourStart"`
+	RawTotalCount     int64     `json:"rawTotalCount"`
+	RawDefectCount    int64     `json:"rawDefectCount"`
+	RollupTotalCount  int64     `json:"rollupTotalCount"`
+	RollupDefectCount int64     `json:"rollupDefectCount"`
+}
+
+type RollupCheckResult struct {
+	StartDate     string              `json:"startDate"`
+	EndDate       string              `json:"endDate"`
+	Consistent    bool                `json:"consistent"`
+	Discrepancies []RollupDiscrepancy `json:"discrepancies"`
+	DailyMismatch []string            `json:"dailyMismatch"` // 日汇总与小时汇总不一致的日期
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// 原始记录导出格式
+const ExportFormatNDJSON = "ndjson"
+
+// 原始记录导出的范围
+const (
+	ExportKindProducts = "products" // 全部产品
+	ExportKindDefects  = "defects"  // 不良品
+)
+
+// 原始记录导出的筛选条件
+type RecordExportQuery struct {
+	StartDate      string `form:"startDate" json:"startDate,omitempty"`
+	EndDate        string `form:"endDate" json:"endDate,omitempty"`
+	TZ             string `form:"tz" json:"tz,omitempty"` // 时区，用于日期区间和输出时间，默认工厂时区
+	SupplierID     *uint  `form:"supplierId" json:"supplierId,omitempty"`
+	ProductModelSN string `form:"productModelSN" json:"productModelSN,omitempty"`
+	ProductLineID  *uint  `form:"productLineId" json:"productLineId,omitempty"`
+	HasDefect      *bool  `form:"hasDefect" json:"hasDefect,omitempty"`
+	Format         string `form:"format" json:"format"` // csv（默认）/ndjson
+	Async          bool   `form:"async" json:"-"`       // 为 true 时总是在后台生成文件
+}
+
+func (q *RecordExportQuery) Validate() error {
+	if q.Format == "" {
+		q.Format = ExportFormatCSV
+	}
+	if q.Format != ExportFormatCSV && q.Format != ExportFormatNDJSON {
+		return fmt.Errorf("invalid format %q, expected %s or %s", q.Format, ExportFormatCSV, ExportFormatNDJSON)
+	}
+	return nil
+}
+
+// 导出的一条产品记录
+type ExportRecord struct {
+	ID             uint      `json:"id"`
+	SN             string    `json:"sn"`
+	BatchNumber    string    `json:"batchNumber"`
+	ProductModelSN string    `json:"productModelSN"`
+	Description    string    `json:"description"`
+	SupplierName   string    `json:"supplierName"`
+	ProductLine    string    `json:"productLine"`
+	PalletSN       string    `json:"palletSN"`
+	HasDefect      bool      `json:"hasDefect"`
+	DefectReason   string    `json:"defectReason"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import "fmt"
+
+// 报表导出格式
+const (
+	ExportFormatXLSX = "xlsx"
+	ExportFormatCSV  = "csv"
+)
+
+// 导出请求中的格式参数，默认 xlsx
+type ExportFormatQuery struct {
+	Format string `form:"format" json:"format"`
+}
+
+func (q *ExportFormatQuery) Validate() error {
+	if q.Format == "" {
+		q.Format = ExportFormatXLSX
+	}
+	if q.Format != ExportFormatXLSX && q.Format != ExportFormatCSV {
+		return fmt.Errorf("invalid format %q, expected %s or %s", q.Format, ExportFormatXLSX, ExportFormatCSV)
+	}
+	return nil
+}
+
+// 导出列的单元格格式
+type ColumnFormat int
+
+const (
+	ColumnText     ColumnFormat = iota
+	ColumnInteger               // 千分位整数
+	ColumnPercent               // 百分比，值为 0~1 的小数
+	ColumnDate                  // 日期，值为 time.Time
+	ColumnDateTime              // 日期时间，值为 time.Time
+)
+
+type ReportColumn struct {
+	Header string
+	Format ColumnFormat
+	Width  float64
+}
+
+// ReportTable 导出的报表：数据表、合计行和筛选条件表
+// 数据行在写出时由 Rows 逐行产生，合计行和筛选条件（含记录数）在读完数据行后填入
+type ReportTable struct {
+	Name    string // 报表名称，用作工作表名和文件名前缀
+	Columns []ReportColumn
+	Rows    ReportRows
+	Totals  []interface{} // 合计行，与 Columns 等长，nil 表示空单元格
+	Filters [][2]string   // 筛选条件：名称、值
+}
+
+// ReportRows 依次以每个数据行调用 yield，yield 返回错误时停止并返回该错误；每次调用都重新读取数据
+type ReportRows func(yield func(row []interface{}) error) error
+
+// 下载文件名，例如 不合格报表_2024-03-01_2024-03-03.xlsx
+func (t *ReportTable) FileName(startDate, endDate, format string) string {
+	name := t.Name
+	if startDate != "" || endDate != "" {
+		name += "_" + startDate + "_" + endDate
+	}
+	return name + "." + format
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// 定时报表类型
+const (
+	ReportDailyInspection       = "daily_inspection"        // 前一天的检测报表
+	ReportWeeklySupplierRanking = "weekly_supplier_ranking" // 前 7 天的供应商不良率排名
+)
+
+// PDF 格式，仅用于定时报表
+const ExportFormatPDF = "pdf"
+
+// 定时报表的投递方式
+const (
+	DeliveryEmail   = "email"   // 通过 SMTP 发送，目标为以逗号分隔的收件人
+	DeliveryWebhook = "webhook" // 以 multipart/form-data POST 到目标地址
+	DeliveryFolder  = "folder"  // 写入 REPORT_FOLDER_DIR 下的子目录
+)
+
+// ReportSchedule 对应 'report_schedules' 表
+// Cron 为 5 段的标准 cron 表达式（或 @daily 等），按工厂时区计算执行时间
+type ReportSchedule struct {
+	ModelFields  `s2m:"-"`
+	Name         string     `gorm:"type:varchar(128)" json:"name"`
+	Report       string     `gorm:"type:varchar(32)" json:"report"`
+	Cron         string     `gorm:"type:varchar(64)" json:"cron"`
+	Formats      string     `gorm:"type:varchar(32)" json:"formats"` // 以逗号分隔，pdf/xlsx
+	Channel      string     `gorm:"type:varchar(16)" json:"channel"`
+	Target       string     `gorm:"type:varchar(512)" json:"target"`
+	SupplierName string     `gorm:"type:varchar(64)" json:"supplierName"` // 只统计该供应商（模糊匹配），为空表示全部
+	Enabled      *bool      `gorm:"not null;default:true" json:"enabled"`
+	NextRunAt    *time.Time `gorm:"index" json:"nextRunAt" s2m:"-"`
+	LastRunAt    *time.Time `json:"lastRunAt" s2m:"-"`
+}
+
+func (s *ReportSchedule) IsEnabled() bool {
+	return s.Enabled == nil || *s.Enabled
+}
+
+func (s *ReportSchedule) FormatList() []string {
+	return splitList(s.Formats)
+}
+
+// 解析 cron 表达式
+func (s *ReportSchedule) CronSchedule() (cron.Schedule, error) {
+	schedule, err := cron.ParseStandard(s.Cron)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron %q: %w", s.Cron, err)
+	}
+	return schedule, nil
+}
+
+func (s *ReportSchedule) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("schedule name is required")
+	}
+	if s.Report != ReportDailyInspection && s.Report != ReportWeeklySupplierRanking {
+		return fmt.Errorf("invalid report %q, expected %s or %s", s.Report, ReportDailyInspection, ReportWeeklySupplierRanking)
+	}
+	if _, err := s.CronSchedule(); err != nil {
+		return err
+	}
+	formats := s.FormatList()
+	if len(formats) == 0 {
+		return fmt.Errorf("at least one format is required")
+	}
+	for _, format := range formats {
+		if format != ExportFormatPDF && format != ExportFormatXLSX {
+			return fmt.Errorf("invalid format %q, expected %s or %s", format, ExportFormatPDF, ExportFormatXLSX)
+		}
+	}
+
+	target := strings.TrimSpace(s.Target)
+	if target == "" {
+		return fmt.Errorf("target is required")
+	}
+	switch s.Channel {
+	case DeliveryEmail:
+		if _, err := mail.ParseAddressList(target); err != nil {
+			return fmt.Errorf("invalid email recipients %q: %w", target, err)
+		}
+	case DeliveryWebhook:
+		u, err := url.Parse(target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid webhook url %q", target)
+		}
+	case DeliveryFolder:
+		// 只能写入投递根目录之内
+		if !filepath.IsLocal(target) {
+			return fmt.Errorf("invalid folder %q, expected a relative path inside the report folder", target)
+		}
+	default:
+		return fmt.Errorf("invalid channel %q, expected %s, %s or %s", s.Channel, DeliveryEmail, DeliveryWebhook, DeliveryFolder)
+	}
+	return nil
+}
+
+// 定时报表的触发方式
+const (
+	ReportTriggerSchedule = "schedule"
+	ReportTriggerManual   = "manual"
+)
+
+// 执行结果
+const (
+	ReportRunRunning   = "running"
+	ReportRunSucceeded = "succeeded"
+	ReportRunFailed    = "failed"
+)
+
+// ReportRun 对应 'report_runs' 表，每次执行（含重试）一条记录
+type ReportRun struct {
+	ID          int64      `gorm:"primary_key" json:"id"`
+	ScheduleID  int64      `gorm:"index" json:"scheduleId"`
+	JobID       int64      `json:"jobId"`
+	Trigger     string     `gorm:"type:varchar(16)" json:"trigger"`
+	Status      string     `gorm:"type:varchar(16)" json:"status"`
+	PeriodStart string     `gorm:"type:char(10)" json:"periodStart"` // 报表区间，YYYY-MM-DD
+	PeriodEnd   string     `gorm:"type:char(10)" json:"periodEnd"`
+	Files       string     `gorm:"type:varchar(512)" json:"files"` // 以逗号分隔的文件名
+	Error       string     `gorm:"type:text" json:"error,omitempty"`
+	StartedAt   time.Time  `json:"startedAt"`
+	FinishedAt  *time.Time `json:"finishedAt,omitempty"`
+}
@@ -0,0 +1,343 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// 检验严格度（ISO 2859-1 转移规则）
+const (
+	SamplingNormal       = "normal"       // 正常检验
+	SamplingTightened    = "tightened"    // 加严检验
+	SamplingReduced      = "reduced"      // 放宽检验
+	SamplingDiscontinued = "discontinued" // 暂停抽样检验，改为全检，需要人工恢复
+)
+
+// 批次抽样结果
+const (
+	SamplingPending  = "pending"
+	SamplingAccepted = "accepted"
+	SamplingRejected = "rejected"
+)
+
+// 自动判定批次时的操作人
+const SamplingOperator = "aql"
+
+// 样本量字码 A~R 及正常检验一次抽样的样本量（GB/T 2828.1 / ISO 2859-1 表 2-A）
+var (
+	AQLCodeLetters = []string{"A", "B", "C", "D", "E", "F", "G", "H", "J", "K", "L", "M", "N", "P", "Q", "R"}
+	AQLSampleSizes = []int{2, 3, 5, 8, 13, 20, 32, 50, 80, 125, 200, 315, 500, 800, 1250, 2000}
+	// 支持的接收质量限（不合格品百分数）
+	AQLValues = []float64{0.010, 0.015, 0.025, 0.040, 0.065, 0.10, 0.15, 0.25, 0.40, 0.65, 1.0, 1.5, 2.5, 4.0, 6.5, 10}
+)
+
+// 批量上限（2~8、9~15……500001 以上），与各检验水平的字码对应（表 1）
+var aqlLotSizeLimits = []int{8, 15, 25, 50, 90, 150, 280, 500, 1200, 3200, 10000, 35000, 150000, 500000}
+
+var aqlLevelLetters = map[string]string{
+	"I":   "AABCCDEFGHJKLMN",
+	"II":  "ABCDEFGHJKLMNPQ",
+	"III": "BCDEFGHJKLMNPQR",
+	"S-1": "AAAABBBBCCCCDDD",
+	"S-2": "AAABBBCCCDDDEEE",
+	"S-3": "AABBCCDDEEFFGGH",
+	"S-4": "AABCCDEEFGGHJJK",
+}
+
+// 一次抽样主表：正常（表 2-A）、加严（表 2-B）、放宽（表 2-C）。各表中接收数和拒收数沿对角线分布，
+// 以字码序号 + AQL 序号 + offset 查 acceptance，其余位置为箭头
+type aqlTable struct {
+	sampleSizes []int // 各字码的样本量，加严表多一行字码 S
+	offset      int
+	acceptance  map[int][2]int
+}
+
+var (
+	aqlNormal = aqlTable{
+		sampleSizes: AQLSampleSizes,
+		acceptance: map[int][2]int{
+			14: {0, 1}, 17: {1, 2}, 18: {2, 3}, 19: {3, 4}, 20: {5, 6}, 21: {7, 8}, 22: {10, 11}, 23: {14, 15}, 24: {21, 22},
+		},
+	}
+	// 加严：同一字码 AQL 严一档，接收数较大处为 8/9、12/13、18/19
+	aqlTightened = aqlTable{
+		sampleSizes: append(append([]int{}, AQLSampleSizes...), 3150),
+		offset:      -1,
+		acceptance: map[int][2]int{
+			14: {0, 1}, 17: {1, 2}, 18: {2, 3}, 19: {3, 4}, 20: {5, 6}, 21: {8, 9}, 22: {12, 13}, 23: {18, 19},
+		},
+	}
+	// 放宽：样本量为正常检验字码降两档（A~C 均为 2），拒收数大于接收数 + 1
+	aqlReduced = aqlTable{
+		sampleSizes: []int{2, 2, 2, 3, 5, 8, 13, 20, 32, 50, 80, 125, 200, 315, 500, 800},
+		offset:      -2,
+		acceptance: map[int][2]int{
+			14: {0, 1}, 17: {0, 2}, 18: {1, 3}, 19: {1, 4}, 20: {2, 5}, 21: {3, 6}, 22: {5, 8}, 23: {7, 10}, 24: {10, 13},
+		},
+	}
+)
+
+// 箭头方向：1 为向下（使用下方第一个抽样方案），-1 为向上，0 为接收数和拒收数
+func (t aqlTable) entry(letter, aql int) (ac, re, arrow int) {
+	d := letter + aql + t.offset
+	if entry, ok := t.acceptance[d]; ok {
+		return entry[0], entry[1], 0
+	}
+	if d < 14 || d == 16 {
+		return 0, 0, 1
+	}
+	return 0, 0, -1
+}
+
+// 按字码和 AQL 查表，沿箭头找到第一个抽样方案（箭头指向表外时改为反方向）
+func (t aqlTable) scheme(letter, aql int) SamplingScheme {
+	ac, re, arrow := t.entry(letter, aql)
+	for arrow != 0 {
+		next := letter + arrow
+		if next < 0 || next >= len(t.sampleSizes) {
+			// 表边缘：沿反方向找到最近的抽样方案
+			for next = letter - arrow; next >= 0 && next < len(t.sampleSizes); next -= arrow {
+				if _, _, a := t.entry(next, aql); a == 0 {
+					break
+				}
+			}
+			if next < 0 || next >= len(t.sampleSizes) {
+				return SamplingScheme{}
+			}
+		}
+		letter = next
+		ac, re, arrow = t.entry(letter, aql)
+	}
+	return SamplingScheme{CodeLetter: aqlLetters[letter], SampleSize: t.sampleSizes[letter], Ac: ac, Re: re}
+}
+
+// 字码 S 只出现在加严表中
+var aqlLetters = append(append([]string{}, AQLCodeLetters...), "S")
+
+func aqlIndex(aql float64) int {
+	for i, value := range AQLValues {
+		if math.Abs(aql-value) < 1e-9 {
+			return i
+		}
+	}
+	return -1
+}
+
+// 一次抽样方案
+type SamplingScheme struct {
+	CodeLetter string `json:"codeLetter"`
+	SampleSize int    `json:"sampleSize"`
+	Ac         int    `json:"ac"`
+	Re         int    `json:"re"`
+}
+
+// 批量对应的样本量字码序号
+func aqlCodeLetter(lotSize int, level string) int {
+	letters := aqlLevelLetters[level]
+	letter := letters[len(letters)-1]
+	for i, limit := range aqlLotSizeLimits {
+		if lotSize <= limit {
+			letter = letters[i]
+			break
+		}
+	}
+	return aqlLetterIndex(string(letter))
+}
+
+func aqlLetterIndex(letter string) int {
+	for i, l := range AQLCodeLetters {
+		if l == letter {
+			return i
+		}
+	}
+	return -1
+}
+
+// SamplingPlan 对应 'sampling_plans' 表，按物料或供应商（二选一）定义来料抽样方案；
+// 物料的方案优先于供应商的方案，转移规则的状态随批次判定结果更新
+type SamplingPlan struct {
+	ModelFields     `s2m:"-"`
+	Name            string        `gorm:"type:varchar(128)" json:"name"`
+	SupplierID      *uint         `gorm:"index" json:"supplierId"`
+	Supplier        *Supplier     `gorm:"foreignKey:SupplierID" json:"supplier,omitempty" s2m:"-"`
+	ProductModelID  *uint         `gorm:"index" json:"productModelId"`
+	ProductModel    *ProductModel `gorm:"foreignKey:ProductModelID" json:"productModel,omitempty" s2m:"-"`
+	InspectionLevel string        `gorm:"type:varchar(4)" json:"inspectionLevel"` // I/II/III/S-1~S-4，默认 II
+	AQL             float64       `gorm:"type:decimal(6,3)" json:"aql"`
+	AllowReduced    *bool         `gorm:"not null;default:true" json:"allowReduced"` // 是否允许转为放宽检验
+	Enabled         *bool         `gorm:"not null;default:true" json:"enabled"`
+
+	Severity            string `gorm:"type:varchar(16);not null;default:normal" json:"severity" s2m:"-"`
+	SwitchingScore      int    `json:"switchingScore" s2m:"-"`                       // 正常检验的转移得分，达到 30 转为放宽检验
+	RecentResults       string `gorm:"type:varchar(8)" json:"recentResults" s2m:"-"` // 正常检验最近 5 批的结果，A 接收、R 拒收
+	ConsecutiveAccepted int    `json:"consecutiveAccepted" s2m:"-"`                  // 加严检验连续接收的批数
+	TightenedRejections int    `json:"tightenedRejections" s2m:"-"`                  // 加严检验累计拒收的批数
+}
+
+func (p *SamplingPlan) IsEnabled() bool {
+	return p.Enabled == nil || *p.Enabled
+}
+
+func (p *SamplingPlan) ReducedAllowed() bool {
+	return p.AllowReduced == nil || *p.AllowReduced
+}
+
+func (p *SamplingPlan) Validate() error {
+	if (p.SupplierID == nil) == (p.ProductModelID == nil) {
+		return fmt.Errorf("exactly one of supplierId and productModelId is required")
+	}
+	if p.InspectionLevel == "" {
+		p.InspectionLevel = "II"
+	}
+	if _, ok := aqlLevelLetters[p.InspectionLevel]; !ok {
+		return fmt.Errorf("invalid inspectionLevel %q, expected I, II, III or S-1~S-4", p.InspectionLevel)
+	}
+	if aqlIndex(p.AQL) < 0 {
+		return fmt.Errorf("invalid aql %v, expected one of 0.010~10 in the ISO 2859-1 series", p.AQL)
+	}
+	return nil
+}
+
+// 当前严格度下批量对应的抽样方案；暂停抽样时为全检，发现不合格即拒收
+func (p *SamplingPlan) Scheme(lotSize int) SamplingScheme {
+	var scheme SamplingScheme
+	letter, aql := aqlCodeLetter(lotSize, p.InspectionLevel), aqlIndex(p.AQL)
+	switch p.Severity {
+	case SamplingTightened:
+		scheme = aqlTightened.scheme(letter, aql)
+	case SamplingReduced:
+		scheme = aqlReduced.scheme(letter, aql)
+	case SamplingDiscontinued:
+		return SamplingScheme{CodeLetter: AQLCodeLetters[letter], SampleSize: lotSize, Ac: 0, Re: 1}
+	default:
+		scheme = aqlNormal.scheme(letter, aql)
+	}
+	// 样本量不小于批量时全检
+	scheme.SampleSize = min(scheme.SampleSize, lotSize)
+	return scheme
+}
+
+// 按批次的判定结果更新转移规则的状态
+func (p *SamplingPlan) ApplyResult(sampling *BatchSampling) {
+	accepted := sampling.Result == SamplingAccepted
+	switch p.Severity {
+	case SamplingTightened:
+		if accepted {
+			p.ConsecutiveAccepted++
+			if p.ConsecutiveAccepted >= 5 {
+				p.switchTo(SamplingNormal)
+			}
+			return
+		}
+		p.ConsecutiveAccepted = 0
+		p.TightenedRejections++
+		if p.TightenedRejections >= 5 {
+			p.switchTo(SamplingDiscontinued)
+		}
+	case SamplingReduced:
+		if !accepted {
+			p.switchTo(SamplingNormal)
+		}
+	case SamplingDiscontinued:
+	default:
+		result := "A"
+		if !accepted {
+			result = "R"
+		}
+		p.RecentResults += result
+		if len(p.RecentResults) > 5 {
+			p.RecentResults = p.RecentResults[len(p.RecentResults)-5:]
+		}
+		// 连续 5 批或少于 5 批中有 2 批拒收
+		if strings.Count(p.RecentResults, "R") >= 2 {
+			p.switchTo(SamplingTightened)
+			return
+		}
+		p.SwitchingScore = p.switchingScore(sampling, accepted)
+		if p.SwitchingScore >= 30 && p.ReducedAllowed() {
+			p.switchTo(SamplingReduced)
+		}
+	}
+}
+
+// 转移得分：接收数为 0 或 1 时批接收加 2 分；接收数不小于 2 时，AQL 严一档仍可接收则加 3 分；其余情况清零
+func (p *SamplingPlan) switchingScore(sampling *BatchSampling, accepted bool) int {
+	if !accepted {
+		return 0
+	}
+	if sampling.Ac < 2 {
+		return p.SwitchingScore + 2
+	}
+	letter, aql := aqlLetterIndex(sampling.CodeLetter), aqlIndex(p.AQL)
+	if tighter, _, arrow := aqlNormal.entry(letter, aql-1); letter >= 0 && aql >= 0 && arrow == 0 && sampling.Defects <= tighter {
+		return p.SwitchingScore + 3
+	}
+	return 0
+}
+
+func (p *SamplingPlan) switchTo(severity string) {
+	p.Severity = severity
+	p.SwitchingScore, p.RecentResults, p.ConsecutiveAccepted, p.TightenedRejections = 0, "", 0, 0
+}
+
+// 人工设置抽样方案严格度的请求参数
+type SamplingResetForm struct {
+	Severity string `json:"severity" binding:"required"`
+}
+
+func (f *SamplingResetForm) Validate() error {
+	switch f.Severity {
+	case SamplingNormal, SamplingTightened, SamplingReduced, SamplingDiscontinued:
+		return nil
+	}
+	return fmt.Errorf("invalid severity %q, expected normal, tightened, reduced or discontinued", f.Severity)
+}
+
+// BatchSampling 对应 'batch_samplings' 表，批次的抽样方案和进度，由产线首次查询时按当时的严格度生成；
+// Inspected、Defects 为该批次检测过的不重复 SN 数和其中不良的 SN 数
+type BatchSampling struct {
+	ID         int64         `gorm:"primary_key" json:"id"`
+	BatchID    int64         `gorm:"uniqueIndex" json:"batchId"`
+	Batch      *Batch        `gorm:"foreignKey:BatchID" json:"batch,omitempty"`
+	PlanID     int64         `gorm:"index" json:"planId"`
+	Plan       *SamplingPlan `gorm:"foreignKey:PlanID" json:"plan,omitempty"`
+	Severity   string        `gorm:"type:varchar(16)" json:"severity"`
+	LotSize    int           `json:"lotSize"`
+	CodeLetter string        `gorm:"type:varchar(2)" json:"codeLetter"`
+	SampleSize int           `json:"sampleSize"`
+	Ac         int           `json:"ac"`
+	Re         int           `json:"re"`
+	Inspected  int           `json:"inspected"`
+	Defects    int           `json:"defects"`
+	Remaining  int           `gorm:"-" json:"remaining"` // 还需检测的数量
+	Result     string        `gorm:"type:varchar(16);not null;default:pending" json:"result"`
+	DecidedAt  *time.Time    `json:"decidedAt,omitempty"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	UpdatedAt  time.Time     `json:"updatedAt"`
+}
+
+// 产线查询抽样方案的参数：sn 为该批次任一产品的 SN，或同时指定物料编码和批次号；首次查询时需要批量
+type SamplingQuery struct {
+	SN              string `form:"sn"`
+	ProductModelSAP string `form:"productModelSap"`
+	BatchNumber     string `form:"batchNumber"`
+	LotSize         int    `form:"lotSize"`
+}
+
+func (q *SamplingQuery) Validate() error {
+	if q.SN != "" {
+		if len(q.SN) < 11 {
+			return fmt.Errorf("sn must be at least 11 characters")
+		}
+		q.ProductModelSAP, q.BatchNumber = q.SN[:7], q.SN[7:11]
+	}
+	if q.ProductModelSAP == "" || q.BatchNumber == "" {
+		return fmt.Errorf("sn or productModelSap and batchNumber are required")
+	}
+	if q.LotSize < 0 {
+		return fmt.Errorf("lotSize must be positive")
+	}
+	return nil
+}
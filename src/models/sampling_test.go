@@ -0,0 +1,55 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+)
+
+// GB/T 2828.1（ISO 2859-1）表 2-A、2-B、2-C 中的抽样方案
+func TestSamplingPlanScheme(t *testing.T) {
+	cases := []struct {
+		severity string
+		level    string
+		lotSize  int
+		aql      float64
+		want     models.SamplingScheme
+	}{
+		// 正常检验
+		{models.SamplingNormal, "II", 60, 1.0, models.SamplingScheme{CodeLetter: "E", SampleSize: 13, Ac: 0, Re: 1}},
+		{models.SamplingNormal, "II", 100, 2.5, models.SamplingScheme{CodeLetter: "F", SampleSize: 20, Ac: 1, Re: 2}},
+		{models.SamplingNormal, "II", 200, 4.0, models.SamplingScheme{CodeLetter: "G", SampleSize: 32, Ac: 3, Re: 4}},
+		{models.SamplingNormal, "II", 500, 0.40, models.SamplingScheme{CodeLetter: "G", SampleSize: 32, Ac: 0, Re: 1}},
+		{models.SamplingNormal, "II", 1000, 2.5, models.SamplingScheme{CodeLetter: "J", SampleSize: 80, Ac: 5, Re: 6}},
+		{models.SamplingNormal, "II", 2000, 1.0, models.SamplingScheme{CodeLetter: "K", SampleSize: 125, Ac: 3, Re: 4}},
+		{models.SamplingNormal, "II", 2000, 10, models.SamplingScheme{CodeLetter: "K", SampleSize: 125, Ac: 21, Re: 22}},
+		{models.SamplingNormal, "II", 5000, 0.65, models.SamplingScheme{CodeLetter: "L", SampleSize: 200, Ac: 3, Re: 4}},
+		// 加严检验
+		{models.SamplingTightened, "II", 200, 4.0, models.SamplingScheme{CodeLetter: "G", SampleSize: 32, Ac: 2, Re: 3}},
+		{models.SamplingTightened, "II", 500, 1.0, models.SamplingScheme{CodeLetter: "J", SampleSize: 80, Ac: 1, Re: 2}},
+		{models.SamplingTightened, "II", 2000, 1.0, models.SamplingScheme{CodeLetter: "K", SampleSize: 125, Ac: 2, Re: 3}},
+		{models.SamplingTightened, "II", 2000, 4.0, models.SamplingScheme{CodeLetter: "K", SampleSize: 125, Ac: 8, Re: 9}},
+		{models.SamplingTightened, "II", 2000, 6.5, models.SamplingScheme{CodeLetter: "K", SampleSize: 125, Ac: 12, Re: 13}},
+		{models.SamplingTightened, "II", 2000, 10, models.SamplingScheme{CodeLetter: "K", SampleSize: 125, Ac: 18, Re: 19}},
+		{models.SamplingTightened, "II", 20000, 10, models.SamplingScheme{CodeLetter: "K", SampleSize: 125, Ac: 18, Re: 19}},
+		{models.SamplingTightened, "III", 600000, 0.025, models.SamplingScheme{CodeLetter: "S", SampleSize: 3150, Ac: 1, Re: 2}},
+		// 放宽检验
+		{models.SamplingReduced, "II", 50, 6.5, models.SamplingScheme{CodeLetter: "C", SampleSize: 2, Ac: 0, Re: 1}},
+		{models.SamplingReduced, "II", 2000, 1.0, models.SamplingScheme{CodeLetter: "K", SampleSize: 50, Ac: 0, Re: 2}},
+		{models.SamplingReduced, "II", 2000, 1.5, models.SamplingScheme{CodeLetter: "K", SampleSize: 50, Ac: 1, Re: 3}},
+		{models.SamplingReduced, "II", 2000, 2.5, models.SamplingScheme{CodeLetter: "K", SampleSize: 50, Ac: 1, Re: 4}},
+		{models.SamplingReduced, "II", 2000, 4.0, models.SamplingScheme{CodeLetter: "K", SampleSize: 50, Ac: 2, Re: 5}},
+		{models.SamplingReduced, "II", 2000, 6.5, models.SamplingScheme{CodeLetter: "K", SampleSize: 50, Ac: 3, Re: 6}},
+		{models.SamplingReduced, "II", 2000, 10, models.SamplingScheme{CodeLetter: "K", SampleSize: 50, Ac: 5, Re: 8}},
+		{models.SamplingReduced, "II", 20000, 6.5, models.SamplingScheme{CodeLetter: "M", SampleSize: 125, Ac: 7, Re: 10}},
+		{models.SamplingReduced, "II", 20000, 10, models.SamplingScheme{CodeLetter: "M", SampleSize: 125, Ac: 10, Re: 13}},
+		// 暂停抽样时全检
+		{models.SamplingDiscontinued, "II", 8, 6.5, models.SamplingScheme{CodeLetter: "A", SampleSize: 8, Ac: 0, Re: 1}},
+	}
+	for _, c := range cases {
+		plan := models.SamplingPlan{InspectionLevel: c.level, AQL: c.aql, Severity: c.severity}
+		if got := plan.Scheme(c.lotSize); got != c.want {
+			t.Errorf("%s level %s lot %d AQL %v: got %+v, want %+v", c.severity, c.level, c.lotSize, c.aql, got, c.want)
+		}
+	}
+}
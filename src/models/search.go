@@ -0,0 +1,91 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// 搜索结果类型
+const (
+	SearchTypeProduct  = "product"
+	SearchTypePallet   = "pallet"
+	SearchTypeModel    = "model"
+	SearchTypeSupplier = "supplier"
+	SearchTypePlan     = "plan"
+)
+
+// 结果排序时的类型顺序
+var SearchTypes = []string{SearchTypeProduct, SearchTypePallet, SearchTypeModel, SearchTypeSupplier, SearchTypePlan}
+
+const (
+	SearchDefaultLimit = 20
+	SearchMaxLimit     = 100
+	SearchMaxLength    = 64
+)
+
+// 搜索索引：每条记录被索引文本的 n-gram（小写），由 search 插件在写入时维护
+type SearchGram struct {
+	Gram       string `gorm:"type:varchar(12);primaryKey"`
+	EntityType string `gorm:"type:varchar(16);primaryKey;index:idx_search_grams_entity,priority:1"`
+	EntityID   int64  `gorm:"primaryKey;autoIncrement:false;index:idx_search_grams_entity,priority:2"`
+}
+
+type SearchQuery struct {
+	Q     string `form:"q" json:"q"`         // 关键字，不区分大小写
+	Types string `form:"types" json:"types"` // 以逗号分隔的结果类型，为空表示全部
+	Limit int    `form:"limit" json:"limit"` // 最多返回的结果数，默认 20，最大 100
+}
+
+func (q *SearchQuery) Validate() error {
+	q.Q = strings.TrimSpace(q.Q)
+	if q.Q == "" {
+		return fmt.Errorf("q is required")
+	}
+	if utf8.RuneCountInString(q.Q) > SearchMaxLength {
+		return fmt.Errorf("q must be at most %d characters", SearchMaxLength)
+	}
+	if q.Limit == 0 {
+		q.Limit = SearchDefaultLimit
+	}
+	if q.Limit < 0 || q.Limit > SearchMaxLimit {
+		return fmt.Errorf("limit must be between 1 and %d", SearchMaxLimit)
+	}
+	for _, t := range q.TypeList() {
+		if !containsString(SearchTypes, t) {
+			return fmt.Errorf("invalid type %q, expected one of %s", t, strings.Join(SearchTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// 请求的结果类型，未指定时为全部类型
+func (q *SearchQuery) TypeList() []string {
+	if q.Types == "" {
+		return SearchTypes
+	}
+	var types []string
+	for _, t := range strings.Split(q.Types, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// 匹配程度，排序时完全匹配在前，其次为前缀匹配
+const (
+	SearchMatchExact    = "exact"
+	SearchMatchPrefix   = "prefix"
+	SearchMatchContains = "contains"
+)
+
+type SearchHit struct {
+	Type     string      `json:"type"`
+	ID       int64       `json:"id"`
+	Title    string      `json:"title"`              // 主要文本（SN、名称或物料编码）
+	Subtitle string      `json:"subtitle,omitempty"` // 辅助说明
+	Field    string      `json:"field"`              // 命中的字段
+	Match    string      `json:"match"`              // exact/prefix/contains
+	Data     interface{} `json:"data"`               // 完整记录
+}
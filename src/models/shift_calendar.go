@@ -0,0 +1,220 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// 分桶方式
+const (
+	BucketByCalendar   = "calendar"   // 按自然日
+	BucketByProduction = "production" // 按生产日（跨零点的夜班计入开班当天）
+)
+
+// Shift 对应 'Shift' 表，ProductLineID 为空表示全厂默认班次
+type Shift struct {
+	ModelFields   `s2m:"-"`
+	ProductLineID *uint        `json:"productLineId"`
+	ProductLine   *ProductLine `gorm:"foreignKey:ProductLineID" json:"productLine,omitempty" s2m:"-"`
+	Name          string       `gorm:"type:char(32)" json:"name"`
+	StartTime     string       `gorm:"type:char(5)" json:"startTime"` // 开班时间 HH:MM
+	EndTime       string       `gorm:"type:char(5)" json:"endTime"`   // 下班时间 HH:MM，小于开班时间表示跨零点
+	Sequence      int          `json:"sequence"`                      // 生产日内的顺序，第一个班次的开班时间即为生产日起点
+}
+
+// Holiday 对应 'Holiday' 表，ProductLineID 为空表示全厂假日
+type Holiday struct {
+	ModelFields   `s2m:"-"`
+	ProductLineID *uint     `json:"productLineId"`
+	Date          time.Time `gorm:"type:date" json:"date"`
+	Name          string    `gorm:"type:char(64)" json:"name"`
+}
+
+// 校验班次时间，班次边界必须为整点以便与按小时的聚合对齐
+func (s *Shift) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("shift name is required")
+	}
+	start, err := parseClock(s.StartTime)
+	if err != nil {
+		return fmt.Errorf("invalid startTime: %w", err)
+	}
+	end, err := parseClock(s.EndTime)
+	if err != nil {
+		return fmt.Errorf("invalid endTime: %w", err)
+	}
+	if start%60 != 0 || end%60 != 0 {
+		return fmt.Errorf("shift boundaries must be on the hour")
+	}
+	if start == end {
+		return fmt.Errorf("shift startTime and endTime must differ")
+	}
+	return nil
+}
+
+// 解析 HH:MM，返回距零点的分钟数
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("expected HH:MM, got %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// 未配置班次时使用的默认三班（与按 8 小时划分的班次一致）
+var defaultShifts = []Shift{
+	{Name: "S1", StartTime: "00:00", EndTime: "08:00", Sequence: 1},
+	{Name: "S2", StartTime: "08:00", EndTime: "16:00", Sequence: 2},
+	{Name: "S3", StartTime: "16:00", EndTime: "00:00", Sequence: 3},
+}
+
+// 某一时刻所属的生产日与班次
+type ShiftSlot struct {
+	ProductionDay string `json:"productionDay"` // YYYY-MM-DD
+	Shift         string `json:"shift"`
+	IsHoliday     bool   `json:"isHoliday"`
+}
+
+type shiftWindow struct {
+	name  string
+	start int // 距生产日起点的分钟数
+	end   int
+}
+
+type lineCalendar struct {
+	dayStart int // 生产日起点，距零点的分钟数
+	windows  []shiftWindow
+}
+
+// ShiftCalendar 由班次、假日和工厂时区组成的班次日历
+type ShiftCalendar struct {
+	Location *time.Location
+	lines    map[uint]*lineCalendar // key 0 表示全厂默认
+	holidays map[string]string      // key: 产线ID|日期
+}
+
+// 班次时间无法解析时返回错误（例如绕过校验直接写入数据库的班次）
+func NewShiftCalendar(location *time.Location, shifts []Shift, holidays []Holiday) (*ShiftCalendar, error) {
+	if location == nil {
+		location = time.Local
+	}
+
+	grouped := make(map[uint][]Shift)
+	for _, shift := range shifts {
+		grouped[lineKey(shift.ProductLineID)] = append(grouped[lineKey(shift.ProductLineID)], shift)
+	}
+	if len(grouped[0]) == 0 {
+		grouped[0] = defaultShifts
+	}
+
+	calendar := &ShiftCalendar{
+		Location: location,
+		lines:    make(map[uint]*lineCalendar),
+		holidays: make(map[string]string),
+	}
+	for key, list := range grouped {
+		lc, err := buildLineCalendar(list)
+		if err != nil {
+			return nil, err
+		}
+		calendar.lines[key] = lc
+	}
+	for _, holiday := range holidays {
+		calendar.holidays[holidayKey(lineKey(holiday.ProductLineID), holiday.Date.Format("2006-01-02"))] = holiday.Name
+	}
+	return calendar, nil
+}
+
+func buildLineCalendar(shifts []Shift) (*lineCalendar, error) {
+	sorted := append([]Shift{}, shifts...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Sequence < sorted[j].Sequence })
+
+	dayStart, err := parseClock(sorted[0].StartTime)
+	if err != nil {
+		return nil, fmt.Errorf("shift %q: invalid startTime: %w", sorted[0].Name, err)
+	}
+	lc := &lineCalendar{dayStart: dayStart}
+	for _, shift := range sorted {
+		start, err := parseClock(shift.StartTime)
+		if err != nil {
+			return nil, fmt.Errorf("shift %q: invalid startTime: %w", shift.Name, err)
+		}
+		end, err := parseClock(shift.EndTime)
+		if err != nil {
+			return nil, fmt.Errorf("shift %q: invalid endTime: %w", shift.Name, err)
+		}
+		relStart := (start - dayStart + 1440) % 1440
+		duration := (end - start + 1440) % 1440
+		lc.windows = append(lc.windows, shiftWindow{name: shift.Name, start: relStart, end: relStart + duration})
+	}
+	return lc, nil
+}
+
+func (c *ShiftCalendar) line(productLineID *uint) *lineCalendar {
+	if lc, exists := c.lines[lineKey(productLineID)]; exists {
+		return lc
+	}
+	return c.lines[0]
+}
+
+// 生产日起点距零点的分钟数
+func (c *ShiftCalendar) DayStart(productLineID *uint) int {
+	return c.line(productLineID).dayStart
+}
+
+// 各产线自定义的生产日起点（距零点的分钟数），不含全厂默认
+func (c *ShiftCalendar) LineDayStarts() map[uint]int {
+	starts := make(map[uint]int)
+	for key, lc := range c.lines {
+		if key != 0 {
+			starts[key] = lc.dayStart
+		}
+	}
+	return starts
+}
+
+// 计算某一时刻所属的生产日和班次
+func (c *ShiftCalendar) Locate(t time.Time, productLineID *uint) ShiftSlot {
+	lc := c.line(productLineID)
+	local := t.In(c.Location)
+
+	shifted := local.Add(-time.Duration(lc.dayStart) * time.Minute)
+	productionDay := shifted.Format("2006-01-02")
+	minute := shifted.Hour()*60 + shifted.Minute()
+
+	slot := ShiftSlot{ProductionDay: productionDay, IsHoliday: c.IsHoliday(productionDay, productLineID)}
+	for _, window := range lc.windows {
+		if minute >= window.start && minute < window.end {
+			slot.Shift = window.name
+			break
+		}
+	}
+	return slot
+}
+
+// 生产日对应的时间区间 [start, end)
+func (c *ShiftCalendar) ProductionDayRange(day time.Time, productLineID *uint) (time.Time, time.Time) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, c.Location).
+		Add(time.Duration(c.line(productLineID).dayStart) * time.Minute)
+	return start, start.AddDate(0, 0, 1)
+}
+
+func (c *ShiftCalendar) IsHoliday(productionDay string, productLineID *uint) bool {
+	if _, exists := c.holidays[holidayKey(0, productionDay)]; exists {
+		return true
+	}
+	_, exists := c.holidays[holidayKey(lineKey(productLineID), productionDay)]
+	return exists
+}
+
+func lineKey(productLineID *uint) uint {
+	if productLineID == nil {
+		return 0
+	}
+	return *productLineID
+}
+
+func holidayKey(line uint, date string) string {
+	return fmt.Sprintf("%d|%s", line, date)
+}
@@ -0,0 +1,31 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+)
+
+// 未经校验写入的班次时间无法解析时，日历构建失败而不是按零点计算生产日
+func TestShiftCalendarRejectsInvalidClock(t *testing.T) {
+	for _, shift := range []models.Shift{
+		{Name: "白班", StartTime: "8点", EndTime: "20:00", Sequence: 1},
+		{Name: "白班", StartTime: "08:00", EndTime: "", Sequence: 1},
+	} {
+		if _, err := models.NewShiftCalendar(time.UTC, []models.Shift{shift}, nil); err == nil {
+			t.Errorf("shift %s-%s: expected error", shift.StartTime, shift.EndTime)
+		}
+	}
+
+	calendar, err := models.NewShiftCalendar(time.UTC, []models.Shift{
+		{Name: "白班", StartTime: "08:00", EndTime: "20:00", Sequence: 1},
+		{Name: "夜班", StartTime: "20:00", EndTime: "08:00", Sequence: 2},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start := calendar.DayStart(nil); start != 8*60 {
+		t.Errorf("day start = %d, want %d", start, 8*60)
+	}
+}
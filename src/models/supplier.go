@@ -1,12 +1,32 @@
 package models
 
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
 // SupplierType 定义供应商类型的枚举
 type SupplierType string
 
+// 供应商类型取值，由 BeforeSave 校验（各数据库统一使用 varchar 存储）
+const (
+	SupplierTypeDirect SupplierType = "直接供应"
+	SupplierTypeTrader SupplierType = "贸易商"
+)
+
 // Supplier 对应 'Supplier' 表
 type Supplier struct {
 	ModelFields `s2m:"-"`
 	Name        string       `gorm:"type:char(64)" json:"name"`
 	SAP         string       `gorm:"type:char(16)" json:"sap"`
-	Type        SupplierType `gorm:"type:enum('直接供应','贸易商')" json:"type" s2m:"-"` // 假设的枚举值
+	Type        SupplierType `gorm:"type:varchar(20)" json:"type" s2m:"-"`
+}
+
+func (s *Supplier) BeforeSave(tx *gorm.DB) error {
+	switch s.Type {
+	case "", SupplierTypeDirect, SupplierTypeTrader:
+		return nil
+	}
+	return fmt.Errorf("invalid supplier type %q, expected %s or %s", s.Type, SupplierTypeDirect, SupplierTypeTrader)
 }
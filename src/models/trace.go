@@ -0,0 +1,102 @@
+package models
+
+import "time"
+
+// 产品事件类型
+const (
+	ProductEventCreated = "created" // 检测结果上报
+	ProductEventUpdated = "updated"
+	ProductEventDeleted = "deleted"
+)
+
+// ProductEvent 对应 'product_events' 表，记录产品记录的创建、修改和删除，只追加不修改
+type ProductEvent struct {
+	ID        int64                    `gorm:"primary_key" json:"id"`
+	ProductID int64                    `gorm:"index" json:"productId"`
+	SN        string                   `gorm:"type:char(32);index" json:"sn"`
+	Type      string                   `gorm:"type:varchar(16)" json:"type"`
+	Changes   map[string]ProductChange `gorm:"type:text;serializer:json" json:"changes,omitempty"` // 变化的字段，创建时为初始值
+	CreatedAt time.Time                `json:"createdAt"`
+}
+
+type ProductChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// 追溯图的节点类型，也用作边的关系名
+const (
+	TraceNodeProduct  = "product" // 一次检测记录
+	TraceNodeModel    = "model"
+	TraceNodeSupplier = "supplier"
+	TraceNodeBatch    = "batch"
+	TraceNodeLine     = "line"
+	TraceNodePallet   = "pallet"
+	TraceNodePlan     = "plan"
+)
+
+type TraceNode struct {
+	ID    string      `json:"id"` // <类型>:<主键>，批次为 batch:<物料ID>:<批次号>
+	Type  string      `json:"type"`
+	Label string      `json:"label"`
+	Data  interface{} `json:"data,omitempty"`
+}
+
+// 边由下游指向上游，例如检测记录 → 托盘、物料 → 供应商
+type TraceEdge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Relation string `json:"relation"`
+}
+
+// 追溯结果中的一条产品记录
+type TraceSerial struct {
+	ID             int64     `json:"id"`
+	SN             string    `json:"sn"`
+	BatchNumber    string    `json:"batchNumber"`
+	ProductModelID *uint     `json:"productModelId"`
+	ModelSN        string    `json:"modelSn"`
+	PalletID       *uint     `json:"palletId"`
+	PalletSN       string    `json:"palletSn"`
+	HasDefect      bool      `json:"hasDefect"`
+	DefectReason   string    `json:"defectReason,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// 单个序列号的追溯结果
+type ProductTrace struct {
+	SN           string             `json:"sn"`
+	Product      Product            `json:"product"`         // 最近一次检测记录
+	Attempts     []Product          `json:"attempts"`        // 全部检测记录（含已删除），按时间先后
+	BatchSize    int64              `json:"batchSize"`       // 同物料同批次的检测记录数
+	Batch        *Batch             `json:"batch,omitempty"` // 最近一次检测记录所属的批次
+	Dispositions []BatchDisposition `json:"dispositions"`    // 该批次的冻结和判定历史
+	PalletMates  []TraceSerial      `json:"palletMates"`     // 同托盘的其他产品
+	Events       []ProductEvent     `json:"events"`
+	Nodes        []TraceNode        `json:"nodes"`
+	Edges        []TraceEdge        `json:"edges"`
+}
+
+// 批次或托盘的反向追溯结果
+type TraceSerials struct {
+	Total   int           `json:"total"`   // 检测记录数
+	Serials int           `json:"serials"` // 不重复的序列号数
+	Defects int           `json:"defects"` // 不良记录数
+	Items   []TraceSerial `json:"items"`
+}
+
+// 按序列号汇总
+func NewTraceSerials(items []TraceSerial) TraceSerials {
+	result := TraceSerials{Total: len(items), Items: items}
+	seen := make(map[string]bool)
+	for _, item := range items {
+		if !seen[item.SN] {
+			seen[item.SN] = true
+			result.Serials++
+		}
+		if item.HasDefect {
+			result.Defects++
+		}
+	}
+	return result
+}
@@ -0,0 +1,174 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/config"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/services"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/testutil"
+)
+
+type batchResponse struct {
+	Data     models.Batch
+	Warnings []string
+	Error    string
+}
+
+func batchAction(t *testing.T, h *testutil.Harness, id int64, action string, body interface{}, want int) models.Batch {
+	t.Helper()
+	recorder := h.Do(t, http.MethodPost, fmt.Sprintf("/api/management/batch/%d/%s", id, action), body, h.AdminToken())
+	if recorder.Code != want {
+		t.Fatalf("%s batch %d: %d %s", action, id, recorder.Code, recorder.Body.String())
+	}
+	var response batchResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	return response.Data
+}
+
+func TestBatchLifecycle(t *testing.T) {
+	h := testutil.NewHarness(t)
+	s := h.Factory.SeedScenario()
+	line := h.LineToken(s.ProductLines[0])
+
+	// 产品上报时自动创建批次
+	var list struct {
+		Data       []models.Batch
+		Pagination models.PaginationResult
+	}
+	if err := json.Unmarshal(h.Get(t, fmt.Sprintf("/api/management/batch?productModelId=%d", s.ProductModels[0].ID)), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Data) != 1 || list.Data[0].BatchNumber != "0301" || list.Data[0].Status != models.BatchStatusOpen {
+		t.Fatalf("batches = %+v", list.Data)
+	}
+	batch := list.Data[0]
+	if summary := batch.Summary; summary == nil || summary.Inspected != 5 || summary.Defects != 2 || summary.DefectRate != 40 {
+		t.Fatalf("summary = %+v", batch.Summary)
+	}
+
+	var detail batchResponse
+	if err := json.Unmarshal(h.Get(t, fmt.Sprintf("/api/management/batch/%d", batch.ID)), &detail); err != nil {
+		t.Fatal(err)
+	}
+	if summary := detail.Data.Summary; len(summary.DefectReasons) != 2 || summary.FirstInspectedAt == nil || !summary.FirstInspectedAt.Equal(s.Products[0].CreatedAt) {
+		t.Fatalf("detail summary = %+v", summary)
+	}
+
+	// 冻结后产线上报：默认只警告
+	if held := batchAction(t, h, batch.ID, "hold", map[string]string{"note": "来料尺寸超差"}, http.StatusOK); held.Status != models.BatchStatusOnHold {
+		t.Fatalf("hold: %+v", held)
+	}
+	product := map[string]interface{}{"sn": "MA00001030100099", "hasDefect": true, "defectReason": "划伤"}
+	recorder := h.Do(t, http.MethodPost, "/api/production/product", product, line)
+	var created batchResponse
+	_ = json.Unmarshal(recorder.Body.Bytes(), &created)
+	if recorder.Code != http.StatusCreated || len(created.Warnings) != 1 {
+		t.Fatalf("add product to held batch (warn): %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	// 拒绝模式
+	services.ConfigureBatches(config.QualityConfig{BatchHoldPolicy: models.BatchHoldReject})
+	t.Cleanup(func() { services.ConfigureBatches(config.Default().Quality) })
+	product["sn"] = "MA00001030100100"
+	if recorder := h.Do(t, http.MethodPost, "/api/production/product", product, line); recorder.Code != http.StatusConflict {
+		t.Fatalf("add product to held batch (reject): %d %s", recorder.Code, recorder.Body.String())
+	}
+	var rejected int64
+	if err := h.DB.Model(&models.Product{}).Where("sn = ?", product["sn"]).Count(&rejected).Error; err != nil || rejected != 0 {
+		t.Fatalf("rejected product stored: %d %v", rejected, err)
+	}
+	pallet := map[string]interface{}{"sn": "PALLET-9", "productModelSap": "MA00001", "goal": 10, "batchNumber": "0301"}
+	if recorder := h.Do(t, http.MethodPost, "/api/production/pallet", pallet, line); recorder.Code != http.StatusConflict {
+		t.Fatalf("add pallet for held batch: %d %s", recorder.Code, recorder.Body.String())
+	}
+	delete(pallet, "batchNumber")
+	recorder = h.Do(t, http.MethodPost, "/api/production/pallet", pallet, line)
+	created = batchResponse{}
+	_ = json.Unmarshal(recorder.Body.Bytes(), &created)
+	if recorder.Code != http.StatusCreated || len(created.Warnings) != 1 {
+		t.Fatalf("add pallet without batch: %d %s", recorder.Code, recorder.Body.String())
+	}
+	// 其他批次不受影响，并自动创建新批次
+	product["sn"] = "MA00001039900001"
+	if recorder := h.Do(t, http.MethodPost, "/api/production/product", product, line); recorder.Code != http.StatusCreated {
+		t.Fatalf("add product to new batch: %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	// 状态流转
+	batchAction(t, h, batch.ID, "disposition", map[string]string{"disposition": "scrap"}, http.StatusBadRequest)
+	if sorted := batchAction(t, h, batch.ID, "disposition", map[string]string{"disposition": "sort", "note": "全检挑选"}, http.StatusOK); sorted.Status != models.BatchStatusSorting {
+		t.Fatalf("sort: %+v", sorted)
+	}
+	batchAction(t, h, batch.ID, "release", nil, http.StatusConflict)
+	if accepted := batchAction(t, h, batch.ID, "disposition", map[string]string{"disposition": "concession"}, http.StatusOK); accepted.Status != models.BatchStatusAccepted || accepted.Disposition != models.BatchActionConcession {
+		t.Fatalf("concession: %+v", accepted)
+	}
+	batchAction(t, h, batch.ID, "hold", nil, http.StatusOK)
+	if released := batchAction(t, h, batch.ID, "release", nil, http.StatusOK); released.Status != models.BatchStatusAccepted {
+		t.Fatalf("release should restore accepted: %+v", released)
+	}
+	batchAction(t, h, 9999, "hold", nil, http.StatusNotFound)
+
+	var history struct{ Data []models.BatchDisposition }
+	if err := json.Unmarshal(h.Get(t, fmt.Sprintf("/api/management/batch/%d/dispositions", batch.ID)), &history); err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, d := range history.Data {
+		actions = append(actions, d.Action+":"+d.FromStatus+">"+d.ToStatus)
+	}
+	want := "[hold:open>on_hold sort:on_hold>sorting concession:sorting>accepted hold:accepted>on_hold release:on_hold>accepted]"
+	if fmt.Sprint(actions) != want || history.Data[0].Note != "来料尺寸超差" || history.Data[0].Operator != "admin" {
+		t.Fatalf("history = %v %+v", actions, history.Data[0])
+	}
+
+	// 来料前手动登记并冻结
+	manual := map[string]interface{}{"productModelId": s.ProductModels[1].ID, "batchNumber": "0400", "status": models.BatchStatusAccepted}
+	if recorder := h.Do(t, http.MethodPost, "/api/management/batch", manual, h.AdminToken()); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("add batch with status accepted: %d", recorder.Code)
+	}
+	manual["status"] = models.BatchStatusOnHold
+	recorder = h.Do(t, http.MethodPost, "/api/management/batch", manual, h.AdminToken())
+	var registered batchResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &registered); err != nil || recorder.Code != http.StatusCreated {
+		t.Fatalf("add batch: %d %s", recorder.Code, recorder.Body.String())
+	}
+	if registered.Data.Status != models.BatchStatusOnHold {
+		t.Fatalf("pre-held batch: %+v", registered.Data)
+	}
+	if recorder := h.Do(t, http.MethodPost, "/api/management/batch", manual, h.AdminToken()); recorder.Code != http.StatusConflict {
+		t.Fatalf("duplicate batch: %d", recorder.Code)
+	}
+	if err := json.Unmarshal(h.Get(t, fmt.Sprintf("/api/management/batch/%d/dispositions", registered.Data.ID)), &history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Data) != 1 || history.Data[0].Action != models.BatchActionHold || history.Data[0].FromStatus != models.BatchStatusOpen || history.Data[0].Operator != "admin" {
+		t.Fatalf("pre-held batch history = %+v", history.Data)
+	}
+	product["sn"] = "MB00002040000001"
+	if recorder := h.Do(t, http.MethodPost, "/api/production/product", product, line); recorder.Code != http.StatusConflict {
+		t.Fatalf("add product to pre-held batch: %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	// 追溯结果带批次状态和历史
+	var trace struct{ Data models.ProductTrace }
+	if err := json.Unmarshal(h.Get(t, "/api/management/trace/"+s.Products[0].SN), &trace); err != nil {
+		t.Fatal(err)
+	}
+	if trace.Data.Batch == nil || trace.Data.Batch.Status != models.BatchStatusAccepted || len(trace.Data.Dispositions) != 5 {
+		t.Fatalf("trace batch = %+v, dispositions = %d", trace.Data.Batch, len(trace.Data.Dispositions))
+	}
+
+	if err := json.Unmarshal(h.Get(t, "/api/management/batch?filter=status:eq:on_hold"), &list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Pagination.Total != 1 || list.Data[0].ID != registered.Data.ID {
+		t.Fatalf("on_hold batches = %+v", list.Data)
+	}
+}
@@ -0,0 +1,115 @@
+package routes_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/services"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/testutil"
+)
+
+func enqueue(t *testing.T, h *testutil.Harness, kind string) *models.Job {
+	t.Helper()
+	jobService, _ := services.NewJobService(h.DB)
+	job, err := jobService.Enqueue(kind, map[string]string{"test": t.Name()}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func TestJobRetriesWithBackoff(t *testing.T) {
+	h := testutil.NewHarness(t)
+	h.StartJobWorkers(t)
+
+	// 前两次失败，第三次成功
+	calls := 0
+	services.RegisterJobHandler("test_flaky", func(ctx context.Context, run *services.JobRun) error {
+		calls++
+		if calls < 3 {
+			return errors.New("temporary failure")
+		}
+		run.Progress(1, 1)
+		return nil
+	})
+	services.RegisterJobHandler("test_invalid", func(ctx context.Context, run *services.JobRun) error {
+		return services.PermanentJobError(errors.New("invalid payload"))
+	})
+
+	job := h.WaitJob(t, enqueue(t, h, "test_flaky").ID)
+	if job.Status != models.JobStatusCompleted || job.Attempts != 3 || job.Error != "" || job.Done != 1 {
+		t.Fatalf("flaky job = %+v", job)
+	}
+	job = h.WaitJob(t, enqueue(t, h, "test_invalid").ID)
+	if job.Status != models.JobStatusFailed || job.Attempts != 1 || job.Error != "invalid payload" {
+		t.Fatalf("permanent failure = %+v", job)
+	}
+
+	var list struct {
+		Data       []models.Job
+		Pagination models.PaginationResult
+	}
+	if err := json.Unmarshal(h.Get(t, "/api/management/jobs?status=failed&pageNum=1&pageSize=10"), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Data) != 1 || list.Data[0].ID != job.ID {
+		t.Fatalf("failed jobs = %+v", list.Data)
+	}
+}
+
+func TestCancelRunningJob(t *testing.T) {
+	h := testutil.NewHarness(t)
+	h.StartJobWorkers(t)
+
+	started := make(chan struct{})
+	services.RegisterJobHandler("test_blocking", func(ctx context.Context, run *services.JobRun) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	job := enqueue(t, h, "test_blocking")
+	<-started
+
+	path := fmt.Sprintf("/api/management/jobs/%d", job.ID)
+	if recorder := h.Do(t, http.MethodDelete, path, nil, h.AdminToken()); recorder.Code != http.StatusConflict {
+		t.Fatalf("delete running job: status %d, want 409", recorder.Code)
+	}
+	if recorder := h.Do(t, http.MethodPost, path+"/cancel", nil, h.AdminToken()); recorder.Code != http.StatusOK {
+		t.Fatalf("cancel: status %d, body %s", recorder.Code, recorder.Body.String())
+	}
+	if job := h.WaitJob(t, job.ID); job.Status != models.JobStatusCanceled {
+		t.Fatalf("job = %+v", job)
+	}
+
+	if recorder := h.Do(t, http.MethodDelete, path, nil, h.AdminToken()); recorder.Code != http.StatusOK {
+		t.Fatalf("delete: status %d", recorder.Code)
+	}
+	if recorder := h.Do(t, http.MethodGet, path, nil, h.AdminToken()); recorder.Code != http.StatusNotFound {
+		t.Fatalf("after delete: status %d, want 404", recorder.Code)
+	}
+}
+
+func TestRebuildQualityRollupAsync(t *testing.T) {
+	h := testutil.NewHarness(t)
+	h.Factory.SeedScenario()
+	h.StartJobWorkers(t)
+
+	recorder := h.Do(t, http.MethodPost, "/api/management/quality_rollup/rebuild", map[string]interface{}{
+		"startDate": "2024-03-01", "endDate": "2024-03-03", "async": true,
+	}, h.AdminToken())
+	if recorder.Code != http.StatusAccepted {
+		t.Fatalf("status %d, body %s", recorder.Code, recorder.Body.String())
+	}
+	var queued struct{ Data models.Job }
+	if err := json.Unmarshal(recorder.Body.Bytes(), &queued); err != nil {
+		t.Fatal(err)
+	}
+	if job := h.WaitJob(t, queued.Data.ID); job.Status != models.JobStatusCompleted {
+		t.Fatalf("job = %+v", job)
+	}
+}
@@ -0,0 +1,87 @@
+package routes_test
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/metrics"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/testutil"
+)
+
+// 抓取 /metrics，返回名称以 prefix 开头（含标签）的样本值之和
+func scrape(t *testing.T, h *testutil.Harness, prefix string) float64 {
+	t.Helper()
+	recorder := h.Do(t, http.MethodGet, "/metrics", nil, "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("metrics: status %d", recorder.Code)
+	}
+	var sum float64
+	scanner := bufio.NewScanner(bytes.NewReader(recorder.Body.Bytes()))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		value, err := strconv.ParseFloat(line[strings.LastIndexByte(line, ' ')+1:], 64)
+		if err != nil {
+			t.Fatalf("parse %q: %v", line, err)
+		}
+		sum += value
+	}
+	return sum
+}
+
+func TestMetricsCountersMove(t *testing.T) {
+	h := testutil.NewHarness(t)
+	line := h.Factory.ProductLine(models.ProductLine{IsRegistered: true})
+	h.Factory.ProductModel(models.ProductModel{SN: "MC00003"})
+	pallet := h.Factory.Pallet(models.Pallet{Goal: 2})
+	metrics.ConfigureDefectReasons([]string{"异响"})
+	t.Cleanup(func() { metrics.ConfigureDefectReasons(nil) })
+
+	ingested := fmt.Sprintf(`products_ingested_total{product_line="%d",product_model="MC00003"}`, line.ID)
+	defects := `product_defects_total{reason="异响"}`
+	otherDefects := `product_defects_total{reason="other"}`
+	freeText := `product_defects_total{reason="外壳划痕"}`
+	authFailures := `device_auth_failures_total{reason="invalid_token"}`
+	requests := `http_request_duration_seconds_count{method="POST",route="/api/production/product",status="201"}`
+	queries := `db_query_duration_seconds_count{operation="create",table="products"}`
+
+	before := map[string]float64{}
+	for _, name := range []string{ingested, defects, otherDefects, freeText, authFailures, requests, queries} {
+		before[name] = scrape(t, h, name)
+	}
+
+	// 未配置的不良原因计为 other
+	for i, reason := range []string{"", "异响", "外壳划痕"} {
+		body := map[string]interface{}{"sn": fmt.Sprintf("MC0000303010000%d", i+1), "hasDefect": reason != ""}
+		if reason != "" {
+			body["defectReason"] = reason
+		} else {
+			body["palletId"] = pallet.ID
+		}
+		if recorder := h.Do(t, http.MethodPost, "/api/production/product", body, h.LineToken(line)); recorder.Code != http.StatusCreated {
+			t.Fatalf("add product: status %d, body %s", recorder.Code, recorder.Body.String())
+		}
+	}
+	if recorder := h.Do(t, http.MethodPost, "/api/production/product", map[string]interface{}{"sn": "MC00003030100009"}, "bad-token"); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("invalid token: status %d, want 401", recorder.Code)
+	}
+
+	want := map[string]float64{ingested: 3, defects: 1, otherDefects: 1, freeText: 0, authFailures: 1, requests: 3, queries: 3}
+	for name, delta := range want {
+		if got := scrape(t, h, name) - before[name]; got != delta {
+			t.Errorf("%s increased by %v, want %v", name, got, delta)
+		}
+	}
+	// 托盘目标为 2，只装入了一个正常品
+	if got := scrape(t, h, "open_pallets "); got != 1 {
+		t.Errorf("open_pallets = %v, want 1", got)
+	}
+}
@@ -0,0 +1,194 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/services"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/testutil"
+)
+
+type listPage struct {
+	Data []struct {
+		SN        string `json:"sn"`
+		ProductSN string `json:"productSN"`
+	}
+	Pagination models.PaginationResult
+}
+
+// 沿 nextCursor 逐页读取，返回全部 SN 和每页的分页信息
+func walkCursor(t *testing.T, h *testutil.Harness, path string, query url.Values) ([]string, []models.PaginationResult) {
+	t.Helper()
+	var sns []string
+	var pages []models.PaginationResult
+	for {
+		var page listPage
+		if err := json.Unmarshal(h.Get(t, path+"?"+query.Encode()), &page); err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range page.Data {
+			sns = append(sns, item.SN+item.ProductSN)
+		}
+		pages = append(pages, page.Pagination)
+		if page.Pagination.NextCursor == "" || len(pages) > 20 {
+			return sns, pages
+		}
+		query.Set("cursor", page.Pagination.NextCursor)
+	}
+}
+
+func TestCursorPagination(t *testing.T) {
+	h := testutil.NewHarness(t)
+	s := h.Factory.SeedScenario()
+	// 与最后一个产品创建时间相同的记录，按主键区分先后
+	for _, sn := range []string{"TIE-1", "TIE-2", "TIE-3"} {
+		product := models.Product{SN: sn, ProductModelID: s.Products[9].ProductModelID}
+		product.CreatedAt = s.Products[9].CreatedAt
+		h.Factory.Product(product)
+	}
+
+	for _, asc := range []string{"false", "true"} {
+		want, _ := walkCursor(t, h, "/api/management/product", url.Values{"asc": {asc}})
+		got, pages := walkCursor(t, h, "/api/management/product", url.Values{"keyset": {"true"}, "pageSize": {"4"}, "asc": {asc}})
+		if !reflect.DeepEqual(got, want) || len(got) != 13 {
+			t.Fatalf("asc=%s: cursor order %v, offset order %v", asc, got, want)
+		}
+		if len(pages) != 4 || pages[0].Total != -1 {
+			t.Errorf("asc=%s: pages = %+v", asc, pages)
+		}
+	}
+
+	got, pages := walkCursor(t, h, "/api/management/pallet", url.Values{"keyset": {"true"}, "pageSize": {"1"}, "withTotal": {"true"}})
+	if !reflect.DeepEqual(got, []string{"PALLET-2", "PALLET-1"}) || pages[0].Total != 2 {
+		t.Errorf("pallets = %v, pages = %+v", got, pages)
+	}
+
+	// 不合格报表：03-01 至 03-03 共 5 条不良记录
+	report := "/api/management/report/defect"
+	got, pages = walkCursor(t, h, report, url.Values{"startDate": {"2024-03-01"}, "endDate": {"2024-03-03"}, "keyset": {"true"}, "pageSize": {"2"}, "withTotal": {"true"}})
+	want, _ := walkCursor(t, h, report, url.Values{"startDate": {"2024-03-01"}, "endDate": {"2024-03-03"}, "pageSize": {"-1"}})
+	if !reflect.DeepEqual(got, want) || len(got) != 5 || len(pages) != 3 || pages[0].Total != 5 {
+		t.Errorf("defect report = %v, want %v, pages = %+v", got, want, pages)
+	}
+
+	var sorted listPage
+	if err := json.Unmarshal(h.Get(t, "/api/management/product?sort=-sn&pageNum=1"), &sorted); err != nil {
+		t.Fatal(err)
+	}
+	if sorted.Pagination.PageSize != models.DefaultPageSize || sorted.Pagination.Total != 13 || sorted.Data[0].SN != "TIE-3" {
+		t.Errorf("sort=-sn: %+v, first %+v", sorted.Pagination, sorted.Data[0])
+	}
+
+	var first listPage
+	if err := json.Unmarshal(h.Get(t, "/api/management/product?keyset=true&pageSize=2"), &first); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{
+		"/api/management/product?sort=password",
+		"/api/management/product?keyset=true&sort=sn",
+		"/api/management/product?cursor=not-a-cursor",
+		"/api/management/product?asc=true&cursor=" + first.Pagination.NextCursor,
+		"/api/management/pallet?sort=productModelId",
+		"/api/management/report/defect?sort=defectReason",
+		"/api/management/report/defect?pageSize=-1&keyset=true",
+		"/api/management/report/defect?pageSize=-1&cursor=" + first.Pagination.NextCursor,
+	} {
+		if recorder := h.Do(t, http.MethodGet, path, nil, h.AdminToken()); recorder.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: status %d, want 400", path, recorder.Code)
+		}
+	}
+}
+
+func TestListFilters(t *testing.T) {
+	h := testutil.NewHarness(t)
+	s := h.Factory.SeedScenario()
+
+	list := func(path, filter, sort string) []string {
+		t.Helper()
+		var page listPage
+		if err := json.Unmarshal(h.Get(t, path+"?"+url.Values{"filter": {filter}, "sort": {sort}}.Encode()), &page); err != nil {
+			t.Fatal(err)
+		}
+		if page.Pagination.Total != len(page.Data) {
+			t.Errorf("%s %s: total %d, %d items", path, filter, page.Pagination.Total, len(page.Data))
+		}
+		var sns []string
+		for _, item := range page.Data {
+			sns = append(sns, item.SN)
+		}
+		return sns
+	}
+
+	cases := []struct {
+		path, filter string
+		want         int
+	}{
+		{"/api/management/product", "hasDefect:eq:true", 5},
+		{"/api/management/product", "hasDefect:eq:true;createdAt:gte:2024-03-03", 2},
+		{"/api/management/product", "createdAt:gte:2024-03-01 23:00:00;createdAt:lt:2024-03-03", 2},
+		{"/api/management/product", "defectReason:in:划伤,异响", 5},
+		{"/api/management/product", "defectReason:like:划", 3},
+		{"/api/management/product", "sn:like:%", 0},
+		{"/api/management/product", "productModelId:eq:" + fmt.Sprint(s.ProductModels[1].ID), 5},
+		{"/api/management/product", "productionPlanId:null:true", 10},
+		{"/api/management/pallet", "productLineId:in:" + fmt.Sprint(s.ProductLines[0].ID), 1},
+		{"/api/management/supplier", "name:eq:甲供应商", 1},
+		{"/api/management/product_model", "description:like:电机;supplierId:ne:" + fmt.Sprint(s.Suppliers[0].ID), 1},
+		{"/api/management/production_plan", "manufacturer:eq:乙供应商;totalPlanned:gte:4", 1},
+		{"/api/management/product_line", "isRegistered:eq:false", 2},
+		{"/api/management/user", "username:eq:nobody", 0},
+		{"/api/management/api", "name:like:x", 0},
+	}
+	for _, c := range cases {
+		if got := list(c.path, c.filter, ""); len(got) != c.want {
+			t.Errorf("%s %s: %d items %v, want %d", c.path, c.filter, len(got), got, c.want)
+		}
+	}
+	// 筛选与排序组合
+	if got := list("/api/management/product", "sn:like:MB", "-sn"); len(got) != 5 || got[0] != s.Products[9].SN {
+		t.Errorf("sn:like:MB sorted by -sn = %v", got)
+	}
+
+	for _, path := range []string{
+		"/api/management/user?filter=password:eq:x",
+		"/api/management/product?filter=hasDefect:gt:true",
+		"/api/management/product?filter=hasDefect:eq:maybe",
+		"/api/management/product?filter=palletId:like:1",
+		"/api/management/product?filter=sn:regex:.*",
+		"/api/management/product?filter=hasDefect",
+		"/api/management/product?filter=createdAt:gte:yesterday",
+		"/api/management/production_plan?filter=planDate:eq:2024-13-01",
+		"/api/management/supplier?sort=-password",
+	} {
+		if recorder := h.Do(t, http.MethodGet, path, nil, h.AdminToken()); recorder.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: status %d, want 400", path, recorder.Code)
+		}
+	}
+}
+
+// pageSize=-1 返回全部不良记录，超过 EXPORT_SYNC_MAX_ROWS 时需改用导出接口
+func TestDefectReportAllRows(t *testing.T) {
+	h := testutil.NewHarness(t)
+	h.Factory.SeedScenario()
+	path := "/api/management/report/defect?startDate=2024-03-01&endDate=2024-03-03&pageSize=-1"
+
+	var all listPage
+	if err := json.Unmarshal(h.Get(t, path), &all); err != nil {
+		t.Fatal(err)
+	}
+	if len(all.Data) != 5 || all.Pagination.Total != 5 || all.Pagination.PageSize != 5 || all.Pagination.PageNum != 1 {
+		t.Errorf("pageSize=-1: %d rows, pagination %+v", len(all.Data), all.Pagination)
+	}
+
+	services.ConfigureExports(t.TempDir(), 4)
+	recorder := h.Do(t, http.MethodGet, path, nil, h.AdminToken())
+	if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "/report/defect/export") {
+		t.Errorf("over limit: status %d, body %s", recorder.Code, recorder.Body.String())
+	}
+}
@@ -0,0 +1,90 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/testutil"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+)
+
+// 趋势序列按名称、日期排序，并补齐查询区间内的每一天（含闰日和两端无数据的日期）
+func TestQualityTrendDeterministicAndDense(t *testing.T) {
+	h := testutil.NewHarness(t)
+	h.Factory.SeedScenario()
+	supplier := h.Factory.Supplier(models.Supplier{Name: "丙供应商"})
+	supplierID := uint(supplier.ID)
+	productModel := h.Factory.ProductModel(models.ProductModel{SN: "MC00003", SupplierID: &supplierID})
+	modelID := uint(productModel.ID)
+	product := models.Product{SN: "MC00003030300001", ProductModelID: &modelID, HasDefect: true, DefectReason: "外观不良"}
+	product.CreatedAt = time.Date(2024, 3, 4, 9, 0, 0, 0, utils.PlantLocation())
+	h.Factory.Product(product)
+
+	days := []string{"2024-02-28", "2024-02-29", "2024-03-01", "2024-03-02", "2024-03-03", "2024-03-04", "2024-03-05"}
+	path := "/api/management/quality_stats?startDate=2024-02-28&endDate=2024-03-05"
+
+	// 多次请求的响应逐字节相同，不受 map 遍历顺序影响
+	body := h.Get(t, path)
+	for i := 0; i < 10; i++ {
+		if again := h.Get(t, path); string(again) != string(body) {
+			t.Fatalf("response changed between requests:\n%s\nwant:\n%s", again, body)
+		}
+	}
+
+	var zero struct{ Data models.QualityStatsResponse }
+	if err := json.Unmarshal(body, &zero); err != nil {
+		t.Fatal(err)
+	}
+	stats := zero.Data
+	var names []string
+	for _, trend := range stats.SupplierDefectTrend {
+		names = append(names, trend.SupplierName)
+		if len(trend.DailyData) != len(days) {
+			t.Fatalf("%s: %d points, want %d", trend.SupplierName, len(trend.DailyData), len(days))
+		}
+		for i, point := range trend.DailyData {
+			if point.Date != days[i] || point.TotalCount == nil || point.DefectCount == nil || point.DefectRate == nil {
+				t.Errorf("%s point %d = %+v, want zero-filled %s", trend.SupplierName, i, point, days[i])
+			}
+		}
+	}
+	if !sort.StringsAreSorted(names) || len(names) != 3 {
+		t.Errorf("suppliers = %v, want 3 sorted by name", names)
+	}
+	for name, series := range map[string][]models.DailyDefectCount{
+		"terminalData":   stats.DefectTrendByType.TerminalData,
+		"tagData":        stats.DefectTrendByType.TagData,
+		"appearanceData": stats.DefectTrendByType.AppearanceData,
+		"noiseData":      stats.DefectTrendByType.NoiseData,
+	} {
+		if len(series) != len(days) {
+			t.Fatalf("%s: %d points, want %d", name, len(series), len(days))
+		}
+		for i, point := range series {
+			if point.Date != days[i] || point.Count == nil {
+				t.Errorf("%s point %d = %+v, want zero-filled %s", name, i, point, days[i])
+			}
+		}
+	}
+	if point := stats.DefectTrendByType.AppearanceData[5]; *point.Count != 1 {
+		t.Errorf("appearanceData on 2024-03-04 = %d, want 1", *point.Count)
+	}
+
+	// fill=null 时只有无数据的日期为 null，日期序列不变
+	var null struct{ Data models.QualityStatsResponse }
+	if err := json.Unmarshal(h.Get(t, path+"&fill=null"), &null); err != nil {
+		t.Fatal(err)
+	}
+	for i, trend := range null.Data.SupplierDefectTrend {
+		for j, point := range trend.DailyData {
+			zeroFilled := stats.SupplierDefectTrend[i].DailyData[j]
+			empty := *zeroFilled.TotalCount == 0
+			if point.Date != days[j] || empty != (point.TotalCount == nil) || empty != (point.DefectRate == nil) {
+				t.Errorf("fill=null %s point %d = %+v", trend.SupplierName, j, point)
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package routes_test
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/testutil"
+)
+
+func TestExportDefectsCSV(t *testing.T) {
+	h := testutil.NewHarness(t)
+	h.Factory.SeedScenario()
+
+	recorder := h.Do(t, http.MethodGet, "/api/management/defect/export?startDate=2024-03-01&endDate=2024-03-02", nil, h.AdminToken())
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", recorder.Code, recorder.Body.String())
+	}
+	rows, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(recorder.Body.String(), "\uFEFF"))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 03-01 至 03-02 共 3 个不良品，时间按工厂时区输出
+	if len(rows) != 4 || rows[0][1] != "产品SN" {
+		t.Fatalf("rows = %v", rows)
+	}
+	if rows[1][9] != "划伤" || rows[1][10] != "2024-03-01 09:30:00" || rows[3][10] != "2024-03-02 00:10:00" {
+		t.Errorf("rows = %v", rows)
+	}
+}
+
+func TestExportProductsNDJSON(t *testing.T) {
+	h := testutil.NewHarness(t)
+	h.Factory.SeedScenario()
+
+	recorder := h.Do(t, http.MethodGet, "/api/management/product/export?productModelSN=MA&hasDefect=false&format=ndjson&tz=UTC", nil, h.AdminToken())
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", recorder.Code, recorder.Body.String())
+	}
+	var records []models.ExportRecord
+	scanner := bufio.NewScanner(recorder.Body)
+	for scanner.Scan() {
+		var record models.ExportRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	// MA00001 共 5 个产品，其中 3 个合格
+	if len(records) != 3 || records[0].SupplierName != "乙供应商" || records[0].PalletSN != "PALLET-1" {
+		t.Fatalf("records = %+v", records)
+	}
+	if records[0].CreatedAt.Location().String() != "UTC" || records[0].CreatedAt.Hour() != 0 {
+		t.Errorf("createdAt = %v, want 2024-03-01 00:15 UTC", records[0].CreatedAt)
+	}
+
+	if recorder := h.Do(t, http.MethodGet, "/api/management/product/export?format=xml", nil, h.AdminToken()); recorder.Code != http.StatusBadRequest {
+		t.Errorf("invalid format: status %d, want 400", recorder.Code)
+	}
+}
+
+func TestBackgroundExport(t *testing.T) {
+	h := testutil.NewHarness(t)
+	h.Factory.SeedScenario()
+	h.StartJobWorkers(t)
+
+	recorder := h.Do(t, http.MethodGet, "/api/management/product/export?async=true", nil, h.AdminToken())
+	if recorder.Code != http.StatusAccepted {
+		t.Fatalf("status %d, body %s", recorder.Code, recorder.Body.String())
+	}
+	var queued struct{ Data models.Job }
+	if err := json.Unmarshal(recorder.Body.Bytes(), &queued); err != nil {
+		t.Fatal(err)
+	}
+	if queued.Data.Kind != models.JobKindRecordExport || queued.Data.Total != 10 {
+		t.Fatalf("job = %+v", queued.Data)
+	}
+
+	job := h.WaitJob(t, queued.Data.ID)
+	if job.Status != models.JobStatusCompleted || job.Done != 10 || !strings.HasPrefix(job.ArtifactName, "products_") {
+		t.Fatalf("job = %+v", job)
+	}
+	download := h.Do(t, http.MethodGet, fmt.Sprintf("/api/management/jobs/%d/artifact", job.ID), nil, h.AdminToken())
+	if download.Code != http.StatusOK || strings.Count(download.Body.String(), "\n") != 11 {
+		t.Fatalf("download: status %d, body %s", download.Code, download.Body.String())
+	}
+}
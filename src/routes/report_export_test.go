@@ -0,0 +1,132 @@
+package routes_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/testutil"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestExportInspectionReportXLSX(t *testing.T) {
+	h := testutil.NewHarness(t)
+	h.Factory.SeedScenario()
+
+	recorder := h.Do(t, http.MethodGet, "/api/management/report/inspection/export?startDate=2024-03-01&endDate=2024-03-03&supplierName=甲", nil, h.AdminToken())
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", recorder.Code, recorder.Body.String())
+	}
+	if !strings.Contains(recorder.Header().Get("Content-Disposition"), "filename*=UTF-8''%E6%A3%80%E6%B5%8B%E6%8A%A5%E8%A1%A8_2024-03-01_2024-03-03.xlsx") {
+		t.Errorf("Content-Disposition = %q", recorder.Header().Get("Content-Disposition"))
+	}
+
+	f, err := excelize.OpenReader(bytes.NewReader(recorder.Body.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if sheets := f.GetSheetList(); len(sheets) != 2 || sheets[0] != "检测报表" || sheets[1] != "筛选条件" {
+		t.Fatalf("sheets = %v", sheets)
+	}
+	rows, err := f.GetRows("检测报表")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[0][0] != "检测日期" || rows[0][9] != "合格率" {
+		t.Errorf("header = %v", rows[0])
+	}
+	// 甲供应商（MB00002）共 5 个产品，其中 3 个不良
+	totals := rows[len(rows)-1]
+	if totals[0] != "合计" || totals[6] != "5" || totals[7] != "2" || totals[8] != "3" || totals[9] != "40.00%" {
+		t.Errorf("totals = %v", totals)
+	}
+	if rows[1][0] != "2024-03-03" {
+		t.Errorf("first date cell = %q, want 2024-03-03", rows[1][0])
+	}
+
+	filters, err := f.GetRows("筛选条件")
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := map[string]string{}
+	for _, row := range filters {
+		summary[row[0]] = row[1]
+	}
+	if summary["开始日期"] != "2024-03-01" || summary["生产厂家"] != "甲" || summary["批次号"] != "全部" || summary["时区"] != "Asia/Shanghai" {
+		t.Errorf("filter summary = %v", summary)
+	}
+}
+
+func TestExportCostReportCSV(t *testing.T) {
+	h := testutil.NewHarness(t)
+	h.Factory.SeedScenario()
+
+	recorder := h.Do(t, http.MethodGet, "/api/management/report/cost/export?startDate=2024-03-01&endDate=2024-03-03&format=csv", nil, h.AdminToken())
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", recorder.Code, recorder.Body.String())
+	}
+	body := recorder.Body.String()
+	if !strings.HasPrefix(body, "\uFEFF") {
+		t.Fatal("CSV must start with a UTF-8 BOM")
+	}
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(body, "\uFEFF"))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(records[0], ",") != "检测日期,厂家,物料编码,电机类型,合格数量,不合格数量,总数量" {
+		t.Errorf("header = %v", records[0])
+	}
+	totals := records[len(records)-1]
+	if totals[0] != "合计" || totals[4] != "5" || totals[5] != "5" || totals[6] != "10" {
+		t.Errorf("totals = %v", totals)
+	}
+}
+
+func TestExportDefectReportCSV(t *testing.T) {
+	h := testutil.NewHarness(t)
+	h.Factory.SeedScenario()
+	h.Factory.Shift(models.Shift{Name: "白班", StartTime: "08:00", EndTime: "20:00", Sequence: 1})
+	h.Factory.Shift(models.Shift{Name: "夜班", StartTime: "20:00", EndTime: "08:00", Sequence: 2})
+
+	recorder := h.Do(t, http.MethodGet, "/api/management/report/defect/export?startDate=2024-03-01&endDate=2024-03-03&bucketBy=production&sort=productSN&format=csv", nil, h.AdminToken())
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", recorder.Code, recorder.Body.String())
+	}
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(recorder.Body.String(), "\uFEFF"))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header := strings.Join(records[0], ","); header != "供应商,质检时间,产品SN,物料编码,物料描述,批次号,不良原因,生产日,班次" {
+		t.Errorf("header = %v", header)
+	}
+	// 5 个不良品按产品SN升序，最后一行为合计
+	rows := records[1 : len(records)-1]
+	if len(rows) != 5 {
+		t.Fatalf("rows = %v", rows)
+	}
+	for i := 1; i < len(rows); i++ {
+		if rows[i-1][2] > rows[i][2] {
+			t.Errorf("rows not sorted by product SN: %v", rows)
+		}
+	}
+	for _, row := range rows {
+		if row[7] == "" || row[8] == "" {
+			t.Errorf("missing production day or shift: %v", row)
+		}
+	}
+	if totals := records[len(records)-1]; totals[0] != "合计" || totals[2] != "5" {
+		t.Errorf("totals = %v", totals)
+	}
+}
+
+func TestExportRejectsUnknownFormat(t *testing.T) {
+	h := testutil.NewHarness(t)
+	recorder := h.Do(t, http.MethodGet, "/api/management/report/defect/export?format=pdf", nil, h.AdminToken())
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400", recorder.Code)
+	}
+}
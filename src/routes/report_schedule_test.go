@@ -0,0 +1,203 @@
+package routes_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/config"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/services"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/testutil"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+	"github.com/xuri/excelize/v2"
+)
+
+// 创建计划并将下一次执行时间设为 2024-03-04 07:00（工厂时区）
+func dueSchedule(t *testing.T, h *testutil.Harness, schedule map[string]interface{}) int64 {
+	t.Helper()
+	recorder := h.Do(t, http.MethodPost, "/api/management/report_schedule", schedule, h.AdminToken())
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("create schedule: status %d, body %s", recorder.Code, recorder.Body.String())
+	}
+	var created struct{ Data models.ReportSchedule }
+	if err := json.Unmarshal(recorder.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Data.NextRunAt == nil || !created.Data.NextRunAt.After(time.Now()) {
+		t.Fatalf("nextRunAt = %v", created.Data.NextRunAt)
+	}
+	due := time.Date(2024, 3, 4, 7, 0, 0, 0, utils.PlantLocation())
+	if err := h.DB.Model(&models.ReportSchedule{}).Where("id = ?", created.Data.ID).Update("next_run_at", utils.InDB(due)).Error; err != nil {
+		t.Fatal(err)
+	}
+	return created.Data.ID
+}
+
+func dispatch(t *testing.T, h *testutil.Harness) {
+	t.Helper()
+	now := time.Date(2024, 3, 4, 8, 0, 0, 0, utils.PlantLocation())
+	if n, err := services.DispatchDueReports(h.DB, now); err != nil || n != 1 {
+		t.Fatalf("dispatch = %d, %v", n, err)
+	}
+	// 已加入的计划不会重复加入
+	if n, err := services.DispatchDueReports(h.DB, now); err != nil || n != 0 {
+		t.Fatalf("second dispatch = %d, %v", n, err)
+	}
+	var job models.Job
+	if err := h.DB.Where("kind = ?", models.JobKindReport).Last(&job).Error; err != nil {
+		t.Fatal(err)
+	}
+	if job := h.WaitJob(t, job.ID); job.Status != models.JobStatusCompleted {
+		t.Fatalf("job = %+v", job)
+	}
+}
+
+func TestScheduledReportToFolder(t *testing.T) {
+	h := testutil.NewHarness(t)
+	h.Factory.SeedScenario()
+	dir := t.TempDir()
+	services.ConfigureReports(config.ReportsConfig{FolderDir: dir})
+	t.Cleanup(func() { services.ConfigureReports(config.Default().Reports) })
+	h.StartJobWorkers(t)
+
+	// 未配置字体时不接受 pdf 格式，xlsx 不受影响
+	if recorder := h.Do(t, http.MethodPost, "/api/management/report_schedule", map[string]interface{}{
+		"name": "检测日报", "report": models.ReportDailyInspection, "cron": "0 7 * * *", "formats": "xlsx,pdf", "channel": "folder", "target": "daily",
+	}, h.AdminToken()); recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "REPORT_FONT_PATH") {
+		t.Fatalf("pdf without font: status %d, body %s", recorder.Code, recorder.Body.String())
+	}
+	services.ConfigureReports(config.ReportsConfig{FolderDir: dir, FontPath: testutil.ReportFont(t)})
+
+	if recorder := h.Do(t, http.MethodPost, "/api/management/report_schedule", map[string]interface{}{
+		"name": "检测日报", "report": models.ReportDailyInspection, "cron": "0 7 * *", "formats": "pdf", "channel": "folder", "target": "daily",
+	}, h.AdminToken()); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("invalid cron: status %d, want 400", recorder.Code)
+	}
+	if recorder := h.Do(t, http.MethodPost, "/api/management/report_schedule", map[string]interface{}{
+		"name": "检测日报", "report": models.ReportDailyInspection, "cron": "0 7 * * *", "formats": "pdf", "channel": "folder", "target": "../etc",
+	}, h.AdminToken()); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("folder outside root: status %d, want 400", recorder.Code)
+	}
+
+	id := dueSchedule(t, h, map[string]interface{}{
+		"name": "检测日报", "report": models.ReportDailyInspection, "cron": "0 7 * * *", "formats": "pdf,xlsx", "channel": "folder", "target": "daily",
+	})
+	dispatch(t, h)
+
+	// 前一天（03-03）的检测报表：4 个产品，2 个不良
+	pdf, err := os.ReadFile(filepath.Join(dir, "daily", "检测报表_2024-03-03_2024-03-03.pdf"))
+	if err != nil || !bytes.HasPrefix(pdf, []byte("%PDF")) {
+		t.Fatalf("pdf: %v", err)
+	}
+	f, err := excelize.OpenFile(filepath.Join(dir, "daily", "检测报表_2024-03-03_2024-03-03.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, _ := f.GetRows("检测报表")
+	if totals := rows[len(rows)-1]; totals[0] != "合计" || totals[6] != "4" || totals[8] != "2" {
+		t.Errorf("totals = %v", totals)
+	}
+
+	var runs struct{ Data []models.ReportRun }
+	if err := json.Unmarshal(h.Get(t, fmt.Sprintf("/api/management/report_schedule/%d/runs", id)), &runs); err != nil {
+		t.Fatal(err)
+	}
+	if len(runs.Data) != 1 || runs.Data[0].Status != models.ReportRunSucceeded || runs.Data[0].PeriodStart != "2024-03-03" ||
+		runs.Data[0].Trigger != models.ReportTriggerSchedule || !strings.Contains(runs.Data[0].Files, ".xlsx") {
+		t.Fatalf("runs = %+v", runs.Data)
+	}
+}
+
+func TestScheduledReportToWebhook(t *testing.T) {
+	h := testutil.NewHarness(t)
+	h.Factory.SeedScenario()
+	h.StartJobWorkers(t)
+
+	var meta map[string]interface{}
+	var files []string
+	var workbook []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		reader := multipart.NewReader(r.Body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+			data, _ := io.ReadAll(part)
+			if part.FormName() == "report" {
+				json.Unmarshal(data, &meta)
+			} else {
+				files = append(files, part.FileName())
+				workbook = data
+			}
+		}
+	}))
+	defer server.Close()
+
+	dueSchedule(t, h, map[string]interface{}{
+		"name": "供应商周排名", "report": models.ReportWeeklySupplierRanking, "cron": "0 7 * * 1", "formats": "xlsx", "channel": "webhook", "target": server.URL,
+	})
+	dispatch(t, h)
+
+	if meta["periodStart"] != "2024-02-26" || meta["periodEnd"] != "2024-03-03" || len(files) != 1 || files[0] != "供应商不良排名_2024-02-26_2024-03-03.xlsx" {
+		t.Fatalf("meta = %v, files = %v", meta, files)
+	}
+	f, err := excelize.OpenReader(bytes.NewReader(workbook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, _ := f.GetRows("供应商不良排名")
+	// 甲供应商 3/5 不良，排在乙供应商 2/5 之前
+	if len(rows) != 4 || rows[1][1] != "甲供应商" || rows[1][4] != "60.00%" || rows[2][1] != "乙供应商" {
+		t.Fatalf("rows = %v", rows)
+	}
+}
+
+// 字体文件无效时在加载字体处失败，执行记录中保留原因
+func TestScheduledReportInvalidFont(t *testing.T) {
+	h := testutil.NewHarness(t)
+	h.Factory.SeedScenario()
+	dir := t.TempDir()
+	font := filepath.Join(dir, "font.ttf")
+	if err := os.WriteFile(font, []byte("not a font"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	services.ConfigureReports(config.ReportsConfig{FolderDir: dir, FontPath: font})
+	t.Cleanup(func() { services.ConfigureReports(config.Default().Reports) })
+	h.StartJobWorkers(t)
+
+	id := dueSchedule(t, h, map[string]interface{}{
+		"name": "检测日报", "report": models.ReportDailyInspection, "cron": "0 7 * * *", "formats": "pdf", "channel": "folder", "target": "daily",
+	})
+	recorder := h.Do(t, http.MethodPost, fmt.Sprintf("/api/management/report_schedule/%d/run", id), nil, h.AdminToken())
+	if recorder.Code != http.StatusAccepted {
+		t.Fatalf("run: status %d, body %s", recorder.Code, recorder.Body.String())
+	}
+	var queued struct{ Data models.Job }
+	if err := json.Unmarshal(recorder.Body.Bytes(), &queued); err != nil {
+		t.Fatal(err)
+	}
+	if job := h.WaitJob(t, queued.Data.ID); job.Status != models.JobStatusFailed || !strings.Contains(job.Error, "REPORT_FONT_PATH") {
+		t.Fatalf("job = %+v", job)
+	}
+
+	// 之后移除字体配置，已有的 pdf 计划不能再手动执行
+	services.ConfigureReports(config.ReportsConfig{FolderDir: dir})
+	if recorder := h.Do(t, http.MethodPost, fmt.Sprintf("/api/management/report_schedule/%d/run", id), nil, h.AdminToken()); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("run without font: status %d, want 400", recorder.Code)
+	}
+}
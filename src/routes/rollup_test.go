@@ -0,0 +1,158 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/services"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/testutil"
+)
+
+func inspectionCounts(t *testing.T, h *testutil.Harness, path string) map[string]int {
+	t.Helper()
+	var response struct{ Data []models.InspectionReportItem }
+	if err := json.Unmarshal(h.Get(t, path), &response); err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for _, item := range response.Data {
+		counts[item.InspectionDate] += item.InspectionCount
+	}
+	return counts
+}
+
+// 与存储时区相差半小时的时区，日界落在小时预聚合的中间，报表和统计改为按原始数据分日
+func TestReportsInHalfHourTimezone(t *testing.T) {
+	h := testutil.NewHarness(t)
+	// Asia/Kolkata 为 UTC+05:30：18:10Z 为 03-01 23:40，18:40Z 为 03-02 00:10，两者在同一个 UTC 小时内
+	for _, createdAt := range []time.Time{
+		time.Date(2024, 3, 1, 18, 10, 0, 0, time.UTC),
+		time.Date(2024, 3, 1, 18, 40, 0, 0, time.UTC),
+	} {
+		product := models.Product{}
+		product.CreatedAt = createdAt
+		h.Factory.Product(product)
+	}
+
+	for _, path := range []string{
+		"/api/management/report/inspection?startDate=2024-03-01&endDate=2024-03-02&tz=Asia/Kolkata&pageNum=1&pageSize=20",
+		"/api/management/report/inspection?tz=Asia/Kolkata&pageNum=1&pageSize=20",
+	} {
+		counts := inspectionCounts(t, h, path)
+		if counts["2024-03-01"] != 1 || counts["2024-03-02"] != 1 {
+			t.Errorf("GET %s: counts = %v, want one product on each day", path, counts)
+		}
+	}
+
+	var aggregate struct {
+		Data models.QualityAggregateResponse
+	}
+	body := h.Get(t, "/api/management/quality_stats/aggregate?startDate=2024-03-01&endDate=2024-03-02&granularity=hour&tz=Asia/Kolkata")
+	if err := json.Unmarshal(body, &aggregate); err != nil {
+		t.Fatal(err)
+	}
+	buckets := make(map[string]float64)
+	for _, row := range aggregate.Data.Rows {
+		buckets[row.Bucket] += row.Measures[models.MeasureTotalCount]
+	}
+	if buckets["2024-03-01 23:00"] != 1 || buckets["2024-03-02 00:00"] != 1 {
+		t.Errorf("hour buckets = %v, want one product at 23:00 and 00:00", buckets)
+	}
+}
+
+// 校验发现预聚合不一致后，报表改为读取原始数据，直到重建该区间
+func TestReportsFallBackToRawWhenRollupStale(t *testing.T) {
+	h := testutil.NewHarness(t)
+	h.Factory.SeedScenario()
+	path := "/api/management/report/inspection?startDate=2024-03-01&endDate=2024-03-03&pageNum=1&pageSize=20"
+	expected := inspectionCounts(t, h, path)
+
+	// 模拟预聚合漂移
+	if err := h.DB.Exec("UPDATE quality_hourly_rollups SET total_count = total_count + 5").Error; err != nil {
+		t.Fatal(err)
+	}
+	if counts := inspectionCounts(t, h, path); counts["2024-03-01"] == expected["2024-03-01"] {
+		t.Fatalf("drifted rollup not read: counts = %v", counts)
+	}
+
+	recorder := h.Do(t, http.MethodGet, "/api/management/quality_rollup/check?startDate=2024-03-01&endDate=2024-03-03", nil, h.AdminToken())
+	var check struct{ Data models.RollupCheckResult }
+	if err := json.Unmarshal(recorder.Body.Bytes(), &check); err != nil {
+		t.Fatal(err)
+	}
+	if check.Data.Consistent {
+		t.Fatalf("check = %+v, want inconsistent", check.Data)
+	}
+	counts := inspectionCounts(t, h, path)
+	for day, count := range expected {
+		if counts[day] != count {
+			t.Errorf("after check: %s count = %d, want %d", day, counts[day], count)
+		}
+	}
+
+	recorder = h.Do(t, http.MethodPost, "/api/management/quality_rollup/rebuild", map[string]interface{}{
+		"startDate": "2024-03-01", "endDate": "2024-03-03",
+	}, h.AdminToken())
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("rebuild: status %d, body %s", recorder.Code, recorder.Body.String())
+	}
+	var stale int64
+	if err := h.DB.Model(&models.QualityRollupStaleRange{}).Count(&stale).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stale != 0 {
+		t.Errorf("stale ranges after rebuild = %d, want 0", stale)
+	}
+}
+
+// 工厂时区与生成预聚合时不同，启动时全量重建日预聚合
+func TestBackfillRebuildsAfterPlantTimezoneChange(t *testing.T) {
+	h := testutil.NewHarness(t)
+	h.Factory.SeedScenario()
+
+	rollupService, _ := services.NewQualityRollupService(h.DB)
+	if err := rollupService.Backfill(); err != nil {
+		t.Fatal(err)
+	}
+	var daily []models.QualityDailyRollup
+	if err := h.DB.Order("id").Find(&daily).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 按其他时区生成的日预聚合，日期与当前工厂时区不符
+	if err := h.DB.Exec("DELETE FROM quality_daily_rollups").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := h.DB.Create(&models.QualityDailyRollup{Day: "1999-01-01", TotalCount: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := h.DB.Model(&models.QualityRollupState{}).Where("1 = 1").Update("plant_timezone", "UTC").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := rollupService.Backfill(); err != nil {
+		t.Fatal(err)
+	}
+
+	var rebuilt []models.QualityDailyRollup
+	if err := h.DB.Order("day, product_line_id, product_model_id, batch_number, defect_reason").Find(&rebuilt).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(rebuilt) != len(daily) {
+		t.Fatalf("daily rollup = %+v, want %+v", rebuilt, daily)
+	}
+	for _, row := range rebuilt {
+		if row.Day == "1999-01-01" {
+			t.Fatalf("daily rollup not rebuilt: %+v", row)
+		}
+	}
+	var state models.QualityRollupState
+	if err := h.DB.Take(&state).Error; err != nil {
+		t.Fatal(err)
+	}
+	if state.PlantTimezone == "UTC" {
+		t.Errorf("plant timezone not updated: %+v", state)
+	}
+}
@@ -1,18 +1,52 @@
 package routes
 
 import (
+	"context"
+	"time"
+
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/controllers"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/health"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/logging"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/metrics"
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/middlewares"
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/tracing"
 	"github.com/dreamskynl/godi"
 	"github.com/gin-gonic/gin"
 )
 
+// 就绪检查的总超时时间
+const readinessTimeout = 5 * time.Second
+
 func RegisterRoute(r *gin.Engine, sc godi.IGoDI) {
-	// 健康检查端点（不需要认证）
+	r.Use(tracing.Middleware(), logging.Middleware(), metrics.Middleware())
+
+	// 健康检查端点（不需要认证），停机过程中返回 503
 	r.GET("/health", func(c *gin.Context) {
+		if !health.Ready() {
+			c.JSON(503, gin.H{"status": "shutting_down"})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+	// 存活检查：进程能处理请求即返回 200
+	r.GET("/livez", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
+	// 就绪检查：运行全部已注册的检查，任一失败或停机过程中返回 503
+	r.GET("/readyz", func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+		defer cancel()
+		report := health.Default.Run(ctx)
+		if report.Status != health.StatusOK {
+			c.JSON(503, report)
+			return
+		}
+		c.JSON(200, report)
+	})
+
+	// Prometheus 指标（不需要认证）
+	r.GET("/metrics", metrics.Handler())
 
 	registerManagementRoutes(r.Group("/api/management"), sc)
 	registerProductionRoutes(r.Group("/api/production"), sc)
@@ -53,6 +87,14 @@ func registerManagementRoutes(r *gin.RouterGroup, sc godi.IGoDI) {
 		r.GET("/pallet", func(c *gin.Context) { controllers.NewManagementController(c, sc).GetPallets() })
 		r.GET("/pallet/:id", func(c *gin.Context) { controllers.NewManagementController(c, sc).GetPallet() })
 
+		r.POST("/shift", func(c *gin.Context) { controllers.NewManagementController(c, sc).AddShift() })
+		r.DELETE("/shift", func(c *gin.Context) { controllers.NewManagementController(c, sc).DeleteShift() })
+		r.GET("/shift", func(c *gin.Context) { controllers.NewManagementController(c, sc).GetShifts() })
+		r.PUT("/shift", func(c *gin.Context) { controllers.NewManagementController(c, sc).UpdateShift() })
+		r.POST("/holiday", func(c *gin.Context) { controllers.NewManagementController(c, sc).AddHoliday() })
+		r.DELETE("/holiday", func(c *gin.Context) { controllers.NewManagementController(c, sc).DeleteHoliday() })
+		r.GET("/holiday", func(c *gin.Context) { controllers.NewManagementController(c, sc).GetHolidays() })
+
 		r.GET("/product", func(c *gin.Context) { controllers.NewManagementController(c, sc).GetProducts() })
 		r.GET("/product/:id", func(c *gin.Context) { controllers.NewManagementController(c, sc).GetProduct() })
 
@@ -70,11 +112,62 @@ func registerManagementRoutes(r *gin.RouterGroup, sc godi.IGoDI) {
 
 		// 质量统计相关接口
 		r.GET("/quality_stats", func(c *gin.Context) { controllers.NewManagementController(c, sc).GetQualityStats() })
+		r.GET("/quality_stats/aggregate", func(c *gin.Context) { controllers.NewManagementController(c, sc).GetQualityAggregate() })
+		r.POST("/quality_rollup/rebuild", func(c *gin.Context) { controllers.NewManagementController(c, sc).RebuildQualityRollup() })
+		r.GET("/quality_rollup/check", func(c *gin.Context) { controllers.NewManagementController(c, sc).CheckQualityRollup() })
 
 		// 数据报表相关接口
 		r.GET("/report/defect", func(c *gin.Context) { controllers.NewManagementController(c, sc).GetDefectReport() })
 		r.GET("/report/inspection", func(c *gin.Context) { controllers.NewManagementController(c, sc).GetInspectionReport() })
 		r.GET("/report/cost", func(c *gin.Context) { controllers.NewManagementController(c, sc).GetCostReport() })
+		r.GET("/report/defect/export", func(c *gin.Context) { controllers.NewManagementController(c, sc).ExportDefectReport() })
+		r.GET("/report/inspection/export", func(c *gin.Context) { controllers.NewManagementController(c, sc).ExportInspectionReport() })
+		r.GET("/report/cost/export", func(c *gin.Context) { controllers.NewManagementController(c, sc).ExportCostReport() })
+		r.GET("/product/export", func(c *gin.Context) { controllers.NewManagementController(c, sc).ExportProducts() })
+		r.GET("/defect/export", func(c *gin.Context) { controllers.NewManagementController(c, sc).ExportDefects() })
+
+		// 后台任务
+		r.GET("/jobs", func(c *gin.Context) { controllers.NewManagementController(c, sc).GetJobs() })
+		r.GET("/jobs/:id", func(c *gin.Context) { controllers.NewManagementController(c, sc).GetJob() })
+		r.POST("/jobs/:id/cancel", func(c *gin.Context) { controllers.NewManagementController(c, sc).CancelJob() })
+		r.DELETE("/jobs/:id", func(c *gin.Context) { controllers.NewManagementController(c, sc).DeleteJob() })
+		r.GET("/jobs/:id/artifact", func(c *gin.Context) { controllers.NewManagementController(c, sc).DownloadJobArtifact() })
+
+		// 定时报表
+		r.POST("/report_schedule", func(c *gin.Context) { controllers.NewManagementController(c, sc).AddReportSchedule() })
+		r.GET("/report_schedule", func(c *gin.Context) { controllers.NewManagementController(c, sc).GetReportSchedules() })
+		r.GET("/report_schedule/:id", func(c *gin.Context) { controllers.NewManagementController(c, sc).GetReportSchedule() })
+		r.PUT("/report_schedule", func(c *gin.Context) { controllers.NewManagementController(c, sc).UpdateReportSchedule() })
+		r.DELETE("/report_schedule", func(c *gin.Context) { controllers.NewManagementController(c, sc).DeleteReportSchedule() })
+		r.POST("/report_schedule/:id/run", func(c *gin.Context) { controllers.NewManagementController(c, sc).RunReportSchedule() })
+		r.GET("/report_schedule/:id/runs", func(c *gin.Context) { controllers.NewManagementController(c, sc).GetReportRuns() })
+
+		// 搜索
+		r.GET("/search", func(c *gin.Context) { controllers.NewManagementController(c, sc).Search() })
+		r.POST("/search/reindex", func(c *gin.Context) { controllers.NewManagementController(c, sc).ReindexSearch() })
+
+		// 追溯
+		r.GET("/trace/:sn", func(c *gin.Context) { controllers.NewManagementController(c, sc).TraceProduct() })
+		r.GET("/trace/batch/:batchNumber", func(c *gin.Context) { controllers.NewManagementController(c, sc).TraceBatch() })
+		r.GET("/trace/pallet/:id", func(c *gin.Context) { controllers.NewManagementController(c, sc).TracePallet() })
+
+		// 批次
+		r.POST("/batch", func(c *gin.Context) { controllers.NewManagementController(c, sc).AddBatch() })
+		r.GET("/batch", func(c *gin.Context) { controllers.NewManagementController(c, sc).GetBatches() })
+		r.GET("/batch/:id", func(c *gin.Context) { controllers.NewManagementController(c, sc).GetBatch() })
+		r.POST("/batch/:id/hold", func(c *gin.Context) { controllers.NewManagementController(c, sc).HoldBatch() })
+		r.POST("/batch/:id/release", func(c *gin.Context) { controllers.NewManagementController(c, sc).ReleaseBatch() })
+		r.POST("/batch/:id/disposition", func(c *gin.Context) { controllers.NewManagementController(c, sc).DisposeBatch() })
+		r.GET("/batch/:id/dispositions", func(c *gin.Context) { controllers.NewManagementController(c, sc).GetBatchDispositions() })
+		r.GET("/batch/:id/sampling", func(c *gin.Context) { controllers.NewManagementController(c, sc).GetBatchSampling() })
+
+		// 抽样方案
+		r.POST("/sampling_plan", func(c *gin.Context) { controllers.NewManagementController(c, sc).AddSamplingPlan() })
+		r.GET("/sampling_plan", func(c *gin.Context) { controllers.NewManagementController(c, sc).GetSamplingPlans() })
+		r.GET("/sampling_plan/:id", func(c *gin.Context) { controllers.NewManagementController(c, sc).GetSamplingPlan() })
+		r.PUT("/sampling_plan", func(c *gin.Context) { controllers.NewManagementController(c, sc).UpdateSamplingPlan() })
+		r.DELETE("/sampling_plan", func(c *gin.Context) { controllers.NewManagementController(c, sc).DeleteSamplingPlan() })
+		r.POST("/sampling_plan/:id/reset", func(c *gin.Context) { controllers.NewManagementController(c, sc).ResetSamplingPlan() })
 	}
 }
 
@@ -91,5 +184,6 @@ func registerProductionRoutes(r *gin.RouterGroup, sc godi.IGoDI) {
 		r.POST("/pallet", func(c *gin.Context) { controllers.NewProductionController(c, sc).AddPallet() })
 
 		r.POST("/product", func(c *gin.Context) { controllers.NewProductionController(c, sc).AddProduct() })
+		r.GET("/sampling", func(c *gin.Context) { controllers.NewProductionController(c, sc).GetSampling() })
 	}
 }
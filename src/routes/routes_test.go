@@ -0,0 +1,173 @@
+package routes_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/health"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/testutil"
+)
+
+// go test ./src/routes -update 重新生成 testdata 下的期望结果
+var update = flag.Bool("update", false, "update golden files")
+
+// 与 testdata/<name>.golden.json 比较格式化后的响应
+func assertGolden(t *testing.T, name string, body []byte) {
+	t.Helper()
+	var formatted bytes.Buffer
+	if err := json.Indent(&formatted, body, "", "  "); err != nil {
+		t.Fatalf("response is not JSON: %v\n%s", err, body)
+	}
+	formatted.WriteByte('\n')
+
+	path := filepath.Join("testdata", name+".golden.json")
+	if *update {
+		if err := os.WriteFile(path, formatted.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(expected, formatted.Bytes()) {
+		t.Errorf("response differs from %s (run with -update to accept)\n--- got ---\n%s", path, formatted.String())
+	}
+}
+
+func TestStatsAndReportEndpoints(t *testing.T) {
+	h := testutil.NewHarness(t)
+	h.Factory.SeedScenario()
+	h.Factory.Shift(models.Shift{Name: "白班", StartTime: "08:00", EndTime: "20:00", Sequence: 1})
+	h.Factory.Shift(models.Shift{Name: "夜班", StartTime: "20:00", EndTime: "08:00", Sequence: 2})
+
+	cases := []struct {
+		name string
+		path string
+	}{
+		{"quality_stats", "/api/management/quality_stats?startDate=2024-03-01&endDate=2024-03-03"},
+		{"quality_stats_fill_null", "/api/management/quality_stats?startDate=2024-03-01&endDate=2024-03-03&fill=null"},
+		{"quality_stats_production", "/api/management/quality_stats?startDate=2024-03-01&endDate=2024-03-03&bucketBy=production"},
+		{"quality_stats_compare", "/api/management/quality_stats?startDate=2024-03-02&endDate=2024-03-03&compare=previous"},
+		{"quality_stats_utc", "/api/management/quality_stats?startDate=2024-03-01&endDate=2024-03-03&tz=UTC"},
+		{"quality_aggregate_day", "/api/management/quality_stats/aggregate?startDate=2024-03-01&endDate=2024-03-03&granularity=day&dimensions=supplier"},
+		{"quality_aggregate_shift", "/api/management/quality_stats/aggregate?startDate=2024-03-01&endDate=2024-03-03&granularity=shift&dimensions=product_line"},
+		{"quality_aggregate_raw", "/api/management/quality_stats/aggregate?startDate=2024-03-01&endDate=2024-03-03&dimensions=defect_reason&source=raw"},
+		{"report_defect", "/api/management/report/defect?startDate=2024-03-01&endDate=2024-03-03&pageNum=1&pageSize=20"},
+		{"report_defect_production", "/api/management/report/defect?startDate=2024-03-01&endDate=2024-03-03&bucketBy=production&pageNum=1&pageSize=20"},
+		{"report_inspection", "/api/management/report/inspection?startDate=2024-03-01&endDate=2024-03-03&pageNum=1&pageSize=20"},
+		{"report_inspection_compare", "/api/management/report/inspection?startDate=2024-03-03&endDate=2024-03-03&compare=previous&pageNum=1&pageSize=20"},
+		{"report_inspection_production", "/api/management/report/inspection?startDate=2024-03-01&endDate=2024-03-03&bucketBy=production&pageNum=1&pageSize=20"},
+		{"report_inspection_tz", "/api/management/report/inspection?startDate=2024-03-01&endDate=2024-03-03&tz=America/Los_Angeles&pageNum=1&pageSize=20"},
+		{"report_cost", "/api/management/report/cost?startDate=2024-03-01&endDate=2024-03-03&pageNum=1&pageSize=20"},
+		{"report_cost_page", "/api/management/report/cost?startDate=2024-03-01&endDate=2024-03-03&pageNum=2&pageSize=2"},
+		{"report_cost_production", "/api/management/report/cost?bucketBy=production&pageSize=-1"},
+		{"production_plan_date", "/api/management/production_plan/date?date=2024-03-01"},
+		{"quality_rollup_check", "/api/management/quality_rollup/check?startDate=2024-03-01&endDate=2024-03-03"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assertGolden(t, tc.name, h.Get(t, tc.path))
+		})
+	}
+}
+
+func TestHealthReflectsReadiness(t *testing.T) {
+	h := testutil.NewHarness(t)
+	t.Cleanup(func() { health.SetReady(false) })
+
+	health.SetReady(true)
+	if recorder := h.Do(t, http.MethodGet, "/health", nil, ""); recorder.Code != http.StatusOK {
+		t.Fatalf("ready: status %d, want 200", recorder.Code)
+	}
+	health.SetReady(false)
+	if recorder := h.Do(t, http.MethodGet, "/health", nil, ""); recorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("shutting down: status %d, want 503", recorder.Code)
+	}
+}
+
+func TestLivenessAndReadiness(t *testing.T) {
+	h := testutil.NewHarness(t)
+	health.SetReady(true)
+	t.Cleanup(func() { health.SetReady(false) })
+	health.Register("database", health.DatabaseCheck(h.DB, 0))
+	health.Register("migrations", health.MigrationsCheck(h.DB))
+
+	if recorder := h.Do(t, http.MethodGet, "/livez", nil, ""); recorder.Code != http.StatusOK {
+		t.Fatalf("livez: status %d", recorder.Code)
+	}
+	recorder := h.Do(t, http.MethodGet, "/readyz", nil, "")
+	var report health.Report
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Code != http.StatusOK || report.Status != health.StatusOK || len(report.Checks) != 2 {
+		t.Fatalf("readyz: status %d, body %s", recorder.Code, recorder.Body.String())
+	}
+
+	// 关闭数据库后数据库检查失败，返回 503 及失败原因
+	sqlDB, _ := h.DB.DB()
+	sqlDB.Close()
+	recorder = h.Do(t, http.MethodGet, "/readyz", nil, "")
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Code != http.StatusServiceUnavailable || report.Checks[0].Status != health.StatusFailed || report.Checks[0].Error == "" {
+		t.Fatalf("readyz with closed database: status %d, body %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestManagementRoutesRequireToken(t *testing.T) {
+	h := testutil.NewHarness(t)
+	recorder := h.Do(t, http.MethodGet, "/api/management/quality_stats?startDate=2024-03-01&endDate=2024-03-01", nil, "")
+	if recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("status %d, want 401", recorder.Code)
+	}
+}
+
+func TestInvalidQueryParameters(t *testing.T) {
+	h := testutil.NewHarness(t)
+	paths := []string{
+		"/api/management/quality_stats?startDate=2024-03-01&endDate=2024-03-03&tz=Mars/Olympus",
+		"/api/management/quality_stats?startDate=2024-03-01&endDate=2024-03-03&compare=custom",
+		"/api/management/quality_stats/aggregate?startDate=2024-03-01&endDate=2024-03-03&dimensions=unknown",
+	}
+	for _, path := range paths {
+		recorder := h.Do(t, http.MethodGet, path, nil, h.AdminToken())
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: status %d, want 400 (body %s)", path, recorder.Code, recorder.Body.String())
+		}
+	}
+}
+
+func TestAddProductUpdatesRollups(t *testing.T) {
+	h := testutil.NewHarness(t)
+	line := h.Factory.ProductLine(models.ProductLine{IsRegistered: true})
+	h.Factory.ProductModel(models.ProductModel{SN: "MC00003"})
+
+	recorder := h.Do(t, http.MethodPost, "/api/production/product", map[string]interface{}{
+		"sn":           "MC00003030100001",
+		"hasDefect":    true,
+		"defectReason": "划伤",
+	}, h.LineToken(line))
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("add product: status %d, body %s", recorder.Code, recorder.Body.String())
+	}
+
+	var totals struct{ Total, Defect int64 }
+	if err := h.DB.Table("quality_hourly_rollups").
+		Select("SUM(total_count) as total, SUM(defect_count) as defect").
+		Scan(&totals).Error; err != nil {
+		t.Fatal(err)
+	}
+	if totals.Total != 1 || totals.Defect != 1 {
+		t.Fatalf("hourly rollup totals = %+v, want 1/1", totals)
+	}
+}
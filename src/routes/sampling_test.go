@@ -0,0 +1,262 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/testutil"
+)
+
+type samplingResponse struct {
+	Data  *models.BatchSampling
+	Error string
+}
+
+type samplingPlanResponse struct {
+	Data  models.SamplingPlan
+	Error string
+}
+
+func getSampling(t *testing.T, h *testutil.Harness, token, query string) *models.BatchSampling {
+	t.Helper()
+	recorder := h.Do(t, http.MethodGet, "/api/production/sampling?"+query, nil, token)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("sampling %s: %d %s", query, recorder.Code, recorder.Body.String())
+	}
+	var response samplingResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	return response.Data
+}
+
+// 产线上报 MA00001 同一批次的 count 个产品，SN 的流水号从 start 开始；良品装入 pallet
+func reportProducts(t *testing.T, h *testutil.Harness, token string, pallet *models.Pallet, batchNumber string, start, count int, defect bool) {
+	t.Helper()
+	for i := start; i < start+count; i++ {
+		product := map[string]interface{}{"sn": fmt.Sprintf("MA00001%s%05d", batchNumber, i), "hasDefect": defect}
+		if defect {
+			product["defectReason"] = "异响"
+		} else {
+			product["palletId"] = pallet.ID
+		}
+		if recorder := h.Do(t, http.MethodPost, "/api/production/product", product, token); recorder.Code != http.StatusCreated {
+			t.Fatalf("add product %v: %d %s", product["sn"], recorder.Code, recorder.Body.String())
+		}
+	}
+}
+
+func getSamplingPlan(t *testing.T, h *testutil.Harness, id int64) models.SamplingPlan {
+	t.Helper()
+	var response samplingPlanResponse
+	if err := json.Unmarshal(h.Get(t, fmt.Sprintf("/api/management/sampling_plan/%d", id)), &response); err != nil {
+		t.Fatal(err)
+	}
+	return response.Data
+}
+
+func TestSamplingPlans(t *testing.T) {
+	h := testutil.NewHarness(t)
+	s := h.Factory.SeedScenario()
+	admin, line := h.AdminToken(), h.LineToken(s.ProductLines[0])
+	supplierID, modelID, pallet := s.Suppliers[1].ID, s.ProductModels[0].ID, s.Pallets[0]
+
+	for _, invalid := range []map[string]interface{}{
+		{"aql": 1.0},
+		{"supplierId": supplierID, "productModelId": modelID, "aql": 1.0},
+		{"supplierId": supplierID, "aql": 3.0},
+		{"supplierId": supplierID, "aql": 1.0, "inspectionLevel": "IV"},
+	} {
+		if recorder := h.Do(t, http.MethodPost, "/api/management/sampling_plan", invalid, admin); recorder.Code != http.StatusBadRequest {
+			t.Fatalf("add plan %v: %d %s", invalid, recorder.Code, recorder.Body.String())
+		}
+	}
+	if recorder := h.Do(t, http.MethodPost, "/api/management/sampling_plan", map[string]interface{}{"supplierId": 9999, "aql": 1.0}, admin); recorder.Code != http.StatusNotFound {
+		t.Fatalf("add plan for missing supplier: %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	// 甲供应商（MB00002）的方案：批量 500 为字码 H，n=50，AQL 1.0 时 Ac=1、Re=2
+	supplierPlan := map[string]interface{}{"name": "甲供应商来料", "supplierId": supplierID, "aql": 1.0}
+	recorder := h.Do(t, http.MethodPost, "/api/management/sampling_plan", supplierPlan, admin)
+	var created samplingPlanResponse
+	_ = json.Unmarshal(recorder.Body.Bytes(), &created)
+	if recorder.Code != http.StatusCreated || created.Data.InspectionLevel != "II" || created.Data.Severity != models.SamplingNormal {
+		t.Fatalf("add supplier plan: %d %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder := h.Do(t, http.MethodPost, "/api/management/sampling_plan", supplierPlan, admin); recorder.Code != http.StatusConflict {
+		t.Fatalf("add duplicate plan: %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	if recorder := h.Do(t, http.MethodGet, "/api/production/sampling?sn=MB00002030200001", nil, line); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("sampling without lotSize: %d %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder := h.Do(t, http.MethodGet, "/api/production/sampling?productModelSap=MZ99999&batchNumber=0101&lotSize=10", nil, line); recorder.Code != http.StatusNotFound {
+		t.Fatalf("sampling for missing model: %d %s", recorder.Code, recorder.Body.String())
+	}
+	// 已检测的 5 个产品中有 3 个不良，生成方案时即判退
+	sampling := getSampling(t, h, line, "sn=MB00002030200001&lotSize=500")
+	if sampling.CodeLetter != "H" || sampling.SampleSize != 50 || sampling.Ac != 1 || sampling.Re != 2 ||
+		sampling.Inspected != 5 || sampling.Defects != 3 || sampling.Result != models.SamplingRejected || sampling.Remaining != 0 {
+		t.Fatalf("supplier plan sampling = %+v", sampling)
+	}
+	if sampling.Batch == nil || sampling.Batch.Status != models.BatchStatusRejected {
+		t.Fatalf("batch = %+v", sampling.Batch)
+	}
+	var history struct{ Data []models.BatchDisposition }
+	if err := json.Unmarshal(h.Get(t, fmt.Sprintf("/api/management/batch/%d/dispositions", sampling.BatchID)), &history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Data) != 1 || history.Data[0].Action != models.BatchActionReject || history.Data[0].Operator != models.SamplingOperator {
+		t.Fatalf("dispositions = %+v", history.Data)
+	}
+
+	// 乙供应商（MA00001）没有方案时全检
+	if sampling := getSampling(t, h, line, "sn=MA00001030100001&lotSize=100"); sampling != nil {
+		t.Fatalf("sampling without plan = %+v", sampling)
+	}
+
+	// 物料的方案：批量 100 为字码 F，n=20，AQL 6.5 时 Ac=3、Re=4
+	recorder = h.Do(t, http.MethodPost, "/api/management/sampling_plan", map[string]interface{}{"productModelId": modelID, "aql": 6.5}, admin)
+	_ = json.Unmarshal(recorder.Body.Bytes(), &created)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("add model plan: %d %s", recorder.Code, recorder.Body.String())
+	}
+	planID := created.Data.ID
+	sampling = getSampling(t, h, line, "productModelSap=MA00001&batchNumber=0301&lotSize=100")
+	if sampling.CodeLetter != "F" || sampling.SampleSize != 20 || sampling.Ac != 3 || sampling.Re != 4 ||
+		sampling.Inspected != 5 || sampling.Defects != 2 || sampling.Remaining != 15 || sampling.Result != models.SamplingPending {
+		t.Fatalf("model plan sampling = %+v", sampling)
+	}
+	// 之后的查询不需要批量
+	if again := getSampling(t, h, line, "sn=MA00001030100001"); again.ID != sampling.ID || again.LotSize != 100 {
+		t.Fatalf("sampling again = %+v", again)
+	}
+	reportProducts(t, h, line, pallet, "0301", 101, 1, true)
+	if sampling := getSampling(t, h, line, "sn=MA00001030100001"); sampling.Result != models.SamplingPending || sampling.Defects != 3 || sampling.Remaining != 14 {
+		t.Fatalf("after 3 defects = %+v", sampling)
+	}
+	reportProducts(t, h, line, pallet, "0301", 102, 1, true)
+	var batch samplingResponse
+	if err := json.Unmarshal(h.Get(t, fmt.Sprintf("/api/management/batch/%d/sampling", sampling.BatchID)), &batch); err != nil {
+		t.Fatal(err)
+	}
+	if batch.Data.Result != models.SamplingRejected || batch.Data.DecidedAt == nil || batch.Data.Batch.Status != models.BatchStatusRejected {
+		t.Fatalf("rejected sampling = %+v", batch.Data)
+	}
+	if plan := getSamplingPlan(t, h, planID); plan.Severity != models.SamplingNormal || plan.RecentResults != "R" {
+		t.Fatalf("plan after 1 rejection = %+v", plan)
+	}
+
+	// 批量 8 为字码 A，n=2、Ac=0；第二批拒收后转为加严检验
+	if sampling := getSampling(t, h, line, "sn=MA00001030300001&lotSize=8"); sampling.CodeLetter != "A" || sampling.SampleSize != 2 || sampling.Ac != 0 {
+		t.Fatalf("lot 8 sampling = %+v", sampling)
+	}
+	reportProducts(t, h, line, pallet, "0303", 1, 1, true)
+	if plan := getSamplingPlan(t, h, planID); plan.Severity != models.SamplingTightened || plan.RecentResults != "" {
+		t.Fatalf("plan after 2 rejections = %+v", plan)
+	}
+
+	// 加严检验：A 行严一档为箭头，改用字码 B（n=3）；连续 5 批接收后恢复正常检验
+	for i := 4; i <= 8; i++ {
+		batchNumber := fmt.Sprintf("%04d", 300+i)
+		sampling := getSampling(t, h, line, fmt.Sprintf("productModelSap=MA00001&batchNumber=%s&lotSize=8", batchNumber))
+		if sampling.Severity != models.SamplingTightened || sampling.CodeLetter != "B" || sampling.SampleSize != 3 || sampling.Ac != 0 {
+			t.Fatalf("tightened sampling %s = %+v", batchNumber, sampling)
+		}
+		reportProducts(t, h, line, pallet, batchNumber, 1, 3, false)
+		if sampling := getSampling(t, h, line, "productModelSap=MA00001&batchNumber="+batchNumber); sampling.Result != models.SamplingAccepted || sampling.Batch.Status != models.BatchStatusAccepted {
+			t.Fatalf("tightened result %s = %+v", batchNumber, sampling)
+		}
+	}
+	if plan := getSamplingPlan(t, h, planID); plan.Severity != models.SamplingNormal || plan.ConsecutiveAccepted != 0 {
+		t.Fatalf("plan after 5 tightened acceptances = %+v", plan)
+	}
+	getSampling(t, h, line, "sn=MA00001030900001&lotSize=8")
+	reportProducts(t, h, line, pallet, "0309", 1, 2, false)
+	if plan := getSamplingPlan(t, h, planID); plan.SwitchingScore != 2 || plan.RecentResults != "A" {
+		t.Fatalf("switching score = %+v", plan)
+	}
+
+	// 放宽检验：批量 50 为字码 D（放宽样本量 3），AQL 6.5 为箭头，改用字码 C（n=2）；拒收一批后恢复正常检验
+	if recorder := h.Do(t, http.MethodPost, fmt.Sprintf("/api/management/sampling_plan/%d/reset", planID), map[string]string{"severity": "loose"}, admin); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("reset to invalid severity: %d %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder := h.Do(t, http.MethodPost, fmt.Sprintf("/api/management/sampling_plan/%d/reset", planID), map[string]string{"severity": models.SamplingReduced}, admin); recorder.Code != http.StatusOK {
+		t.Fatalf("reset to reduced: %d %s", recorder.Code, recorder.Body.String())
+	}
+	if sampling := getSampling(t, h, line, "sn=MA00001031000001&lotSize=50"); sampling.Severity != models.SamplingReduced || sampling.CodeLetter != "C" || sampling.SampleSize != 2 {
+		t.Fatalf("reduced sampling = %+v", sampling)
+	}
+	reportProducts(t, h, line, pallet, "0310", 1, 1, true)
+	if plan := getSamplingPlan(t, h, planID); plan.Severity != models.SamplingNormal {
+		t.Fatalf("plan after reduced rejection = %+v", plan)
+	}
+
+	// 暂停抽样时全检
+	h.Do(t, http.MethodPost, fmt.Sprintf("/api/management/sampling_plan/%d/reset", planID), map[string]string{"severity": models.SamplingDiscontinued}, admin)
+	if sampling := getSampling(t, h, line, "sn=MA00001031100001&lotSize=8"); sampling.SampleSize != 8 || sampling.Ac != 0 || sampling.Re != 1 {
+		t.Fatalf("discontinued sampling = %+v", sampling)
+	}
+
+	// 人工冻结的批次只记录抽样结果，不自动放行
+	h.Do(t, http.MethodPost, fmt.Sprintf("/api/management/sampling_plan/%d/reset", planID), map[string]string{"severity": models.SamplingNormal}, admin)
+	recorder = h.Do(t, http.MethodPost, "/api/management/batch", map[string]interface{}{"productModelId": modelID, "batchNumber": "0312"}, admin)
+	var held batchResponse
+	_ = json.Unmarshal(recorder.Body.Bytes(), &held)
+	batchAction(t, h, held.Data.ID, "hold", nil, http.StatusOK)
+	getSampling(t, h, line, "sn=MA00001031200001&lotSize=8")
+	reportProducts(t, h, line, pallet, "0312", 1, 2, false)
+	if sampling := getSampling(t, h, line, "sn=MA00001031200001"); sampling.Result != models.SamplingAccepted || sampling.Batch.Status != models.BatchStatusOnHold {
+		t.Fatalf("held batch sampling = %+v", sampling)
+	}
+
+	recorder = h.Do(t, http.MethodPost, "/api/management/batch", map[string]interface{}{"productModelId": modelID, "batchNumber": "0399"}, admin)
+	_ = json.Unmarshal(recorder.Body.Bytes(), &held)
+	if recorder := h.Do(t, http.MethodGet, fmt.Sprintf("/api/management/batch/%d/sampling", held.Data.ID), nil, admin); recorder.Code != http.StatusNotFound {
+		t.Fatalf("batch without sampling: %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	// 修改和删除方案
+	update := map[string]interface{}{"id": planID, "aql": 0.65, "inspectionLevel": "S-4"}
+	if recorder := h.Do(t, http.MethodPut, "/api/management/sampling_plan", update, admin); recorder.Code != http.StatusOK {
+		t.Fatalf("update plan: %d %s", recorder.Code, recorder.Body.String())
+	}
+	if plan := getSamplingPlan(t, h, planID); plan.AQL != 0.65 || plan.InspectionLevel != "S-4" || plan.Severity != models.SamplingNormal {
+		t.Fatalf("updated plan = %+v", plan)
+	}
+	update = map[string]interface{}{"id": planID, "supplierId": supplierID}
+	if recorder := h.Do(t, http.MethodPut, "/api/management/sampling_plan", update, admin); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("update plan with both targets: %d %s", recorder.Code, recorder.Body.String())
+	}
+	var plans struct{ Data []models.SamplingPlan }
+	if err := json.Unmarshal(h.Get(t, "/api/management/sampling_plan"), &plans); err != nil {
+		t.Fatal(err)
+	}
+	if len(plans.Data) != 2 {
+		t.Fatalf("plans = %+v", plans.Data)
+	}
+	if err := json.Unmarshal(h.Get(t, fmt.Sprintf("/api/management/sampling_plan?filter=aql:lt:1&sort=-aql&supplierId=%d", supplierID)), &plans); err != nil {
+		t.Fatal(err)
+	}
+	if len(plans.Data) != 0 {
+		t.Fatalf("filtered plans = %+v", plans.Data)
+	}
+	if err := json.Unmarshal(h.Get(t, "/api/management/sampling_plan?filter=inspectionLevel:eq:S-4"), &plans); err != nil {
+		t.Fatal(err)
+	}
+	if len(plans.Data) != 1 || plans.Data[0].ID != planID {
+		t.Fatalf("plans at level S-4 = %+v", plans.Data)
+	}
+	if recorder := h.Do(t, http.MethodGet, "/api/management/sampling_plan?filter=switchingScore:gt:0", nil, admin); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("filter on unlisted field: %d %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder := h.Do(t, http.MethodDelete, "/api/management/sampling_plan", map[string]interface{}{"ids": []int64{created.Data.ID}}, admin); recorder.Code != http.StatusOK {
+		t.Fatalf("delete plan: %d %s", recorder.Code, recorder.Body.String())
+	}
+	if sampling := getSampling(t, h, line, "sn=MA00001031300001&lotSize=8"); sampling != nil {
+		t.Fatalf("sampling after plan deleted = %+v", sampling)
+	}
+}
@@ -0,0 +1,117 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/testutil"
+)
+
+func searchHits(t *testing.T, h *testutil.Harness, query url.Values) []models.SearchHit {
+	t.Helper()
+	var response struct{ Data []models.SearchHit }
+	if err := json.Unmarshal(h.Get(t, "/api/management/search?"+query.Encode()), &response); err != nil {
+		t.Fatal(err)
+	}
+	return response.Data
+}
+
+func TestSearch(t *testing.T) {
+	h := testutil.NewHarness(t)
+	s := h.Factory.SeedScenario()
+
+	// 完全匹配的物料排在前缀匹配的产品之前
+	hits := searchHits(t, h, url.Values{"q": {"mb00002"}})
+	if len(hits) != 6 || hits[0].Type != models.SearchTypeModel || hits[0].Match != models.SearchMatchExact || hits[0].ID != s.ProductModels[1].ID {
+		t.Fatalf("q=mb00002: %+v", hits)
+	}
+	for _, hit := range hits[1:] {
+		if hit.Type != models.SearchTypeProduct || hit.Field != "sn" || hit.Match != models.SearchMatchPrefix {
+			t.Errorf("q=mb00002: unexpected hit %+v", hit)
+		}
+	}
+
+	// 少于 3 个字符的关键字，以及按类型筛选
+	hits = searchHits(t, h, url.Values{"q": {"甲"}})
+	if len(hits) != 2 || hits[0].Type != models.SearchTypeSupplier || hits[1].Type != models.SearchTypePlan || hits[1].Field != "manufacturer" {
+		t.Fatalf("q=甲: %+v", hits)
+	}
+	if hits = searchHits(t, h, url.Values{"q": {"甲"}, "types": {"plan"}}); len(hits) != 1 || hits[0].Type != models.SearchTypePlan {
+		t.Fatalf("q=甲&types=plan: %+v", hits)
+	}
+	if hits = searchHits(t, h, url.Values{"q": {"直流电"}}); len(hits) != 1 || hits[0].Field != "description" || hits[0].Match != models.SearchMatchContains {
+		t.Fatalf("q=直流电: %+v", hits)
+	}
+	if hits = searchHits(t, h, url.Values{"q": {"%"}}); len(hits) != 0 {
+		t.Fatalf("q=%%: %+v", hits)
+	}
+
+	// 更新和删除后索引同步
+	supplier := s.Suppliers[0]
+	if recorder := h.Do(t, http.MethodPut, "/api/management/supplier", map[string]interface{}{"id": supplier.ID, "name": "丙供应商"}, h.AdminToken()); recorder.Code != http.StatusOK {
+		t.Fatalf("update supplier: %d %s", recorder.Code, recorder.Body.String())
+	}
+	if hits = searchHits(t, h, url.Values{"q": {"乙"}}); len(hits) != 1 || hits[0].Type != models.SearchTypePlan {
+		t.Fatalf("q=乙 after rename: %+v", hits)
+	}
+	if hits = searchHits(t, h, url.Values{"q": {"丙供应"}}); len(hits) != 1 || hits[0].ID != supplier.ID {
+		t.Fatalf("q=丙供应: %+v", hits)
+	}
+	if recorder := h.Do(t, http.MethodDelete, "/api/management/supplier", map[string]interface{}{"ids": []int64{s.Suppliers[1].ID}}, h.AdminToken()); recorder.Code != http.StatusOK {
+		t.Fatalf("delete supplier: %d %s", recorder.Code, recorder.Body.String())
+	}
+	if hits = searchHits(t, h, url.Values{"q": {"甲供应商"}, "types": {"supplier"}}); len(hits) != 0 {
+		t.Fatalf("deleted supplier still found: %+v", hits)
+	}
+
+	// 重建索引
+	if err := h.DB.Where("1 = 1").Delete(&models.SearchGram{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	h.StartJobWorkers(t)
+	recorder := h.Do(t, http.MethodPost, "/api/management/search/reindex", nil, h.AdminToken())
+	var accepted struct{ Data models.Job }
+	if err := json.Unmarshal(recorder.Body.Bytes(), &accepted); err != nil || recorder.Code != http.StatusAccepted {
+		t.Fatalf("reindex: %d %s", recorder.Code, recorder.Body.String())
+	}
+	if job := h.WaitJob(t, accepted.Data.ID); job.Status != models.JobStatusCompleted || job.Done != job.Total || job.Total != 17 {
+		t.Fatalf("reindex job = %+v", job)
+	}
+	if hits = searchHits(t, h, url.Values{"q": {"丙供应商"}}); len(hits) != 1 || hits[0].Match != models.SearchMatchExact {
+		t.Fatalf("q=丙供应商 after reindex: %+v", hits)
+	}
+
+	for _, path := range []string{"/api/management/search", "/api/management/search?q=x&types=user", "/api/management/search?q=x&limit=1000"} {
+		if recorder := h.Do(t, http.MethodGet, path, nil, h.AdminToken()); recorder.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: status %d, want 400", path, recorder.Code)
+		}
+	}
+}
+
+func TestProductListSearchMatchesModelSN(t *testing.T) {
+	h := testutil.NewHarness(t)
+	h.Factory.SeedScenario()
+	// 批次号也在索引中但不属于综合查询的字段；ABCXBCD 包含 ABCD 的全部 n-gram 但不包含 ABCD
+	h.Factory.Product(models.Product{SN: "QQABCXBCDQQ", BatchNumber: "ZZ99"})
+
+	for search, want := range map[string]int{
+		"MA00001":  5, // 物料编码
+		"PALLET-2": 5, // 托盘SN
+		"PALLET":   10,
+		"00009":    1, // 产品SN
+		"B0":       5, // 短关键字按 n-gram 前缀匹配
+		"ZZ99":     0,
+		"ABCD":     0,
+	} {
+		var page listPage
+		if err := json.Unmarshal(h.Get(t, "/api/management/product?search="+url.QueryEscape(search)), &page); err != nil {
+			t.Fatal(err)
+		}
+		if page.Pagination.Total != want {
+			t.Errorf("search=%s: total %d, want %d", search, page.Pagination.Total, want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/services"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/testutil"
+)
+
+// 假日按工厂时区的日期保存，生产日为该日期时标记为假日
+func TestAddHolidayKeepsPlantDate(t *testing.T) {
+	h := testutil.NewHarness(t)
+
+	recorder := h.Do(t, http.MethodPost, "/api/management/holiday", map[string]interface{}{"date": "2024-03-02", "name": "调休"}, h.AdminToken())
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("add holiday: %d %s", recorder.Code, recorder.Body)
+	}
+	if !strings.Contains(recorder.Body.String(), `"date":"2024-03-02`) {
+		t.Errorf("create response = %s", recorder.Body)
+	}
+	if recorder := h.Do(t, http.MethodPost, "/api/management/holiday", map[string]interface{}{"date": "2024/03/02"}, h.AdminToken()); recorder.Code != http.StatusBadRequest {
+		t.Errorf("invalid date: status %d, want 400", recorder.Code)
+	}
+
+	var list struct{ Data []models.Holiday }
+	if err := json.Unmarshal(h.Get(t, "/api/management/holiday"), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Data) != 1 || list.Data[0].Date.Format("2006-01-02") != "2024-03-02" {
+		t.Fatalf("holidays = %+v", list.Data)
+	}
+
+	service, _ := services.NewShiftCalendarService(h.DB)
+	calendar, err := service.LoadCalendar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for day, want := range map[string]bool{"2024-03-01": false, "2024-03-02": true, "2024-03-03": false} {
+		if got := calendar.IsHoliday(day, nil); got != want {
+			t.Errorf("IsHoliday(%s) = %v, want %v", day, got, want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package routes_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/testutil"
+)
+
+// 进程时区不同时，统计和报表接口的响应与 testdata 中的期望结果逐字节相同
+func TestReportsIndependentOfProcessTimezone(t *testing.T) {
+	if *update {
+		t.Skip("golden files are written by TestStatsAndReportEndpoints")
+	}
+	original := time.Local
+	t.Cleanup(func() { time.Local = original })
+
+	cases := []struct {
+		name string
+		path string
+	}{
+		{"quality_stats", "/api/management/quality_stats?startDate=2024-03-01&endDate=2024-03-03"},
+		{"quality_stats_production", "/api/management/quality_stats?startDate=2024-03-01&endDate=2024-03-03&bucketBy=production"},
+		{"quality_stats_utc", "/api/management/quality_stats?startDate=2024-03-01&endDate=2024-03-03&tz=UTC"},
+		{"quality_aggregate_day", "/api/management/quality_stats/aggregate?startDate=2024-03-01&endDate=2024-03-03&granularity=day&dimensions=supplier"},
+		{"quality_aggregate_raw", "/api/management/quality_stats/aggregate?startDate=2024-03-01&endDate=2024-03-03&dimensions=defect_reason&source=raw"},
+		{"report_defect", "/api/management/report/defect?startDate=2024-03-01&endDate=2024-03-03&pageNum=1&pageSize=20"},
+		{"report_inspection", "/api/management/report/inspection?startDate=2024-03-01&endDate=2024-03-03&pageNum=1&pageSize=20"},
+		{"report_inspection_tz", "/api/management/report/inspection?startDate=2024-03-01&endDate=2024-03-03&tz=America/Los_Angeles&pageNum=1&pageSize=20"},
+		{"report_cost", "/api/management/report/cost?startDate=2024-03-01&endDate=2024-03-03&pageNum=1&pageSize=20"},
+		{"production_plan_date", "/api/management/production_plan/date?date=2024-03-01"},
+	}
+	for _, name := range []string{"UTC", "America/Los_Angeles", "Asia/Kolkata", "Pacific/Auckland"} {
+		location, err := time.LoadLocation(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Local = location
+
+		h := testutil.NewHarness(t)
+		h.Factory.SeedScenario()
+		h.Factory.Shift(models.Shift{Name: "白班", StartTime: "08:00", EndTime: "20:00", Sequence: 1})
+		h.Factory.Shift(models.Shift{Name: "夜班", StartTime: "20:00", EndTime: "08:00", Sequence: 2})
+		for _, tc := range cases {
+			t.Run(name+"/"+tc.name, func(t *testing.T) {
+				assertGolden(t, tc.name, h.Get(t, tc.path))
+			})
+		}
+	}
+}
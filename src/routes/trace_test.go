@@ -0,0 +1,111 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/services"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/testutil"
+)
+
+func traceSerials(t *testing.T, h *testutil.Harness, path string) models.TraceSerials {
+	t.Helper()
+	var response struct{ Data models.TraceSerials }
+	if err := json.Unmarshal(h.Get(t, path), &response); err != nil {
+		t.Fatal(err)
+	}
+	return response.Data
+}
+
+func TestTrace(t *testing.T) {
+	h := testutil.NewHarness(t)
+	s := h.Factory.SeedScenario()
+	first := s.Products[0]
+
+	// 复判改为不良，再由产线重新上报一次
+	productService, _ := services.NewProductService(h.DB)
+	if err := productService.UpdateProduct(first, map[string]interface{}{"has_defect": true, "defect_reason": "划伤"}); err != nil {
+		t.Fatal(err)
+	}
+	retest := map[string]interface{}{"sn": first.SN, "hasDefect": true, "defectReason": "异响"}
+	if recorder := h.Do(t, http.MethodPost, "/api/production/product", retest, h.LineToken(s.ProductLines[0])); recorder.Code != http.StatusCreated {
+		t.Fatalf("retest: %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	var response struct{ Data models.ProductTrace }
+	if err := json.Unmarshal(h.Get(t, "/api/management/trace/"+first.SN), &response); err != nil {
+		t.Fatal(err)
+	}
+	trace := response.Data
+	if len(trace.Attempts) != 2 || trace.Attempts[0].ID != first.ID || trace.Product.ID != trace.Attempts[1].ID || trace.Product.DefectReason != "异响" {
+		t.Fatalf("attempts = %+v, product = %+v", trace.Attempts, trace.Product)
+	}
+	if trace.BatchSize != 6 || len(trace.PalletMates) != 4 {
+		t.Fatalf("batchSize = %d, palletMates = %d", trace.BatchSize, len(trace.PalletMates))
+	}
+	var eventTypes []string
+	for _, event := range trace.Events {
+		eventTypes = append(eventTypes, event.Type)
+	}
+	if fmt.Sprint(eventTypes) != "[created updated created]" {
+		t.Fatalf("events = %v", eventTypes)
+	}
+	if change := trace.Events[1].Changes["defectReason"]; change.From != "" || change.To != "划伤" || len(trace.Events[1].Changes) != 2 {
+		t.Fatalf("update event changes = %+v", trace.Events[1].Changes)
+	}
+
+	nodes := make(map[string]models.TraceNode)
+	for _, node := range trace.Nodes {
+		nodes[node.ID] = node
+	}
+	modelNode := fmt.Sprintf("model:%d", s.ProductModels[0].ID)
+	edges := make(map[string]bool)
+	for _, edge := range trace.Edges {
+		if _, ok := nodes[edge.From]; !ok {
+			t.Errorf("edge from unknown node %s", edge.From)
+		}
+		if _, ok := nodes[edge.To]; !ok {
+			t.Errorf("edge to unknown node %s", edge.To)
+		}
+		edges[edge.From+"->"+edge.To] = true
+	}
+	for _, edge := range []string{
+		fmt.Sprintf("product:%d->%s", first.ID, modelNode),
+		fmt.Sprintf("%s->supplier:%d", modelNode, s.Suppliers[0].ID),
+		fmt.Sprintf("product:%d->batch:%d:0301", first.ID, s.ProductModels[0].ID),
+		fmt.Sprintf("product:%d->line:%d", first.ID, s.ProductLines[0].ID),
+		fmt.Sprintf("product:%d->pallet:%d", first.ID, s.Pallets[0].ID),
+		fmt.Sprintf("product:%d->pallet:%d", s.Products[1].ID, s.Pallets[0].ID),
+	} {
+		if !edges[edge] {
+			t.Errorf("missing edge %s", edge)
+		}
+	}
+	// 2 条检测记录、4 个同托盘产品，以及物料、供应商、批次、产线、托盘
+	if len(trace.Nodes) != 11 {
+		t.Errorf("nodes = %d, want 11", len(trace.Nodes))
+	}
+
+	if recorder := h.Do(t, http.MethodGet, "/api/management/trace/UNKNOWN", nil, h.AdminToken()); recorder.Code != http.StatusNotFound {
+		t.Fatalf("unknown sn: %d", recorder.Code)
+	}
+
+	// 反向追溯
+	batch := traceSerials(t, h, "/api/management/trace/batch/0301")
+	if batch.Total != 6 || batch.Serials != 5 || batch.Defects != 4 {
+		t.Fatalf("batch 0301 = %d/%d/%d", batch.Total, batch.Serials, batch.Defects)
+	}
+	if batch = traceSerials(t, h, fmt.Sprintf("/api/management/trace/batch/0301?productModelId=%d", s.ProductModels[1].ID)); batch.Total != 0 {
+		t.Fatalf("batch 0301 of MB00002 = %+v", batch)
+	}
+	pallet := traceSerials(t, h, fmt.Sprintf("/api/management/trace/pallet/%d", s.Pallets[0].ID))
+	if pallet.Total != 5 || pallet.Items[0].PalletSN != "PALLET-1" || pallet.Items[0].ModelSN != "MA00001" {
+		t.Fatalf("pallet 1 = %+v", pallet)
+	}
+	if recorder := h.Do(t, http.MethodGet, "/api/management/trace/pallet/9999", nil, h.AdminToken()); recorder.Code != http.StatusNotFound {
+		t.Fatalf("unknown pallet: %d", recorder.Code)
+	}
+}
@@ -0,0 +1,74 @@
+package routes_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/testutil"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 请求 span -> 服务方法 span -> SQL span
+func TestTracingSpanHierarchy(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	original := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(original) })
+
+	h := testutil.NewHarness(t)
+	h.Factory.SeedScenario()
+	h.Factory.Shift(models.Shift{Name: "白班", StartTime: "08:00", EndTime: "20:00", Sequence: 1})
+	exporter.Reset()
+
+	h.Get(t, "/api/management/report/inspection?startDate=2024-03-01&endDate=2024-03-03&pageNum=1&pageSize=20")
+
+	spans := exporter.GetSpans()
+	byName := map[string]tracetest.SpanStub{}
+	for _, span := range spans {
+		byName[span.Name] = span
+	}
+	request, ok := byName["GET /api/management/report/inspection"]
+	if !ok || request.SpanKind != trace.SpanKindServer || request.Parent.IsValid() {
+		t.Fatalf("missing root request span in %v", names(spans))
+	}
+	service, ok := byName["DataReportService.GetInspectionReport"]
+	if !ok || service.Parent.SpanID() != request.SpanContext.SpanID() {
+		t.Fatalf("service span must be a child of the request span: %v", names(spans))
+	}
+
+	var queries int
+	for _, span := range spans {
+		if !strings.HasPrefix(span.Name, "gorm.") || span.Parent.SpanID() != service.SpanContext.SpanID() {
+			continue
+		}
+		queries++
+		if span.SpanKind != trace.SpanKindClient {
+			t.Errorf("%s: kind %v, want client", span.Name, span.SpanKind)
+		}
+		var hasStatement bool
+		for _, attribute := range span.Attributes {
+			if attribute.Key == "db.query.text" && attribute.Value.AsString() != "" {
+				hasStatement = true
+			}
+		}
+		if !hasStatement {
+			t.Errorf("%s: missing db.query.text", span.Name)
+		}
+	}
+	if queries == 0 {
+		t.Fatalf("no SQL spans under the service span: %v", names(spans))
+	}
+}
+
+func names(spans tracetest.SpanStubs) []string {
+	var result []string
+	for _, span := range spans {
+		result = append(result, span.Name)
+	}
+	return result
+}
@@ -0,0 +1,169 @@
+package search
+
+import (
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const idsKey = "search:ids"
+
+// GormPlugin 在被索引的表写入后同步更新搜索索引，索引写入与原语句在同一事务（或连接）中执行，失败时原语句一并返回错误
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "search"
+}
+
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	registrations := []func() error{
+		func() error {
+			return callback.Create().After("gorm:create").Register("search:after_create", afterCreate)
+		},
+		func() error {
+			return callback.Update().Before("gorm:update").Register("search:before_update", collectIDs)
+		},
+		func() error {
+			return callback.Update().After("gorm:update").Register("search:after_update", afterUpdate)
+		},
+		func() error {
+			return callback.Delete().Before("gorm:delete").Register("search:before_delete", collectIDs)
+		},
+		func() error {
+			return callback.Delete().After("gorm:delete").Register("search:after_delete", afterDelete)
+		},
+	}
+	for _, register := range registrations {
+		if err := register(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func afterCreate(db *gorm.DB) {
+	entity, ok := entityOf(db.Statement.Table)
+	if !ok || db.Error != nil {
+		return
+	}
+	if ids := primaryKeys(db.Statement); len(ids) > 0 {
+		db.AddError(index(db, entity, ids, false))
+	}
+}
+
+// 更新和删除前记录受影响的主键：模型实例带主键时直接使用，否则按语句的 WHERE 条件查询
+func collectIDs(db *gorm.DB) {
+	if _, ok := entityOf(db.Statement.Table); !ok || db.Error != nil || !touchesIndexedColumns(db.Statement) {
+		return
+	}
+	ids := primaryKeys(db.Statement)
+	if len(ids) == 0 {
+		where, ok := db.Statement.Clauses["WHERE"]
+		if !ok {
+			return
+		}
+		// 条件中可能以 clause.PrimaryKey 引用主键列，需要带上模型的 schema
+		tx := db.Session(&gorm.Session{NewDB: true}).Unscoped()
+		if db.Statement.Model != nil {
+			tx = tx.Model(db.Statement.Model)
+		}
+		err := tx.Table(db.Statement.Table).Clauses(where.Expression).Pluck("id", &ids).Error
+		if err != nil {
+			db.AddError(err)
+			return
+		}
+	}
+	db.InstanceSet(idsKey, ids)
+}
+
+func afterUpdate(db *gorm.DB) {
+	if entity, ids, ok := collectedIDs(db); ok {
+		db.AddError(index(db, entity, ids, true))
+	}
+}
+
+func afterDelete(db *gorm.DB) {
+	if entity, ids, ok := collectedIDs(db); ok {
+		db.AddError(remove(db, entity, ids))
+	}
+}
+
+func collectedIDs(db *gorm.DB) (Entity, []int64, bool) {
+	entity, ok := entityOf(db.Statement.Table)
+	if !ok || db.Error != nil {
+		return entity, nil, false
+	}
+	value, ok := db.InstanceGet(idsKey)
+	if !ok {
+		return entity, nil, false
+	}
+	ids, _ := value.([]int64)
+	return entity, ids, len(ids) > 0
+}
+
+// 以 map 更新时只有涉及被索引的列才需要重建索引
+func touchesIndexedColumns(stmt *gorm.Statement) bool {
+	updates, ok := stmt.Dest.(map[string]interface{})
+	if !ok {
+		return true
+	}
+	entity, _ := entityOf(stmt.Table)
+	for key := range updates {
+		column := key
+		if stmt.Schema != nil {
+			if field := stmt.Schema.LookUpField(key); field != nil {
+				column = field.DBName
+			}
+		}
+		for _, indexed := range entity.Columns {
+			if column == indexed || column == "deleted_at" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 语句模型（单条或切片）中非零的主键
+func primaryKeys(stmt *gorm.Statement) []int64 {
+	if stmt.Schema == nil || stmt.Schema.PrioritizedPrimaryField == nil {
+		return nil
+	}
+	field := stmt.Schema.PrioritizedPrimaryField
+	var ids []int64
+	collect := func(value reflect.Value) {
+		if id, ok := primaryKey(stmt, field, value); ok {
+			ids = append(ids, id)
+		}
+	}
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			collect(reflect.Indirect(stmt.ReflectValue.Index(i)))
+		}
+	case reflect.Struct:
+		collect(stmt.ReflectValue)
+	}
+	return ids
+}
+
+func primaryKey(stmt *gorm.Statement, field *schema.Field, value reflect.Value) (int64, bool) {
+	if value.Kind() != reflect.Struct {
+		return 0, false
+	}
+	raw, zero := field.ValueOf(stmt.Context, value)
+	if zero {
+		return 0, false
+	}
+	switch id := raw.(type) {
+	case int64:
+		return id, true
+	case uint:
+		return int64(id), true
+	case int:
+		return int64(id), true
+	}
+	return 0, false
+}
@@ -0,0 +1,205 @@
+package search
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// n-gram 长度：文本每个位置起的 3 个字符（末尾不足 3 个字符时取剩余部分），
+// 3 个字符以上的关键字要求全部 n-gram 命中，更短的关键字按 n-gram 前缀匹配
+const GramSize = 3
+
+// 单次写入索引或重建时每批的记录数
+const batchSize = 500
+
+// 被索引的表及其文本列
+type Entity struct {
+	Type    string
+	Table   string
+	Columns []string
+}
+
+var Entities = []Entity{
+	{Type: models.SearchTypeProduct, Table: "products", Columns: []string{"sn", "batch_number"}},
+	{Type: models.SearchTypePallet, Table: "pallets", Columns: []string{"sn"}},
+	{Type: models.SearchTypeModel, Table: "product_models", Columns: []string{"sn", "part_number", "description"}},
+	{Type: models.SearchTypeSupplier, Table: "suppliers", Columns: []string{"name", "sap"}},
+	{Type: models.SearchTypePlan, Table: "production_plans", Columns: []string{"material_code", "part_number", "manufacturer"}},
+}
+
+func entityOf(table string) (Entity, bool) {
+	for _, entity := range Entities {
+		if entity.Table == table {
+			return entity, true
+		}
+	}
+	return Entity{}, false
+}
+
+func normalize(text string) []rune {
+	return []rune(strings.ToLower(strings.TrimSpace(text)))
+}
+
+// 文本的全部 n-gram（去重并排序）
+func Grams(texts ...string) []string {
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		runes := normalize(text)
+		for i := range runes {
+			seen[string(runes[i:min(i+GramSize, len(runes))])] = true
+		}
+	}
+	grams := make([]string, 0, len(seen))
+	for gram := range seen {
+		grams = append(grams, gram)
+	}
+	sort.Strings(grams)
+	return grams
+}
+
+// 关键字的查询条件：3 个字符以上返回需要全部命中的 n-gram，否则返回 n-gram 前缀
+func QueryGrams(q string) (grams []string, prefix string) {
+	runes := normalize(q)
+	if len(runes) < GramSize {
+		return nil, string(runes)
+	}
+	seen := make(map[string]bool)
+	for i := 0; i+GramSize <= len(runes); i++ {
+		gram := string(runes[i : i+GramSize])
+		if !seen[gram] {
+			seen[gram] = true
+			grams = append(grams, gram)
+		}
+	}
+	return grams, ""
+}
+
+// 候选记录 ID，按 ID 倒序（最近创建的在前）；结果可能包含误命中，调用方需按原文本再次确认
+func Candidates(db *gorm.DB, entityType, q string, limit int) ([]int64, error) {
+	var ids []int64
+	err := CandidateQuery(db, entityType, q).Order("entity_id DESC").Limit(limit).Pluck("entity_id", &ids).Error
+	return ids, err
+}
+
+// 候选记录 ID 的子查询（SELECT entity_id），用于 "id IN (?)" 条件，同样可能包含误命中
+func CandidateQuery(db *gorm.DB, entityType, q string) *gorm.DB {
+	grams, prefix := QueryGrams(q)
+	model := db.Model(&models.SearchGram{}).Select("entity_id").Where("entity_type = ?", entityType)
+	if prefix != "" {
+		escaped := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(prefix)
+		model = model.Where("gram LIKE ? ESCAPE '!'", escaped+"%")
+	} else {
+		model = model.Where("gram IN ?", grams).Having("COUNT(*) = ?", len(grams))
+	}
+	return model.Group("entity_id")
+}
+
+// 重新生成 ids 对应记录的索引；已删除（含软删除）的记录只移除索引
+func index(db *gorm.DB, entity Entity, ids []int64, replace bool) error {
+	db = db.Session(&gorm.Session{NewDB: true})
+	for start := 0; start < len(ids); start += batchSize {
+		batch := ids[start:min(start+batchSize, len(ids))]
+		if replace {
+			if err := db.Where("entity_type = ? AND entity_id IN ?", entity.Type, batch).Delete(&models.SearchGram{}).Error; err != nil {
+				return err
+			}
+		}
+		rows, err := db.Table(entity.Table).
+			Select(append([]string{"id"}, entity.Columns...)).
+			Where("id IN ? AND deleted_at IS NULL", batch).
+			Rows()
+		if err != nil {
+			return err
+		}
+		var grams []models.SearchGram
+		for rows.Next() {
+			var id int64
+			texts := make([]*string, len(entity.Columns))
+			dest := []interface{}{&id}
+			for i := range texts {
+				dest = append(dest, &texts[i])
+			}
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
+				return err
+			}
+			var values []string
+			for _, text := range texts {
+				if text != nil {
+					values = append(values, *text)
+				}
+			}
+			for _, gram := range Grams(values...) {
+				grams = append(grams, models.SearchGram{Gram: gram, EntityType: entity.Type, EntityID: id})
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(grams) == 0 {
+			continue
+		}
+		// 与并发写入产生的相同 n-gram 冲突时忽略
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(grams, batchSize).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func remove(db *gorm.DB, entity Entity, ids []int64) error {
+	db = db.Session(&gorm.Session{NewDB: true})
+	for start := 0; start < len(ids); start += batchSize {
+		batch := ids[start:min(start+batchSize, len(ids))]
+		if err := db.Where("entity_type = ? AND entity_id IN ?", entity.Type, batch).Delete(&models.SearchGram{}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 重建全部索引，progress 在每批完成后报告已处理和总记录数
+func Reindex(db *gorm.DB, progress func(done, total int64) error) error {
+	var total, done int64
+	for _, entity := range Entities {
+		var count int64
+		if err := db.Table(entity.Table).Where("deleted_at IS NULL").Count(&count).Error; err != nil {
+			return err
+		}
+		total += count
+	}
+
+	for _, entity := range Entities {
+		if err := db.Where("entity_type = ?", entity.Type).Delete(&models.SearchGram{}).Error; err != nil {
+			return err
+		}
+		var lastID int64
+		for {
+			var ids []int64
+			err := db.Table(entity.Table).Where("id > ? AND deleted_at IS NULL", lastID).
+				Order("id").Limit(batchSize).Pluck("id", &ids).Error
+			if err != nil {
+				return err
+			}
+			if len(ids) == 0 {
+				break
+			}
+			if err := index(db, entity, ids, false); err != nil {
+				return err
+			}
+			lastID = ids[len(ids)-1]
+			done += int64(len(ids))
+			if progress != nil {
+				if err := progress(done, total); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
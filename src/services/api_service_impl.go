@@ -1,11 +1,26 @@
 package services
 
 import (
+	"context"
+	"time"
+
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 )
 
+// API列表可筛选、排序的字段
+var apiFields = utils.Fields{
+	"id":        {Column: "apis.id", Type: utils.FieldInt, Sort: true},
+	"createdAt": {Column: "apis.created_at", Type: utils.FieldTime, Sort: true},
+	"updatedAt": {Column: "apis.updated_at", Type: utils.FieldTime, Sort: true},
+	"name":      {Column: "apis.name", Type: utils.FieldString, Sort: true},
+	"appId":     {Column: "apis.app_id", Type: utils.FieldString, Sort: true},
+}
+
+var apiKeyset = utils.KeysetColumns{Field: "createdAt", CreatedAt: "apis.created_at", ID: "apis.id"}
+
 type APIService struct {
 	db *gorm.DB
 }
@@ -14,19 +29,40 @@ func NewAPIService(db *gorm.DB) (IAPIService, error) {
 	return &APIService{db: db}, nil
 }
 
+func (s *APIService) WithContext(ctx context.Context) IAPIService {
+	return &APIService{db: s.db.WithContext(ctx)}
+}
+
+func (s *APIService) startSpan(method string) (*APIService, trace.Span) {
+	db, span := serviceSpan(s.db, "APIService."+method)
+	return &APIService{db: db}, span
+}
+
 func (s *APIService) CreateAPI(api *models.API) error {
+	s, span := s.startSpan("CreateAPI")
+	defer span.End()
+
 	return s.db.Create(api).Error
 }
 
 func (s *APIService) GetAPI(id int64) (*models.API, error) {
+	s, span := s.startSpan("GetAPI")
+	defer span.End()
+
 	var api models.API
 	err := s.db.First(&api, id).Error
 	return &api, err
 }
 
 func (s *APIService) GetAPIs(query map[string]interface{}, paginate map[string]interface{}, sqlHandler ...func(*gorm.DB) *gorm.DB) ([]models.API, models.PaginationResult, error) {
+	s, span := s.startSpan("GetAPIs")
+	defer span.End()
+
 	var apis []models.API
-	var pagination models.PaginationResult
+	page, err := utils.ParsePage(paginate, apiFields, apiKeyset)
+	if err != nil {
+		return []models.API{}, models.PaginationResult{}, err
+	}
 	var model = s.db.Model(&models.API{})
 
 	for _, handler := range sqlHandler {
@@ -34,23 +70,34 @@ func (s *APIService) GetAPIs(query map[string]interface{}, paginate map[string]i
 	}
 	model = model.Where(query)
 
-	model, pagination = utils.DoPagination(model, paginate)
-	model = utils.DoOrder(model, paginate)
+	model, pagination, err := page.Apply(model)
+	if err != nil {
+		return []models.API{}, pagination, err
+	}
 
 	result := model.Find(&apis)
 	if result.Error != nil {
 		return []models.API{}, pagination, result.Error
 	}
 
+	apis = apis[:page.Next(&pagination, len(apis), func(i int) (time.Time, int64) {
+		return apis[i].CreatedAt, apis[i].ID
+	})]
 	return apis, pagination, nil
 }
 
 func (s *APIService) UpdateAPI(apiInstance *models.API, api map[string]interface{}) error {
+	s, span := s.startSpan("UpdateAPI")
+	defer span.End()
+
 	result := s.db.Model(apiInstance).Updates(api)
 	return result.Error
 }
 
 func (s *APIService) DeleteAPIs(ids []int64) error {
+	s, span := s.startSpan("DeleteAPIs")
+	defer span.End()
+
 	result := s.db.Delete(&models.API{}, ids)
 	return result.Error
 }
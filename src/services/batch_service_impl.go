@@ -0,0 +1,353 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/config"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrBatchExists            = errors.New("batch already exists")
+	ErrInvalidBatchTransition = errors.New("invalid batch transition")
+)
+
+// 由配置设置的冻结批次处理方式
+var batchHoldPolicy = config.Default().Quality.BatchHoldPolicy
+
+func ConfigureBatches(cfg config.QualityConfig) {
+	batchHoldPolicy = cfg.BatchHoldPolicy
+}
+
+// 产线上报冻结批次的产品或托盘时是否拒绝
+func BatchHoldRejects() bool {
+	return batchHoldPolicy == models.BatchHoldReject
+}
+
+// BatchBlockedError 按 BATCH_HOLD_POLICY=reject 拒绝上报冻结或判退批次的产品或托盘时返回
+type BatchBlockedError struct {
+	Batch models.Batch
+}
+
+func (e *BatchBlockedError) Error() string {
+	return fmt.Sprintf("batch %s is %s", strings.TrimSpace(e.Batch.BatchNumber), e.Batch.Status)
+}
+
+// 批次列表可筛选、排序的字段
+var batchFields = utils.Fields{
+	"id":             {Column: "batches.id", Type: utils.FieldInt, Sort: true},
+	"createdAt":      {Column: "batches.created_at", Type: utils.FieldTime, Sort: true},
+	"updatedAt":      {Column: "batches.updated_at", Type: utils.FieldTime, Sort: true},
+	"productModelId": {Column: "batches.product_model_id", Type: utils.FieldInt},
+	"batchNumber":    {Column: "batches.batch_number", Type: utils.FieldString, Sort: true},
+	"status":         {Column: "batches.status", Type: utils.FieldString, Sort: true},
+	"disposition":    {Column: "batches.disposition", Type: utils.FieldString},
+}
+
+var batchKeyset = utils.KeysetColumns{Field: "createdAt", CreatedAt: "batches.created_at", ID: "batches.id"}
+
+type BatchService struct {
+	db *gorm.DB
+}
+
+func NewBatchService(db *gorm.DB) (IBatchService, error) {
+	return &BatchService{db: db}, nil
+}
+
+func (s *BatchService) WithContext(ctx context.Context) IBatchService {
+	return &BatchService{db: s.db.WithContext(ctx)}
+}
+
+func (s *BatchService) startSpan(method string) (*BatchService, trace.Span) {
+	db, span := serviceSpan(s.db, "BatchService."+method)
+	return &BatchService{db: db}, span
+}
+
+// 手动登记批次（例如供应商来料前预先冻结）：status 为 on_hold 时登记后立即冻结并记录历史，否则为 open；
+// 以唯一索引判断批次是否已存在，已存在时返回 ErrBatchExists
+func (s *BatchService) CreateBatch(batch *models.Batch, operator string) error {
+	s, span := s.startSpan("CreateBatch")
+	defer span.End()
+
+	hold := batch.Status == models.BatchStatusOnHold
+	batch.Status, batch.Disposition = models.BatchStatusOpen, ""
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(batch)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrBatchExists
+		}
+		if !hold {
+			return nil
+		}
+		if err := transitionBatch(tx, batch.ID, models.BatchActionHold, "", operator); err != nil {
+			return err
+		}
+		batch.Status = models.BatchStatusOnHold
+		return nil
+	})
+}
+
+func (s *BatchService) GetBatch(id int64) (*models.Batch, error) {
+	s, span := s.startSpan("GetBatch")
+	defer span.End()
+
+	var batch models.Batch
+	if err := s.db.Preload("ProductModel.Supplier").First(&batch, id).Error; err != nil {
+		return nil, err
+	}
+	summary, err := s.detailSummary(&batch)
+	if err != nil {
+		return nil, err
+	}
+	batch.Summary = summary
+	return &batch, nil
+}
+
+func (s *BatchService) GetBatches(query map[string]interface{}, paginate map[string]interface{}, sqlHandler ...func(*gorm.DB) *gorm.DB) ([]models.Batch, models.PaginationResult, error) {
+	s, span := s.startSpan("GetBatches")
+	defer span.End()
+
+	var batches []models.Batch
+	page, err := utils.ParsePage(paginate, batchFields, batchKeyset)
+	if err != nil {
+		return []models.Batch{}, models.PaginationResult{}, err
+	}
+	var model = s.db.Model(&models.Batch{}).Preload("ProductModel.Supplier")
+
+	for _, handler := range sqlHandler {
+		model = handler(model)
+	}
+	model = model.Where(query)
+
+	model, pagination, err := page.Apply(model)
+	if err != nil {
+		return []models.Batch{}, pagination, err
+	}
+
+	result := model.Find(&batches)
+	if result.Error != nil {
+		return []models.Batch{}, pagination, result.Error
+	}
+
+	batches = batches[:page.Next(&pagination, len(batches), func(i int) (time.Time, int64) {
+		return batches[i].CreatedAt, batches[i].ID
+	})]
+	if err := s.summarize(batches); err != nil {
+		return []models.Batch{}, pagination, err
+	}
+	return batches, pagination, nil
+}
+
+// 冻结、解冻或质量判定，并记录历史；状态不允许该操作时返回 ErrInvalidBatchTransition
+func (s *BatchService) Transition(id int64, action, note, operator string) (*models.Batch, error) {
+	s, span := s.startSpan("Transition")
+	defer span.End()
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		return transitionBatch(tx, id, action, note, operator)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetBatch(id)
+}
+
+func (s *BatchService) GetDispositions(batchID int64) ([]models.BatchDisposition, error) {
+	s, span := s.startSpan("GetDispositions")
+	defer span.End()
+
+	dispositions := []models.BatchDisposition{}
+	err := s.db.Where("batch_id = ?", batchID).Order("id").Find(&dispositions).Error
+	return dispositions, err
+}
+
+// 物料的批次，不存在时返回 nil
+func (s *BatchService) FindBatch(productModelID uint, batchNumber string) (*models.Batch, error) {
+	s, span := s.startSpan("FindBatch")
+	defer span.End()
+
+	return findBatch(s.db, productModelID, batchNumber)
+}
+
+// 物料下冻结或判退的批次
+func (s *BatchService) GetBlockedBatches(productModelID uint) ([]models.Batch, error) {
+	s, span := s.startSpan("GetBlockedBatches")
+	defer span.End()
+
+	batches := []models.Batch{}
+	err := s.db.Where("product_model_id = ? AND status IN ?", productModelID, []string{models.BatchStatusOnHold, models.BatchStatusRejected}).
+		Order("id").Find(&batches).Error
+	return batches, err
+}
+
+func findBatch(db *gorm.DB, productModelID uint, batchNumber string) (*models.Batch, error) {
+	var batch models.Batch
+	err := db.Where("product_model_id = ? AND batch_number = ?", productModelID, batchNumber).Take(&batch).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// 产品所属的批次不存在时创建，与产品在同一事务中执行；并发创建同一批次时以唯一索引去重
+func ensureBatch(tx *gorm.DB, product *models.Product) error {
+	if product.ProductModelID == nil || product.BatchNumber == "" {
+		return nil
+	}
+	batch, err := findBatch(tx, *product.ProductModelID, product.BatchNumber)
+	if err != nil || batch != nil {
+		return err
+	}
+	created := models.Batch{ProductModelID: *product.ProductModelID, BatchNumber: product.BatchNumber, Status: models.BatchStatusOpen}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&created).Error
+}
+
+// 按 BATCH_HOLD_POLICY=reject 拒绝冻结或判退的批次，应与产品或托盘的写入在同一事务中调用；
+// 读取时锁定批次行，并发的冻结操作等待该事务提交后才生效
+func checkBatchHold(tx *gorm.DB, productModelID *uint, batchNumber string) error {
+	if !BatchHoldRejects() || productModelID == nil || batchNumber == "" {
+		return nil
+	}
+	batch, err := findBatch(tx.Clauses(clause.Locking{Strength: "UPDATE"}), *productModelID, batchNumber)
+	if err != nil || batch == nil || !batch.Blocked() {
+		return err
+	}
+	return &BatchBlockedError{Batch: *batch}
+}
+
+func transitionBatch(tx *gorm.DB, id int64, action, note, operator string) error {
+	var batch models.Batch
+	if err := tx.First(&batch, id).Error; err != nil {
+		return err
+	}
+	to, err := models.NextBatchStatus(batch.Status, action)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidBatchTransition, err)
+	}
+	if action == models.BatchActionRelease {
+		// 恢复为最近一次冻结前的状态
+		var hold models.BatchDisposition
+		err := tx.Where("batch_id = ? AND action = ?", id, models.BatchActionHold).Order("id DESC").Take(&hold).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			to = models.BatchStatusOpen
+		case err != nil:
+			return err
+		default:
+			to = hold.FromStatus
+		}
+	}
+
+	updates := map[string]interface{}{"status": to}
+	if models.IsBatchDisposition(action) {
+		updates["disposition"] = action
+	}
+	// 以原状态为条件，并发操作时只有一个生效
+	result := tx.Model(&models.Batch{}).Where("id = ? AND status = ?", id, batch.Status).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: batch status changed concurrently", ErrInvalidBatchTransition)
+	}
+	return tx.Create(&models.BatchDisposition{
+		BatchID: id, Action: action, FromStatus: batch.Status, ToStatus: to, Note: note, Operator: operator,
+	}).Error
+}
+
+// 批次的检测汇总
+type batchCount struct {
+	ProductModelID uint
+	BatchNumber    string
+	Inspected      int
+	Defects        int
+}
+
+// 按物料 + 批次号统计产品记录，填充各批次的检测汇总
+func (s *BatchService) summarize(batches []models.Batch) error {
+	if len(batches) == 0 {
+		return nil
+	}
+	modelIDs := make([]uint, 0, len(batches))
+	numbers := make([]string, 0, len(batches))
+	for _, batch := range batches {
+		modelIDs = append(modelIDs, batch.ProductModelID)
+		numbers = append(numbers, batch.BatchNumber)
+	}
+	var counts []batchCount
+	err := s.db.Model(&models.Product{}).
+		Select("product_model_id, batch_number, COUNT(*) AS inspected, SUM(CASE WHEN has_defect THEN 1 ELSE 0 END) AS defects").
+		Where("product_model_id IN ? AND batch_number IN ?", modelIDs, numbers).
+		Group("product_model_id, batch_number").
+		Scan(&counts).Error
+	if err != nil {
+		return err
+	}
+	byKey := make(map[string]batchCount, len(counts))
+	for _, count := range counts {
+		byKey[fmt.Sprintf("%d/%s", count.ProductModelID, strings.TrimSpace(count.BatchNumber))] = count
+	}
+	for i := range batches {
+		count := byKey[fmt.Sprintf("%d/%s", batches[i].ProductModelID, strings.TrimSpace(batches[i].BatchNumber))]
+		batches[i].Summary = &models.BatchSummary{
+			Inspected:  count.Inspected,
+			Defects:    count.Defects,
+			DefectRate: percentage(int64(count.Defects), int64(count.Inspected)),
+		}
+	}
+	return nil
+}
+
+// 批次详情的汇总：首次和最近一次检测时间、不良原因分布
+func (s *BatchService) detailSummary(batch *models.Batch) (*models.BatchSummary, error) {
+	counted := []models.Batch{*batch}
+	if err := s.summarize(counted); err != nil {
+		return nil, err
+	}
+	summary := counted[0].Summary
+	if summary.Inspected == 0 {
+		return summary, nil
+	}
+	products := func() *gorm.DB {
+		return s.db.Model(&models.Product{}).Where("product_model_id = ? AND batch_number = ?", batch.ProductModelID, batch.BatchNumber)
+	}
+
+	var first, last []time.Time
+	if err := products().Order("created_at").Limit(1).Pluck("created_at", &first).Error; err != nil {
+		return nil, err
+	}
+	if err := products().Order("created_at DESC").Limit(1).Pluck("created_at", &last).Error; err != nil {
+		return nil, err
+	}
+	if len(first) > 0 && len(last) > 0 {
+		summary.FirstInspectedAt, summary.LastInspectedAt = &first[0], &last[0]
+	}
+
+	var reasons []models.DefectTypeItem
+	err := products().Select("defect_reason AS type, COUNT(*) AS count").
+		Where("has_defect = ?", true).Group("defect_reason").Scan(&reasons).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range reasons {
+		reasons[i].Rate = percentage(int64(reasons[i].Count), int64(summary.Inspected))
+	}
+	sort.SliceStable(reasons, func(i, j int) bool { return reasons[i].Count > reasons[j].Count })
+	summary.DefectReasons = reasons
+	return summary, nil
+}
@@ -1,12 +1,31 @@
 package services
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/databases"
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 )
 
+// 不合格报表可排序的字段
+var defectReportFields = utils.Fields{
+	"qualityDate":    {Column: "p.created_at", Type: utils.FieldTime, Sort: true},
+	"productSN":      {Column: "p.sn", Type: utils.FieldString, Sort: true},
+	"productModelSN": {Column: "pm.sn", Type: utils.FieldString, Sort: true},
+	"supplierName":   {Column: "s.name", Type: utils.FieldString, Sort: true},
+	"batchNumber":    {Column: "p.batch_number", Type: utils.FieldString, Sort: true},
+}
+
+var defectReportKeyset = utils.KeysetColumns{Field: "qualityDate", CreatedAt: "p.created_at", ID: "p.id"}
+
 type DataReportService struct {
 	db *gorm.DB
 }
@@ -15,32 +34,124 @@ func NewDataReportService(db *gorm.DB) (IDataReportService, error) {
 	return &DataReportService{db: db}, nil
 }
 
+func (s *DataReportService) WithContext(ctx context.Context) IDataReportService {
+	return &DataReportService{db: s.db.WithContext(ctx)}
+}
+
+func (s *DataReportService) startSpan(method string) (*DataReportService, trace.Span) {
+	db, span := serviceSpan(s.db, "DataReportService."+method)
+	return &DataReportService{db: db}, span
+}
+
 func (s *DataReportService) GetDefectReport(query *models.DefectReportQuery) (*models.DefectReportResponse, error) {
+	s, span := s.startSpan("GetDefectReport")
+	defer span.End()
+
 	var items []models.DefectReportItem
 
-	// 构建查询
+	// 时间筛选：按请求时区（或生产日）换算为时刻区间
+	window, err := s.newReportWindow(query.StartDate, query.EndDate, query.BucketBy, query.TZ)
+	if err != nil {
+		return nil, err
+	}
+
+	// 分页参数处理：pageSize 为 -1 时返回全部，不能与游标分页同时使用，且不超过 EXPORT_SYNC_MAX_ROWS 条（更多时应使用导出接口）；
+	// 默认按质检时间倒序
+	pageSize, pageNum := query.PageSize, max(query.PageNum, 0)
+	all := pageSize == -1
+	if pageSize <= 0 && !all {
+		pageSize = models.DefaultPageSize
+	}
+	page, err := defectReportPage(query, pageSize, pageNum)
+	if err != nil {
+		return nil, err
+	}
+	if all && page.Keyset {
+		return nil, fmt.Errorf("%w: pageSize=-1 cannot be used with cursor pagination", utils.ErrInvalidPagination)
+	}
+
+	queryBuilder, pagination, err := page.Apply(s.defectReportQuery(query, window))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count records: %v", err)
+	}
+	if all {
+		if pagination.Total > exportSyncMaxRows {
+			return nil, fmt.Errorf("%w: %d records exceed the pageSize=-1 limit of %d, use /report/defect/export",
+				utils.ErrInvalidPagination, pagination.Total, exportSyncMaxRows)
+		}
+		pagination.PageNum, pagination.PageSize = 1, pagination.Total
+	}
+
+	if err := queryBuilder.Scan(&items).Error; err != nil {
+		return nil, fmt.Errorf("failed to query defect report: %v", err)
+	}
+	items = items[:page.Next(&pagination, len(items), func(i int) (time.Time, int64) {
+		return items[i].QualityDate, items[i].ID
+	})]
+	for i := range items {
+		window.localize(&items[i])
+	}
+
+	// 与基准周期对比，筛选条件与报表一致
+	var comparisonHandlers []func(*gorm.DB) *gorm.DB
+	if query.SupplierID != nil {
+		comparisonHandlers = append(comparisonHandlers, func(db *gorm.DB) *gorm.DB {
+			return db.Where("pm.supplier_id = ?", *query.SupplierID)
+		})
+	}
+	if query.ProductModelSN != "" {
+		comparisonHandlers = append(comparisonHandlers, func(db *gorm.DB) *gorm.DB {
+			return db.Where("pm.sn LIKE ?", "%"+query.ProductModelSN+"%")
+		})
+	}
+	comparison, err := s.compareReport(window, query.ComparisonQuery, comparisonHandlers...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.DefectReportResponse{
+		Items:      items,
+		Pagination: pagination,
+		Comparison: comparison,
+	}, nil
+}
+
+func defectReportPage(query *models.DefectReportQuery, pageSize, pageNum int) (utils.Page, error) {
+	return utils.ParsePage(map[string]interface{}{
+		"page_size":  pageSize,
+		"page_num":   pageNum,
+		"sort":       query.Sort,
+		"cursor":     query.Cursor,
+		"keyset":     query.Keyset,
+		"with_total": query.WithTotal,
+	}, defectReportFields, defectReportKeyset)
+}
+
+// 不合格明细查询（未分页）
+func (s *DataReportService) defectReportQuery(query *models.DefectReportQuery, window *reportWindow) *gorm.DB {
 	dbQuery := s.db.Table("products p").
 		Select(`
+			p.id as id,
 			s.name as supplier_name,
 			p.created_at as quality_date,
 			p.sn as product_sn,
 			pm.sn as product_model_sn,
 			pm.description as description,
 			p.batch_number,
-			p.defect_reason
+			p.defect_reason,
+			p.product_line_id
 		`).
 		Joins("LEFT JOIN product_models pm ON p.product_model_id = pm.id").
 		Joins("LEFT JOIN suppliers s ON pm.supplier_id = s.id").
 		Where("p.has_defect = ?", true).
 		Where("p.defect_reason != ''")
 
-	// 时间筛选
-	if query.StartDate != "" {
-		dbQuery = dbQuery.Where("DATE(p.created_at) >= ?", query.StartDate)
+	start, end := window.exactRange()
+	if start != nil {
+		dbQuery = dbQuery.Where("p.created_at >= ?", utils.InDB(*start))
 	}
-
-	if query.EndDate != "" {
-		dbQuery = dbQuery.Where("DATE(p.created_at) <= ?", query.EndDate)
+	if end != nil {
+		dbQuery = dbQuery.Where("p.created_at < ?", utils.InDB(*end))
 	}
 
 	// 厂家ID筛选
@@ -52,270 +163,428 @@ func (s *DataReportService) GetDefectReport(query *models.DefectReportQuery) (*m
 	if query.ProductModelSN != "" {
 		dbQuery = dbQuery.Where("pm.sn LIKE ?", "%"+query.ProductModelSN+"%")
 	}
+	return dbQuery
+}
 
-	// 计算总数
-	var total int64
-	if err := dbQuery.Count(&total).Error; err != nil {
-		return nil, fmt.Errorf("failed to count records: %v", err)
-	}
-
-	// 分页参数处理
-	pageSize := query.PageSize
-	page := query.PageNum
-	var isExportAll bool
+// 检测报表：小时预聚合（或原始数据，见 reportWindow.source）按请求时区的自然日（或生产日）和维度在 SQL 中分组、排序和分页
+func (s *DataReportService) GetInspectionReport(query *models.InspectionReportQuery) (*models.InspectionReportResponse, error) {
+	s, span := s.startSpan("GetInspectionReport")
+	defer span.End()
 
-	if pageSize == -1 {
-		// 导出全部数据模式
-		isExportAll = true
-		pageSize = int(total) // 设置为总数量
-		page = 1
-	} else {
-		// 正常分页模式
-		if pageSize <= 0 {
-			pageSize = 20
-		}
-		if page <= 0 {
-			page = 1
-		}
+	window, err := s.newReportWindow(query.StartDate, query.EndDate, query.BucketBy, query.TZ)
+	if err != nil {
+		return nil, err
 	}
-
-	// 执行查询
-	queryBuilder := dbQuery.Order("p.created_at DESC")
-
-	if !isExportAll {
-		// 只有在非导出全部模式下才应用分页
-		offset := (page - 1) * pageSize
-		queryBuilder = queryBuilder.Limit(pageSize).Offset(offset)
+	grouped, err := s.inspectionReportQuery(query, window)
+	if err != nil {
+		return nil, err
 	}
-
-	if err := queryBuilder.Scan(&items).Error; err != nil {
-		return nil, fmt.Errorf("failed to query defect report: %v", err)
+	items := []models.InspectionReportItem{}
+	pagination, err := s.paginateGrouped(grouped, query.PageNum, query.PageSize, &items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query inspection report: %v", err)
 	}
 
-	// 构建分页结果
-	pagination := models.PaginationResult{
-		Total:    int(total),
-		PageNum:  page,
-		PageSize: pageSize,
+	// 与基准周期对比，筛选条件与报表一致
+	comparison, err := s.compareReport(window, query.ComparisonQuery, inspectionReportFilters(query)...)
+	if err != nil {
+		return nil, err
 	}
 
-	return &models.DefectReportResponse{
+	return &models.InspectionReportResponse{
 		Items:      items,
 		Pagination: pagination,
+		Comparison: comparison,
 	}, nil
 }
 
-func (s *DataReportService) GetInspectionReport(query *models.InspectionReportQuery) (*models.InspectionReportResponse, error) {
-	// 构建基础SQL查询，按物料编码、批次号、检测日期分组统计
-	baseSQL := `
-		SELECT 
+// 检测报表的物料编码、批次号、生产厂家模糊筛选
+func inspectionReportFilters(query *models.InspectionReportQuery) []func(*gorm.DB) *gorm.DB {
+	return likeHandlers(map[string]string{
+		"pm.sn":          query.ProductModelSN,
+		"p.batch_number": query.BatchNumber,
+		"s.name":         query.SupplierName,
+	})
+}
+
+// 检测报表的分组查询，排序：检测日期倒序、物料编码、批次号
+func (s *DataReportService) inspectionReportQuery(query *models.InspectionReportQuery, window *reportWindow) (*gorm.DB, error) {
+	source, err := window.source(s.db)
+	if err != nil {
+		return nil, err
+	}
+	day, dayArgs, err := s.daySQL(window, source, "p.product_line_id")
+	if err != nil {
+		return nil, err
+	}
+	rows := s.db.Table(source.table).
+		Select(day+` as inspection_date,
 			pm.sn as product_model_sn,
 			pm.description as description,
-			p.batch_number,
-			DATE(p.created_at) as inspection_date,
+			p.batch_number as batch_number,
 			s.name as supplier_name,
 			pl.name as product_line,
-			COUNT(*) as inspection_count,
-			SUM(CASE WHEN p.has_defect = false THEN 1 ELSE 0 END) as qualified_count,
-			SUM(CASE WHEN p.has_defect = true THEN 1 ELSE 0 END) as unqualified_count
-		FROM products p
-		LEFT JOIN product_models pm ON p.product_model_id = pm.id
-		LEFT JOIN suppliers s ON pm.supplier_id = s.id
-		LEFT JOIN product_lines pl ON p.product_line_id = pl.id
-		WHERE 1=1`
-
-	var conditions []string
-	var args []interface{}
-
-	// 物料编码筛选
-	if query.ProductModelSN != "" {
-		conditions = append(conditions, "pm.sn LIKE ?")
-		args = append(args, "%"+query.ProductModelSN+"%")
+			`+source.rowTotal+` as total_count,
+			`+source.rowDefect+` as defect_count`, dayArgs...).
+		Joins("LEFT JOIN product_models pm ON p.product_model_id = pm.id").
+		Joins("LEFT JOIN suppliers s ON pm.supplier_id = s.id").
+		Joins("LEFT JOIN product_lines pl ON p.product_line_id = pl.id")
+	for _, handler := range inspectionReportFilters(query) {
+		rows = handler(rows)
 	}
-
-	// 批次号筛选
-	if query.BatchNumber != "" {
-		conditions = append(conditions, "p.batch_number LIKE ?")
-		args = append(args, "%"+query.BatchNumber+"%")
+	for _, condition := range source.conditions {
+		rows = rows.Where(condition)
 	}
+	rows = window.where(rows, source.timeColumn)
+
+	return window.whereDay(s.db.Table("(?) r", rows), "r.inspection_date").
+		Select(`r.inspection_date, r.product_model_sn, r.description, r.batch_number, r.supplier_name, r.product_line,
+			SUM(r.total_count) as inspection_count,
+			SUM(r.total_count - r.defect_count) as qualified_count,
+			SUM(r.defect_count) as unqualified_count`).
+		Group("r.inspection_date, r.product_model_sn, r.description, r.batch_number, r.supplier_name, r.product_line").
+		Order("r.inspection_date DESC, r.product_model_sn, r.batch_number, r.supplier_name, r.product_line, r.description"), nil
+}
 
-	// 生产厂家筛选
-	if query.SupplierName != "" {
-		conditions = append(conditions, "s.name LIKE ?")
-		args = append(args, "%"+query.SupplierName+"%")
-	}
+// 检测费用报表：小时预聚合（或原始数据，见 reportWindow.source）按请求时区的自然日（或生产日）和维度在 SQL 中分组、排序和分页
+func (s *DataReportService) GetCostReport(query *models.CostReportQuery) (*models.CostReportResponse, error) {
+	s, span := s.startSpan("GetCostReport")
+	defer span.End()
 
-	// 时间范围筛选
-	if query.StartDate != "" {
-		conditions = append(conditions, "DATE(p.created_at) >= ?")
-		args = append(args, query.StartDate)
+	window, err := s.newReportWindow(query.StartDate, query.EndDate, query.BucketBy, query.TZ)
+	if err != nil {
+		return nil, err
+	}
+	grouped, err := s.costReportQuery(query, window)
+	if err != nil {
+		return nil, err
+	}
+	items := []models.CostReportItem{}
+	pagination, err := s.paginateGrouped(grouped, query.PageNum, query.PageSize, &items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cost report: %v", err)
 	}
 
-	if query.EndDate != "" {
-		conditions = append(conditions, "DATE(p.created_at) <= ?")
-		args = append(args, query.EndDate)
+	// 与基准周期对比，筛选条件与报表一致
+	comparison, err := s.compareReport(window, query.ComparisonQuery, costReportFilters(query)...)
+	if err != nil {
+		return nil, err
 	}
 
-	// 构建完整的查询SQL
-	for _, condition := range conditions {
-		baseSQL += " AND " + condition
+	return &models.CostReportResponse{
+		Items:      items,
+		Pagination: pagination,
+		Comparison: comparison,
+	}, nil
+}
+
+// 检测费用报表的厂家名称、物料编码、电机类型模糊筛选
+func costReportFilters(query *models.CostReportQuery) []func(*gorm.DB) *gorm.DB {
+	return likeHandlers(map[string]string{
+		"s.name":         query.SupplierName,
+		"pm.sn":          query.ProductModelSN,
+		"pm.description": query.MotorType,
+	})
+}
+
+// 检测费用报表的分组查询，排序：检测日期倒序、厂家、物料编码
+func (s *DataReportService) costReportQuery(query *models.CostReportQuery, window *reportWindow) (*gorm.DB, error) {
+	source, err := window.source(s.db)
+	if err != nil {
+		return nil, err
+	}
+	day, dayArgs, err := s.daySQL(window, source, "p.product_line_id")
+	if err != nil {
+		return nil, err
+	}
+	rows := s.db.Table(source.table).
+		Select(day+` as test_date,
+			s.name as supplier_name,
+			pm.sn as product_model_sn,
+			pm.description as motor_type,
+			`+source.rowTotal+` as total_count,
+			`+source.rowDefect+` as defect_count`, dayArgs...).
+		Joins("LEFT JOIN product_models pm ON p.product_model_id = pm.id").
+		Joins("LEFT JOIN suppliers s ON pm.supplier_id = s.id")
+	for _, handler := range costReportFilters(query) {
+		rows = handler(rows)
 	}
-	baseSQL += " GROUP BY pm.sn, pm.description, p.batch_number, DATE(p.created_at), s.name, pl.name ORDER BY inspection_date DESC, pm.sn, p.batch_number"
+	for _, condition := range source.conditions {
+		rows = rows.Where(condition)
+	}
+	rows = window.where(rows, source.timeColumn)
+
+	return window.whereDay(s.db.Table("(?) r", rows), "r.test_date").
+		Select(`r.test_date, r.supplier_name, r.product_model_sn, r.motor_type,
+			SUM(r.total_count - r.defect_count) as qualified_count,
+			SUM(r.defect_count) as unqualified_count,
+			SUM(r.total_count) as total_count`).
+		Group("r.test_date, r.supplier_name, r.product_model_sn, r.motor_type").
+		Order("r.test_date DESC, r.supplier_name, r.product_model_sn, r.motor_type"), nil
+}
 
-	// 先查询总数
-	countSQL := fmt.Sprintf("SELECT COUNT(*) FROM (%s) as temp", baseSQL)
+// 分组报表分页：总数为分组子查询的行数，pageSize 为 -1 时返回全部
+func (s *DataReportService) paginateGrouped(grouped *gorm.DB, pageNum, pageSize int, dest interface{}) (models.PaginationResult, error) {
 	var total int64
-	if err := s.db.Raw(countSQL, args...).Scan(&total).Error; err != nil {
-		return nil, fmt.Errorf("failed to count inspection report records: %v", err)
-	}
-
-	// 分页参数处理
-	pageSize := query.PageSize
-	page := query.PageNum
-	var isExportAll bool
-
-	if pageSize == -1 {
-		// 导出全部数据模式
-		isExportAll = true
-		pageSize = int(total)
-		page = 1
-	} else {
-		// 正常分页模式
+	if err := s.db.Table("(?) g", grouped).Count(&total).Error; err != nil {
+		return models.PaginationResult{}, err
+	}
+
+	pagination := models.PaginationResult{Total: int(total), PageNum: 1, PageSize: int(total)}
+	if pageSize != -1 {
 		if pageSize <= 0 {
-			pageSize = 20
-		}
-		if page <= 0 {
-			page = 1
+			pageSize = models.DefaultPageSize
 		}
+		pagination.PageNum, pagination.PageSize = max(pageNum, 1), pageSize
+		grouped = grouped.Limit(pageSize).Offset((pagination.PageNum - 1) * pageSize)
 	}
+	return pagination, grouped.Scan(dest).Error
+}
 
-	// 应用分页
-	if !isExportAll {
-		offset := (page - 1) * pageSize
-		baseSQL += fmt.Sprintf(" LIMIT %d OFFSET %d", pageSize, offset)
-	}
+// 报表时间窗口：将 [startDate, endDate] 按请求时区（或生产日）换算为查询时刻区间，并将小时桶归并为日期
+type reportWindow struct {
+	bucketer *timeBucketer
+	startDay string
+	endDay   string
+}
 
-	// 执行查询
-	var items []models.InspectionReportItem
-	if err := s.db.Raw(baseSQL, args...).Scan(&items).Error; err != nil {
-		return nil, fmt.Errorf("failed to query inspection report: %v", err)
+func (s *DataReportService) newReportWindow(startDate, endDate, bucketBy, tz string) (*reportWindow, error) {
+	location, err := utils.ResolveLocation(tz)
+	if err != nil {
+		return nil, err
+	}
+	if bucketBy == "" {
+		bucketBy = models.BucketByCalendar
+	}
+	if bucketBy != models.BucketByCalendar && bucketBy != models.BucketByProduction {
+		return nil, fmt.Errorf("invalid bucketBy %q", bucketBy)
+	}
+	for _, date := range []string{startDate, endDate} {
+		if date == "" {
+			continue
+		}
+		if _, err := utils.ParseDate(date, location); err != nil {
+			return nil, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", date)
+		}
 	}
 
-	// 构建分页结果
-	pagination := models.PaginationResult{
-		Total:    int(total),
-		PageNum:  page,
-		PageSize: pageSize,
+	calendar, err := (&ShiftCalendarService{db: s.db}).LoadCalendar()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load shift calendar: %v", err)
 	}
 
-	return &models.InspectionReportResponse{
-		Items:      items,
-		Pagination: pagination,
+	return &reportWindow{
+		bucketer: &timeBucketer{granularity: models.GranularityDay, bucketBy: bucketBy, calendar: calendar, location: location},
+		startDay: startDate,
+		endDay:   endDate,
 	}, nil
 }
 
-func (s *DataReportService) GetCostReport(query *models.CostReportQuery) (*models.CostReportResponse, error) {
-	// 构建基础SQL查询，按供应商、物料编码、检测日期分组统计
-	baseSQL := `
-		SELECT 
-			s.name as supplier_name,
-			pm.sn as product_model_sn,
-			pm.description as motor_type,
-			DATE(p.created_at) as test_date,
-			SUM(CASE WHEN p.has_defect = false THEN 1 ELSE 0 END) as qualified_count,
-			SUM(CASE WHEN p.has_defect = true THEN 1 ELSE 0 END) as unqualified_count,
-			COUNT(*) as total_count
-		FROM products p
-		LEFT JOIN product_models pm ON p.product_model_id = pm.id
-		LEFT JOIN suppliers s ON pm.supplier_id = s.id
-		WHERE 1=1`
-
-	var conditions []string
-	var args []interface{}
+// 按请求时区输出质检时间，生产日模式下标注记录所属的生产日和班次
+func (w *reportWindow) localize(item *models.DefectReportItem) {
+	item.QualityDate = item.QualityDate.In(w.bucketer.location)
+	if w.production() {
+		slot := w.bucketer.calendar.Locate(item.QualityDate, item.ProductLineID)
+		item.ProductionDay = slot.ProductionDay
+		item.Shift = slot.Shift
+	}
+}
 
-	// 厂家名称筛选
-	if query.SupplierName != "" {
-		conditions = append(conditions, "s.name LIKE ?")
-		args = append(args, "%"+query.SupplierName+"%")
+func (w *reportWindow) production() bool {
+	return w.bucketer.bucketBy == models.BucketByProduction
+}
+
+// 精确的时刻区间 [start, end)：自然日按请求时区的零点，生产日按全厂默认的生产日起点
+func (w *reportWindow) exactRange() (*time.Time, *time.Time) {
+	var start, end *time.Time
+	if w.startDay != "" {
+		day, _ := utils.ParseDate(w.startDay, w.bucketer.location)
+		if w.production() {
+			day, _ = utils.ParseDate(w.startDay, w.bucketer.calendar.Location)
+			day, _ = w.bucketer.calendar.ProductionDayRange(day, nil)
+		}
+		start = &day
+	}
+	if w.endDay != "" {
+		day, _ := utils.ParseDate(w.endDay, w.bucketer.location)
+		day = day.AddDate(0, 0, 1)
+		if w.production() {
+			day, _ = utils.ParseDate(w.endDay, w.bucketer.calendar.Location)
+			_, day = w.bucketer.calendar.ProductionDayRange(day, nil)
+		}
+		end = &day
 	}
+	return start, end
+}
 
-	// 物料编码筛选
-	if query.ProductModelSN != "" {
-		conditions = append(conditions, "pm.sn LIKE ?")
-		args = append(args, "%"+query.ProductModelSN+"%")
+// 分组查询的时刻区间；生产日模式下前后各放宽一天（各产线生产日起点可能不同），再按日期裁剪
+func (w *reportWindow) queryRange() (*time.Time, *time.Time) {
+	start, end := w.exactRange()
+	if w.production() {
+		if start != nil {
+			*start = start.AddDate(0, 0, -1)
+		}
+		if end != nil {
+			*end = end.AddDate(0, 0, 1)
+		}
 	}
+	return start, end
+}
 
-	// 电机类型筛选
-	if query.MotorType != "" {
-		conditions = append(conditions, "pm.description LIKE ?")
-		args = append(args, "%"+query.MotorType+"%")
+// 报表的数据来源（见 hourlyAggregateSource）：自然日按请求时区判断，生产日按工厂时区判断
+func (w *reportWindow) source(db *gorm.DB) (aggregateSource, error) {
+	start, end := w.queryRange()
+	location := w.bucketer.location
+	if w.production() {
+		location = w.bucketer.calendar.Location
 	}
+	return hourlyAggregateSource(db, start, end, location)
+}
 
-	// 时间范围筛选
-	if query.StartDate != "" {
-		conditions = append(conditions, "DATE(p.created_at) >= ?")
-		args = append(args, query.StartDate)
+// 时间列位于查询区间内
+func (w *reportWindow) where(db *gorm.DB, column string) *gorm.DB {
+	start, end := w.queryRange()
+	if start != nil {
+		db = db.Where(column+" >= ?", utils.InDB(*start))
+	}
+	if end != nil {
+		db = db.Where(column+" < ?", utils.InDB(*end))
 	}
+	return db
+}
 
-	if query.EndDate != "" {
-		conditions = append(conditions, "DATE(p.created_at) <= ?")
-		args = append(args, query.EndDate)
+// 日期列（YYYY-MM-DD）位于 [startDate, endDate] 内
+func (w *reportWindow) whereDay(db *gorm.DB, column string) *gorm.DB {
+	if w.startDay != "" {
+		db = db.Where(column+" >= ?", w.startDay)
 	}
+	if w.endDay != "" {
+		db = db.Where(column+" <= ?", w.endDay)
+	}
+	return db
+}
 
-	// 构建完整的查询SQL
-	for _, condition := range conditions {
-		baseSQL += " AND " + condition
+// 数据源各行所属日期的 SQL 表达式：自然日按请求时区、生产日按工厂时区和各产线的生产日起点平移后取日期；
+// 时区偏移在查询区间内变化（夏令时）时按切换时刻分段
+func (s *DataReportService) daySQL(w *reportWindow, source aggregateSource, lineColumn string) (string, []interface{}, error) {
+	column := source.timeColumn
+	location := w.bucketer.location
+	if w.production() {
+		location = w.bucketer.calendar.Location
+	}
+	offset := func(t time.Time) int {
+		_, local := t.In(location).Zone()
+		_, stored := t.In(utils.DBLocation()).Zone()
+		return (local - stored) / 60
 	}
-	baseSQL += " GROUP BY s.name, pm.sn, pm.description, DATE(p.created_at) ORDER BY test_date DESC, s.name, pm.sn"
 
-	// 先查询总数
-	countSQL := fmt.Sprintf("SELECT COUNT(*) FROM (%s) as temp", baseSQL)
-	var total int64
-	if err := s.db.Raw(countSQL, args...).Scan(&total).Error; err != nil {
-		return nil, fmt.Errorf("failed to count cost report records: %v", err)
-	}
-
-	// 分页参数处理
-	pageSize := query.PageSize
-	page := query.PageNum
-	var isExportAll bool
-
-	if pageSize == -1 {
-		// 导出全部数据模式
-		isExportAll = true
-		pageSize = int(total)
-		page = 1
-	} else {
-		// 正常分页模式
-		if pageSize <= 0 {
-			pageSize = 20
+	// 未指定起止日期时以数据源中最早和最晚的时间为界
+	start, end := w.queryRange()
+	if start == nil || end == nil {
+		var first, last []time.Time
+		if err := s.db.Table(source.table).Order(column).Limit(1).Pluck(column, &first).Error; err != nil {
+			return "", nil, err
+		}
+		if err := s.db.Table(source.table).Order(column+" DESC").Limit(1).Pluck(column, &last).Error; err != nil {
+			return "", nil, err
+		}
+		if start == nil && len(first) > 0 {
+			start = &first[0]
+		}
+		if end == nil && len(last) > 0 {
+			next := last[0].Add(time.Hour)
+			end = &next
+		}
+	}
+
+	current := time.Now()
+	if start != nil {
+		current = *start
+	}
+	minutes := offset(current)
+	var cases []string
+	var args []interface{}
+	for end != nil {
+		next := zoneTransition(current, location, utils.DBLocation())
+		if next.IsZero() || !next.Before(*end) {
+			break
 		}
-		if page <= 0 {
-			page = 1
+		if shifted := offset(next); shifted != minutes {
+			cases = append(cases, fmt.Sprintf("WHEN %s < ? THEN %d", column, minutes))
+			args = append(args, utils.InDB(next))
+			minutes = shifted
 		}
+		current = next
+	}
+	shift := strconv.Itoa(minutes)
+	if len(cases) > 0 {
+		shift = fmt.Sprintf("CASE %s ELSE %d END", strings.Join(cases, " "), minutes)
 	}
 
-	// 应用分页
-	if !isExportAll {
-		offset := (page - 1) * pageSize
-		baseSQL += fmt.Sprintf(" LIMIT %d OFFSET %d", pageSize, offset)
+	if w.production() {
+		calendar := w.bucketer.calendar
+		dayStart := strconv.Itoa(calendar.DayStart(nil))
+		if starts := calendar.LineDayStarts(); len(starts) > 0 {
+			lines := make([]uint, 0, len(starts))
+			for line := range starts {
+				lines = append(lines, line)
+			}
+			sort.Slice(lines, func(i, j int) bool { return lines[i] < lines[j] })
+			whens := make([]string, len(lines))
+			for i, line := range lines {
+				whens[i] = fmt.Sprintf("WHEN %d THEN %d", line, starts[line])
+			}
+			dayStart = fmt.Sprintf("CASE %s %s ELSE %d END", lineColumn, strings.Join(whens, " "), calendar.DayStart(nil))
+		}
+		shift = fmt.Sprintf("(%s) - (%s)", shift, dayStart)
 	}
+	return databases.DialectOf(s.db).ShiftedDate(column, shift), args, nil
+}
 
-	// 执行查询
-	var items []models.CostReportItem
-	if err := s.db.Raw(baseSQL, args...).Scan(&items).Error; err != nil {
-		return nil, fmt.Errorf("failed to query cost report: %v", err)
+// t 之后最近一次时区偏移切换的时刻，各时区都没有切换时返回零值
+func zoneTransition(t time.Time, locations ...*time.Location) time.Time {
+	var next time.Time
+	for _, location := range locations {
+		_, end := t.In(location).ZoneBounds()
+		if !end.IsZero() && (next.IsZero() || end.Before(next)) {
+			next = end
+		}
 	}
+	return next
+}
 
-	// 构建分页结果
-	pagination := models.PaginationResult{
-		Total:    int(total),
-		PageNum:  page,
-		PageSize: pageSize,
+// 报表区间与基准周期的质量对比，未指定 compare 时返回 nil
+func (s *DataReportService) compareReport(window *reportWindow, comparison models.ComparisonQuery, sqlHandler ...func(*gorm.DB) *gorm.DB) (*models.QualityComparison, error) {
+	if comparison.Compare == models.CompareNone {
+		return nil, nil
+	}
+	if window.startDay == "" || window.endDay == "" {
+		return nil, fmt.Errorf("startDate and endDate are required for comparison")
+	}
+	start, end, err := utils.DateRange(window.startDay, window.endDay, window.bucketer.location)
+	if err != nil {
+		return nil, err
 	}
+	return (&QualityStatsService{db: s.db}).compare(start, end, window.bucketer.bucketBy, comparison, nil, sqlHandler...)
+}
 
-	return &models.CostReportResponse{
-		Items:      items,
-		Pagination: pagination,
-	}, nil
+// 将 列 -> 关键字 转换为 LIKE 模糊匹配条件，关键字为空的列忽略
+func likeHandlers(filters map[string]string) []func(*gorm.DB) *gorm.DB {
+	columns := make([]string, 0, len(filters))
+	for column := range filters {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	var handlers []func(*gorm.DB) *gorm.DB
+	for _, column := range columns {
+		column, keyword := column, filters[column]
+		if keyword == "" {
+			continue
+		}
+		handlers = append(handlers, func(db *gorm.DB) *gorm.DB {
+			return db.Where(column+" LIKE ?", "%"+keyword+"%")
+		})
+	}
+	return handlers
 }
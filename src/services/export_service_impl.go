@@ -0,0 +1,273 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// 每批读取的记录数，导出按主键游标分批读取，内存占用与总记录数无关
+const exportBatchSize = 1000
+
+// 由配置设置的导出目录（同时存放后台任务的产物）和同步导出的记录数上限
+var (
+	exportDir         = "data/exports"
+	exportSyncMaxRows = 50000
+)
+
+func ConfigureExports(dir string, syncMaxRows int) {
+	exportDir = dir
+	exportSyncMaxRows = syncMaxRows
+}
+
+type ExportService struct {
+	db *gorm.DB
+}
+
+func NewExportService(db *gorm.DB) (IExportService, error) {
+	return &ExportService{db: db}, nil
+}
+
+func (s *ExportService) WithContext(ctx context.Context) IExportService {
+	return &ExportService{db: s.db.WithContext(ctx)}
+}
+
+func (s *ExportService) startSpan(method string) (*ExportService, trace.Span) {
+	db, span := serviceSpan(s.db, "ExportService."+method)
+	return &ExportService{db: db}, span
+}
+
+// 符合条件的记录数，以及是否应转为后台导出
+func (s *ExportService) CountRecords(kind string, query *models.RecordExportQuery) (int64, bool, error) {
+	s, span := s.startSpan("CountRecords")
+	defer span.End()
+
+	records, err := s.recordQuery(kind, query)
+	if err != nil {
+		return 0, false, err
+	}
+	var total int64
+	if err := records.Count(&total).Error; err != nil {
+		return 0, false, err
+	}
+	return total, query.Async || total > int64(exportSyncMaxRows), nil
+}
+
+// 将记录流式写入 w，ctx 取消（例如客户端断开）后停止读取并返回 ctx 的错误
+func (s *ExportService) StreamRecords(ctx context.Context, kind string, query *models.RecordExportQuery, w io.Writer) (int64, error) {
+	s, span := s.startSpan("StreamRecords")
+	defer span.End()
+
+	return s.streamRecords(ctx, kind, query, w, nil)
+}
+
+func (s *ExportService) streamRecords(ctx context.Context, kind string, query *models.RecordExportQuery, w io.Writer, progress func(int64)) (int64, error) {
+	location, err := utils.ResolveLocation(query.TZ)
+	if err != nil {
+		return 0, err
+	}
+	records, err := s.recordQuery(kind, query)
+	if err != nil {
+		return 0, err
+	}
+	writer := newRecordWriter(query.Format, w)
+	if err := writer.Header(); err != nil {
+		return 0, err
+	}
+
+	var written int64
+	var lastID uint
+	for {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+		var batch []models.ExportRecord
+		if err := records.Session(&gorm.Session{}).WithContext(ctx).
+			Where("p.id > ?", lastID).Order("p.id").Limit(exportBatchSize).
+			Scan(&batch).Error; err != nil {
+			return written, err
+		}
+		for i := range batch {
+			batch[i].CreatedAt = batch[i].CreatedAt.In(location)
+			if err := writer.Write(&batch[i]); err != nil {
+				return written, err
+			}
+		}
+		written += int64(len(batch))
+		if err := writer.Flush(); err != nil {
+			return written, err
+		}
+		if progress != nil {
+			progress(written)
+		}
+		if len(batch) < exportBatchSize {
+			return written, nil
+		}
+		lastID = batch[len(batch)-1].ID
+	}
+}
+
+// 产品记录查询，kind 为 defects 时只包含不良品
+func (s *ExportService) recordQuery(kind string, query *models.RecordExportQuery) (*gorm.DB, error) {
+	if kind != models.ExportKindProducts && kind != models.ExportKindDefects {
+		return nil, fmt.Errorf("invalid export kind %q", kind)
+	}
+	location, err := utils.ResolveLocation(query.TZ)
+	if err != nil {
+		return nil, err
+	}
+
+	records := s.db.Table("products p").
+		Select(`p.id, p.sn, p.batch_number, pm.sn as product_model_sn, pm.description, s.name as supplier_name,
+			pl.name as product_line, pa.sn as pallet_sn, p.has_defect, p.defect_reason, p.created_at`).
+		Joins("LEFT JOIN product_models pm ON p.product_model_id = pm.id").
+		Joins("LEFT JOIN suppliers s ON pm.supplier_id = s.id").
+		Joins("LEFT JOIN product_lines pl ON p.product_line_id = pl.id").
+		Joins("LEFT JOIN pallets pa ON p.pallet_id = pa.id").
+		Where("p.deleted_at IS NULL")
+
+	if query.StartDate != "" {
+		start, err := utils.ParseDate(query.StartDate, location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid startDate %q, expected YYYY-MM-DD", query.StartDate)
+		}
+		records = records.Where("p.created_at >= ?", utils.InDB(start))
+	}
+	if query.EndDate != "" {
+		end, err := utils.ParseDate(query.EndDate, location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid endDate %q, expected YYYY-MM-DD", query.EndDate)
+		}
+		records = records.Where("p.created_at < ?", utils.InDB(end.AddDate(0, 0, 1)))
+	}
+	if query.SupplierID != nil {
+		records = records.Where("pm.supplier_id = ?", *query.SupplierID)
+	}
+	if query.ProductModelSN != "" {
+		records = records.Where("pm.sn LIKE ?", "%"+query.ProductModelSN+"%")
+	}
+	if query.ProductLineID != nil {
+		records = records.Where("p.product_line_id = ?", *query.ProductLineID)
+	}
+	if kind == models.ExportKindDefects {
+		records = records.Where("p.has_defect = ?", true)
+	} else if query.HasDefect != nil {
+		records = records.Where("p.has_defect = ?", *query.HasDefect)
+	}
+	return records, nil
+}
+
+// 后台导出任务的参数
+type recordExportPayload struct {
+	Kind  string                   `json:"kind"`
+	Query models.RecordExportQuery `json:"query"`
+}
+
+// 加入后台任务队列，由工作协程生成文件，完成后通过任务接口下载
+func (s *ExportService) StartExport(kind string, query *models.RecordExportQuery, total int64) (*models.Job, error) {
+	s, span := s.startSpan("StartExport")
+	defer span.End()
+
+	return enqueueJob(s.db, models.JobKindRecordExport, recordExportPayload{Kind: kind, Query: *query}, total)
+}
+
+func runRecordExport(ctx context.Context, run *JobRun) error {
+	var payload recordExportPayload
+	if err := run.Decode(&payload); err != nil {
+		return err
+	}
+	s := &ExportService{db: run.DB()}
+	name := fmt.Sprintf("%s_%s.%s", payload.Kind, run.Job.CreatedAt.In(utils.PlantLocation()).Format("20060102150405"), payload.Query.Format)
+	return run.WriteArtifact(name, func(w io.Writer) error {
+		_, err := s.streamRecords(ctx, payload.Kind, &payload.Query, w, func(rows int64) {
+			run.Progress(rows, max(run.Job.Total, rows))
+		})
+		return err
+	})
+}
+
+// 按格式逐条写出记录，每批结束时 Flush（写入 HTTP 响应时同时推送给客户端）
+type recordWriter interface {
+	Header() error
+	Write(record *models.ExportRecord) error
+	Flush() error
+}
+
+func newRecordWriter(format string, w io.Writer) recordWriter {
+	buffered := bufio.NewWriter(w)
+	if format == models.ExportFormatNDJSON {
+		return &ndjsonRecordWriter{out: w, buffered: buffered, encoder: json.NewEncoder(buffered)}
+	}
+	return &csvRecordWriter{out: w, buffered: buffered, writer: csv.NewWriter(buffered)}
+}
+
+type csvRecordWriter struct {
+	out      io.Writer
+	buffered *bufio.Writer
+	writer   *csv.Writer
+}
+
+func (w *csvRecordWriter) Header() error {
+	if _, err := w.buffered.WriteString("\uFEFF"); err != nil {
+		return err
+	}
+	return w.writer.Write([]string{"ID", "产品SN", "批次号", "物料编码", "物料描述", "供应商", "产线", "托盘SN", "是否不良", "不良原因", "创建时间"})
+}
+
+func (w *csvRecordWriter) Write(record *models.ExportRecord) error {
+	defect := "否"
+	if record.HasDefect {
+		defect = "是"
+	}
+	return w.writer.Write([]string{
+		strconv.FormatUint(uint64(record.ID), 10), record.SN, record.BatchNumber, record.ProductModelSN, record.Description,
+		record.SupplierName, record.ProductLine, record.PalletSN, defect, record.DefectReason,
+		record.CreatedAt.Format("2006-01-02 15:04:05"),
+	})
+}
+
+func (w *csvRecordWriter) Flush() error {
+	w.writer.Flush()
+	if err := w.writer.Error(); err != nil {
+		return err
+	}
+	return flush(w.out, w.buffered)
+}
+
+type ndjsonRecordWriter struct {
+	out      io.Writer
+	buffered *bufio.Writer
+	encoder  *json.Encoder
+}
+
+func (w *ndjsonRecordWriter) Header() error {
+	return nil
+}
+
+func (w *ndjsonRecordWriter) Write(record *models.ExportRecord) error {
+	return w.encoder.Encode(record)
+}
+
+func (w *ndjsonRecordWriter) Flush() error {
+	return flush(w.out, w.buffered)
+}
+
+func flush(out io.Writer, buffered *bufio.Writer) error {
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	if flusher, ok := out.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
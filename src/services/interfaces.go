@@ -1,6 +1,8 @@
 package services
 
 import (
+	"context"
+	"io"
 	"mime/multipart"
 	"time"
 
@@ -10,6 +12,7 @@ import (
 )
 
 type IUserService interface {
+	WithContext(ctx context.Context) IUserService
 	CreateUser(user *models.User) error
 	GetUserBy(identifierType UserIdentifierType, value interface{}) (*models.User, error)
 	GetUsers(query map[string]interface{}, paginate map[string]interface{}, sqlHandler ...func(*gorm.DB) *gorm.DB) ([]models.User, models.PaginationResult, error)
@@ -18,6 +21,7 @@ type IUserService interface {
 }
 
 type IProductService interface {
+	WithContext(ctx context.Context) IProductService
 	CreateProduct(product *models.Product) error
 	GetProduct(id int64) (models.Product, error)
 	GetProducts(query map[string]interface{}, paginate map[string]interface{}, sqlHandler ...func(*gorm.DB) *gorm.DB) ([]models.Product, models.PaginationResult, error)
@@ -26,6 +30,7 @@ type IProductService interface {
 }
 
 type ISupplierService interface {
+	WithContext(ctx context.Context) ISupplierService
 	CreateSupplier(supplier *models.Supplier) error
 	GetSupplier(id int64) (*models.Supplier, error)
 	GetSuppliers(query map[string]interface{}, paginate map[string]interface{}, sqlHandler ...func(*gorm.DB) *gorm.DB) ([]models.Supplier, models.PaginationResult, error)
@@ -34,6 +39,7 @@ type ISupplierService interface {
 }
 
 type IProductModelService interface {
+	WithContext(ctx context.Context) IProductModelService
 	CreateProductModel(productModel *models.ProductModel) error
 	GetProductModel(id int64) (*models.ProductModel, error)
 	GetProductModelBySN(sap string) (*models.ProductModel, error)
@@ -43,11 +49,12 @@ type IProductModelService interface {
 }
 
 type IProductionPlanService interface {
+	WithContext(ctx context.Context) IProductionPlanService
 	CreateProductionPlan(productionPlan *models.ProductionPlan) error
 	BatchCreateProductionPlans(plans []models.ProductionPlan) ([]models.ProductionPlan, error)
 	GetProductionPlan(id int64) (*models.ProductionPlan, error)
 	GetProductionPlans(query map[string]interface{}, paginate map[string]interface{}, sqlHandler ...func(*gorm.DB) *gorm.DB) ([]models.ProductionPlan, models.PaginationResult, error)
-	GetProductionPlansByDate(date time.Time) ([]models.ProductionPlan, error)
+	GetProductionPlansByDate(date time.Time, bucketBy string) ([]models.ProductionPlan, error)
 	UpdateProductionPlan(productionPlanInstance *models.ProductionPlan, productionPlan map[string]interface{}) error
 	DeleteProductionPlans(ids []int64) error
 	GetProductionPlansByDateRange(baseDate time.Time) (map[string][]models.ProductionPlan, error)
@@ -56,6 +63,7 @@ type IProductionPlanService interface {
 }
 
 type IProductLineService interface {
+	WithContext(ctx context.Context) IProductLineService
 	CreateProductLine(productLine *models.ProductLine) error
 	GetProductLine(id int64) (*models.ProductLine, error)
 	GetProductLineByDeviceID(deviceID string) (*models.ProductLine, error)
@@ -65,7 +73,9 @@ type IProductLineService interface {
 }
 
 type IPalletService interface {
+	WithContext(ctx context.Context) IPalletService
 	CreatePallet(pallet *models.Pallet) error
+	CreatePalletForBatch(pallet *models.Pallet, batchNumber string) error
 	GetPallet(id int64) (*models.Pallet, error)
 	GetPallets(query map[string]interface{}, paginate map[string]interface{}, sqlHandler ...func(*gorm.DB) *gorm.DB) ([]models.Pallet, models.PaginationResult, error)
 	UpdatePallet(palletInstance *models.Pallet, pallet map[string]interface{}) error
@@ -73,6 +83,7 @@ type IPalletService interface {
 }
 
 type IAPIService interface {
+	WithContext(ctx context.Context) IAPIService
 	CreateAPI(api *models.API) error
 	GetAPI(id int64) (*models.API, error)
 	GetAPIs(query map[string]interface{}, paginate map[string]interface{}, sqlHandler ...func(*gorm.DB) *gorm.DB) ([]models.API, models.PaginationResult, error)
@@ -90,12 +101,110 @@ type IKeyManagementService interface {
 	ValidateDeviceIDAndPublicKey(deviceID, publicKey string) bool
 }
 
+type IShiftCalendarService interface {
+	WithContext(ctx context.Context) IShiftCalendarService
+	CreateShift(shift *models.Shift) error
+	GetShift(id int64) (*models.Shift, error)
+	GetShifts(query map[string]interface{}) ([]models.Shift, error)
+	UpdateShift(shiftInstance *models.Shift, shift map[string]interface{}) error
+	DeleteShifts(ids []int64) error
+	CreateHoliday(holiday *models.Holiday) error
+	GetHolidays(query map[string]interface{}) ([]models.Holiday, error)
+	DeleteHolidays(ids []int64) error
+	LoadCalendar() (*models.ShiftCalendar, error)
+}
+
 type IQualityStatsService interface {
-	GetQualityStats(startDate, endDate time.Time) (*models.QualityStatsResponse, error)
+	WithContext(ctx context.Context) IQualityStatsService
+	GetQualityStats(startDate, endDate time.Time, options models.QualityStatsOptions) (*models.QualityStatsResponse, error)
+	Aggregate(startDate, endDate time.Time, spec models.AggregateSpec, sqlHandler ...func(*gorm.DB) *gorm.DB) (*models.QualityAggregateResponse, error)
+}
+
+type IQualityRollupService interface {
+	WithContext(ctx context.Context) IQualityRollupService
+	Apply(product *models.Product, delta int64) error
+	Rebuild(startDate, endDate time.Time) error
+	Backfill() error
+	Check(startDate, endDate time.Time) (*models.RollupCheckResult, error)
 }
 
 type IDataReportService interface {
+	WithContext(ctx context.Context) IDataReportService
 	GetDefectReport(query *models.DefectReportQuery) (*models.DefectReportResponse, error)
 	GetInspectionReport(query *models.InspectionReportQuery) (*models.InspectionReportResponse, error)
 	GetCostReport(query *models.CostReportQuery) (*models.CostReportResponse, error)
 }
+
+type IReportExportService interface {
+	WithContext(ctx context.Context) IReportExportService
+	DefectReportTable(query *models.DefectReportQuery) (*models.ReportTable, error)
+	InspectionReportTable(query *models.InspectionReportQuery) (*models.ReportTable, error)
+	CostReportTable(query *models.CostReportQuery) (*models.ReportTable, error)
+	SupplierRankingTable(startDate, endDate, supplierName string) (*models.ReportTable, error)
+	Write(w io.Writer, table *models.ReportTable, format string) error
+}
+
+type IExportService interface {
+	WithContext(ctx context.Context) IExportService
+	CountRecords(kind string, query *models.RecordExportQuery) (int64, bool, error)
+	StreamRecords(ctx context.Context, kind string, query *models.RecordExportQuery, w io.Writer) (int64, error)
+	StartExport(kind string, query *models.RecordExportQuery, total int64) (*models.Job, error)
+}
+
+type IJobService interface {
+	WithContext(ctx context.Context) IJobService
+	Enqueue(kind string, payload interface{}, total int64) (*models.Job, error)
+	GetJob(id int64) (*models.Job, error)
+	GetJobs(query *models.JobQuery, paginate map[string]interface{}) ([]models.Job, models.PaginationResult, error)
+	CancelJob(id int64) (*models.Job, error)
+	DeleteJob(id int64) error
+	JobArtifact(id int64) (string, string, error)
+}
+
+type IReportScheduleService interface {
+	WithContext(ctx context.Context) IReportScheduleService
+	CreateSchedule(schedule *models.ReportSchedule) error
+	GetSchedule(id int64) (*models.ReportSchedule, error)
+	GetSchedules(paginate map[string]interface{}) ([]models.ReportSchedule, models.PaginationResult, error)
+	UpdateSchedule(scheduleInstance *models.ReportSchedule, merged *models.ReportSchedule, schedule map[string]interface{}) error
+	DeleteSchedules(ids []int64) error
+	RunSchedule(id int64) (*models.Job, error)
+	GetRuns(scheduleID int64, paginate map[string]interface{}) ([]models.ReportRun, models.PaginationResult, error)
+}
+
+type ISearchService interface {
+	WithContext(ctx context.Context) ISearchService
+	Search(query *models.SearchQuery) ([]models.SearchHit, error)
+	Reindex(progress func(done, total int64) error) error
+	Backfill() (*models.Job, error)
+}
+
+type ITraceService interface {
+	WithContext(ctx context.Context) ITraceService
+	TraceProduct(sn string) (*models.ProductTrace, error)
+	TraceBatch(batchNumber string, productModelID *uint) (models.TraceSerials, error)
+	TracePallet(palletID int64) (models.TraceSerials, error)
+}
+
+type IBatchService interface {
+	WithContext(ctx context.Context) IBatchService
+	CreateBatch(batch *models.Batch, operator string) error
+	GetBatch(id int64) (*models.Batch, error)
+	GetBatches(query map[string]interface{}, paginate map[string]interface{}, sqlHandler ...func(*gorm.DB) *gorm.DB) ([]models.Batch, models.PaginationResult, error)
+	Transition(id int64, action, note, operator string) (*models.Batch, error)
+	GetDispositions(batchID int64) ([]models.BatchDisposition, error)
+	FindBatch(productModelID uint, batchNumber string) (*models.Batch, error)
+	GetBlockedBatches(productModelID uint) ([]models.Batch, error)
+}
+
+type ISamplingService interface {
+	WithContext(ctx context.Context) ISamplingService
+	CreatePlan(plan *models.SamplingPlan) error
+	GetPlan(id int64) (*models.SamplingPlan, error)
+	GetPlans(query map[string]interface{}, paginate map[string]interface{}, sqlHandler ...func(*gorm.DB) *gorm.DB) ([]models.SamplingPlan, models.PaginationResult, error)
+	UpdatePlan(planInstance *models.SamplingPlan, merged *models.SamplingPlan, plan map[string]interface{}) error
+	DeletePlans(ids []int64) error
+	ResetPlan(id int64, severity string) (*models.SamplingPlan, error)
+	Instruct(productModel *models.ProductModel, batchNumber string, lotSize int) (*models.BatchSampling, error)
+	GetBatchSampling(batchID int64) (*models.BatchSampling, error)
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/config"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrJobFinished    = errors.New("job already finished")
+	ErrJobNotFinished = errors.New("job is not finished")
+	ErrJobNoArtifact  = errors.New("job has no artifact")
+)
+
+// 由配置设置的任务执行参数
+var jobOptions = config.Default().Jobs
+
+func ConfigureJobs(cfg config.JobsConfig) {
+	jobOptions = cfg
+}
+
+type JobService struct {
+	db *gorm.DB
+}
+
+func NewJobService(db *gorm.DB) (IJobService, error) {
+	return &JobService{db: db}, nil
+}
+
+func (s *JobService) WithContext(ctx context.Context) IJobService {
+	return &JobService{db: s.db.WithContext(ctx)}
+}
+
+func (s *JobService) startSpan(method string) (*JobService, trace.Span) {
+	db, span := serviceSpan(s.db, "JobService."+method)
+	return &JobService{db: db}, span
+}
+
+// 加入队列，payload 编码为 JSON 交给对应类型的处理函数
+func (s *JobService) Enqueue(kind string, payload interface{}, total int64) (*models.Job, error) {
+	s, span := s.startSpan("Enqueue")
+	defer span.End()
+
+	return enqueueJob(s.db, kind, payload, total)
+}
+
+func enqueueJob(db *gorm.DB, kind string, payload interface{}, total int64) (*models.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	job := models.Job{
+		Kind:        kind,
+		Payload:     string(data),
+		Status:      models.JobStatusQueued,
+		RunAt:       time.Now(),
+		MaxAttempts: jobOptions.MaxAttempts,
+		Total:       total,
+	}
+	if err := db.Create(&job).Error; err != nil {
+		return nil, err
+	}
+	wakeJobWorkers()
+	return &job, nil
+}
+
+func (s *JobService) GetJob(id int64) (*models.Job, error) {
+	s, span := s.startSpan("GetJob")
+	defer span.End()
+
+	var job models.Job
+	err := s.db.First(&job, id).Error
+	return &job, err
+}
+
+func (s *JobService) GetJobs(query *models.JobQuery, paginate map[string]interface{}) ([]models.Job, models.PaginationResult, error) {
+	s, span := s.startSpan("GetJobs")
+	defer span.End()
+
+	var jobs []models.Job
+	model := s.db.Model(&models.Job{})
+	if query.Status != "" {
+		model = model.Where("status = ?", query.Status)
+	}
+	if query.Kind != "" {
+		model = model.Where("kind = ?", query.Kind)
+	}
+
+	model, pagination := utils.DoPagination(model, paginate)
+	model = utils.DoOrder(model, paginate)
+
+	if err := model.Find(&jobs).Error; err != nil {
+		return []models.Job{}, pagination, err
+	}
+	return jobs, pagination, nil
+}
+
+// 排队中的任务直接取消；执行中的任务标记取消，由执行它的工作协程中断处理函数
+func (s *JobService) CancelJob(id int64) (*models.Job, error) {
+	s, span := s.startSpan("CancelJob")
+	defer span.End()
+
+	now := utils.InDB(time.Now())
+	result := s.db.Model(&models.Job{}).Where("id = ? AND status = ?", id, models.JobStatusQueued).
+		Updates(map[string]interface{}{"status": models.JobStatusCanceled, "cancel_requested": true, "finished_at": now})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		result = s.db.Model(&models.Job{}).Where("id = ? AND status = ?", id, models.JobStatusRunning).
+			Update("cancel_requested", true)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected > 0 {
+			cancelRunningJob(id)
+		}
+	}
+
+	job, err := s.GetJob(id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Finished() && job.Status != models.JobStatusCanceled {
+		return job, ErrJobFinished
+	}
+	return job, nil
+}
+
+// 删除已结束的任务及其产物
+func (s *JobService) DeleteJob(id int64) error {
+	s, span := s.startSpan("DeleteJob")
+	defer span.End()
+
+	job, err := s.GetJob(id)
+	if err != nil {
+		return err
+	}
+	if !job.Finished() {
+		return ErrJobNotFinished
+	}
+	if err := s.db.Delete(&models.Job{}, id).Error; err != nil {
+		return err
+	}
+	if job.ArtifactPath != "" {
+		if err := os.Remove(filepath.Join(exportDir, job.ArtifactPath)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// 已完成任务的产物路径和下载文件名
+func (s *JobService) JobArtifact(id int64) (string, string, error) {
+	s, span := s.startSpan("JobArtifact")
+	defer span.End()
+
+	job, err := s.GetJob(id)
+	if err != nil {
+		return "", "", err
+	}
+	if job.Status != models.JobStatusCompleted || job.ArtifactPath == "" {
+		return "", "", ErrJobNoArtifact
+	}
+	return filepath.Join(exportDir, job.ArtifactPath), job.ArtifactName, nil
+}
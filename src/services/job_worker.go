@@ -0,0 +1,388 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/logging"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/tracing"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// JobHandler 执行一种类型的任务；ctx 在任务被取消或服务停止时取消
+// 返回的错误会按退避时间重试，用 PermanentJobError 包装的错误不重试
+type JobHandler func(ctx context.Context, run *JobRun) error
+
+var (
+	jobHandlersMu sync.RWMutex
+	jobHandlers   = map[string]JobHandler{
+		models.JobKindRecordExport:  runRecordExport,
+		models.JobKindRollupRebuild: runRollupRebuild,
+		models.JobKindReport:        runReport,
+		models.JobKindSearchReindex: runSearchReindex,
+	}
+)
+
+// 注册任务类型，须在 StartJobWorkers 之前调用
+func RegisterJobHandler(kind string, handler JobHandler) {
+	jobHandlersMu.Lock()
+	defer jobHandlersMu.Unlock()
+	jobHandlers[kind] = handler
+}
+
+func jobHandler(kind string) (JobHandler, bool) {
+	jobHandlersMu.RLock()
+	defer jobHandlersMu.RUnlock()
+	handler, ok := jobHandlers[kind]
+	return handler, ok
+}
+
+type permanentJobError struct{ err error }
+
+func (e permanentJobError) Error() string { return e.err.Error() }
+func (e permanentJobError) Unwrap() error { return e.err }
+
+// 不可重试的错误，例如参数无效
+func PermanentJobError(err error) error {
+	return permanentJobError{err: err}
+}
+
+var errJobCanceled = errors.New("job canceled")
+
+var (
+	// 本进程内执行中任务的取消函数，取消请求由 CancelJob 直接送达
+	runningJobs sync.Map
+	// 新任务入队时唤醒空闲的工作协程，不必等到下一次轮询
+	jobWake = make(chan struct{}, 1)
+)
+
+func cancelRunningJob(id int64) {
+	if cancel, ok := runningJobs.Load(id); ok {
+		cancel.(context.CancelCauseFunc)(errJobCanceled)
+	}
+}
+
+func wakeJobWorkers() {
+	select {
+	case jobWake <- struct{}{}:
+	default:
+	}
+}
+
+// JobWorkers 进程内的工作协程池，从 jobs 表领取任务执行；多个实例可共用同一个队列
+type JobWorkers struct {
+	db     *gorm.DB
+	id     string
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// 按 ConfigureJobs 的配置启动工作协程，Workers 为 0 时只启动超时任务的回收
+func StartJobWorkers(db *gorm.DB) *JobWorkers {
+	hostname, _ := os.Hostname()
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &JobWorkers{
+		db:     db,
+		id:     fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		cancel: cancel,
+	}
+	for i := 0; i < jobOptions.Workers; i++ {
+		w.wg.Add(1)
+		go w.work(ctx)
+	}
+	w.wg.Add(1)
+	go w.reap(ctx)
+	return w
+}
+
+// 停止领取任务并中断执行中的任务，被中断的任务重新排队，不计入执行次数
+func (w *JobWorkers) Stop(ctx context.Context) error {
+	w.cancel()
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *JobWorkers) work(ctx context.Context) {
+	defer w.wg.Done()
+	for ctx.Err() == nil {
+		job, err := w.claim(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("claim job failed", "error", err)
+		}
+		if job != nil {
+			w.run(ctx, job)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-jobWake:
+		case <-time.After(jobOptions.PollInterval):
+		}
+	}
+}
+
+// 领取最早可执行的任务；条件更新保证同一任务只被一个工作协程领取
+func (w *JobWorkers) claim(ctx context.Context) (*models.Job, error) {
+	db := w.db.WithContext(ctx)
+	for {
+		now := time.Now()
+		var job models.Job
+		err := db.Where("status = ? AND run_at <= ?", models.JobStatusQueued, utils.InDB(now)).
+			Order("run_at, id").First(&job).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		result := db.Model(&models.Job{}).Where("id = ? AND status = ?", job.ID, models.JobStatusQueued).
+			Updates(map[string]interface{}{
+				"status":       models.JobStatusRunning,
+				"attempts":     gorm.Expr("attempts + 1"),
+				"worker_id":    w.id,
+				"started_at":   utils.InDB(now),
+				"heartbeat_at": utils.InDB(now),
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			job.Status = models.JobStatusRunning
+			job.Attempts++
+			job.WorkerID = w.id
+			return &job, nil
+		}
+		// 已被其他工作协程领取，继续找下一个
+	}
+}
+
+func (w *JobWorkers) run(parent context.Context, job *models.Job) {
+	ctx, cancel := context.WithCancelCause(parent)
+	defer cancel(nil)
+	runningJobs.Store(job.ID, cancel)
+	defer runningJobs.Delete(job.ID)
+
+	logger := slog.Default().With("job_id", job.ID, "job_kind", job.Kind, "attempt", job.Attempts)
+	ctx = logging.NewContext(ctx, logger)
+	ctx, span := tracing.Start(ctx, "job "+job.Kind, trace.WithAttributes(
+		attribute.Int64("job.id", job.ID),
+		attribute.Int("job.attempt", job.Attempts),
+	))
+	defer span.End()
+
+	stopHeartbeat := w.heartbeat(ctx, job.ID, cancel)
+	logger.Info("job started")
+	err := w.execute(ctx, job)
+	stopHeartbeat()
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	if finishErr := w.finish(job, err, context.Cause(ctx)); finishErr != nil {
+		logger.Error("update job status failed", "error", finishErr)
+	}
+}
+
+func (w *JobWorkers) execute(ctx context.Context, job *models.Job) (err error) {
+	handler, ok := jobHandler(job.Kind)
+	if !ok {
+		return PermanentJobError(fmt.Errorf("unknown job kind %q", job.Kind))
+	}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("job panicked: %v", recovered)
+		}
+	}()
+	return handler(ctx, &JobRun{Job: job, db: w.db.WithContext(ctx)})
+}
+
+// 定期上报心跳，并检查其他实例发出的取消请求
+func (w *JobWorkers) heartbeat(ctx context.Context, id int64, cancel context.CancelCauseFunc) func() {
+	ctx, stop := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(jobOptions.StaleTimeout / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			var job models.Job
+			if err := w.db.WithContext(ctx).Select("cancel_requested").First(&job, id).Error; err == nil && job.CancelRequested {
+				cancel(errJobCanceled)
+				return
+			}
+			w.db.WithContext(ctx).Model(&models.Job{}).Where("id = ?", id).Update("heartbeat_at", utils.InDB(time.Now()))
+		}
+	}()
+	return func() {
+		stop()
+		<-done
+	}
+}
+
+// 记录执行结果：失败时未用尽次数则按退避时间重新排队
+func (w *JobWorkers) finish(job *models.Job, err error, cause error) error {
+	now := time.Now()
+	updates := map[string]interface{}{"worker_id": "", "heartbeat_at": nil}
+	var permanent permanentJobError
+	switch {
+	case err == nil:
+		updates["status"] = models.JobStatusCompleted
+		updates["finished_at"] = utils.InDB(now)
+		updates["error"] = ""
+	case errors.Is(cause, errJobCanceled):
+		updates["status"] = models.JobStatusCanceled
+		updates["finished_at"] = utils.InDB(now)
+	case cause != nil:
+		// 服务停止，下次启动后继续执行
+		updates["status"] = models.JobStatusQueued
+		updates["attempts"] = gorm.Expr("attempts - 1")
+		updates["run_at"] = utils.InDB(now)
+	case errors.As(err, &permanent) || job.Attempts >= job.MaxAttempts:
+		updates["status"] = models.JobStatusFailed
+		updates["finished_at"] = utils.InDB(now)
+		updates["error"] = err.Error()
+	default:
+		updates["status"] = models.JobStatusQueued
+		updates["run_at"] = utils.InDB(now.Add(retryBackoff(job.Attempts)))
+		updates["error"] = err.Error()
+	}
+
+	logger := slog.Default().With("job_id", job.ID, "job_kind", job.Kind, "attempt", job.Attempts, "status", updates["status"])
+	if err != nil && cause == nil {
+		logger.Error("job failed", "error", err)
+	} else {
+		logger.Info("job finished")
+	}
+	return w.db.Model(&models.Job{}).Where("id = ?", job.ID).Updates(updates).Error
+}
+
+// 第 n 次执行失败后的等待时间：RetryBackoff * 2^(n-1)，不超过 MaxRetryBackoff
+func retryBackoff(attempts int) time.Duration {
+	backoff := jobOptions.RetryBackoff
+	for i := 1; i < attempts && backoff < jobOptions.MaxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > jobOptions.MaxRetryBackoff {
+		backoff = jobOptions.MaxRetryBackoff
+	}
+	return backoff
+}
+
+// 回收心跳超时的任务（执行它的进程已退出），次数用尽的标记为失败，否则重新排队
+func (w *JobWorkers) reap(ctx context.Context) {
+	defer w.wg.Done()
+	ticker := time.NewTicker(jobOptions.StaleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		stale := w.db.WithContext(ctx).Model(&models.Job{}).
+			Where("status = ? AND heartbeat_at < ?", models.JobStatusRunning, utils.InDB(now.Add(-jobOptions.StaleTimeout)))
+		if err := stale.Session(&gorm.Session{}).Where("attempts >= max_attempts").Updates(map[string]interface{}{
+			"status": models.JobStatusFailed, "finished_at": utils.InDB(now), "error": "worker lost", "worker_id": "",
+		}).Error; err != nil && ctx.Err() == nil {
+			slog.Error("reap stale jobs failed", "error", err)
+		}
+		if err := stale.Session(&gorm.Session{}).Updates(map[string]interface{}{
+			"status": models.JobStatusQueued, "run_at": utils.InDB(now), "worker_id": "",
+		}).Error; err != nil && ctx.Err() == nil {
+			slog.Error("reap stale jobs failed", "error", err)
+		}
+	}
+}
+
+// JobRun 处理函数可用的任务参数、进度上报和产物写入
+type JobRun struct {
+	Job *models.Job
+	db  *gorm.DB
+}
+
+// 使用任务 context 的数据库连接
+func (r *JobRun) DB() *gorm.DB {
+	return r.db
+}
+
+// 解析任务参数，参数无效时不重试
+func (r *JobRun) Decode(v interface{}) error {
+	if err := json.Unmarshal([]byte(r.Job.Payload), v); err != nil {
+		return PermanentJobError(fmt.Errorf("invalid payload: %w", err))
+	}
+	return nil
+}
+
+// 上报进度，同时作为心跳
+func (r *JobRun) Progress(done, total int64) {
+	r.Job.Done, r.Job.Total = done, total
+	err := r.db.Model(&models.Job{}).Where("id = ?", r.Job.ID).Updates(map[string]interface{}{
+		"done": done, "total": total, "heartbeat_at": utils.InDB(time.Now()),
+	}).Error
+	if err != nil {
+		logging.FromContext(r.db.Statement.Context).Warn("update job progress failed", "error", err)
+	}
+}
+
+// 将产物写入导出目录：先写临时文件，成功后改名并记录到任务，name 为下载文件名
+func (r *JobRun) WriteArtifact(name string, write func(io.Writer) error) error {
+	if err := os.MkdirAll(exportDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create export directory: %w", err)
+	}
+	fileName := fmt.Sprintf("job-%d%s", r.Job.ID, filepath.Ext(name))
+	path := filepath.Join(exportDir, fileName)
+	temp := path + ".tmp"
+	file, err := os.Create(temp)
+	if err != nil {
+		return err
+	}
+	err = write(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp, path)
+	}
+	if err != nil {
+		os.Remove(temp)
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	r.Job.ArtifactPath, r.Job.ArtifactName, r.Job.ArtifactSize = fileName, name, info.Size()
+	return r.db.Model(&models.Job{}).Where("id = ?", r.Job.ID).Updates(map[string]interface{}{
+		"artifact_path": fileName, "artifact_name": name, "artifact_size": info.Size(),
+	}).Error
+}
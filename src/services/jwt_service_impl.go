@@ -9,8 +9,18 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 )
 
+// 由配置设置的签名密钥，未设置时从环境变量 SECRET 读取
+var secretKey string
+
+func ConfigureSecretKey(secret string) {
+	secretKey = secret
+}
+
 func GetSecretKey() string {
-	secret := os.Getenv("SECRET")
+	secret := secretKey
+	if secret == "" {
+		secret = os.Getenv("SECRET")
+	}
 	if secret == "" {
 		secret = "secret"
 	}
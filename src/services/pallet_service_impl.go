@@ -1,11 +1,28 @@
 package services
 
 import (
+	"context"
+	"time"
+
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 )
 
+// 托盘列表可筛选、排序的字段
+var palletFields = utils.Fields{
+	"id":             {Column: "pallets.id", Type: utils.FieldInt, Sort: true},
+	"createdAt":      {Column: "pallets.created_at", Type: utils.FieldTime, Sort: true},
+	"updatedAt":      {Column: "pallets.updated_at", Type: utils.FieldTime, Sort: true},
+	"sn":             {Column: "pallets.sn", Type: utils.FieldString, Sort: true},
+	"productModelId": {Column: "pallets.product_model_id", Type: utils.FieldInt},
+	"productLineId":  {Column: "pallets.product_line_id", Type: utils.FieldInt},
+	"goal":           {Column: "pallets.goal", Type: utils.FieldInt, Sort: true},
+}
+
+var palletKeyset = utils.KeysetColumns{Field: "createdAt", CreatedAt: "pallets.created_at", ID: "pallets.id"}
+
 type PalletService struct {
 	db *gorm.DB
 }
@@ -14,19 +31,53 @@ func NewPalletService(db *gorm.DB) (IPalletService, error) {
 	return &PalletService{db: db}, nil
 }
 
+func (s *PalletService) WithContext(ctx context.Context) IPalletService {
+	return &PalletService{db: s.db.WithContext(ctx)}
+}
+
+func (s *PalletService) startSpan(method string) (*PalletService, trace.Span) {
+	db, span := serviceSpan(s.db, "PalletService."+method)
+	return &PalletService{db: db}, span
+}
+
 func (s *PalletService) CreatePallet(pallet *models.Pallet) error {
+	s, span := s.startSpan("CreatePallet")
+	defer span.End()
+
 	return s.db.Create(pallet).Error
 }
 
+// 创建装载指定批次的托盘，批次冻结或判退且按配置拒绝时返回 *BatchBlockedError
+func (s *PalletService) CreatePalletForBatch(pallet *models.Pallet, batchNumber string) error {
+	s, span := s.startSpan("CreatePalletForBatch")
+	defer span.End()
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkBatchHold(tx, pallet.ProductModelID, batchNumber); err != nil {
+			return err
+		}
+		return tx.Create(pallet).Error
+	})
+}
+
 func (s *PalletService) GetPallet(id int64) (*models.Pallet, error) {
+	s, span := s.startSpan("GetPallet")
+	defer span.End()
+
 	var pallet models.Pallet
 	err := s.db.First(&pallet, id).Error
 	return &pallet, err
 }
 
 func (s *PalletService) GetPallets(query map[string]interface{}, paginate map[string]interface{}, sqlHandler ...func(*gorm.DB) *gorm.DB) ([]models.Pallet, models.PaginationResult, error) {
+	s, span := s.startSpan("GetPallets")
+	defer span.End()
+
 	var pallets []models.Pallet
-	var pagination models.PaginationResult
+	page, err := utils.ParsePage(paginate, palletFields, palletKeyset)
+	if err != nil {
+		return []models.Pallet{}, models.PaginationResult{}, err
+	}
 	var model = s.db.Model(&models.Pallet{}).Preload("ProductModel").Preload("ProductLine").Preload("ProductModel.Supplier")
 
 	for _, handler := range sqlHandler {
@@ -34,23 +85,34 @@ func (s *PalletService) GetPallets(query map[string]interface{}, paginate map[st
 	}
 	model = model.Where(query)
 
-	model, pagination = utils.DoPagination(model, paginate)
-	model = utils.DoOrder(model, paginate)
+	model, pagination, err := page.Apply(model)
+	if err != nil {
+		return []models.Pallet{}, pagination, err
+	}
 
 	result := model.Find(&pallets)
 	if result.Error != nil {
 		return []models.Pallet{}, pagination, result.Error
 	}
 
+	pallets = pallets[:page.Next(&pagination, len(pallets), func(i int) (time.Time, int64) {
+		return pallets[i].CreatedAt, pallets[i].ID
+	})]
 	return pallets, pagination, nil
 }
 
 func (s *PalletService) UpdatePallet(palletInstance *models.Pallet, pallet map[string]interface{}) error {
+	s, span := s.startSpan("UpdatePallet")
+	defer span.End()
+
 	result := s.db.Model(palletInstance).Updates(pallet)
 	return result.Error
 }
 
 func (s *PalletService) DeletePallets(ids []int64) error {
+	s, span := s.startSpan("DeletePallets")
+	defer span.End()
+
 	result := s.db.Delete(&models.Pallet{}, ids)
 	return result.Error
 }
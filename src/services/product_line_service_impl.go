@@ -1,13 +1,28 @@
 package services
 
 import (
-	"fmt"
+	"context"
+	"time"
 
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 )
 
+// 产线列表可筛选、排序的字段
+var productLineFields = utils.Fields{
+	"id":             {Column: "product_lines.id", Type: utils.FieldInt, Sort: true},
+	"createdAt":      {Column: "product_lines.created_at", Type: utils.FieldTime, Sort: true},
+	"updatedAt":      {Column: "product_lines.updated_at", Type: utils.FieldTime, Sort: true},
+	"name":           {Column: "product_lines.name", Type: utils.FieldString, Sort: true},
+	"palletSnPrefix": {Column: "product_lines.pallet_sn_prefix", Type: utils.FieldString},
+	"deviceId":       {Column: "product_lines.device_id", Type: utils.FieldString, Sort: true},
+	"isRegistered":   {Column: "product_lines.is_registered", Type: utils.FieldBool},
+}
+
+var productLineKeyset = utils.KeysetColumns{Field: "createdAt", CreatedAt: "product_lines.created_at", ID: "product_lines.id"}
+
 type ProductLineService struct {
 	db *gorm.DB
 }
@@ -16,20 +31,40 @@ func NewProductLineService(db *gorm.DB) (IProductLineService, error) {
 	return &ProductLineService{db: db}, nil
 }
 
+func (s *ProductLineService) WithContext(ctx context.Context) IProductLineService {
+	return &ProductLineService{db: s.db.WithContext(ctx)}
+}
+
+func (s *ProductLineService) startSpan(method string) (*ProductLineService, trace.Span) {
+	db, span := serviceSpan(s.db, "ProductLineService."+method)
+	return &ProductLineService{db: db}, span
+}
+
 func (s *ProductLineService) CreateProductLine(productLine *models.ProductLine) error {
+	s, span := s.startSpan("CreateProductLine")
+	defer span.End()
+
 	return s.db.Create(productLine).Error
 }
 
 func (s *ProductLineService) GetProductLine(id int64) (*models.ProductLine, error) {
+	s, span := s.startSpan("GetProductLine")
+	defer span.End()
+
 	var productLine models.ProductLine
-	err := s.db.Debug().First(&productLine, id).Error
-	fmt.Println(productLine)
+	err := s.db.First(&productLine, id).Error
 	return &productLine, err
 }
 
 func (s *ProductLineService) GetProductLines(query map[string]interface{}, paginate map[string]interface{}, sqlHandler ...func(*gorm.DB) *gorm.DB) ([]models.ProductLine, models.PaginationResult, error) {
+	s, span := s.startSpan("GetProductLines")
+	defer span.End()
+
 	var productLines []models.ProductLine
-	var pagination models.PaginationResult
+	page, err := utils.ParsePage(paginate, productLineFields, productLineKeyset)
+	if err != nil {
+		return []models.ProductLine{}, models.PaginationResult{}, err
+	}
 	var model = s.db.Model(&models.ProductLine{})
 
 	for _, handler := range sqlHandler {
@@ -37,28 +72,42 @@ func (s *ProductLineService) GetProductLines(query map[string]interface{}, pagin
 	}
 	model = model.Where(query)
 
-	model, pagination = utils.DoPagination(model, paginate)
-	model = utils.DoOrder(model, paginate)
+	model, pagination, err := page.Apply(model)
+	if err != nil {
+		return []models.ProductLine{}, pagination, err
+	}
 
 	result := model.Find(&productLines)
 	if result.Error != nil {
 		return []models.ProductLine{}, pagination, result.Error
 	}
 
+	productLines = productLines[:page.Next(&pagination, len(productLines), func(i int) (time.Time, int64) {
+		return productLines[i].CreatedAt, productLines[i].ID
+	})]
 	return productLines, pagination, nil
 }
 
 func (s *ProductLineService) UpdateProductLine(productLineInstance *models.ProductLine, productLine map[string]interface{}) error {
+	s, span := s.startSpan("UpdateProductLine")
+	defer span.End()
+
 	result := s.db.Model(productLineInstance).Updates(productLine)
 	return result.Error
 }
 func (s *ProductLineService) DeleteProductLines(ids []int64) error {
+	s, span := s.startSpan("DeleteProductLines")
+	defer span.End()
+
 	// Hard delete the product lines
 	result := s.db.Unscoped().Delete(&models.ProductLine{}, ids)
 	return result.Error
 }
 
 func (s *ProductLineService) GetProductLineByDeviceID(deviceID string) (*models.ProductLine, error) {
+	s, span := s.startSpan("GetProductLineByDeviceID")
+	defer span.End()
+
 	var productLine models.ProductLine
 	err := s.db.Where("device_id = ?", deviceID).First(&productLine).Error
 	return &productLine, err
@@ -1,11 +1,28 @@
 package services
 
 import (
+	"context"
+	"time"
+
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 )
 
+// 物料列表可筛选、排序的字段
+var productModelFields = utils.Fields{
+	"id":          {Column: "product_models.id", Type: utils.FieldInt, Sort: true},
+	"createdAt":   {Column: "product_models.created_at", Type: utils.FieldTime, Sort: true},
+	"updatedAt":   {Column: "product_models.updated_at", Type: utils.FieldTime, Sort: true},
+	"sn":          {Column: "product_models.sn", Type: utils.FieldString, Sort: true},
+	"partNumber":  {Column: "product_models.part_number", Type: utils.FieldString, Sort: true},
+	"description": {Column: "product_models.description", Type: utils.FieldString},
+	"supplierId":  {Column: "product_models.supplier_id", Type: utils.FieldInt},
+}
+
+var productModelKeyset = utils.KeysetColumns{Field: "createdAt", CreatedAt: "product_models.created_at", ID: "product_models.id"}
+
 type ProductModelService struct {
 	db *gorm.DB
 }
@@ -14,19 +31,40 @@ func NewProductModelService(db *gorm.DB) (IProductModelService, error) {
 	return &ProductModelService{db: db}, nil
 }
 
+func (s *ProductModelService) WithContext(ctx context.Context) IProductModelService {
+	return &ProductModelService{db: s.db.WithContext(ctx)}
+}
+
+func (s *ProductModelService) startSpan(method string) (*ProductModelService, trace.Span) {
+	db, span := serviceSpan(s.db, "ProductModelService."+method)
+	return &ProductModelService{db: db}, span
+}
+
 func (s *ProductModelService) CreateProductModel(productModel *models.ProductModel) error {
+	s, span := s.startSpan("CreateProductModel")
+	defer span.End()
+
 	return s.db.Create(productModel).Error
 }
 
 func (s *ProductModelService) GetProductModel(id int64) (*models.ProductModel, error) {
+	s, span := s.startSpan("GetProductModel")
+	defer span.End()
+
 	var productModel models.ProductModel
 	err := s.db.First(&productModel, id).Error
 	return &productModel, err
 }
 
 func (s *ProductModelService) GetProductModels(query map[string]interface{}, paginate map[string]interface{}, sqlHandler ...func(*gorm.DB) *gorm.DB) ([]models.ProductModel, models.PaginationResult, error) {
+	s, span := s.startSpan("GetProductModels")
+	defer span.End()
+
 	var productModels []models.ProductModel
-	var pagination models.PaginationResult
+	page, err := utils.ParsePage(paginate, productModelFields, productModelKeyset)
+	if err != nil {
+		return []models.ProductModel{}, models.PaginationResult{}, err
+	}
 	var model = s.db.Model(&models.ProductModel{}).Preload("Supplier")
 
 	for _, handler := range sqlHandler {
@@ -34,29 +72,43 @@ func (s *ProductModelService) GetProductModels(query map[string]interface{}, pag
 	}
 	model = model.Where(query)
 
-	model, pagination = utils.DoPagination(model, paginate)
-	model = utils.DoOrder(model, paginate)
+	model, pagination, err := page.Apply(model)
+	if err != nil {
+		return []models.ProductModel{}, pagination, err
+	}
 
 	result := model.Find(&productModels)
 	if result.Error != nil {
 		return []models.ProductModel{}, pagination, result.Error
 	}
 
+	productModels = productModels[:page.Next(&pagination, len(productModels), func(i int) (time.Time, int64) {
+		return productModels[i].CreatedAt, productModels[i].ID
+	})]
 	return productModels, pagination, nil
 }
 
 func (s *ProductModelService) GetProductModelBySN(sn string) (*models.ProductModel, error) {
+	s, span := s.startSpan("GetProductModelBySN")
+	defer span.End()
+
 	var productModel models.ProductModel
 	err := s.db.Where("sn = ?", sn).First(&productModel).Error
 	return &productModel, err
 }
 
 func (s *ProductModelService) UpdateProductModel(productModelInstance *models.ProductModel, productModel map[string]interface{}) error {
+	s, span := s.startSpan("UpdateProductModel")
+	defer span.End()
+
 	result := s.db.Model(productModelInstance).Updates(productModel)
 	return result.Error
 }
 
 func (s *ProductModelService) DeleteProductModels(ids []int64) error {
+	s, span := s.startSpan("DeleteProductModels")
+	defer span.End()
+
 	result := s.db.Delete(&models.ProductModel{}, ids)
 	return result.Error
 }
@@ -1,11 +1,32 @@
 package services
 
 import (
+	"context"
+	"time"
+
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 )
 
+// 产品列表可筛选、排序的字段
+var productFields = utils.Fields{
+	"id":               {Column: "products.id", Type: utils.FieldInt, Sort: true},
+	"createdAt":        {Column: "products.created_at", Type: utils.FieldTime, Sort: true},
+	"updatedAt":        {Column: "products.updated_at", Type: utils.FieldTime, Sort: true},
+	"sn":               {Column: "products.sn", Type: utils.FieldString, Sort: true},
+	"batchNumber":      {Column: "products.batch_number", Type: utils.FieldString, Sort: true},
+	"productModelId":   {Column: "products.product_model_id", Type: utils.FieldInt},
+	"productLineId":    {Column: "products.product_line_id", Type: utils.FieldInt},
+	"productionPlanId": {Column: "products.production_plan_id", Type: utils.FieldInt},
+	"palletId":         {Column: "products.pallet_id", Type: utils.FieldInt},
+	"hasDefect":        {Column: "products.has_defect", Type: utils.FieldBool},
+	"defectReason":     {Column: "products.defect_reason", Type: utils.FieldString},
+}
+
+var productKeyset = utils.KeysetColumns{Field: "createdAt", CreatedAt: "products.created_at", ID: "products.id"}
+
 type ProductService struct {
 	db *gorm.DB
 }
@@ -14,19 +35,59 @@ func NewProductService(db *gorm.DB) (IProductService, error) {
 	return &ProductService{db: db}, nil
 }
 
+func (s *ProductService) WithContext(ctx context.Context) IProductService {
+	return &ProductService{db: s.db.WithContext(ctx)}
+}
+
+func (s *ProductService) startSpan(method string) (*ProductService, trace.Span) {
+	db, span := serviceSpan(s.db, "ProductService."+method)
+	return &ProductService{db: db}, span
+}
+
+// 创建产品，并在同一事务中创建所属批次（不存在时）、更新质量预聚合和产品事件；
+// 所属批次冻结或判退且按配置拒绝时返回 *BatchBlockedError
 func (s *ProductService) CreateProduct(product *models.Product) error {
-	return s.db.Create(product).Error
+	s, span := s.startSpan("CreateProduct")
+	defer span.End()
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkBatchHold(tx, product.ProductModelID, product.BatchNumber); err != nil {
+			return err
+		}
+		if err := tx.Create(product).Error; err != nil {
+			return err
+		}
+		if err := ensureBatch(tx, product); err != nil {
+			return err
+		}
+		if err := recordProductEvent(tx, models.ProductEventCreated, product, productChanges(&models.Product{}, product)); err != nil {
+			return err
+		}
+		if err := recordSample(tx, product); err != nil {
+			return err
+		}
+		return (&QualityRollupService{db: tx}).Apply(product, 1)
+	})
 }
 
 func (s *ProductService) GetProduct(id int64) (models.Product, error) {
+	s, span := s.startSpan("GetProduct")
+	defer span.End()
+
 	var product models.Product
 	err := s.db.First(&product, id).Error
 	return product, err
 }
 
 func (s *ProductService) GetProducts(query map[string]interface{}, paginate map[string]interface{}, sqlHandler ...func(*gorm.DB) *gorm.DB) ([]models.Product, models.PaginationResult, error) {
+	s, span := s.startSpan("GetProducts")
+	defer span.End()
+
 	var products []models.Product
-	var pagination models.PaginationResult
+	page, err := utils.ParsePage(paginate, productFields, productKeyset)
+	if err != nil {
+		return []models.Product{}, models.PaginationResult{}, err
+	}
 	var model = s.db.Model(&models.Product{}).Preload("ProductModel").Preload("ProductLine").Preload("Pallet").Preload("ProductionPlan").Preload("ProductModel.Supplier")
 
 	for _, handler := range sqlHandler {
@@ -34,23 +95,73 @@ func (s *ProductService) GetProducts(query map[string]interface{}, paginate map[
 	}
 	model = model.Where(query)
 
-	model, pagination = utils.DoPagination(model, paginate)
-	model = utils.DoOrder(model, paginate)
+	model, pagination, err := page.Apply(model)
+	if err != nil {
+		return []models.Product{}, pagination, err
+	}
 
 	result := model.Find(&products)
 	if result.Error != nil {
 		return []models.Product{}, pagination, result.Error
 	}
 
+	products = products[:page.Next(&pagination, len(products), func(i int) (time.Time, int64) {
+		return products[i].CreatedAt, products[i].ID
+	})]
 	return products, pagination, nil
 }
 
+// 更新产品，预聚合中先移出旧记录再计入新记录，并记录变化的字段
 func (s *ProductService) UpdateProduct(productInstance *models.Product, product map[string]interface{}) error {
-	result := s.db.Model(productInstance).Updates(product)
-	return result.Error
+	s, span := s.startSpan("UpdateProduct")
+	defer span.End()
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		rollup := &QualityRollupService{db: tx}
+		var current models.Product
+		if err := tx.First(&current, productInstance.ID).Error; err != nil {
+			return err
+		}
+		if err := rollup.Apply(&current, -1); err != nil {
+			return err
+		}
+		if err := tx.Model(productInstance).Updates(product).Error; err != nil {
+			return err
+		}
+		var updated models.Product
+		if err := tx.First(&updated, productInstance.ID).Error; err != nil {
+			return err
+		}
+		if changes := productChanges(&current, &updated); len(changes) > 0 {
+			if err := recordProductEvent(tx, models.ProductEventUpdated, &updated, changes); err != nil {
+				return err
+			}
+		}
+		return rollup.Apply(&updated, 1)
+	})
 }
 
 func (s *ProductService) DeleteProducts(ids []int64) error {
-	result := s.db.Delete(&models.Product{}, ids)
-	return result.Error
+	s, span := s.startSpan("DeleteProducts")
+	defer span.End()
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var products []models.Product
+		if err := tx.Find(&products, ids).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.Product{}, ids).Error; err != nil {
+			return err
+		}
+		rollup := &QualityRollupService{db: tx}
+		for i := range products {
+			if err := rollup.Apply(&products[i], -1); err != nil {
+				return err
+			}
+			if err := recordProductEvent(tx, models.ProductEventDeleted, &products[i], nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
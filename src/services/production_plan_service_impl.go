@@ -1,17 +1,40 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"mime/multipart"
 	"strconv"
 	"time"
 
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/databases"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/logging"
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
 	"github.com/xuri/excelize/v2"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 )
 
+// 生产计划列表可筛选、排序的字段
+var productionPlanFields = utils.Fields{
+	"id":              {Column: "production_plans.id", Type: utils.FieldInt, Sort: true},
+	"createdAt":       {Column: "production_plans.created_at", Type: utils.FieldTime, Sort: true},
+	"updatedAt":       {Column: "production_plans.updated_at", Type: utils.FieldTime, Sort: true},
+	"materialCode":    {Column: "production_plans.material_code", Type: utils.FieldString, Sort: true},
+	"partNumber":      {Column: "production_plans.part_number", Type: utils.FieldString, Sort: true},
+	"type":            {Column: "production_plans.type", Type: utils.FieldString, Sort: true},
+	"manufacturer":    {Column: "production_plans.manufacturer", Type: utils.FieldString, Sort: true},
+	"planDate":        {Column: "DATE(production_plans.plan_date)", Type: utils.FieldDate, Sort: true},
+	"productionLine":  {Column: "production_plans.production_line", Type: utils.FieldString, Sort: true},
+	"totalPlanned":    {Column: "production_plans.total_planned", Type: utils.FieldInt, Sort: true},
+	"totalInspected":  {Column: "production_plans.total_inspected", Type: utils.FieldInt, Sort: true},
+	"totalUnfinished": {Column: "production_plans.total_unfinished", Type: utils.FieldInt, Sort: true},
+	"achievementRate": {Column: "production_plans.achievement_rate", Type: utils.FieldFloat, Sort: true},
+}
+
+var productionPlanKeyset = utils.KeysetColumns{Field: "createdAt", CreatedAt: "production_plans.created_at", ID: "production_plans.id"}
+
 type ProductionPlanService struct {
 	db *gorm.DB
 }
@@ -20,11 +43,26 @@ func NewProductionPlanService(db *gorm.DB) (IProductionPlanService, error) {
 	return &ProductionPlanService{db: db}, nil
 }
 
+func (s *ProductionPlanService) WithContext(ctx context.Context) IProductionPlanService {
+	return &ProductionPlanService{db: s.db.WithContext(ctx)}
+}
+
+func (s *ProductionPlanService) startSpan(method string) (*ProductionPlanService, trace.Span) {
+	db, span := serviceSpan(s.db, "ProductionPlanService."+method)
+	return &ProductionPlanService{db: db}, span
+}
+
 func (s *ProductionPlanService) CreateProductionPlan(productionPlan *models.ProductionPlan) error {
+	s, span := s.startSpan("CreateProductionPlan")
+	defer span.End()
+
 	return s.db.Create(productionPlan).Error
 }
 
 func (s *ProductionPlanService) BatchCreateProductionPlans(plans []models.ProductionPlan) ([]models.ProductionPlan, error) {
+	s, span := s.startSpan("BatchCreateProductionPlans")
+	defer span.End()
+
 	err := s.db.Transaction(func(tx *gorm.DB) error {
 		return tx.Create(&plans).Error
 	})
@@ -35,14 +73,27 @@ func (s *ProductionPlanService) BatchCreateProductionPlans(plans []models.Produc
 }
 
 func (s *ProductionPlanService) GetProductionPlan(id int64) (*models.ProductionPlan, error) {
+	s, span := s.startSpan("GetProductionPlan")
+	defer span.End()
+
 	var productionPlan models.ProductionPlan
 	err := s.db.First(&productionPlan, id).Error
 	return &productionPlan, err
 }
 
 func (s *ProductionPlanService) GetProductionPlans(query map[string]interface{}, paginate map[string]interface{}, sqlHandler ...func(*gorm.DB) *gorm.DB) ([]models.ProductionPlan, models.PaginationResult, error) {
+	s, span := s.startSpan("GetProductionPlans")
+	defer span.End()
+
 	var productionPlans []models.ProductionPlan
-	var pagination models.PaginationResult
+	// 未指定排序时按主键升序，保持导入顺序
+	if sort, _ := paginate["sort"].(string); sort == "" {
+		paginate = utils.MergeMaps(paginate, map[string]interface{}{"sort": "id"})
+	}
+	page, err := utils.ParsePage(paginate, productionPlanFields, productionPlanKeyset)
+	if err != nil {
+		return []models.ProductionPlan{}, models.PaginationResult{}, err
+	}
 	var model = s.db.Model(&models.ProductionPlan{})
 
 	for _, handler := range sqlHandler {
@@ -50,85 +101,50 @@ func (s *ProductionPlanService) GetProductionPlans(query map[string]interface{},
 	}
 	model = model.Where(query)
 
-	model, pagination = utils.DoPagination(model, paginate)
-
-	// Order by ID to maintain import order
-	model = model.Order("id ASC")
+	model, pagination, err := page.Apply(model)
+	if err != nil {
+		return []models.ProductionPlan{}, pagination, err
+	}
 
 	result := model.Find(&productionPlans)
 	if result.Error != nil {
 		return []models.ProductionPlan{}, pagination, result.Error
 	}
 
+	productionPlans = productionPlans[:page.Next(&pagination, len(productionPlans), func(i int) (time.Time, int64) {
+		return productionPlans[i].CreatedAt, productionPlans[i].ID
+	})]
 	return productionPlans, pagination, nil
 }
 
 func (s *ProductionPlanService) UpdateProductionPlan(productionPlanInstance *models.ProductionPlan, productionPlan map[string]interface{}) error {
+	s, span := s.startSpan("UpdateProductionPlan")
+	defer span.End()
+
 	result := s.db.Model(productionPlanInstance).Updates(productionPlan)
 	return result.Error
 }
 
 func (s *ProductionPlanService) DeleteProductionPlans(ids []int64) error {
+	s, span := s.startSpan("DeleteProductionPlans")
+	defer span.End()
+
 	result := s.db.Delete(&models.ProductionPlan{}, ids)
 	return result.Error
 }
 
-/*
 func (s *ProductionPlanService) GetProductionPlansByDateRange(baseDate time.Time) (map[string][]models.ProductionPlan, error) {
-	// 计算T, T+1, T+2, T+3的日期
-	t := baseDate
-	tPlus1 := baseDate.AddDate(0, 0, 1)
-	tPlus2 := baseDate.AddDate(0, 0, 2)
-	tPlus3 := baseDate.AddDate(0, 0, 3)
-
-	// 查询指定日期范围内的生产计划
-	var productionPlans []models.ProductionPlan
-	err := s.db.Preload("ProductModel").
-		Where("(start_at <= ? AND end_at >= ?) OR (start_at <= ? AND end_at >= ?) OR (start_at <= ? AND end_at >= ?) OR (start_at <= ? AND end_at >= ?)",
-			t.Format("2006-01-02"), t.Format("2006-01-02"), tPlus1.Format("2006-01-02"), tPlus1.Format("2006-01-02"), tPlus2.Format("2006-01-02"), tPlus2.Format("2006-01-02"), tPlus3.Format("2006-01-02"), tPlus3.Format("2006-01-02")).Debug().
-		Find(&productionPlans).Error
+	s, span := s.startSpan("GetProductionPlansByDateRange")
+	defer span.End()
 
-	if err != nil {
-		return nil, err
-	}
-
-	// 按日期分组生产计划
-	result := map[string][]models.ProductionPlan{
-		"T":   {},
-		"T+1": {},
-		"T+2": {},
-		"T+3": {},
-	}
-
-	for _, plan := range productionPlans {
-		// 检查计划是否包含T日期
-		if plan.StartAt.Before(t.AddDate(0, 0, 1)) && plan.EndAt.After(t.AddDate(0, 0, -1)) {
-			result["T"] = append(result["T"], plan)
-		}
-		// 检查计划是否包含T+1日期
-		if plan.StartAt.Before(tPlus1.AddDate(0, 0, 1)) && plan.EndAt.After(tPlus1.AddDate(0, 0, -1)) {
-			result["T+1"] = append(result["T+1"], plan)
-		}
-		// 检查计划是否包含T+2日期
-		if plan.StartAt.Before(tPlus2.AddDate(0, 0, 1)) && plan.EndAt.After(tPlus2.AddDate(0, 0, -1)) {
-			result["T+2"] = append(result["T+2"], plan)
-		}
-		// 检查计划是否包含T+3日期
-		if plan.StartAt.Before(tPlus3.AddDate(0, 0, 1)) && plan.EndAt.After(tPlus3.AddDate(0, 0, -1)) {
-			result["T+3"] = append(result["T+3"], plan)
-		}
-	}
-
-	return result, nil
-}
-*/
-
-func (s *ProductionPlanService) GetProductionPlansByDateRange(baseDate time.Time) (map[string][]models.ProductionPlan, error) {
 	// Temporary stub
 	return nil, nil
 }
 
-func (s *ProductionPlanService) GetProductionPlansByDate(date time.Time) ([]models.ProductionPlan, error) {
+func (s *ProductionPlanService) GetProductionPlansByDate(date time.Time, bucketBy string) ([]models.ProductionPlan, error) {
+	s, span := s.startSpan("GetProductionPlansByDate")
+	defer span.End()
+
 	// 1. 查询指定日期的所有生产计划
 	var plans []models.ProductionPlan
 	dateStr := date.Format("2006-01-02")
@@ -148,18 +164,34 @@ func (s *ProductionPlanService) GetProductionPlansByDate(date time.Time) ([]mode
 		partNumbers = append(partNumbers, plan.PartNumber)
 	}
 
-	// 3. 使用 SUBSTRING_INDEX 提取 description 中第一个 "/" 之前的部分，然后分组统计
+	// 3. 提取 description 中第一个 "/" 之前的部分，然后分组统计
+	partNumberPrefix := databases.DialectOf(s.db).SubstringBefore("product_models.description", "/")
 	type CountResult struct {
 		PartNumberPrefix string
 		Count            int
 	}
 
+	countQuery := s.db.Table("products").
+		Select(partNumberPrefix + " as part_number_prefix, COUNT(*) as count").
+		Joins("INNER JOIN product_models ON products.product_model_id = product_models.id")
+
+	if bucketBy == models.BucketByProduction {
+		// 按生产日统计：夜班跨零点的产量计入开班当天
+		calendar, err := (&ShiftCalendarService{db: s.db}).LoadCalendar()
+		if err != nil {
+			return nil, err
+		}
+		start, end := calendar.ProductionDayRange(date, nil)
+		countQuery = countQuery.Where("products.created_at >= ? AND products.created_at < ?", utils.InDB(start), utils.InDB(end))
+	} else {
+		// 按 date 所在时区的自然日统计
+		start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+		countQuery = countQuery.Where("products.created_at >= ? AND products.created_at < ?", utils.InDB(start), utils.InDB(start.AddDate(0, 0, 1)))
+	}
+
 	var countResults []CountResult
-	err = s.db.Table("products").
-		Select("SUBSTRING_INDEX(product_models.description, '/', 1) as part_number_prefix, COUNT(*) as count").
-		Joins("INNER JOIN product_models ON products.product_model_id = product_models.id").
-		Where("DATE(products.created_at) = ?", dateStr).
-		Where("SUBSTRING_INDEX(product_models.description, '/', 1) IN ?", partNumbers).
+	err = countQuery.
+		Where(partNumberPrefix+" IN ?", partNumbers).
 		Group("part_number_prefix").
 		Scan(&countResults).Error
 
@@ -227,6 +259,9 @@ func min(a, b int) int {
 }
 
 func (s *ProductionPlanService) GetActiveProductionPlan(date time.Time, productModelID *uint, allowExceed bool) (*models.ProductionPlan, error) {
+	s, span := s.startSpan("GetActiveProductionPlan")
+	defer span.End()
+
 	// This method might need adjustment or removal based on new requirements,
 	// but keeping it for now as it might be used elsewhere.
 	// Since the model changed, this implementation is likely broken and needs to be updated if used.
@@ -234,9 +269,13 @@ func (s *ProductionPlanService) GetActiveProductionPlan(date time.Time, productM
 }
 
 func (s *ProductionPlanService) ImportProductionPlan(file multipart.File) ([]models.ProductionPlan, error) {
+	s, span := s.startSpan("ImportProductionPlan")
+	defer span.End()
+
+	log := logging.FromContext(s.db.Statement.Context)
 	f, err := excelize.OpenReader(file)
 	if err != nil {
-		fmt.Printf("Error opening file: %v\n", err)
+		log.Warn("open production plan file failed", "error", err)
 		return nil, err
 	}
 	defer f.Close()
@@ -244,12 +283,10 @@ func (s *ProductionPlanService) ImportProductionPlan(file multipart.File) ([]mod
 	// Assuming the first sheet is the one we want
 	rows, err := f.GetRows(f.GetSheetName(0))
 	if err != nil {
-		fmt.Printf("Error getting rows: %v\n", err)
+		log.Warn("read production plan sheet failed", "error", err)
 		return nil, err
 	}
 
-	fmt.Printf("Total rows read: %d\n", len(rows))
-
 	var plans []models.ProductionPlan
 	var targetDate time.Time
 	dateSet := false
@@ -356,7 +393,7 @@ func (s *ProductionPlanService) ImportProductionPlan(file multipart.File) ([]mod
 		plans = append(plans, plan)
 	}
 
-	fmt.Printf("Total plans to save: %d\n", len(plans))
+	log.Info("production plan file parsed", "rows", len(rows), "plans", len(plans))
 
 	if len(plans) == 0 {
 		return nil, fmt.Errorf("文件中没有找到有效的数据")
@@ -0,0 +1,454 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 重建预聚合时每批写入的行数
+const rollupBatchSize = 500
+
+// 预聚合的分组键，与 products 表中参与聚合的字段一一对应
+type rollupKey struct {
+	ProductLineID  uint
+	ProductModelID uint
+	BatchNumber    string
+	DefectReason   string
+}
+
+type QualityRollupService struct {
+	db *gorm.DB
+}
+
+func NewQualityRollupService(db *gorm.DB) (IQualityRollupService, error) {
+	return &QualityRollupService{db: db}, nil
+}
+
+func (s *QualityRollupService) WithContext(ctx context.Context) IQualityRollupService {
+	return &QualityRollupService{db: s.db.WithContext(ctx)}
+}
+
+func (s *QualityRollupService) startSpan(method string) (*QualityRollupService, trace.Span) {
+	db, span := serviceSpan(s.db, "QualityRollupService."+method)
+	return &QualityRollupService{db: db}, span
+}
+
+func rollupID(id *uint) uint {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
+
+// 产品所属的小时桶（数据库存储时区下的整点）
+func rollupHour(t time.Time) time.Time {
+	return t.In(utils.DBLocation()).Truncate(time.Hour)
+}
+
+// 时刻所属的自然日（工厂时区）；小时预聚合按小时起点归日
+func rollupDay(t time.Time) string {
+	return t.In(utils.PlantLocation()).Format("2006-01-02")
+}
+
+// 工厂时区自然日区间 [startDate, endDate) 对应的小时预聚合范围：
+// 工厂时区与存储时区的偏移差不是整小时时，日界落在小时中间，按小时起点归日取日界之后的第一个整点
+func rollupHours(startDate, endDate time.Time) (time.Time, time.Time) {
+	ceil := func(t time.Time) time.Time {
+		hour := rollupHour(t)
+		if hour.Before(t) {
+			hour = hour.Add(time.Hour)
+		}
+		return hour
+	}
+	return ceil(startDate), ceil(endDate)
+}
+
+// 将单个产品计入（delta 为 1）或移出（delta 为 -1）小时和日预聚合，应与产品的写入在同一事务中调用
+func (s *QualityRollupService) Apply(product *models.Product, delta int64) error {
+	s, span := s.startSpan("Apply")
+	defer span.End()
+
+	var defect int64
+	if product.HasDefect {
+		defect = delta
+	}
+	key := rollupKey{
+		ProductLineID:  rollupID(product.ProductLineID),
+		ProductModelID: rollupID(product.ProductModelID),
+		BatchNumber:    product.BatchNumber,
+		DefectReason:   product.DefectReason,
+	}
+	increments := clause.Assignments(map[string]interface{}{
+		"total_count":  gorm.Expr("total_count + ?", delta),
+		"defect_count": gorm.Expr("defect_count + ?", defect),
+	})
+	keyColumns := []clause.Column{{Name: "product_line_id"}, {Name: "product_model_id"}, {Name: "batch_number"}, {Name: "defect_reason"}}
+
+	hourly := models.QualityHourlyRollup{
+		HourStart:      rollupHour(product.CreatedAt),
+		ProductLineID:  key.ProductLineID,
+		ProductModelID: key.ProductModelID,
+		BatchNumber:    key.BatchNumber,
+		DefectReason:   key.DefectReason,
+		TotalCount:     delta,
+		DefectCount:    defect,
+	}
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   append([]clause.Column{{Name: "hour_start"}}, keyColumns...),
+		DoUpdates: increments,
+	}).Create(&hourly).Error; err != nil {
+		return fmt.Errorf("failed to update hourly rollup: %w", err)
+	}
+
+	daily := models.QualityDailyRollup{
+		Day:            rollupDay(hourly.HourStart),
+		ProductLineID:  key.ProductLineID,
+		ProductModelID: key.ProductModelID,
+		BatchNumber:    key.BatchNumber,
+		DefectReason:   key.DefectReason,
+		TotalCount:     delta,
+		DefectCount:    defect,
+	}
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   append([]clause.Column{{Name: "day"}}, keyColumns...),
+		DoUpdates: increments,
+	}).Create(&daily).Error; err != nil {
+		return fmt.Errorf("failed to update daily rollup: %w", err)
+	}
+
+	// 移出产品后清理计数归零的行，避免聚合结果中出现空分组
+	if delta < 0 {
+		if err := s.db.Where("hour_start = ? AND total_count <= 0", hourly.HourStart).
+			Delete(&models.QualityHourlyRollup{}).Error; err != nil {
+			return err
+		}
+		if err := s.db.Where("day = ? AND total_count <= 0", daily.Day).
+			Delete(&models.QualityDailyRollup{}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 从原始数据重新计算 [startDate, endDate) 内的预聚合，区间应为工厂时区的整日
+func (s *QualityRollupService) Rebuild(startDate, endDate time.Time) error {
+	s, span := s.startSpan("Rebuild")
+	defer span.End()
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return rebuildRollup(tx, startDate, endDate)
+	})
+}
+
+// 在事务 tx 中重建 [startDate, endDate) 内的预聚合，并清除区间内的不一致标记
+func rebuildRollup(tx *gorm.DB, startDate, endDate time.Time) error {
+	firstHour, endHour := rollupHours(startDate, endDate)
+
+	// 1. 小时预聚合：按小时和分组键统计原始数据
+	// 分组键逐列声明：gorm 不会扫描未导出类型（rollupKey）的嵌入字段
+	var rows []struct {
+		HourBucket     string
+		ProductLineID  uint
+		ProductModelID uint
+		BatchNumber    string
+		DefectReason   string
+		TotalCount     int64
+		DefectCount    int64
+	}
+	err := tx.Table("products p").
+		Select(hourBucketSQL(tx, "p.created_at")+` as hour_bucket,
+			COALESCE(p.product_line_id, 0) as product_line_id,
+			COALESCE(p.product_model_id, 0) as product_model_id,
+			COALESCE(p.batch_number, '') as batch_number,
+			COALESCE(p.defect_reason, '') as defect_reason,
+			COUNT(*) as total_count,
+			SUM(CASE WHEN p.has_defect = true THEN 1 ELSE 0 END) as defect_count`).
+		Where("p.deleted_at IS NULL").
+		Where("p.created_at >= ? AND p.created_at < ?", utils.InDB(firstHour), utils.InDB(endHour)).
+		Group("hour_bucket, product_line_id, product_model_id, batch_number, defect_reason").
+		Scan(&rows).Error
+	if err != nil {
+		return fmt.Errorf("failed to aggregate products: %w", err)
+	}
+
+	hourly := make([]models.QualityHourlyRollup, 0, len(rows))
+	dailyIndex := make(map[string]int)
+	var daily []models.QualityDailyRollup
+	for _, row := range rows {
+		hourStart, err := parseHourBucket(row.HourBucket)
+		if err != nil {
+			return err
+		}
+		hourly = append(hourly, models.QualityHourlyRollup{
+			HourStart:      hourStart,
+			ProductLineID:  row.ProductLineID,
+			ProductModelID: row.ProductModelID,
+			BatchNumber:    row.BatchNumber,
+			DefectReason:   row.DefectReason,
+			TotalCount:     row.TotalCount,
+			DefectCount:    row.DefectCount,
+		})
+
+		// 2. 日预聚合：将小时预聚合按工厂时区的自然日归并
+		day := rollupDay(hourStart)
+		key := fmt.Sprintf("%s|%d|%d|%s|%s", day, row.ProductLineID, row.ProductModelID, row.BatchNumber, row.DefectReason)
+		index, exists := dailyIndex[key]
+		if !exists {
+			index = len(daily)
+			dailyIndex[key] = index
+			daily = append(daily, models.QualityDailyRollup{
+				Day:            day,
+				ProductLineID:  row.ProductLineID,
+				ProductModelID: row.ProductModelID,
+				BatchNumber:    row.BatchNumber,
+				DefectReason:   row.DefectReason,
+			})
+		}
+		daily[index].TotalCount += row.TotalCount
+		daily[index].DefectCount += row.DefectCount
+	}
+
+	// 3. 替换区间内已有的预聚合
+	if err := tx.Where("hour_start >= ? AND hour_start < ?", utils.InDB(firstHour), utils.InDB(endHour)).
+		Delete(&models.QualityHourlyRollup{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("day >= ? AND day < ?", rollupDay(startDate), rollupDay(endDate)).
+		Delete(&models.QualityDailyRollup{}).Error; err != nil {
+		return err
+	}
+	if len(hourly) > 0 {
+		if err := tx.CreateInBatches(hourly, rollupBatchSize).Error; err != nil {
+			return fmt.Errorf("failed to write hourly rollup: %w", err)
+		}
+	}
+	if len(daily) > 0 {
+		if err := tx.CreateInBatches(daily, rollupBatchSize).Error; err != nil {
+			return fmt.Errorf("failed to write daily rollup: %w", err)
+		}
+	}
+	return clearStaleRanges(tx, firstHour, endHour)
+}
+
+// 后台重建预聚合，参数与重建接口相同
+func runRollupRebuild(ctx context.Context, run *JobRun) error {
+	var payload models.RollupRangeQuery
+	if err := run.Decode(&payload); err != nil {
+		return err
+	}
+	startDate, endDate, err := utils.DateRange(payload.StartDate, payload.EndDate, utils.PlantLocation())
+	if err != nil {
+		return PermanentJobError(err)
+	}
+	s := &QualityRollupService{db: run.DB()}
+	return s.Rebuild(startDate, endDate)
+}
+
+// 预聚合表为空而原始数据不为空时（首次部署），或工厂时区与生成预聚合时不同（日预聚合按工厂时区分日）时，
+// 按原始数据的完整时间范围重建，并记录当前的工厂时区；升级前生成、未记录时区的预聚合视为按当前工厂时区生成
+func (s *QualityRollupService) Backfill() error {
+	s, span := s.startSpan("Backfill")
+	defer span.End()
+
+	plant := utils.PlantLocation().String()
+	var state models.QualityRollupState
+	if err := s.db.Take(&state).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	var rollupCount int64
+	if err := s.db.Model(&models.QualityHourlyRollup{}).Count(&rollupCount).Error; err != nil {
+		return err
+	}
+	if state.ID != 0 && state.PlantTimezone == plant && rollupCount > 0 {
+		return nil
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if rollupCount == 0 || (state.ID != 0 && state.PlantTimezone != plant) {
+			if state.ID != 0 && state.PlantTimezone != plant {
+				slog.Info("plant timezone changed, rebuilding quality rollup", "from", state.PlantTimezone, "to", plant)
+			}
+			if err := rebuildAllRollup(tx); err != nil {
+				return err
+			}
+		}
+		state.PlantTimezone = plant
+		return tx.Save(&state).Error
+	})
+}
+
+// 清空预聚合后按原始数据的完整时间范围（工厂时区的整日）重建
+func rebuildAllRollup(tx *gorm.DB) error {
+	for _, model := range []interface{}{&models.QualityHourlyRollup{}, &models.QualityDailyRollup{}, &models.QualityRollupStaleRange{}} {
+		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+
+	// 按排序取首尾产品而非 MIN/MAX，SQLite 中聚合函数返回的时间为文本无法直接扫描
+	var firstProduct, lastProduct models.Product
+	err := tx.Select("created_at").Order("created_at ASC").Take(&firstProduct).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := tx.Select("created_at").Order("created_at DESC").Take(&lastProduct).Error; err != nil {
+		return err
+	}
+
+	// 小时预聚合按小时起点归日，首尾按产品所在小时取日期
+	location := utils.PlantLocation()
+	first := rollupHour(firstProduct.CreatedAt).In(location)
+	last := rollupHour(lastProduct.CreatedAt).In(location)
+	start := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, location)
+	end := time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, location).AddDate(0, 0, 1)
+	return rebuildRollup(tx, start, end)
+}
+
+// 校验 [startDate, endDate) 内预聚合与原始数据是否一致：逐小时比较原始数据与小时预聚合，逐日比较小时预聚合与日预聚合；
+// 不一致时记录该区间，此后与之重叠的报表和统计读取原始数据，直到重建或再次校验一致
+func (s *QualityRollupService) Check(startDate, endDate time.Time) (*models.RollupCheckResult, error) {
+	s, span := s.startSpan("Check")
+	defer span.End()
+
+	firstHour, endHour := rollupHours(startDate, endDate)
+
+	type hourTotals struct {
+		HourBucket  string
+		TotalCount  int64
+		DefectCount int64
+	}
+
+	var raw []hourTotals
+	if err := s.db.Table("products p").
+		Select(hourBucketSQL(s.db, "p.created_at")+" as hour_bucket, COUNT(*) as total_count, SUM(CASE WHEN p.has_defect = true THEN 1 ELSE 0 END) as defect_count").
+		Where("p.deleted_at IS NULL").
+		Where("p.created_at >= ? AND p.created_at < ?", utils.InDB(firstHour), utils.InDB(endHour)).
+		Group("hour_bucket").
+		Scan(&raw).Error; err != nil {
+		return nil, err
+	}
+
+	var rollup []hourTotals
+	if err := s.db.Table("quality_hourly_rollups p").
+		Select(hourBucketSQL(s.db, "p.hour_start")+" as hour_bucket, SUM(p.total_count) as total_count, SUM(p.defect_count) as defect_count").
+		Where("p.hour_start >= ? AND p.hour_start < ?", utils.InDB(firstHour), utils.InDB(endHour)).
+		Group("hour_bucket").
+		Scan(&rollup).Error; err != nil {
+		return nil, err
+	}
+
+	var daily []struct {
+		Day        string
+		TotalCount int64
+	}
+	if err := s.db.Model(&models.QualityDailyRollup{}).
+		Select("day, SUM(total_count) as total_count").
+		Where("day >= ? AND day < ?", rollupDay(startDate), rollupDay(endDate)).
+		Group("day").
+		Scan(&daily).Error; err != nil {
+		return nil, err
+	}
+
+	// 按小时合并两侧的统计
+	hours := make(map[string]*models.RollupDiscrepancy)
+	var order []string
+	entry := func(hourBucket string) (*models.RollupDiscrepancy, error) {
+		if d, exists := hours[hourBucket]; exists {
+			return d, nil
+		}
+		hourStart, err := parseHourBucket(hourBucket)
+		if err != nil {
+			return nil, err
+		}
+		hours[hourBucket] = &models.RollupDiscrepancy{HourStart: hourStart}
+		order = append(order, hourBucket)
+		return hours[hourBucket], nil
+	}
+	for _, row := range raw {
+		d, err := entry(row.HourBucket)
+		if err != nil {
+			return nil, err
+		}
+		d.RawTotalCount, d.RawDefectCount = row.TotalCount, row.DefectCount
+	}
+	hourlyByDay := make(map[string]int64)
+	for _, row := range rollup {
+		d, err := entry(row.HourBucket)
+		if err != nil {
+			return nil, err
+		}
+		d.RollupTotalCount, d.RollupDefectCount = row.TotalCount, row.DefectCount
+		hourlyByDay[rollupDay(d.HourStart)] += row.TotalCount
+	}
+
+	result := &models.RollupCheckResult{
+		StartDate:     rollupDay(startDate),
+		EndDate:       rollupDay(endDate.Add(-time.Nanosecond)),
+		Discrepancies: []models.RollupDiscrepancy{},
+		DailyMismatch: []string{},
+	}
+	sort.Strings(order)
+	for _, hourBucket := range order {
+		d := hours[hourBucket]
+		if d.RawTotalCount != d.RollupTotalCount || d.RawDefectCount != d.RollupDefectCount {
+			result.Discrepancies = append(result.Discrepancies, *d)
+		}
+	}
+
+	dailyByDay := make(map[string]int64)
+	for _, row := range daily {
+		dailyByDay[row.Day] = row.TotalCount
+	}
+	for _, day := range unionKeys(dailyByDay, hourlyByDay) {
+		if dailyByDay[day] != hourlyByDay[day] {
+			result.DailyMismatch = append(result.DailyMismatch, day)
+		}
+	}
+
+	result.Consistent = len(result.Discrepancies) == 0 && len(result.DailyMismatch) == 0
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := clearStaleRanges(tx, firstHour, endHour); err != nil || result.Consistent {
+			return err
+		}
+		return tx.Create(&models.QualityRollupStaleRange{StartAt: utils.InDB(firstHour), EndAt: utils.InDB(endHour)}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record rollup check: %w", err)
+	}
+	return result, nil
+}
+
+// 删除完全位于 [start, end) 内的不一致标记
+func clearStaleRanges(tx *gorm.DB, start, end time.Time) error {
+	return tx.Where("start_at >= ? AND end_at <= ?", utils.InDB(start), utils.InDB(end)).
+		Delete(&models.QualityRollupStaleRange{}).Error
+}
+
+// 时刻区间 [start, end) 是否与校验不一致的区间重叠，nil 表示不限
+func rollupStale(db *gorm.DB, start, end *time.Time) (bool, error) {
+	query := db.Model(&models.QualityRollupStaleRange{})
+	if start != nil {
+		query = query.Where("end_at > ?", utils.InDB(*start))
+	}
+	if end != nil {
+		query = query.Where("start_at < ?", utils.InDB(*end))
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
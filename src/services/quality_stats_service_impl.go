@@ -1,23 +1,67 @@
 package services
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 )
 
 // 优化后的统计数据聚合结构
 type statsAggregation struct {
 	Date         string
-	SupplierID   int64
+	SupplierID   uint
 	SupplierName string
 	DefectReason string
 	TotalCount   int64
 	DefectCount  int64
 }
 
+// 聚合查询的扫描结果，未选中的维度列保持为空
+type aggregateRow struct {
+	HourBucket    string
+	Day           string // 读取日预聚合时为工厂时区的日期
+	ProductLineID *uint
+	Supplier      string
+	SupplierID    uint
+	SupplierType  string
+	ProductLine   string
+	ProductModel  string
+	BatchNumber   string
+	DefectReason  string
+	TotalCount    int64
+	DefectCount   int64
+}
+
+// 维度白名单：维度名 -> SQL 表达式及取值方法
+type aggregateDimension struct {
+	expr  string
+	value func(row *aggregateRow) string
+}
+
+var aggregateDimensions = map[string]aggregateDimension{
+	models.DimensionSupplier: {"COALESCE(s.name, '')", func(r *aggregateRow) string { return r.Supplier }},
+	models.DimensionSupplierID: {"COALESCE(s.id, 0)", func(r *aggregateRow) string {
+		if r.SupplierID == 0 {
+			return ""
+		}
+		return strconv.FormatUint(uint64(r.SupplierID), 10)
+	}},
+	models.DimensionSupplierType: {"COALESCE(s.type, '')", func(r *aggregateRow) string { return r.SupplierType }},
+	models.DimensionProductLine:  {"COALESCE(pl.name, '')", func(r *aggregateRow) string { return r.ProductLine }},
+	models.DimensionProductModel: {"COALESCE(pm.sn, '')", func(r *aggregateRow) string { return r.ProductModel }},
+	models.DimensionBatchNumber:  {"COALESCE(p.batch_number, '')", func(r *aggregateRow) string { return r.BatchNumber }},
+	models.DimensionDefectReason: {"COALESCE(p.defect_reason, '')", func(r *aggregateRow) string { return r.DefectReason }},
+}
+
 type QualityStatsService struct {
 	db *gorm.DB
 }
@@ -26,47 +70,505 @@ func NewQualityStatsService(db *gorm.DB) (IQualityStatsService, error) {
 	return &QualityStatsService{db: db}, nil
 }
 
-func (s *QualityStatsService) GetQualityStats(startDate, endDate time.Time) (*models.QualityStatsResponse, error) {
+func (s *QualityStatsService) WithContext(ctx context.Context) IQualityStatsService {
+	return &QualityStatsService{db: s.db.WithContext(ctx)}
+}
+
+func (s *QualityStatsService) startSpan(method string) (*QualityStatsService, trace.Span) {
+	db, span := serviceSpan(s.db, "QualityStatsService."+method)
+	return &QualityStatsService{db: db}, span
+}
+
+func (s *QualityStatsService) GetQualityStats(startDate, endDate time.Time, options models.QualityStatsOptions) (*models.QualityStatsResponse, error) {
+	s, span := s.startSpan("GetQualityStats")
+	defer span.End()
+
 	// 使用优化的单次查询获取所有统计数据
-	return s.getAllStatsOptimized(startDate, endDate)
-}
-
-// 优化版本：使用一次SQL查询获取所有需要的数据
-func (s *QualityStatsService) getAllStatsOptimized(startDate, endDate time.Time) (*models.QualityStatsResponse, error) {
-	// 1. 使用单次聚合查询获取所有基础数据
-	query := `
-		SELECT 
-			DATE(p.created_at) as date,
-			s.id as supplier_id,
-			s.name as supplier_name,
-			COALESCE(p.defect_reason, '') as defect_reason,
-			COUNT(*) as total_count,
-			SUM(CASE WHEN p.has_defect = true THEN 1 ELSE 0 END) as defect_count
-		FROM products p
-		INNER JOIN product_models pm ON p.product_model_id = pm.id
-		INNER JOIN suppliers s ON pm.supplier_id = s.id
-		WHERE p.created_at BETWEEN ? AND ?
-		GROUP BY DATE(p.created_at), s.id, s.name, p.defect_reason
-		ORDER BY date, supplier_name
-	`
+	return s.getAllStatsOptimized(startDate, endDate, options)
+}
 
-	var aggregations []statsAggregation
-	if err := s.db.Raw(query, startDate, endDate).Scan(&aggregations).Error; err != nil {
+// 通用维度/指标聚合：SQL 按小时和维度分组，再在内存中归并到目标粒度
+// 查询区间为 [startDate, endDate)，自然日分桶使用 startDate 所在的时区
+func (s *QualityStatsService) Aggregate(startDate, endDate time.Time, spec models.AggregateSpec, sqlHandler ...func(*gorm.DB) *gorm.DB) (*models.QualityAggregateResponse, error) {
+	s, span := s.startSpan("Aggregate")
+	defer span.End()
+
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	calendar, err := (&ShiftCalendarService{db: s.db}).LoadCalendar()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load shift calendar: %w", err)
+	}
+	bucketer := &timeBucketer{granularity: spec.Granularity, bucketBy: spec.BucketBy, calendar: calendar, location: startDate.Location()}
+
+	// 生产日模式下前后各放宽一天查询，再按生产日裁剪
+	queryStart, queryEnd := startDate, endDate
+	if spec.BucketBy == models.BucketByProduction {
+		queryStart, queryEnd = startDate.AddDate(0, 0, -1), endDate.AddDate(0, 0, 1)
+	}
+
+	rows, err := s.queryAggregateRows(queryStart, queryEnd, spec, bucketer, sqlHandler...)
+	if err != nil {
+		return nil, err
+	}
+
+	// 按 (时间桶, 维度值) 归并
+	type groupValue struct {
+		row    models.QualityAggregateRow
+		total  int64
+		defect int64
+	}
+	groups := make(map[string]*groupValue)
+	var keys []string
+	for i := range rows {
+		if spec.BucketBy == models.BucketByProduction {
+			inRange, err := bucketer.inDayRange(rows[i].HourBucket, rows[i].ProductLineID, startDate.Format("2006-01-02"), endDate.Add(-time.Nanosecond).Format("2006-01-02"))
+			if err != nil {
+				return nil, err
+			}
+			if !inRange {
+				continue
+			}
+		}
+
+		var bucket string
+		if rows[i].Day != "" {
+			bucket, err = bucketer.labelDay(rows[i].Day)
+		} else {
+			bucket, err = bucketer.label(rows[i].HourBucket, rows[i].ProductLineID)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		dimensionValues := make(map[string]string, len(spec.Dimensions))
+		key := bucket
+		for _, dimension := range spec.Dimensions {
+			value := aggregateDimensions[dimension].value(&rows[i])
+			dimensionValues[dimension] = value
+			key += "\x00" + value
+		}
+
+		group, exists := groups[key]
+		if !exists {
+			group = &groupValue{row: models.QualityAggregateRow{Bucket: bucket, Dimensions: dimensionValues}}
+			groups[key] = group
+			keys = append(keys, key)
+		}
+		group.total += rows[i].TotalCount
+		group.defect += rows[i].DefectCount
+	}
+
+	sort.Strings(keys)
+	result := make([]models.QualityAggregateRow, 0, len(keys))
+	for _, key := range keys {
+		group := groups[key]
+		group.row.Measures = buildMeasures(spec.Measures, group.total, group.defect)
+		result = append(result, group.row)
+	}
+
+	return &models.QualityAggregateResponse{
+		Granularity: spec.Granularity,
+		BucketBy:    spec.BucketBy,
+		Dimensions:  spec.Dimensions,
+		Measures:    spec.Measures,
+		Rows:        result,
+	}, nil
+}
+
+func (s *QualityStatsService) queryAggregateRows(startDate, endDate time.Time, spec models.AggregateSpec, bucketer *timeBucketer, sqlHandler ...func(*gorm.DB) *gorm.DB) ([]aggregateRow, error) {
+	source, err := s.selectAggregateSource(startDate, endDate, spec, bucketer)
+	if err != nil {
+		return nil, err
+	}
+
+	var selects, groupBy []string
+	if source.daily {
+		if spec.Granularity != models.GranularityNone {
+			selects = append(selects, "p.day")
+			groupBy = append(groupBy, "p.day")
+		}
+	} else if spec.Granularity != models.GranularityNone || spec.BucketBy == models.BucketByProduction {
+		selects = append(selects, bucketSQL(s.db, source.timeColumn, source.bucketMinutes)+" as hour_bucket")
+		groupBy = append(groupBy, "hour_bucket")
+	}
+	if bucketer.needsProductLine() {
+		// 班次日历按产线区分，需要保留产线ID
+		selects = append(selects, "p.product_line_id")
+		groupBy = append(groupBy, "p.product_line_id")
+	}
+	for _, dimension := range spec.Dimensions {
+		selects = append(selects, fmt.Sprintf("%s as %s", aggregateDimensions[dimension].expr, dimension))
+		groupBy = append(groupBy, dimension)
+	}
+	selects = append(selects,
+		source.totalExpr+" as total_count",
+		source.defectExpr+" as defect_count",
+	)
+
+	query := s.db.Table(source.table).
+		Select(strings.Join(selects, ", ")).
+		Joins("LEFT JOIN product_models pm ON p.product_model_id = pm.id").
+		Joins("LEFT JOIN suppliers s ON pm.supplier_id = s.id").
+		Joins("LEFT JOIN product_lines pl ON p.product_line_id = pl.id")
+	for _, condition := range source.conditions {
+		query = query.Where(condition)
+	}
+	if source.daily {
+		query = query.Where("p.day >= ? AND p.day < ?", rollupDay(startDate), rollupDay(endDate))
+	} else {
+		query = query.Where(source.timeColumn+" >= ? AND "+source.timeColumn+" < ?", utils.InDB(startDate), utils.InDB(endDate))
+	}
+	for _, handler := range sqlHandler {
+		query = handler(query)
+	}
+	if len(groupBy) > 0 {
+		query = query.Group(strings.Join(groupBy, ", "))
+	}
+
+	var rows []aggregateRow
+	if err := query.Scan(&rows).Error; err != nil {
 		return nil, fmt.Errorf("failed to fetch aggregated stats: %w", err)
 	}
+	return rows, nil
+}
+
+// 聚合数据源，原始表和预聚合表均以 p 为别名，以便共用维度表达式和报表的筛选条件
+type aggregateSource struct {
+	table         string
+	timeColumn    string
+	bucketMinutes int  // 时间列的分桶分钟数，预聚合固定为 60
+	daily         bool // 日预聚合按 day 列筛选，没有小时信息
+	totalExpr     string
+	defectExpr    string
+	rowTotal      string // 单行的数量和不良数，供报表先取行再按日期分组
+	rowDefect     string
+	conditions    []string
+}
+
+var (
+	rawAggregateSource = aggregateSource{
+		table:         "products p",
+		timeColumn:    "p.created_at",
+		bucketMinutes: 60,
+		totalExpr:     "COUNT(*)",
+		defectExpr:    "SUM(CASE WHEN p.has_defect = true THEN 1 ELSE 0 END)",
+		rowTotal:      "1",
+		rowDefect:     "CASE WHEN p.has_defect = true THEN 1 ELSE 0 END",
+		conditions:    []string{"p.deleted_at IS NULL"},
+	}
+	hourlyRollupSource = aggregateSource{
+		table:         "quality_hourly_rollups p",
+		timeColumn:    "p.hour_start",
+		bucketMinutes: 60,
+		totalExpr:     "SUM(p.total_count)",
+		defectExpr:    "SUM(p.defect_count)",
+		rowTotal:      "p.total_count",
+		rowDefect:     "p.defect_count",
+	}
+	dailyRollupSource = aggregateSource{
+		table:      "quality_daily_rollups p",
+		daily:      true,
+		totalExpr:  "SUM(p.total_count)",
+		defectExpr: "SUM(p.defect_count)",
+	}
+)
 
-	// 2. 从聚合数据中构建所有响应部分
+// 小时级数据源：[start, end) 内各时区与存储时区的偏移差不是整小时时，按可用的分钟粒度读取原始数据；
+// 区间与校验不一致的预聚合重叠时按小时读取原始数据；其余情况读取小时预聚合。start、end 为 nil 表示不限
+func hourlyAggregateSource(db *gorm.DB, start, end *time.Time, locations ...*time.Location) (aggregateSource, error) {
+	if minutes := bucketMinutes(start, end, locations...); minutes < 60 {
+		source := rawAggregateSource
+		source.bucketMinutes = minutes
+		return source, nil
+	}
+	stale, err := rollupStale(db, start, end)
+	if err != nil {
+		return aggregateSource{}, fmt.Errorf("failed to check rollup state: %w", err)
+	}
+	if stale {
+		return rawAggregateSource, nil
+	}
+	return hourlyRollupSource, nil
+}
+
+// 选择聚合数据源：按工厂时区自然日、粒度不细于天且区间为整日时读取日预聚合，其余情况见 hourlyAggregateSource；
+// 指定 source=raw 时始终读取原始数据
+func (s *QualityStatsService) selectAggregateSource(startDate, endDate time.Time, spec models.AggregateSpec, bucketer *timeBucketer) (aggregateSource, error) {
+	locations := []*time.Location{bucketer.location}
+	if bucketer.needsProductLine() {
+		locations = append(locations, bucketer.calendar.Location)
+	}
+	if spec.Source == models.AggregateSourceRaw {
+		source := rawAggregateSource
+		source.bucketMinutes = bucketMinutes(&startDate, &endDate, locations...)
+		return source, nil
+	}
+	source, err := hourlyAggregateSource(s.db, &startDate, &endDate, locations...)
+	if err != nil || source.table != hourlyRollupSource.table {
+		return source, err
+	}
+
+	plant := utils.PlantLocation()
+	daily := spec.BucketBy != models.BucketByProduction &&
+		spec.Granularity != models.GranularityHour && spec.Granularity != models.GranularityShift &&
+		bucketer.location.String() == plant.String() &&
+		isMidnight(startDate.In(plant)) && isMidnight(endDate.In(plant))
+	if daily {
+		return dailyRollupSource, nil
+	}
+	return hourlyRollupSource, nil
+}
+
+func isMidnight(t time.Time) bool {
+	return t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0
+}
+
+func buildMeasures(measures []string, total, defect int64) map[string]float64 {
+	result := make(map[string]float64, len(measures))
+	for _, measure := range measures {
+		switch measure {
+		case models.MeasureTotalCount:
+			result[measure] = float64(total)
+		case models.MeasureDefectCount:
+			result[measure] = float64(defect)
+		case models.MeasureQualifiedCount:
+			result[measure] = float64(total - defect)
+		case models.MeasureDefectRate:
+			result[measure] = percentage(defect, total)
+		case models.MeasureQualityRate:
+			result[measure] = percentage(total-defect, total)
+		}
+	}
+	return result
+}
+
+func percentage(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
+
+// 兼容预设：按天、供应商、不良原因聚合后构建原有的响应结构
+func (s *QualityStatsService) getAllStatsOptimized(startDate, endDate time.Time, options models.QualityStatsOptions) (*models.QualityStatsResponse, error) {
+	// 1. 使用通用聚合获取所有基础数据
+	aggregations, err := s.loadStatsAggregations(startDate, endDate, models.GranularityDay, options.BucketBy)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. 从聚合数据中构建所有响应部分，趋势序列按 [startDate, endDate) 内的每一天补齐
+	days := dayLabels(startDate, endDate)
 	qualityRate := s.buildQualityRate(aggregations)
 	defectTypeDistribution := s.buildDefectTypeDistribution(aggregations)
-	supplierDefectTrend := s.buildSupplierDefectTrend(aggregations)
-	defectTrendByType := s.buildDefectTrendByType(aggregations)
+	supplierDefectTrend := s.buildSupplierDefectTrend(aggregations, days, options.Fill)
+	defectTrendByType := s.buildDefectTrendByType(aggregations, days, options.Fill)
 
-	return &models.QualityStatsResponse{
+	response := &models.QualityStatsResponse{
 		QualityRate:            qualityRate,
 		DefectTypeDistribution: defectTypeDistribution,
 		SupplierDefectTrend:    supplierDefectTrend,
 		DefectTrendByType:      defectTrendByType,
-	}, nil
+	}
+
+	// 3. 与基准周期对比
+	if options.Comparison.Compare != models.CompareNone {
+		response.Comparison, err = s.compare(startDate, endDate, options.BucketBy, options.Comparison, aggregations)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+// 按供应商ID、不良原因（及时间粒度）聚合，同名的不同供应商分别统计；未关联供应商的产品不计入统计
+func (s *QualityStatsService) loadStatsAggregations(startDate, endDate time.Time, granularity, bucketBy string, sqlHandler ...func(*gorm.DB) *gorm.DB) ([]statsAggregation, error) {
+	result, err := s.Aggregate(startDate, endDate, models.AggregateSpec{
+		Granularity: granularity,
+		BucketBy:    bucketBy,
+		Dimensions:  []string{models.DimensionSupplierID, models.DimensionSupplier, models.DimensionDefectReason},
+		Measures:    []string{models.MeasureTotalCount, models.MeasureDefectCount},
+	}, sqlHandler...)
+	if err != nil {
+		return nil, err
+	}
+
+	var aggregations []statsAggregation
+	for _, row := range result.Rows {
+		// 与原查询保持一致：未关联供应商的产品不计入统计
+		if row.Dimensions[models.DimensionSupplierID] == "" {
+			continue
+		}
+		supplierID, err := strconv.ParseUint(row.Dimensions[models.DimensionSupplierID], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		aggregations = append(aggregations, statsAggregation{
+			Date:         row.Bucket,
+			SupplierID:   uint(supplierID),
+			SupplierName: row.Dimensions[models.DimensionSupplier],
+			DefectReason: row.Dimensions[models.DimensionDefectReason],
+			TotalCount:   int64(row.Measures[models.MeasureTotalCount]),
+			DefectCount:  int64(row.Measures[models.MeasureDefectCount]),
+		})
+	}
+	return aggregations, nil
+}
+
+// 当前周期与基准周期对比：合格率、不良类型占比、供应商不良率
+// current 为当前周期已聚合的数据，为空时按 sqlHandler 重新查询
+func (s *QualityStatsService) compare(startDate, endDate time.Time, bucketBy string, comparison models.ComparisonQuery, current []statsAggregation, sqlHandler ...func(*gorm.DB) *gorm.DB) (*models.QualityComparison, error) {
+	if err := comparison.Validate(); err != nil {
+		return nil, err
+	}
+	baselineStart, baselineEnd, err := comparison.BaselineRange(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	if current == nil {
+		if current, err = s.loadStatsAggregations(startDate, endDate, models.GranularityNone, bucketBy, sqlHandler...); err != nil {
+			return nil, err
+		}
+	}
+	baseline, err := s.loadStatsAggregations(baselineStart, baselineEnd, models.GranularityNone, bucketBy, sqlHandler...)
+	if err != nil {
+		return nil, err
+	}
+
+	currentTotals, baselineTotals := summarizeAggregations(current), summarizeAggregations(baseline)
+
+	result := &models.QualityComparison{
+		Mode:              comparison.Compare,
+		BaselineStartDate: baselineStart.Format("2006-01-02"),
+		BaselineEndDate:   baselineEnd.Add(-time.Nanosecond).Format("2006-01-02"),
+		QualityRate: compareProportions(
+			currentTotals.total-currentTotals.defect, currentTotals.total,
+			baselineTotals.total-baselineTotals.defect, baselineTotals.total,
+		),
+	}
+
+	// 不良类型占比：某类型不良数 / 全部不良数
+	for _, defectType := range unionKeys(currentTotals.byReason, baselineTotals.byReason) {
+		result.DefectTypeShares = append(result.DefectTypeShares, models.DefectShareComparison{
+			Type: defectType,
+			MetricComparison: compareProportions(
+				currentTotals.byReason[defectType], currentTotals.defect,
+				baselineTotals.byReason[defectType], baselineTotals.defect,
+			),
+		})
+	}
+	sort.SliceStable(result.DefectTypeShares, func(i, j int) bool {
+		return result.DefectTypeShares[i].Current > result.DefectTypeShares[j].Current
+	})
+
+	// 供应商不良率：供应商不良数 / 供应商产品总数，按供应商ID区分同名供应商
+	supplierNames := make(map[uint]string)
+	for _, totals := range []aggregationTotals{baselineTotals, currentTotals} {
+		for supplierID, name := range totals.supplierName {
+			supplierNames[supplierID] = name
+		}
+	}
+	for _, supplierID := range sortedSuppliers(supplierNames) {
+		result.SupplierDefectRates = append(result.SupplierDefectRates, models.SupplierRateComparison{
+			SupplierID:   supplierID,
+			SupplierName: supplierNames[supplierID],
+			MetricComparison: compareProportions(
+				currentTotals.supplierDefect[supplierID], currentTotals.supplierTotal[supplierID],
+				baselineTotals.supplierDefect[supplierID], baselineTotals.supplierTotal[supplierID],
+			),
+		})
+	}
+
+	return result, nil
+}
+
+type aggregationTotals struct {
+	total          int64
+	defect         int64
+	byReason       map[string]int64
+	supplierTotal  map[uint]int64
+	supplierDefect map[uint]int64
+	supplierName   map[uint]string
+}
+
+func summarizeAggregations(aggregations []statsAggregation) aggregationTotals {
+	totals := aggregationTotals{
+		byReason:       make(map[string]int64),
+		supplierTotal:  make(map[uint]int64),
+		supplierDefect: make(map[uint]int64),
+		supplierName:   make(map[uint]string),
+	}
+	for _, agg := range aggregations {
+		totals.total += agg.TotalCount
+		totals.defect += agg.DefectCount
+		totals.supplierTotal[agg.SupplierID] += agg.TotalCount
+		totals.supplierDefect[agg.SupplierID] += agg.DefectCount
+		totals.supplierName[agg.SupplierID] = agg.SupplierName
+		if agg.DefectReason != "" && agg.DefectCount > 0 {
+			totals.byReason[agg.DefectReason] += agg.DefectCount
+		}
+	}
+	return totals
+}
+
+// 两个 map 的键的并集，按字典序排序
+func unionKeys(a, b map[string]int64) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, m := range []map[string]int64{a, b} {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// 供应商ID按名称排序，同名时按ID排序
+func sortedSuppliers(names map[uint]string) []uint {
+	ids := make([]uint, 0, len(names))
+	for id := range names {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if names[ids[i]] != names[ids[j]] {
+			return names[ids[i]] < names[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+// 显著性阈值，对应双侧 95% 置信水平
+const significanceZ = 1.96
+
+// 对比两个比例 x1/n1 与 x2/n2，使用两比例 z 检验判断差异是否显著
+func compareProportions(x1, n1, x2, n2 int64) models.MetricComparison {
+	comparison := models.MetricComparison{
+		Current:  percentage(x1, n1),
+		Baseline: percentage(x2, n2),
+	}
+	comparison.Delta = comparison.Current - comparison.Baseline
+	if comparison.Baseline != 0 {
+		comparison.PctChange = float64Ptr(comparison.Delta / comparison.Baseline * 100)
+	}
+
+	if n1 > 0 && n2 > 0 {
+		pooled := float64(x1+x2) / float64(n1+n2)
+		se := math.Sqrt(pooled * (1 - pooled) * (1/float64(n1) + 1/float64(n2)))
+		if se > 0 {
+			comparison.ZScore = (float64(x1)/float64(n1) - float64(x2)/float64(n2)) / se
+			comparison.Significant = math.Abs(comparison.ZScore) >= significanceZ
+		}
+	}
+	return comparison
 }
 
 // 从聚合数据构建合格率统计
@@ -114,66 +616,73 @@ func (s *QualityStatsService) buildDefectTypeDistribution(aggregations []statsAg
 		})
 	}
 
+	// 按数量倒序，数量相同时按类型名排序
+	sort.Slice(defectTypes, func(i, j int) bool {
+		if defectTypes[i].Count != defectTypes[j].Count {
+			return defectTypes[i].Count > defectTypes[j].Count
+		}
+		return defectTypes[i].Type < defectTypes[j].Type
+	})
+
 	return defectTypes
 }
 
-// 从聚合数据构建供应商不良趋势
-func (s *QualityStatsService) buildSupplierDefectTrend(aggregations []statsAggregation) []models.SupplierDefectTrend {
-	// 使用嵌套 map: supplierName -> date -> {total, defect}
-	supplierDailyMap := make(map[string]map[string]*struct {
+// 从聚合数据构建供应商不良趋势，每个供应商ID一条序列（同名供应商分别列出），按名称排序，每个序列覆盖 days 中的每一天
+func (s *QualityStatsService) buildSupplierDefectTrend(aggregations []statsAggregation, days []string, fill string) []models.SupplierDefectTrend {
+	type dailyCounts struct {
 		total  int64
 		defect int64
-	})
+	}
 
+	// 使用嵌套 map: supplierID -> date -> {total, defect}，名称仅用于展示
+	supplierDailyMap := make(map[uint]map[string]*dailyCounts)
+	supplierNames := make(map[uint]string)
 	for _, agg := range aggregations {
-		if _, exists := supplierDailyMap[agg.SupplierName]; !exists {
-			supplierDailyMap[agg.SupplierName] = make(map[string]*struct {
-				total  int64
-				defect int64
-			})
+		if _, exists := supplierDailyMap[agg.SupplierID]; !exists {
+			supplierDailyMap[agg.SupplierID] = make(map[string]*dailyCounts)
+			supplierNames[agg.SupplierID] = agg.SupplierName
 		}
-
-		if _, exists := supplierDailyMap[agg.SupplierName][agg.Date]; !exists {
-			supplierDailyMap[agg.SupplierName][agg.Date] = &struct {
-				total  int64
-				defect int64
-			}{}
+		if _, exists := supplierDailyMap[agg.SupplierID][agg.Date]; !exists {
+			supplierDailyMap[agg.SupplierID][agg.Date] = &dailyCounts{}
 		}
-
-		supplierDailyMap[agg.SupplierName][agg.Date].total += agg.TotalCount
-		supplierDailyMap[agg.SupplierName][agg.Date].defect += agg.DefectCount
+		supplierDailyMap[agg.SupplierID][agg.Date].total += agg.TotalCount
+		supplierDailyMap[agg.SupplierID][agg.Date].defect += agg.DefectCount
 	}
 
 	// 转换为响应格式
 	var supplierTrends []models.SupplierDefectTrend
-	for supplierName, dailyMap := range supplierDailyMap {
-		var dailyData []models.DailyDefectRate
-		for date, counts := range dailyMap {
-			var defectRate float64
-			if counts.total > 0 {
-				defectRate = float64(counts.defect) / float64(counts.total) * 100
+	for _, supplierID := range sortedSuppliers(supplierNames) {
+		dailyMap := supplierDailyMap[supplierID]
+		dailyData := make([]models.DailyDefectRate, 0, len(days))
+		for _, date := range days {
+			counts, exists := dailyMap[date]
+			if !exists {
+				if fill == models.FillNull {
+					dailyData = append(dailyData, models.DailyDefectRate{Date: date})
+					continue
+				}
+				counts = &dailyCounts{}
 			}
 			dailyData = append(dailyData, models.DailyDefectRate{
 				Date:        date,
-				DefectRate:  defectRate,
-				TotalCount:  int(counts.total),
-				DefectCount: int(counts.defect),
+				DefectRate:  float64Ptr(percentage(counts.defect, counts.total)),
+				TotalCount:  intPtr(int(counts.total)),
+				DefectCount: intPtr(int(counts.defect)),
 			})
 		}
 
-		if len(dailyData) > 0 {
-			supplierTrends = append(supplierTrends, models.SupplierDefectTrend{
-				SupplierName: supplierName,
-				DailyData:    dailyData,
-			})
-		}
+		supplierTrends = append(supplierTrends, models.SupplierDefectTrend{
+			SupplierID:   supplierID,
+			SupplierName: supplierNames[supplierID],
+			DailyData:    dailyData,
+		})
 	}
 
 	return supplierTrends
 }
 
 // 从聚合数据构建各类型不良趋势
-func (s *QualityStatsService) buildDefectTrendByType(aggregations []statsAggregation) models.DefectTrendByType {
+func (s *QualityStatsService) buildDefectTrendByType(aggregations []statsAggregation, days []string, fill string) models.DefectTrendByType {
 	// 定义缺陷类型映射
 	defectTypeMap := map[string]string{
 		"端子变形": "terminal",
@@ -205,229 +714,36 @@ func (s *QualityStatsService) buildDefectTrendByType(aggregations []statsAggrega
 
 	// 转换为响应格式
 	trends := models.DefectTrendByType{
-		TerminalData:   s.convertToDefectCountArray(typeDailyMap["端子变形"]),
-		TagData:        s.convertToDefectCountArray(typeDailyMap["铭牌不良"]),
-		AppearanceData: s.convertToDefectCountArray(typeDailyMap["外观不良"]),
-		NoiseData:      s.convertToDefectCountArray(typeDailyMap["轴承噪音"]),
+		TerminalData:   s.convertToDefectCountArray(typeDailyMap["端子变形"], days, fill),
+		TagData:        s.convertToDefectCountArray(typeDailyMap["铭牌不良"], days, fill),
+		AppearanceData: s.convertToDefectCountArray(typeDailyMap["外观不良"], days, fill),
+		NoiseData:      s.convertToDefectCountArray(typeDailyMap["轴承噪音"], days, fill),
 	}
 
 	return trends
 }
 
-// 辅助函数：将 map[date]count 转换为数组
-func (s *QualityStatsService) convertToDefectCountArray(dailyMap map[string]int64) []models.DailyDefectCount {
-	var dailyData []models.DailyDefectCount
-	for date, count := range dailyMap {
+// 辅助函数：将 map[date]count 按 days 的顺序转换为数组，缺失的日期按 fill 补齐
+func (s *QualityStatsService) convertToDefectCountArray(dailyMap map[string]int64, days []string, fill string) []models.DailyDefectCount {
+	dailyData := make([]models.DailyDefectCount, 0, len(days))
+	for _, date := range days {
+		count, exists := dailyMap[date]
+		if !exists && fill == models.FillNull {
+			dailyData = append(dailyData, models.DailyDefectCount{Date: date})
+			continue
+		}
 		dailyData = append(dailyData, models.DailyDefectCount{
 			Date:  date,
-			Count: int(count),
+			Count: intPtr(int(count)),
 		})
 	}
 	return dailyData
 }
 
-// 获取合格率统计
-func (s *QualityStatsService) getQualityRateStats(startDate, endDate time.Time) (*models.QualityRateStats, error) {
-	var totalCount, defectCount int64
-
-	// 统计总数
-	if err := s.db.Model(&models.Product{}).
-		Where("created_at BETWEEN ? AND ?", startDate, endDate).
-		Count(&totalCount).Error; err != nil {
-		return nil, err
-	}
-
-	// 统计不合格数
-	if err := s.db.Model(&models.Product{}).
-		Where("created_at BETWEEN ? AND ? AND has_defect = ?", startDate, endDate, true).
-		Count(&defectCount).Error; err != nil {
-		return nil, err
-	}
-
-	qualifiedCount := totalCount - defectCount
-	var qualityRate float64
-	if totalCount > 0 {
-		qualityRate = float64(qualifiedCount) / float64(totalCount) * 100
-	}
-
-	return &models.QualityRateStats{
-		QualifiedCount: int(qualifiedCount),
-		DefectCount:    int(defectCount),
-		TotalCount:     int(totalCount),
-		QualityRate:    qualityRate,
-	}, nil
+func intPtr(v int) *int {
+	return &v
 }
 
-// 获取不良类型分布
-func (s *QualityStatsService) getDefectTypeDistribution(startDate, endDate time.Time) ([]models.DefectTypeItem, error) {
-	var results []struct {
-		DefectReason string
-		Count        int64
-	}
-
-	// 查询不良产品的缺陷原因分布
-	if err := s.db.Model(&models.Product{}).
-		Select("defect_reason, COUNT(*) as count").
-		Where("created_at BETWEEN ? AND ? AND has_defect = ? AND defect_reason != ''", startDate, endDate, true).
-		Group("defect_reason").
-		Scan(&results).Error; err != nil {
-		return nil, err
-	}
-
-	// 获取总的不良数量
-	var totalDefectCount int64
-	for _, result := range results {
-		totalDefectCount += result.Count
-	}
-
-	// 转换为响应格式
-	var defectTypes []models.DefectTypeItem
-	for _, result := range results {
-		rate := float64(result.Count) / float64(totalDefectCount) * 100
-		defectTypes = append(defectTypes, models.DefectTypeItem{
-			Type:  result.DefectReason,
-			Count: int(result.Count),
-			Rate:  rate,
-		})
-	}
-
-	return defectTypes, nil
-}
-
-// 获取供应商不良趋势
-func (s *QualityStatsService) getSupplierDefectTrend(startDate, endDate time.Time) ([]models.SupplierDefectTrend, error) {
-	// 首先获取所有供应商
-	var suppliers []models.Supplier
-	if err := s.db.Find(&suppliers).Error; err != nil {
-		return nil, err
-	}
-
-	var supplierTrends []models.SupplierDefectTrend
-
-	for _, supplier := range suppliers {
-		dailyData, err := s.getSupplierDailyDefectData(supplier.ID, startDate, endDate)
-		if err != nil {
-			return nil, err
-		}
-
-		if len(dailyData) > 0 {
-			supplierTrends = append(supplierTrends, models.SupplierDefectTrend{
-				SupplierName: supplier.Name,
-				DailyData:    dailyData,
-			})
-		}
-	}
-
-	return supplierTrends, nil
-}
-
-// 获取供应商每日不良率数据
-func (s *QualityStatsService) getSupplierDailyDefectData(supplierID int64, startDate, endDate time.Time) ([]models.DailyDefectRate, error) {
-	// 按天分组统计每个供应商的产品数量和不良数量
-	var results []struct {
-		Date        string
-		TotalCount  int64
-		DefectCount int64
-	}
-
-	query := `
-		SELECT 
-			DATE(p.created_at) as date,
-			COUNT(*) as total_count,
-			SUM(CASE WHEN p.has_defect = true THEN 1 ELSE 0 END) as defect_count
-		FROM products p
-		INNER JOIN product_models pm ON p.product_model_id = pm.id
-		WHERE pm.supplier_id = ? 
-			AND p.created_at BETWEEN ? AND ?
-		GROUP BY DATE(p.created_at)
-		ORDER BY date
-	`
-
-	if err := s.db.Raw(query, supplierID, startDate, endDate).Scan(&results).Error; err != nil {
-		return nil, err
-	}
-
-	var dailyData []models.DailyDefectRate
-	for _, result := range results {
-		var defectRate float64
-		if result.TotalCount > 0 {
-			defectRate = float64(result.DefectCount) / float64(result.TotalCount) * 100
-		}
-
-		dailyData = append(dailyData, models.DailyDefectRate{
-			Date:        result.Date,
-			DefectRate:  defectRate,
-			TotalCount:  int(result.TotalCount),
-			DefectCount: int(result.DefectCount),
-		})
-	}
-
-	return dailyData, nil
-}
-
-// 获取各类型不良趋势
-func (s *QualityStatsService) getDefectTrendByType(startDate, endDate time.Time) (*models.DefectTrendByType, error) {
-	defectTypes := map[string]string{
-		"terminal":   "端子变形",
-		"tag":        "铭牌不良",
-		"appearance": "外观不良",
-		"noise":      "轴承噪音",
-	}
-
-	trends := &models.DefectTrendByType{}
-
-	for key, defectType := range defectTypes {
-		dailyData, err := s.getDefectTypeDailyData(defectType, startDate, endDate)
-		if err != nil {
-			return nil, err
-		}
-
-		switch key {
-		case "terminal":
-			trends.TerminalData = dailyData
-		case "tag":
-			trends.TagData = dailyData
-		case "appearance":
-			trends.AppearanceData = dailyData
-		case "noise":
-			trends.NoiseData = dailyData
-		}
-	}
-
-	return trends, nil
-}
-
-// 获取特定缺陷类型的每日数据
-func (s *QualityStatsService) getDefectTypeDailyData(defectType string, startDate, endDate time.Time) ([]models.DailyDefectCount, error) {
-	var results []struct {
-		Date  string
-		Count int64
-	}
-
-	query := `
-		SELECT 
-			DATE(created_at) as date,
-			COUNT(*) as count
-		FROM products 
-		WHERE has_defect = true 
-			AND defect_reason LIKE ?
-			AND created_at BETWEEN ? AND ?
-		GROUP BY DATE(created_at)
-		ORDER BY date
-	`
-
-	searchPattern := fmt.Sprintf("%%%s%%", defectType)
-	if err := s.db.Raw(query, searchPattern, startDate, endDate).Scan(&results).Error; err != nil {
-		return nil, err
-	}
-
-	var dailyData []models.DailyDefectCount
-	for _, result := range results {
-		dailyData = append(dailyData, models.DailyDefectCount{
-			Date:  result.Date,
-			Count: int(result.Count),
-		})
-	}
-
-	return dailyData, nil
+func float64Ptr(v float64) *float64 {
+	return &v
 }
@@ -0,0 +1,208 @@
+package services_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/services"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/testutil"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+)
+
+func qualityStats(t *testing.T, options models.QualityStatsOptions) *models.QualityStatsResponse {
+	t.Helper()
+	db := testutil.NewDB(t)
+	factory := testutil.NewFactory(t, db)
+	scenario := factory.SeedScenario()
+
+	// 名称排在最前、ID 最大的供应商，用于检查趋势按名称而非 ID 排序
+	supplier := factory.Supplier(models.Supplier{Name: "丙供应商"})
+	supplierID := uint(supplier.ID)
+	productModel := factory.ProductModel(models.ProductModel{SN: "MC00003", SupplierID: &supplierID})
+	modelID := uint(productModel.ID)
+	product := models.Product{SN: "MC00003030300001", ProductModelID: &modelID, HasDefect: true, DefectReason: "划伤"}
+	product.CreatedAt = scenario.Products[0].CreatedAt
+	factory.Product(product)
+
+	service, _ := services.NewQualityStatsService(db)
+	start, end, err := utils.DateRange("2024-03-01", "2024-03-03", utils.PlantLocation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := service.GetQualityStats(start, end, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stats
+}
+
+// 统计结果只取决于工厂时区和请求时区，与进程所在时区无关
+func TestQualityStatsIndependentOfProcessTimezone(t *testing.T) {
+	original := time.Local
+	t.Cleanup(func() { time.Local = original })
+
+	var expected []byte
+	for _, name := range []string{"UTC", "America/Los_Angeles", "Asia/Kolkata", "Pacific/Auckland"} {
+		location, err := time.LoadLocation(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Local = location
+
+		body, err := json.Marshal(qualityStats(t, models.QualityStatsOptions{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected == nil {
+			expected = body
+			continue
+		}
+		if string(body) != string(expected) {
+			t.Errorf("stats with TZ=%s differ:\n%s\nwant:\n%s", name, body, expected)
+		}
+	}
+}
+
+func TestSupplierTrendSortedAndDense(t *testing.T) {
+	stats := qualityStats(t, models.QualityStatsOptions{})
+
+	var names []string
+	for _, trend := range stats.SupplierDefectTrend {
+		names = append(names, trend.SupplierName)
+		var dates []string
+		for _, point := range trend.DailyData {
+			dates = append(dates, point.Date)
+			if point.TotalCount == nil || point.DefectRate == nil {
+				t.Errorf("%s %s: fill=zero must not produce null values", trend.SupplierName, point.Date)
+			}
+		}
+		if len(dates) != 3 || dates[0] != "2024-03-01" || dates[1] != "2024-03-02" || dates[2] != "2024-03-03" {
+			t.Errorf("%s: dates = %v, want every day from 2024-03-01 to 2024-03-03", trend.SupplierName, dates)
+		}
+	}
+	want := []string{"丙供应商", "乙供应商", "甲供应商"}
+	if len(names) != len(want) {
+		t.Fatalf("suppliers = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("suppliers = %v, want %v", names, want)
+		}
+	}
+
+	// 不良类型按数量降序，数量相同按名称升序
+	distribution := stats.DefectTypeDistribution
+	for i := 1; i < len(distribution); i++ {
+		previous, current := distribution[i-1], distribution[i]
+		if previous.Count < current.Count || (previous.Count == current.Count && previous.Type > current.Type) {
+			t.Errorf("defect types not ordered: %+v", distribution)
+		}
+	}
+}
+
+func TestSupplierTrendFillNull(t *testing.T) {
+	stats := qualityStats(t, models.QualityStatsOptions{Fill: models.FillNull})
+
+	for _, trend := range stats.SupplierDefectTrend {
+		for _, point := range trend.DailyData {
+			// 乙供应商 03-02 没有产品，丙供应商只有 03-01 有产品
+			empty := (trend.SupplierName == "乙供应商" && point.Date == "2024-03-02") ||
+				(trend.SupplierName == "丙供应商" && point.Date != "2024-03-01")
+			if empty != (point.TotalCount == nil) || empty != (point.DefectRate == nil) {
+				t.Errorf("%s %s: totalCount=%v defectRate=%v", trend.SupplierName, point.Date, point.TotalCount, point.DefectRate)
+			}
+		}
+	}
+}
+
+// 兼容预设按供应商ID分组：名称为空的供应商照常统计，同名供应商在聚合结果中分别出现
+func TestQualityStatsGroupsSuppliersByID(t *testing.T) {
+	db := testutil.NewDB(t)
+	factory := testutil.NewFactory(t, db)
+	scenario := factory.SeedScenario()
+	service, _ := services.NewQualityStatsService(db)
+	start, end, err := utils.DateRange("2024-03-01", "2024-03-03", utils.PlantLocation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := service.GetQualityStats(start, end, models.QualityStatsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, name := range []string{"丁供应商", "丁供应商", ""} {
+		supplier := factory.Supplier(models.Supplier{Name: name})
+		if name == "" {
+			if err := db.Model(supplier).Update("name", "").Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+		supplierID := uint(supplier.ID)
+		productModel := factory.ProductModel(models.ProductModel{SupplierID: &supplierID})
+		modelID := uint(productModel.ID)
+		product := models.Product{SN: fmt.Sprintf("MD0000%d030300001", i), ProductModelID: &modelID, HasDefect: true, DefectReason: "划伤"}
+		product.CreatedAt = scenario.Products[0].CreatedAt
+		factory.Product(product)
+	}
+
+	after, err := service.GetQualityStats(start, end, models.QualityStatsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := after.QualityRate.TotalCount - before.QualityRate.TotalCount; got != 3 {
+		t.Errorf("total count increased by %d, want 3 (supplier without a name must be counted)", got)
+	}
+	series := map[uint]int{}
+	for _, trend := range after.SupplierDefectTrend {
+		if trend.SupplierName != "丁供应商" {
+			continue
+		}
+		for _, point := range trend.DailyData {
+			series[trend.SupplierID] += *point.TotalCount
+		}
+	}
+	if len(series) != 2 {
+		t.Errorf("suppliers named 丁供应商 grouped into %d trend series, want 2: %v", len(series), series)
+	}
+	for supplierID, total := range series {
+		if total != 1 {
+			t.Errorf("supplier %d trend total = %d, want 1", supplierID, total)
+		}
+	}
+
+	compared, err := service.GetQualityStats(start, end, models.QualityStatsOptions{
+		Comparison: models.ComparisonQuery{Compare: models.ComparePrevious},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rates := map[uint]bool{}
+	for _, rate := range compared.Comparison.SupplierDefectRates {
+		if rate.SupplierName == "丁供应商" {
+			rates[rate.SupplierID] = true
+		}
+	}
+	if len(rates) != 2 {
+		t.Errorf("suppliers named 丁供应商 compared as %d rows, want 2", len(rates))
+	}
+
+	aggregate, err := service.Aggregate(start, end, models.AggregateSpec{
+		Dimensions: []string{models.DimensionSupplierID, models.DimensionSupplier},
+		Measures:   []string{models.MeasureTotalCount},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]bool{}
+	for _, row := range aggregate.Rows {
+		if row.Dimensions[models.DimensionSupplier] == "丁供应商" {
+			ids[row.Dimensions[models.DimensionSupplierID]] = true
+		}
+	}
+	if len(ids) != 2 {
+		t.Errorf("suppliers named 丁供应商 grouped into %d rows, want 2", len(ids))
+	}
+}
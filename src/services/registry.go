@@ -0,0 +1,44 @@
+package services
+
+import (
+	"github.com/dreamskynl/godi"
+	"gorm.io/gorm"
+)
+
+// 向容器注册全部服务，服务启动和集成测试共用
+func RegisterAll(sc godi.IGoDI, db *gorm.DB) error {
+	registrations := []struct {
+		service     interface{}
+		constructor interface{}
+		args        []interface{}
+	}{
+		{&APIService{}, NewAPIService, []interface{}{db}},
+		{&PalletService{}, NewPalletService, []interface{}{db}},
+		{&ProductLineService{}, NewProductLineService, []interface{}{db}},
+		{&ProductModelService{}, NewProductModelService, []interface{}{db}},
+		{&ProductService{}, NewProductService, []interface{}{db}},
+		{&ProductionPlanService{}, NewProductionPlanService, []interface{}{db}},
+		{&SupplierService{}, NewSupplierService, []interface{}{db}},
+		{&UserService{}, NewUserService, []interface{}{db}},
+		{&JwtService{}, NewJWTService, nil},
+		{&KeyManagementService{}, NewKeyManagementService, nil},
+		{&ShiftCalendarService{}, NewShiftCalendarService, []interface{}{db}},
+		{&QualityRollupService{}, NewQualityRollupService, []interface{}{db}},
+		{&QualityStatsService{}, NewQualityStatsService, []interface{}{db}},
+		{&DataReportService{}, NewDataReportService, []interface{}{db}},
+		{&ReportExportService{}, NewReportExportService, []interface{}{db}},
+		{&ExportService{}, NewExportService, []interface{}{db}},
+		{&JobService{}, NewJobService, []interface{}{db}},
+		{&ReportScheduleService{}, NewReportScheduleService, []interface{}{db}},
+		{&SearchService{}, NewSearchService, []interface{}{db}},
+		{&TraceService{}, NewTraceService, []interface{}{db}},
+		{&BatchService{}, NewBatchService, []interface{}{db}},
+		{&SamplingService{}, NewSamplingService, []interface{}{db}},
+	}
+	for _, registration := range registrations {
+		if err := sc.Register(registration.service, registration.constructor, registration.args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
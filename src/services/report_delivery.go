@@ -0,0 +1,232 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+)
+
+// 生成的报表文件
+type ReportAttachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// 一次投递的内容
+type ReportDeliveryMessage struct {
+	Schedule    *models.ReportSchedule
+	Subject     string
+	PeriodStart string
+	PeriodEnd   string
+	Attachments []ReportAttachment
+}
+
+// ReportDelivery 投递方式，按 ReportSchedule.Channel 选择
+type ReportDelivery interface {
+	Deliver(ctx context.Context, message *ReportDeliveryMessage) error
+}
+
+var (
+	reportDeliveriesMu sync.RWMutex
+	reportDeliveries   = map[string]ReportDelivery{
+		models.DeliveryEmail:   smtpDelivery{},
+		models.DeliveryWebhook: webhookDelivery{client: &http.Client{Timeout: 30 * time.Second}},
+		models.DeliveryFolder:  folderDelivery{},
+	}
+)
+
+// 替换或新增投递方式
+func RegisterReportDelivery(channel string, delivery ReportDelivery) {
+	reportDeliveriesMu.Lock()
+	defer reportDeliveriesMu.Unlock()
+	reportDeliveries[channel] = delivery
+}
+
+func reportDelivery(channel string) (ReportDelivery, bool) {
+	reportDeliveriesMu.RLock()
+	defer reportDeliveriesMu.RUnlock()
+	delivery, ok := reportDeliveries[channel]
+	return delivery, ok
+}
+
+// 通过 SMTP 发送带附件的邮件；服务器支持时使用 STARTTLS
+type smtpDelivery struct{}
+
+func (smtpDelivery) Deliver(ctx context.Context, message *ReportDeliveryMessage) error {
+	cfg := reportOptions.SMTP
+	if cfg.Host == "" {
+		return PermanentJobError(fmt.Errorf("SMTP_HOST is not configured"))
+	}
+	recipients, err := mail.ParseAddressList(message.Schedule.Target)
+	if err != nil {
+		return PermanentJobError(err)
+	}
+	to := make([]string, len(recipients))
+	headerTo := make([]string, len(recipients))
+	for i, recipient := range recipients {
+		to[i] = recipient.Address
+		headerTo[i] = recipient.String()
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	fmt.Fprintf(&body, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&body, "To: %s\r\n", joinHeader(headerTo))
+	fmt.Fprintf(&body, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&body, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&body, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&body, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", writer.Boundary())
+
+	text, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return err
+	}
+	content := fmt.Sprintf("%s\r\n报表区间：%s 至 %s\r\n", message.Subject, message.PeriodStart, message.PeriodEnd)
+	if err := writeBase64(text, []byte(content)); err != nil {
+		return err
+	}
+	for _, attachment := range message.Attachments {
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {attachment.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name})},
+		})
+		if err != nil {
+			return err
+		}
+		if err := writeBase64(part, attachment.Data); err != nil {
+			return err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	return smtp.SendMail(net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)), auth, cfg.From, to, body.Bytes())
+}
+
+func joinHeader(values []string) string {
+	var buf bytes.Buffer
+	for i, value := range values {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(value)
+	}
+	return buf.String()
+}
+
+// base64 编码，每行 76 个字符
+func writeBase64(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 0 {
+		n := min(76, len(encoded))
+		if _, err := w.Write([]byte(encoded[:n] + "\r\n")); err != nil {
+			return err
+		}
+		encoded = encoded[n:]
+	}
+	return nil
+}
+
+// 以 multipart/form-data POST 到目标地址：report 字段为报表信息（JSON），files 字段为各个文件
+type webhookDelivery struct {
+	client *http.Client
+}
+
+func (d webhookDelivery) Deliver(ctx context.Context, message *ReportDeliveryMessage) error {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	meta, err := json.Marshal(map[string]interface{}{
+		"scheduleId":  message.Schedule.ID,
+		"name":        message.Schedule.Name,
+		"report":      message.Schedule.Report,
+		"subject":     message.Subject,
+		"periodStart": message.PeriodStart,
+		"periodEnd":   message.PeriodEnd,
+	})
+	if err != nil {
+		return err
+	}
+	if err := writer.WriteField("report", string(meta)); err != nil {
+		return err
+	}
+	for _, attachment := range message.Attachments {
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":        {attachment.ContentType},
+			"Content-Disposition": {mime.FormatMediaType("form-data", map[string]string{"name": "files", "filename": attachment.Name})},
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := part.Write(attachment.Data); err != nil {
+			return err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, message.Schedule.Target, &body)
+	if err != nil {
+		return PermanentJobError(err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// 写入 REPORT_FOLDER_DIR/<目标>，先写临时文件再改名，避免读取到不完整的文件
+type folderDelivery struct{}
+
+func (folderDelivery) Deliver(ctx context.Context, message *ReportDeliveryMessage) error {
+	if !filepath.IsLocal(message.Schedule.Target) {
+		return PermanentJobError(fmt.Errorf("invalid folder %q", message.Schedule.Target))
+	}
+	dir := filepath.Join(reportOptions.FolderDir, message.Schedule.Target)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, attachment := range message.Attachments {
+		path := filepath.Join(dir, attachment.Name)
+		if err := os.WriteFile(path+".tmp", attachment.Data, 0o644); err != nil {
+			return err
+		}
+		if err := os.Rename(path+".tmp", path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,571 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+	"github.com/xuri/excelize/v2"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const filterSheetName = "筛选条件"
+
+type ReportExportService struct {
+	db *gorm.DB
+}
+
+func NewReportExportService(db *gorm.DB) (IReportExportService, error) {
+	return &ReportExportService{db: db}, nil
+}
+
+func (s *ReportExportService) WithContext(ctx context.Context) IReportExportService {
+	return &ReportExportService{db: s.db.WithContext(ctx)}
+}
+
+func (s *ReportExportService) startSpan(method string) (*ReportExportService, trace.Span) {
+	db, span := serviceSpan(s.db, "ReportExportService."+method)
+	return &ReportExportService{db: db}, span
+}
+
+// 不合格报表的全部明细，写出时以游标逐行读取
+func (s *ReportExportService) DefectReportTable(query *models.DefectReportQuery) (*models.ReportTable, error) {
+	s, span := s.startSpan("DefectReportTable")
+	defer span.End()
+
+	reports := &DataReportService{db: s.db}
+	window, err := reports.newReportWindow(query.StartDate, query.EndDate, query.BucketBy, query.TZ)
+	if err != nil {
+		return nil, err
+	}
+	// 导出全部记录，不使用游标分页，也不包含基准周期对比
+	page, err := defectReportPage(&models.DefectReportQuery{Sort: query.Sort}, -1, 0)
+	if err != nil {
+		return nil, err
+	}
+	records := page.Order(reports.defectReportQuery(query, window))
+
+	production := window.production()
+	table := &models.ReportTable{
+		Name: "不合格报表",
+		Columns: []models.ReportColumn{
+			{Header: "供应商", Width: 20},
+			{Header: "质检时间", Format: models.ColumnDateTime, Width: 20},
+			{Header: "产品SN", Width: 22},
+			{Header: "物料编码", Width: 14},
+			{Header: "物料描述", Width: 24},
+			{Header: "批次号", Width: 10},
+			{Header: "不良原因", Width: 16},
+		},
+	}
+	if production {
+		table.Columns = append(table.Columns,
+			models.ReportColumn{Header: "生产日", Format: models.ColumnDate, Width: 12},
+			models.ReportColumn{Header: "班次", Width: 10},
+		)
+	}
+
+	supplier := ""
+	if query.SupplierID != nil {
+		var supplierRecord models.Supplier
+		if err := s.db.First(&supplierRecord, *query.SupplierID).Error; err == nil {
+			supplier = supplierRecord.Name
+		} else {
+			supplier = strconv.FormatUint(uint64(*query.SupplierID), 10)
+		}
+	}
+
+	table.Rows = func(yield func([]interface{}) error) error {
+		var item models.DefectReportItem
+		count, err := eachRow(records, &item, func() error {
+			window.localize(&item)
+			row := []interface{}{item.SupplierName, item.QualityDate, item.ProductSN, item.ProductModelSN, item.Description, item.BatchNumber, item.DefectReason}
+			if production {
+				row = append(row, dateValue(item.ProductionDay), item.Shift)
+			}
+			return yield(row)
+		})
+		if err != nil {
+			return err
+		}
+		table.Totals = totalsRow(len(table.Columns), map[int]interface{}{2: count})
+		table.Filters = reportFilters(table, count, query.StartDate, query.EndDate, query.BucketBy, query.TZ, [][2]string{
+			{"供应商", supplier},
+			{"物料编码", query.ProductModelSN},
+		})
+		return nil
+	}
+	return table, nil
+}
+
+// 检测报表的全部行，合格率 = 合格数量 / 检测数量；写出时以游标逐行读取并累计合计行
+func (s *ReportExportService) InspectionReportTable(query *models.InspectionReportQuery) (*models.ReportTable, error) {
+	s, span := s.startSpan("InspectionReportTable")
+	defer span.End()
+
+	reports := &DataReportService{db: s.db}
+	window, err := reports.newReportWindow(query.StartDate, query.EndDate, query.BucketBy, query.TZ)
+	if err != nil {
+		return nil, err
+	}
+	grouped, err := reports.inspectionReportQuery(query, window)
+	if err != nil {
+		return nil, err
+	}
+
+	table := &models.ReportTable{
+		Name: "检测报表",
+		Columns: []models.ReportColumn{
+			{Header: "检测日期", Format: models.ColumnDate, Width: 12},
+			{Header: "物料编码", Width: 14},
+			{Header: "物料描述", Width: 24},
+			{Header: "批次号", Width: 10},
+			{Header: "生产厂家", Width: 20},
+			{Header: "产线", Width: 12},
+			{Header: "检测数量", Format: models.ColumnInteger, Width: 12},
+			{Header: "合格数量", Format: models.ColumnInteger, Width: 12},
+			{Header: "不合格数量", Format: models.ColumnInteger, Width: 12},
+			{Header: "合格率", Format: models.ColumnPercent, Width: 10},
+		},
+	}
+	table.Rows = func(yield func([]interface{}) error) error {
+		var item models.InspectionReportItem
+		var inspected, qualified, unqualified int
+		count, err := eachRow(grouped, &item, func() error {
+			inspected += item.InspectionCount
+			qualified += item.QualifiedCount
+			unqualified += item.UnqualifiedCount
+			return yield([]interface{}{
+				dateValue(item.InspectionDate), item.ProductModelSN, item.Description, item.BatchNumber, item.SupplierName, item.ProductLine,
+				item.InspectionCount, item.QualifiedCount, item.UnqualifiedCount, ratio(item.QualifiedCount, item.InspectionCount),
+			})
+		})
+		if err != nil {
+			return err
+		}
+		table.Totals = totalsRow(len(table.Columns), map[int]interface{}{6: inspected, 7: qualified, 8: unqualified, 9: ratio(qualified, inspected)})
+		table.Filters = reportFilters(table, count, query.StartDate, query.EndDate, query.BucketBy, query.TZ, [][2]string{
+			{"物料编码", query.ProductModelSN},
+			{"批次号", query.BatchNumber},
+			{"生产厂家", query.SupplierName},
+		})
+		return nil
+	}
+	return table, nil
+}
+
+// 检测费用报表的全部行，写出时以游标逐行读取并累计合计行
+func (s *ReportExportService) CostReportTable(query *models.CostReportQuery) (*models.ReportTable, error) {
+	s, span := s.startSpan("CostReportTable")
+	defer span.End()
+
+	reports := &DataReportService{db: s.db}
+	window, err := reports.newReportWindow(query.StartDate, query.EndDate, query.BucketBy, query.TZ)
+	if err != nil {
+		return nil, err
+	}
+	grouped, err := reports.costReportQuery(query, window)
+	if err != nil {
+		return nil, err
+	}
+
+	table := &models.ReportTable{
+		Name: "检测费用报表",
+		Columns: []models.ReportColumn{
+			{Header: "检测日期", Format: models.ColumnDate, Width: 12},
+			{Header: "厂家", Width: 20},
+			{Header: "物料编码", Width: 14},
+			{Header: "电机类型", Width: 24},
+			{Header: "合格数量", Format: models.ColumnInteger, Width: 12},
+			{Header: "不合格数量", Format: models.ColumnInteger, Width: 12},
+			{Header: "总数量", Format: models.ColumnInteger, Width: 12},
+		},
+	}
+	table.Rows = func(yield func([]interface{}) error) error {
+		var item models.CostReportItem
+		var qualified, unqualified, total int
+		count, err := eachRow(grouped, &item, func() error {
+			qualified += item.QualifiedCount
+			unqualified += item.UnqualifiedCount
+			total += item.TotalCount
+			return yield([]interface{}{
+				dateValue(item.TestDate), item.SupplierName, item.ProductModelSN, item.MotorType,
+				item.QualifiedCount, item.UnqualifiedCount, item.TotalCount,
+			})
+		})
+		if err != nil {
+			return err
+		}
+		table.Totals = totalsRow(len(table.Columns), map[int]interface{}{4: qualified, 5: unqualified, 6: total})
+		table.Filters = reportFilters(table, count, query.StartDate, query.EndDate, query.BucketBy, query.TZ, [][2]string{
+			{"厂家", query.SupplierName},
+			{"物料编码", query.ProductModelSN},
+			{"电机类型", query.MotorType},
+		})
+		return nil
+	}
+	return table, nil
+}
+
+// 以游标逐行读取查询结果：每行扫描到 dest 后调用 each，返回读取的行数
+func eachRow(query *gorm.DB, dest interface{}, each func() error) (int, error) {
+	rows, err := query.Session(&gorm.Session{}).Rows()
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		reflect.ValueOf(dest).Elem().SetZero()
+		if err := query.ScanRows(rows, dest); err != nil {
+			return count, err
+		}
+		if err := each(); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, rows.Err()
+}
+
+// 供应商不良率排名：按不良率降序，不良率相同按不良数量降序；supplierName 非空时只保留名称包含它的供应商
+func (s *ReportExportService) SupplierRankingTable(startDate, endDate, supplierName string) (*models.ReportTable, error) {
+	s, span := s.startSpan("SupplierRankingTable")
+	defer span.End()
+
+	start, end, err := utils.DateRange(startDate, endDate, utils.PlantLocation())
+	if err != nil {
+		return nil, err
+	}
+	spec := models.AggregateSpec{Dimensions: []string{models.DimensionSupplier}}
+	result, err := (&QualityStatsService{db: s.db}).Aggregate(start, end, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	type ranking struct {
+		supplier      string
+		total, defect int
+	}
+	var rankings []ranking
+	for _, row := range result.Rows {
+		name := row.Dimensions[models.DimensionSupplier]
+		if supplierName != "" && !strings.Contains(name, supplierName) {
+			continue
+		}
+		rankings = append(rankings, ranking{name, int(row.Measures[models.MeasureTotalCount]), int(row.Measures[models.MeasureDefectCount])})
+	}
+	sort.SliceStable(rankings, func(i, j int) bool {
+		ri, rj := float64(rankings[i].defect)*float64(rankings[j].total), float64(rankings[j].defect)*float64(rankings[i].total)
+		if ri != rj {
+			return ri > rj
+		}
+		if rankings[i].defect != rankings[j].defect {
+			return rankings[i].defect > rankings[j].defect
+		}
+		return rankings[i].supplier < rankings[j].supplier
+	})
+
+	table := &models.ReportTable{
+		Name: "供应商不良排名",
+		Columns: []models.ReportColumn{
+			{Header: "排名", Format: models.ColumnInteger, Width: 8},
+			{Header: "供应商", Width: 24},
+			{Header: "检测数量", Format: models.ColumnInteger, Width: 12},
+			{Header: "不良数量", Format: models.ColumnInteger, Width: 12},
+			{Header: "不良率", Format: models.ColumnPercent, Width: 10},
+		},
+	}
+	var total, defect int
+	for _, r := range rankings {
+		total += r.total
+		defect += r.defect
+	}
+	table.Rows = func(yield func([]interface{}) error) error {
+		for i, r := range rankings {
+			if err := yield([]interface{}{i + 1, r.supplier, r.total, r.defect, ratio(r.defect, r.total)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	table.Totals = totalsRow(len(table.Columns), map[int]interface{}{2: total, 3: defect, 4: ratio(defect, total)})
+	table.Filters = reportFilters(table, len(rankings), startDate, endDate, "", "", [][2]string{{"供应商", supplierName}})
+	return table, nil
+}
+
+// 按格式写出报表：xlsx 以流式写入工作表，csv 带 UTF-8 BOM 以便 Excel 正确识别中文，pdf 用于定时报表
+func (s *ReportExportService) Write(w io.Writer, table *models.ReportTable, format string) error {
+	switch format {
+	case models.ExportFormatXLSX:
+		return writeReportXLSX(w, table)
+	case models.ExportFormatCSV:
+		return writeReportCSV(w, table)
+	case models.ExportFormatPDF:
+		return writeReportPDF(w, table)
+	default:
+		return fmt.Errorf("invalid format %q", format)
+	}
+}
+
+func writeReportXLSX(w io.Writer, table *models.ReportTable) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	if err := f.SetSheetName(f.GetSheetName(0), table.Name); err != nil {
+		return err
+	}
+	styles, err := newReportStyles(f, table.Columns)
+	if err != nil {
+		return err
+	}
+
+	sw, err := f.NewStreamWriter(table.Name)
+	if err != nil {
+		return err
+	}
+	for i, column := range table.Columns {
+		if column.Width > 0 {
+			if err := sw.SetColWidth(i+1, i+1, column.Width); err != nil {
+				return err
+			}
+		}
+	}
+	if err := sw.SetPanes(&excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+		return err
+	}
+
+	header := make([]interface{}, len(table.Columns))
+	for i, column := range table.Columns {
+		header[i] = excelize.Cell{StyleID: styles.header, Value: column.Header}
+	}
+	if err := sw.SetRow("A1", header); err != nil {
+		return err
+	}
+	next := 2
+	if err := table.Rows(func(row []interface{}) error {
+		cell, _ := excelize.CoordinatesToCellName(1, next)
+		next++
+		return sw.SetRow(cell, styles.cells(row, false))
+	}); err != nil {
+		return err
+	}
+	if table.Totals != nil {
+		cell, _ := excelize.CoordinatesToCellName(1, next)
+		if err := sw.SetRow(cell, styles.cells(table.Totals, true)); err != nil {
+			return err
+		}
+	}
+	if err := sw.Flush(); err != nil {
+		return err
+	}
+
+	// 筛选条件表
+	if _, err := f.NewSheet(filterSheetName); err != nil {
+		return err
+	}
+	if err := f.SetColWidth(filterSheetName, "A", "A", 14); err != nil {
+		return err
+	}
+	if err := f.SetColWidth(filterSheetName, "B", "B", 32); err != nil {
+		return err
+	}
+	for i, filter := range table.Filters {
+		if err := f.SetSheetRow(filterSheetName, fmt.Sprintf("A%d", i+1), &[]interface{}{filter[0], filter[1]}); err != nil {
+			return err
+		}
+	}
+	if len(table.Filters) > 0 {
+		if err := f.SetCellStyle(filterSheetName, "A1", fmt.Sprintf("A%d", len(table.Filters)), styles.label); err != nil {
+			return err
+		}
+	}
+
+	_, err = f.WriteTo(w)
+	return err
+}
+
+func writeReportCSV(w io.Writer, table *models.ReportTable) error {
+	if _, err := io.WriteString(w, "\uFEFF"); err != nil {
+		return err
+	}
+	writer := csv.NewWriter(w)
+	header := make([]string, len(table.Columns))
+	for i, column := range table.Columns {
+		header[i] = column.Header
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	record := make([]string, len(table.Columns))
+	write := func(row []interface{}) error {
+		for i, value := range row {
+			record[i] = csvValue(value, table.Columns[i].Format)
+		}
+		return writer.Write(record)
+	}
+	if err := table.Rows(write); err != nil {
+		return err
+	}
+	if table.Totals != nil {
+		if err := write(table.Totals); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func csvValue(value interface{}, format models.ColumnFormat) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		if format == models.ColumnDate {
+			return v.Format("2006-01-02")
+		}
+		return v.Format("2006-01-02 15:04:05")
+	case float64:
+		if format == models.ColumnPercent {
+			return strconv.FormatFloat(v*100, 'f', 2, 64) + "%"
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// 每列的单元格样式，合计行另用加粗样式
+type reportStyles struct {
+	header int
+	label  int
+	body   []int
+	totals []int
+}
+
+func newReportStyles(f *excelize.File, columns []models.ReportColumn) (*reportStyles, error) {
+	border := []excelize.Border{{Type: "top", Color: "000000", Style: 1}}
+	header, err := f.NewStyle(&excelize.Style{
+		Font:      &excelize.Font{Bold: true},
+		Fill:      excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"D9E1F2"}},
+		Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "center"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	label, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return nil, err
+	}
+	styles := &reportStyles{header: header, label: label}
+	for _, column := range columns {
+		style := &excelize.Style{}
+		switch column.Format {
+		case models.ColumnInteger:
+			style.NumFmt = 3 // #,##0
+		case models.ColumnPercent:
+			style.NumFmt = 10 // 0.00%
+		case models.ColumnDate:
+			format := "yyyy-mm-dd"
+			style.CustomNumFmt = &format
+		case models.ColumnDateTime:
+			format := "yyyy-mm-dd hh:mm:ss"
+			style.CustomNumFmt = &format
+		}
+		body, err := f.NewStyle(style)
+		if err != nil {
+			return nil, err
+		}
+		style.Font = &excelize.Font{Bold: true}
+		style.Border = border
+		totals, err := f.NewStyle(style)
+		if err != nil {
+			return nil, err
+		}
+		styles.body = append(styles.body, body)
+		styles.totals = append(styles.totals, totals)
+	}
+	return styles, nil
+}
+
+func (s *reportStyles) cells(row []interface{}, totals bool) []interface{} {
+	cells := make([]interface{}, len(row))
+	for i, value := range row {
+		style := s.body[i]
+		if totals {
+			style = s.totals[i]
+		}
+		// excelize 按绝对时刻换算，这里保留报表时区的钟面时间
+		if t, ok := value.(time.Time); ok {
+			value = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+		}
+		cells[i] = excelize.Cell{StyleID: style, Value: value}
+	}
+	return cells
+}
+
+// 合计行：第一列为“合计”，values 为列序号 -> 合计值
+func totalsRow(columns int, values map[int]interface{}) []interface{} {
+	row := make([]interface{}, columns)
+	row[0] = "合计"
+	for i, value := range values {
+		row[i] = value
+	}
+	return row
+}
+
+// YYYY-MM-DD 日期转为日期单元格，无法解析时原样输出
+func dateValue(day string) interface{} {
+	t, err := time.Parse("2006-01-02", day)
+	if err != nil {
+		return day
+	}
+	return t
+}
+
+func ratio(numerator, denominator int) interface{} {
+	if denominator == 0 {
+		return nil
+	}
+	return float64(numerator) / float64(denominator)
+}
+
+// 筛选条件表：报表区间、统计口径、时区、各筛选条件（未填写的显示“全部”）、记录数和导出时间
+func reportFilters(table *models.ReportTable, count int, startDate, endDate, bucketBy, tz string, filters [][2]string) [][2]string {
+	bucket := "自然日"
+	if bucketBy == models.BucketByProduction {
+		bucket = "生产日（按班次日历）"
+	}
+	location, _ := utils.ResolveLocation(tz)
+	result := [][2]string{
+		{"报表", table.Name},
+		{"开始日期", orAll(startDate)},
+		{"结束日期", orAll(endDate)},
+		{"统计口径", bucket},
+		{"时区", location.String()},
+	}
+	for _, filter := range filters {
+		result = append(result, [2]string{filter[0], orAll(filter[1])})
+	}
+	return append(result,
+		[2]string{"记录数", strconv.Itoa(count)},
+		[2]string{"导出时间", time.Now().In(location).Format("2006-01-02 15:04:05")},
+	)
+}
+
+func orAll(value string) string {
+	if value == "" {
+		return "全部"
+	}
+	return value
+}
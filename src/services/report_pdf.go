@@ -0,0 +1,135 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/go-pdf/fpdf"
+)
+
+// PDF 版面（A4 横向，单位 mm）
+const (
+	pdfMargin     = 10.0
+	pdfRowHeight  = 7.0
+	pdfFontFamily = "report"
+)
+
+// 数据表逐行输出，换页时重复表头；筛选条件列在标题下方
+func writeReportPDF(w io.Writer, table *models.ReportTable) error {
+	pdf := fpdf.New("L", "mm", "A4", "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(false, pdfMargin)
+
+	// 内置字体不包含中文字形，需通过 REPORT_FONT_PATH 指定 TrueType 字体；字体无效时在此处失败，而不是在 Output 时
+	if reportOptions.FontPath == "" {
+		return ErrReportFontRequired
+	}
+	// fpdf 加载无效字体时只打印错误而不设置 Err，先解析一次；且按字体目录解析文件名，绝对路径需先读出字体数据
+	if _, err := fpdf.TtfParse(reportOptions.FontPath); err != nil {
+		return fmt.Errorf("load REPORT_FONT_PATH %q: %w", reportOptions.FontPath, err)
+	}
+	font, err := os.ReadFile(reportOptions.FontPath)
+	if err != nil {
+		return fmt.Errorf("load REPORT_FONT_PATH: %w", err)
+	}
+	pdf.AddUTF8FontFromBytes(pdfFontFamily, "", font)
+	pdf.AddUTF8FontFromBytes(pdfFontFamily, "B", font)
+	if pdf.Err() {
+		return fmt.Errorf("load REPORT_FONT_PATH %q: %w", reportOptions.FontPath, pdf.Error())
+	}
+	family := pdfFontFamily
+
+	pageWidth, pageHeight := pdf.GetPageSize()
+	usable := pageWidth - 2*pdfMargin
+	var totalWidth float64
+	for _, column := range table.Columns {
+		totalWidth += columnWidth(column)
+	}
+	widths := make([]float64, len(table.Columns))
+	for i, column := range table.Columns {
+		widths[i] = usable * columnWidth(column) / totalWidth
+	}
+
+	header := func() {
+		pdf.SetFont(family, "B", 9)
+		pdf.SetFillColor(217, 225, 242)
+		for i, column := range table.Columns {
+			pdf.CellFormat(widths[i], pdfRowHeight, column.Header, "1", 0, "C", true, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetFont(family, "", 9)
+	}
+	row := func(values []interface{}, bold bool) {
+		if pdf.GetY()+pdfRowHeight > pageHeight-pdfMargin {
+			pdf.AddPage()
+			header()
+		}
+		if bold {
+			pdf.SetFont(family, "B", 9)
+		}
+		for i, value := range values {
+			align := "L"
+			format := table.Columns[i].Format
+			if format == models.ColumnInteger || format == models.ColumnPercent {
+				align = "R"
+			}
+			text := fitText(pdf, csvValue(value, format), widths[i]-2)
+			pdf.CellFormat(widths[i], pdfRowHeight, text, "1", 0, align, false, 0, "")
+		}
+		pdf.Ln(-1)
+		if bold {
+			pdf.SetFont(family, "", 9)
+		}
+	}
+
+	// PDF 文档本身在内存中生成，且筛选条件（含记录数）位于数据表之前，因此先读完数据行
+	var rows [][]interface{}
+	if err := table.Rows(func(values []interface{}) error {
+		rows = append(rows, values)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	pdf.AddPage()
+	pdf.SetFont(family, "B", 14)
+	pdf.CellFormat(usable, 10, table.Name, "", 1, "L", false, 0, "")
+	pdf.SetFont(family, "", 8)
+	var filters []string
+	for _, filter := range table.Filters {
+		filters = append(filters, filter[0]+": "+filter[1])
+	}
+	pdf.MultiCell(usable, 5, strings.Join(filters, "    "), "", "L", false)
+	pdf.Ln(2)
+
+	header()
+	for _, values := range rows {
+		row(values, false)
+	}
+	if table.Totals != nil {
+		row(table.Totals, true)
+	}
+	return pdf.Output(w)
+}
+
+func columnWidth(column models.ReportColumn) float64 {
+	if column.Width > 0 {
+		return column.Width
+	}
+	return 12
+}
+
+// 超出单元格宽度的文本截断并以省略号结尾
+func fitText(pdf *fpdf.Fpdf, text string, width float64) string {
+	if pdf.GetStringWidth(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"..") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + ".."
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+type ReportScheduleService struct {
+	db *gorm.DB
+}
+
+func NewReportScheduleService(db *gorm.DB) (IReportScheduleService, error) {
+	return &ReportScheduleService{db: db}, nil
+}
+
+func (s *ReportScheduleService) WithContext(ctx context.Context) IReportScheduleService {
+	return &ReportScheduleService{db: s.db.WithContext(ctx)}
+}
+
+func (s *ReportScheduleService) startSpan(method string) (*ReportScheduleService, trace.Span) {
+	db, span := serviceSpan(s.db, "ReportScheduleService."+method)
+	return &ReportScheduleService{db: db}, span
+}
+
+// 下一次执行时间，停用的计划为 nil
+func nextReportRun(schedule *models.ReportSchedule) (*time.Time, error) {
+	if !schedule.IsEnabled() {
+		return nil, nil
+	}
+	cronSchedule, err := schedule.CronSchedule()
+	if err != nil {
+		return nil, err
+	}
+	next := cronSchedule.Next(time.Now().In(utils.PlantLocation()))
+	return &next, nil
+}
+
+func (s *ReportScheduleService) CreateSchedule(schedule *models.ReportSchedule) error {
+	s, span := s.startSpan("CreateSchedule")
+	defer span.End()
+
+	if err := checkReportFormats(schedule); err != nil {
+		return err
+	}
+	next, err := nextReportRun(schedule)
+	if err != nil {
+		return err
+	}
+	schedule.NextRunAt = next
+	return s.db.Create(schedule).Error
+}
+
+func (s *ReportScheduleService) GetSchedule(id int64) (*models.ReportSchedule, error) {
+	s, span := s.startSpan("GetSchedule")
+	defer span.End()
+
+	var schedule models.ReportSchedule
+	err := s.db.First(&schedule, id).Error
+	return &schedule, err
+}
+
+func (s *ReportScheduleService) GetSchedules(paginate map[string]interface{}) ([]models.ReportSchedule, models.PaginationResult, error) {
+	s, span := s.startSpan("GetSchedules")
+	defer span.End()
+
+	var schedules []models.ReportSchedule
+	model := s.db.Model(&models.ReportSchedule{})
+	model, pagination := utils.DoPagination(model, paginate)
+	model = utils.DoOrder(model, paginate)
+	if err := model.Find(&schedules).Error; err != nil {
+		return []models.ReportSchedule{}, pagination, err
+	}
+	return schedules, pagination, nil
+}
+
+// merged 为合并修改后的计划，用于重新计算下一次执行时间
+func (s *ReportScheduleService) UpdateSchedule(scheduleInstance *models.ReportSchedule, merged *models.ReportSchedule, schedule map[string]interface{}) error {
+	s, span := s.startSpan("UpdateSchedule")
+	defer span.End()
+
+	if err := checkReportFormats(merged); err != nil {
+		return err
+	}
+	next, err := nextReportRun(merged)
+	if err != nil {
+		return err
+	}
+	var nextRunAt interface{}
+	if next != nil {
+		nextRunAt = utils.InDB(*next)
+	}
+	schedule["next_run_at"] = nextRunAt
+	return s.db.Model(scheduleInstance).Updates(schedule).Error
+}
+
+func (s *ReportScheduleService) DeleteSchedules(ids []int64) error {
+	s, span := s.startSpan("DeleteSchedules")
+	defer span.End()
+
+	return s.db.Delete(&models.ReportSchedule{}, ids).Error
+}
+
+// 立即生成一次，报表区间按当前时间计算
+func (s *ReportScheduleService) RunSchedule(id int64) (*models.Job, error) {
+	s, span := s.startSpan("RunSchedule")
+	defer span.End()
+
+	schedule, err := s.GetSchedule(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkReportFormats(schedule); err != nil {
+		return nil, err
+	}
+	payload := reportJobPayload{ScheduleID: id, Trigger: models.ReportTriggerManual, ScheduledAt: time.Now()}
+	return enqueueJob(s.db, models.JobKindReport, payload, 0)
+}
+
+func (s *ReportScheduleService) GetRuns(scheduleID int64, paginate map[string]interface{}) ([]models.ReportRun, models.PaginationResult, error) {
+	s, span := s.startSpan("GetRuns")
+	defer span.End()
+
+	var runs []models.ReportRun
+	model := s.db.Model(&models.ReportRun{}).Where("schedule_id = ?", scheduleID)
+	model, pagination := utils.DoPagination(model, paginate)
+	if err := model.Order("id DESC").Find(&runs).Error; err != nil {
+		return []models.ReportRun{}, pagination, err
+	}
+	return runs, pagination, nil
+}
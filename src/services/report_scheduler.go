@@ -0,0 +1,223 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/config"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/logging"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+	"gorm.io/gorm"
+)
+
+// 检查到期计划的间隔，cron 的最小粒度为分钟
+const reportSchedulerInterval = 30 * time.Second
+
+// 由配置设置的字体、投递目录和 SMTP
+var reportOptions = config.Default().Reports
+
+// 未配置 REPORT_FONT_PATH 时不生成 PDF：内置字体无法显示中文
+var ErrReportFontRequired = errors.New("REPORT_FONT_PATH is required for pdf reports")
+
+func ConfigureReports(cfg config.ReportsConfig) {
+	reportOptions = cfg
+	if cfg.FontPath == "" {
+		slog.Warn("REPORT_FONT_PATH is not set, report schedules with pdf format are rejected")
+	}
+}
+
+// 计划包含 pdf 格式时要求已配置字体
+func checkReportFormats(schedule *models.ReportSchedule) error {
+	for _, format := range schedule.FormatList() {
+		if format == models.ExportFormatPDF && reportOptions.FontPath == "" {
+			return ErrReportFontRequired
+		}
+	}
+	return nil
+}
+
+// ReportScheduler 定期将到期的计划加入后台任务队列，报表由任务工作协程生成和投递
+type ReportScheduler struct {
+	db     *gorm.DB
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func StartReportScheduler(db *gorm.DB) *ReportScheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &ReportScheduler{db: db, cancel: cancel}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(reportSchedulerInterval)
+		defer ticker.Stop()
+		for {
+			if _, err := DispatchDueReports(db.WithContext(ctx), time.Now()); err != nil && ctx.Err() == nil {
+				slog.Error("dispatch scheduled reports failed", "error", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return s
+}
+
+func (s *ReportScheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// 将 NextRunAt 不晚于 now 的计划加入队列并计算下一次执行时间，返回加入的数量
+// 以 NextRunAt 为条件更新，多个实例同时检查时每次只有一个实例加入；停机期间错过的多次执行只补一次
+func DispatchDueReports(db *gorm.DB, now time.Time) (int, error) {
+	var schedules []models.ReportSchedule
+	if err := db.Where("enabled = ? AND next_run_at <= ?", true, utils.InDB(now)).Find(&schedules).Error; err != nil {
+		return 0, err
+	}
+	dispatched := 0
+	for _, schedule := range schedules {
+		cronSchedule, err := schedule.CronSchedule()
+		if err != nil {
+			slog.Error("invalid report schedule", "schedule_id", schedule.ID, "error", err)
+			continue
+		}
+		next := cronSchedule.Next(now.In(utils.PlantLocation()))
+		result := db.Model(&models.ReportSchedule{}).
+			Where("id = ? AND next_run_at = ?", schedule.ID, utils.InDB(*schedule.NextRunAt)).
+			Updates(map[string]interface{}{"next_run_at": utils.InDB(next), "last_run_at": utils.InDB(now)})
+		if result.Error != nil {
+			return dispatched, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		payload := reportJobPayload{ScheduleID: schedule.ID, Trigger: models.ReportTriggerSchedule, ScheduledAt: *schedule.NextRunAt}
+		if _, err := enqueueJob(db, models.JobKindReport, payload, 0); err != nil {
+			return dispatched, err
+		}
+		dispatched++
+	}
+	return dispatched, nil
+}
+
+// 定时报表任务的参数；ScheduledAt 决定报表区间，重试时区间不变
+type reportJobPayload struct {
+	ScheduleID  int64     `json:"scheduleId"`
+	Trigger     string    `json:"trigger"`
+	ScheduledAt time.Time `json:"scheduledAt"`
+}
+
+// 报表区间（工厂时区）：检测日报为前一天，供应商排名为截至前一天的 7 天
+func reportPeriod(report string, at time.Time) (string, string) {
+	day := at.In(utils.PlantLocation())
+	end := time.Date(day.Year(), day.Month(), day.Day()-1, 0, 0, 0, 0, day.Location())
+	start := end
+	if report == models.ReportWeeklySupplierRanking {
+		start = end.AddDate(0, 0, -6)
+	}
+	return start.Format(utils.DateLayout), end.Format(utils.DateLayout)
+}
+
+var reportContentTypes = map[string]string{
+	models.ExportFormatPDF:  "application/pdf",
+	models.ExportFormatXLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// 生成报表并投递，每次执行记录一条 ReportRun
+func runReport(ctx context.Context, run *JobRun) error {
+	var payload reportJobPayload
+	if err := run.Decode(&payload); err != nil {
+		return err
+	}
+	db := run.DB()
+	var schedule models.ReportSchedule
+	if err := db.First(&schedule, payload.ScheduleID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return PermanentJobError(fmt.Errorf("report schedule %d not found", payload.ScheduleID))
+		}
+		return err
+	}
+
+	startDate, endDate := reportPeriod(schedule.Report, payload.ScheduledAt)
+	record := models.ReportRun{
+		ScheduleID:  schedule.ID,
+		JobID:       run.Job.ID,
+		Trigger:     payload.Trigger,
+		Status:      models.ReportRunRunning,
+		PeriodStart: startDate,
+		PeriodEnd:   endDate,
+		StartedAt:   time.Now(),
+	}
+	if err := db.Create(&record).Error; err != nil {
+		return err
+	}
+
+	files, err := deliverReport(ctx, db, &schedule, startDate, endDate)
+	now := time.Now()
+	updates := map[string]interface{}{"status": models.ReportRunSucceeded, "files": strings.Join(files, ","), "finished_at": utils.InDB(now)}
+	if err != nil {
+		updates["status"] = models.ReportRunFailed
+		updates["error"] = err.Error()
+	}
+	// 任务取消时 ctx 已取消，执行记录仍需写入
+	if updateErr := db.WithContext(context.WithoutCancel(ctx)).Model(&record).Updates(updates).Error; updateErr != nil {
+		logging.FromContext(ctx).Error("update report run failed", "error", updateErr)
+	}
+	return err
+}
+
+func deliverReport(ctx context.Context, db *gorm.DB, schedule *models.ReportSchedule, startDate, endDate string) ([]string, error) {
+	delivery, ok := reportDelivery(schedule.Channel)
+	if !ok {
+		return nil, PermanentJobError(fmt.Errorf("unknown delivery channel %q", schedule.Channel))
+	}
+
+	exporter := &ReportExportService{db: db}
+	var table *models.ReportTable
+	var err error
+	switch schedule.Report {
+	case models.ReportDailyInspection:
+		table, err = exporter.InspectionReportTable(&models.InspectionReportQuery{StartDate: startDate, EndDate: endDate, SupplierName: schedule.SupplierName})
+	case models.ReportWeeklySupplierRanking:
+		table, err = exporter.SupplierRankingTable(startDate, endDate, schedule.SupplierName)
+	default:
+		err = PermanentJobError(fmt.Errorf("unknown report %q", schedule.Report))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	message := &ReportDeliveryMessage{
+		Schedule:    schedule,
+		Subject:     fmt.Sprintf("%s %s", schedule.Name, startDate),
+		PeriodStart: startDate,
+		PeriodEnd:   endDate,
+	}
+	if startDate != endDate {
+		message.Subject = fmt.Sprintf("%s %s ~ %s", schedule.Name, startDate, endDate)
+	}
+	var files []string
+	for _, format := range schedule.FormatList() {
+		var buf bytes.Buffer
+		if err := exporter.Write(&buf, table, format); err != nil {
+			return nil, fmt.Errorf("render %s: %w", format, err)
+		}
+		name := table.FileName(startDate, endDate, format)
+		message.Attachments = append(message.Attachments, ReportAttachment{Name: name, ContentType: reportContentTypes[format], Data: buf.Bytes()})
+		files = append(files, name)
+	}
+	if err := delivery.Deliver(ctx, message); err != nil {
+		return files, fmt.Errorf("deliver via %s: %w", schedule.Channel, err)
+	}
+	return files, nil
+}
@@ -0,0 +1,343 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrSamplingPlanExists = errors.New("sampling plan already exists")
+	ErrLotSizeRequired    = errors.New("lotSize is required for the first sampling query of a batch")
+)
+
+// 抽样方案列表可筛选、排序的字段
+var samplingPlanFields = utils.Fields{
+	"id":              {Column: "sampling_plans.id", Type: utils.FieldInt, Sort: true},
+	"createdAt":       {Column: "sampling_plans.created_at", Type: utils.FieldTime, Sort: true},
+	"updatedAt":       {Column: "sampling_plans.updated_at", Type: utils.FieldTime, Sort: true},
+	"name":            {Column: "sampling_plans.name", Type: utils.FieldString, Sort: true},
+	"supplierId":      {Column: "sampling_plans.supplier_id", Type: utils.FieldInt},
+	"productModelId":  {Column: "sampling_plans.product_model_id", Type: utils.FieldInt},
+	"inspectionLevel": {Column: "sampling_plans.inspection_level", Type: utils.FieldString},
+	"aql":             {Column: "sampling_plans.aql", Type: utils.FieldFloat, Sort: true},
+	"enabled":         {Column: "sampling_plans.enabled", Type: utils.FieldBool},
+	"severity":        {Column: "sampling_plans.severity", Type: utils.FieldString, Sort: true},
+}
+
+var samplingPlanKeyset = utils.KeysetColumns{Field: "createdAt", CreatedAt: "sampling_plans.created_at", ID: "sampling_plans.id"}
+
+type SamplingService struct {
+	db *gorm.DB
+}
+
+func NewSamplingService(db *gorm.DB) (ISamplingService, error) {
+	return &SamplingService{db: db}, nil
+}
+
+func (s *SamplingService) WithContext(ctx context.Context) ISamplingService {
+	return &SamplingService{db: s.db.WithContext(ctx)}
+}
+
+func (s *SamplingService) startSpan(method string) (*SamplingService, trace.Span) {
+	db, span := serviceSpan(s.db, "SamplingService."+method)
+	return &SamplingService{db: db}, span
+}
+
+// 每个物料、每个供应商各只有一个抽样方案，已存在时返回 ErrSamplingPlanExists
+func (s *SamplingService) CreatePlan(plan *models.SamplingPlan) error {
+	s, span := s.startSpan("CreatePlan")
+	defer span.End()
+
+	if err := s.checkDuplicate(plan); err != nil {
+		return err
+	}
+	plan.Severity = models.SamplingNormal
+	return s.db.Create(plan).Error
+}
+
+func (s *SamplingService) GetPlan(id int64) (*models.SamplingPlan, error) {
+	s, span := s.startSpan("GetPlan")
+	defer span.End()
+
+	var plan models.SamplingPlan
+	err := s.db.Preload("Supplier").Preload("ProductModel").First(&plan, id).Error
+	return &plan, err
+}
+
+func (s *SamplingService) GetPlans(query map[string]interface{}, paginate map[string]interface{}, sqlHandler ...func(*gorm.DB) *gorm.DB) ([]models.SamplingPlan, models.PaginationResult, error) {
+	s, span := s.startSpan("GetPlans")
+	defer span.End()
+
+	var plans []models.SamplingPlan
+	page, err := utils.ParsePage(paginate, samplingPlanFields, samplingPlanKeyset)
+	if err != nil {
+		return []models.SamplingPlan{}, models.PaginationResult{}, err
+	}
+	var model = s.db.Model(&models.SamplingPlan{}).Preload("Supplier").Preload("ProductModel")
+
+	for _, handler := range sqlHandler {
+		model = handler(model)
+	}
+	model = model.Where(query)
+
+	model, pagination, err := page.Apply(model)
+	if err != nil {
+		return []models.SamplingPlan{}, pagination, err
+	}
+
+	result := model.Find(&plans)
+	if result.Error != nil {
+		return []models.SamplingPlan{}, pagination, result.Error
+	}
+
+	plans = plans[:page.Next(&pagination, len(plans), func(i int) (time.Time, int64) {
+		return plans[i].CreatedAt, plans[i].ID
+	})]
+	return plans, pagination, nil
+}
+
+// merged 为合并修改后的方案，用于检查是否与其他方案重复
+func (s *SamplingService) UpdatePlan(planInstance *models.SamplingPlan, merged *models.SamplingPlan, plan map[string]interface{}) error {
+	s, span := s.startSpan("UpdatePlan")
+	defer span.End()
+
+	if err := s.checkDuplicate(merged); err != nil {
+		return err
+	}
+	return s.db.Model(planInstance).Updates(plan).Error
+}
+
+func (s *SamplingService) DeletePlans(ids []int64) error {
+	s, span := s.startSpan("DeletePlans")
+	defer span.End()
+
+	return s.db.Delete(&models.SamplingPlan{}, ids).Error
+}
+
+// 人工设置严格度（例如暂停抽样后恢复正常检验），并清零转移规则的状态
+func (s *SamplingService) ResetPlan(id int64, severity string) (*models.SamplingPlan, error) {
+	s, span := s.startSpan("ResetPlan")
+	defer span.End()
+
+	plan, err := s.GetPlan(id)
+	if err != nil {
+		return nil, err
+	}
+	plan.Severity = severity
+	plan.SwitchingScore, plan.RecentResults, plan.ConsecutiveAccepted, plan.TightenedRejections = 0, "", 0, 0
+	if err := saveSwitching(s.db, plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// 批次的抽样方案，首次查询时按适用方案的当前严格度和批量生成；没有适用的方案时返回 nil（全检）
+func (s *SamplingService) Instruct(productModel *models.ProductModel, batchNumber string, lotSize int) (*models.BatchSampling, error) {
+	s, span := s.startSpan("Instruct")
+	defer span.End()
+
+	var sampling *models.BatchSampling
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		modelID := uint(productModel.ID)
+		if err := ensureBatch(tx, &models.Product{ProductModelID: &modelID, BatchNumber: batchNumber}); err != nil {
+			return err
+		}
+		batch, err := findBatch(tx, modelID, batchNumber)
+		if err != nil {
+			return err
+		}
+		existing, err := findBatchSampling(tx, batch.ID)
+		if err != nil || existing != nil {
+			sampling = existing
+			return err
+		}
+
+		plan, err := applicablePlan(tx, productModel)
+		if err != nil || plan == nil {
+			return err
+		}
+		if lotSize <= 0 {
+			return ErrLotSizeRequired
+		}
+		scheme := plan.Scheme(lotSize)
+		created := models.BatchSampling{
+			BatchID: batch.ID, PlanID: plan.ID, Severity: plan.Severity, LotSize: lotSize,
+			CodeLetter: scheme.CodeLetter, SampleSize: scheme.SampleSize, Ac: scheme.Ac, Re: scheme.Re,
+			Result: models.SamplingPending,
+		}
+		// 并发查询同一批次时以唯一索引去重
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&created).Error; err != nil {
+			return err
+		}
+		sampling, err = findBatchSampling(tx, batch.ID)
+		if err != nil {
+			return err
+		}
+		// 生成前已检测的产品计入样本
+		return recordSampling(tx, batch, sampling)
+	})
+	if err != nil || sampling == nil {
+		return nil, err
+	}
+	return s.GetBatchSampling(sampling.BatchID)
+}
+
+// 批次的抽样进度，未生成抽样方案时返回 gorm.ErrRecordNotFound
+func (s *SamplingService) GetBatchSampling(batchID int64) (*models.BatchSampling, error) {
+	s, span := s.startSpan("GetBatchSampling")
+	defer span.End()
+
+	var sampling models.BatchSampling
+	if err := s.db.Preload("Batch").Preload("Plan").Where("batch_id = ?", batchID).Take(&sampling).Error; err != nil {
+		return nil, err
+	}
+	setRemaining(&sampling)
+	return &sampling, nil
+}
+
+func (s *SamplingService) checkDuplicate(plan *models.SamplingPlan) error {
+	model := s.db.Model(&models.SamplingPlan{}).Where("id <> ?", plan.ID)
+	if plan.ProductModelID != nil {
+		model = model.Where("product_model_id = ?", *plan.ProductModelID)
+	} else {
+		model = model.Where("supplier_id = ?", *plan.SupplierID)
+	}
+	var count int64
+	if err := model.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrSamplingPlanExists
+	}
+	return nil
+}
+
+func saveSwitching(tx *gorm.DB, plan *models.SamplingPlan) error {
+	return tx.Model(&models.SamplingPlan{}).Where("id = ?", plan.ID).Updates(map[string]interface{}{
+		"severity":             plan.Severity,
+		"switching_score":      plan.SwitchingScore,
+		"recent_results":       plan.RecentResults,
+		"consecutive_accepted": plan.ConsecutiveAccepted,
+		"tightened_rejections": plan.TightenedRejections,
+	}).Error
+}
+
+// 启用的物料方案优先，其次为物料所属供应商的方案
+func applicablePlan(tx *gorm.DB, productModel *models.ProductModel) (*models.SamplingPlan, error) {
+	var plans []models.SamplingPlan
+	model := tx.Where("enabled = ?", true)
+	if productModel.SupplierID != nil {
+		model = model.Where("product_model_id = ? OR supplier_id = ?", productModel.ID, *productModel.SupplierID)
+	} else {
+		model = model.Where("product_model_id = ?", productModel.ID)
+	}
+	if err := model.Find(&plans).Error; err != nil {
+		return nil, err
+	}
+	var plan *models.SamplingPlan
+	for i := range plans {
+		if plan == nil || plans[i].ProductModelID != nil {
+			plan = &plans[i]
+		}
+	}
+	return plan, nil
+}
+
+func findBatchSampling(tx *gorm.DB, batchID int64) (*models.BatchSampling, error) {
+	var sampling models.BatchSampling
+	err := tx.Where("batch_id = ?", batchID).Take(&sampling).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sampling, nil
+}
+
+func setRemaining(sampling *models.BatchSampling) {
+	sampling.Remaining = 0
+	if sampling.Result == models.SamplingPending {
+		sampling.Remaining = max(sampling.SampleSize-sampling.Inspected, 0)
+	}
+}
+
+// 产品记录写入后更新所属批次的抽样进度，与产品在同一事务中执行
+func recordSample(tx *gorm.DB, product *models.Product) error {
+	if product.ProductModelID == nil || product.BatchNumber == "" {
+		return nil
+	}
+	batch, err := findBatch(tx, *product.ProductModelID, product.BatchNumber)
+	if err != nil || batch == nil {
+		return err
+	}
+	sampling, err := findBatchSampling(tx, batch.ID)
+	if err != nil || sampling == nil || sampling.Result != models.SamplingPending {
+		return err
+	}
+	return recordSampling(tx, batch, sampling)
+}
+
+// 按批次已检测的不重复 SN 统计样本，不良数达到拒收数时判退，样本检测完毕时接收；
+// 判定后更新方案的转移规则状态，批次仍为未判定状态时自动接收或判退
+func recordSampling(tx *gorm.DB, batch *models.Batch, sampling *models.BatchSampling) error {
+	var inspected, defects int64
+	products := func() *gorm.DB {
+		return tx.Model(&models.Product{}).Where("product_model_id = ? AND batch_number = ?", batch.ProductModelID, batch.BatchNumber)
+	}
+	if err := products().Distinct("sn").Count(&inspected).Error; err != nil {
+		return err
+	}
+	if err := products().Where("has_defect = ?", true).Distinct("sn").Count(&defects).Error; err != nil {
+		return err
+	}
+	sampling.Inspected, sampling.Defects = int(inspected), int(defects)
+
+	updates := map[string]interface{}{"inspected": sampling.Inspected, "defects": sampling.Defects}
+	switch {
+	case sampling.Defects >= sampling.Re:
+		sampling.Result = models.SamplingRejected
+	case sampling.Inspected >= sampling.SampleSize:
+		sampling.Result = models.SamplingAccepted
+	}
+	if sampling.Result != models.SamplingPending {
+		now := time.Now()
+		sampling.DecidedAt = &now
+		updates["result"], updates["decided_at"] = sampling.Result, now
+	}
+	// 以未判定为条件，并发上报时只判定一次
+	result := tx.Model(&models.BatchSampling{}).Where("id = ? AND result = ?", sampling.ID, models.SamplingPending).Updates(updates)
+	if result.Error != nil || result.RowsAffected == 0 || sampling.Result == models.SamplingPending {
+		return result.Error
+	}
+
+	// 方案的严格度在抽样后被人工修改时不再按本批结果转移
+	var plan models.SamplingPlan
+	if err := tx.First(&plan, sampling.PlanID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	} else if err == nil && plan.Severity == sampling.Severity {
+		plan.ApplyResult(sampling)
+		if err := saveSwitching(tx, &plan); err != nil {
+			return err
+		}
+	}
+
+	if batch.Status != models.BatchStatusOpen {
+		return nil
+	}
+	action := models.BatchActionAccept
+	if sampling.Result == models.SamplingRejected {
+		action = models.BatchActionReject
+	}
+	note := fmt.Sprintf("AQL %s sampling: code %s, n=%d, Ac=%d, Re=%d, %d defects in %d inspected",
+		sampling.Severity, sampling.CodeLetter, sampling.SampleSize, sampling.Ac, sampling.Re, sampling.Defects, sampling.Inspected)
+	return transitionBatch(tx, batch.ID, action, note, models.SamplingOperator)
+}
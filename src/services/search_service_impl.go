@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/search"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+type SearchService struct {
+	db *gorm.DB
+}
+
+func NewSearchService(db *gorm.DB) (ISearchService, error) {
+	return &SearchService{db: db}, nil
+}
+
+func (s *SearchService) WithContext(ctx context.Context) ISearchService {
+	return &SearchService{db: s.db.WithContext(ctx)}
+}
+
+func (s *SearchService) startSpan(method string) (*SearchService, trace.Span) {
+	db, span := serviceSpan(s.db, "SearchService."+method)
+	return &SearchService{db: db}, span
+}
+
+// 候选记录中的一条，fields 为被索引的字段名和值
+type searchRecord struct {
+	id       int64
+	title    string
+	subtitle string
+	fields   [][2]string
+	data     interface{}
+}
+
+// 按类型加载候选记录
+var searchLoaders = map[string]func(db *gorm.DB, ids []int64) ([]searchRecord, error){
+	models.SearchTypeProduct: func(db *gorm.DB, ids []int64) ([]searchRecord, error) {
+		var products []models.Product
+		if err := db.Preload("ProductModel").Preload("Pallet").Find(&products, ids).Error; err != nil {
+			return nil, err
+		}
+		records := make([]searchRecord, len(products))
+		for i, p := range products {
+			var subtitle []string
+			if p.ProductModel != nil {
+				subtitle = append(subtitle, p.ProductModel.SN)
+			}
+			if p.Pallet != nil {
+				subtitle = append(subtitle, p.Pallet.SN)
+			}
+			records[i] = searchRecord{id: p.ID, title: p.SN, subtitle: strings.Join(subtitle, " / "), data: products[i],
+				fields: [][2]string{{"sn", p.SN}, {"batchNumber", p.BatchNumber}}}
+		}
+		return records, nil
+	},
+	models.SearchTypePallet: func(db *gorm.DB, ids []int64) ([]searchRecord, error) {
+		var pallets []models.Pallet
+		if err := db.Preload("ProductModel").Find(&pallets, ids).Error; err != nil {
+			return nil, err
+		}
+		records := make([]searchRecord, len(pallets))
+		for i, p := range pallets {
+			var subtitle string
+			if p.ProductModel != nil {
+				subtitle = p.ProductModel.SN
+			}
+			records[i] = searchRecord{id: p.ID, title: p.SN, subtitle: subtitle, data: pallets[i],
+				fields: [][2]string{{"sn", p.SN}}}
+		}
+		return records, nil
+	},
+	models.SearchTypeModel: func(db *gorm.DB, ids []int64) ([]searchRecord, error) {
+		var productModels []models.ProductModel
+		if err := db.Preload("Supplier").Find(&productModels, ids).Error; err != nil {
+			return nil, err
+		}
+		records := make([]searchRecord, len(productModels))
+		for i, m := range productModels {
+			records[i] = searchRecord{id: m.ID, title: m.SN, subtitle: m.Description, data: productModels[i],
+				fields: [][2]string{{"sn", m.SN}, {"partNumber", m.PartNumber}, {"description", m.Description}}}
+		}
+		return records, nil
+	},
+	models.SearchTypeSupplier: func(db *gorm.DB, ids []int64) ([]searchRecord, error) {
+		var suppliers []models.Supplier
+		if err := db.Find(&suppliers, ids).Error; err != nil {
+			return nil, err
+		}
+		records := make([]searchRecord, len(suppliers))
+		for i, s := range suppliers {
+			records[i] = searchRecord{id: s.ID, title: s.Name, subtitle: s.SAP, data: suppliers[i],
+				fields: [][2]string{{"name", s.Name}, {"sap", s.SAP}}}
+		}
+		return records, nil
+	},
+	models.SearchTypePlan: func(db *gorm.DB, ids []int64) ([]searchRecord, error) {
+		var plans []models.ProductionPlan
+		if err := db.Find(&plans, ids).Error; err != nil {
+			return nil, err
+		}
+		records := make([]searchRecord, len(plans))
+		for i, p := range plans {
+			records[i] = searchRecord{id: p.ID, title: p.MaterialCode, subtitle: strings.TrimSpace(p.PartNumber + " " + p.Manufacturer), data: plans[i],
+				fields: [][2]string{{"materialCode", p.MaterialCode}, {"partNumber", p.PartNumber}, {"manufacturer", p.Manufacturer}}}
+		}
+		return records, nil
+	},
+}
+
+var searchMatchRank = map[string]int{models.SearchMatchExact: 0, models.SearchMatchPrefix: 1, models.SearchMatchContains: 2}
+
+// 从 n-gram 索引取各类型的候选记录，按原文本确认命中的字段后排序：
+// 完全匹配、前缀匹配、包含，同等匹配程度按类型顺序和创建先后（新的在前）
+func (s *SearchService) Search(query *models.SearchQuery) ([]models.SearchHit, error) {
+	s, span := s.startSpan("Search")
+	defer span.End()
+
+	keyword := strings.ToLower(query.Q)
+	typeRank := make(map[string]int)
+	hits := []models.SearchHit{}
+	for _, entityType := range query.TypeList() {
+		typeRank[entityType] = len(typeRank)
+		// 多取一些候选记录，弥补 n-gram 误命中被排除的部分
+		ids, err := search.Candidates(s.db, entityType, query.Q, query.Limit*2)
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		records, err := searchLoaders[entityType](s.db, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			if hit, ok := matchRecord(entityType, keyword, record); ok {
+				hits = append(hits, hit)
+			}
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		if searchMatchRank[a.Match] != searchMatchRank[b.Match] {
+			return searchMatchRank[a.Match] < searchMatchRank[b.Match]
+		}
+		if a.Type != b.Type {
+			return typeRank[a.Type] < typeRank[b.Type]
+		}
+		return a.ID > b.ID
+	})
+	if len(hits) > query.Limit {
+		hits = hits[:query.Limit]
+	}
+	return hits, nil
+}
+
+// 取匹配程度最高的字段
+func matchRecord(entityType, keyword string, record searchRecord) (models.SearchHit, bool) {
+	hit := models.SearchHit{Type: entityType, ID: record.id, Title: record.title, Subtitle: record.subtitle, Data: record.data}
+	best := len(searchMatchRank)
+	for _, field := range record.fields {
+		value := strings.ToLower(strings.TrimSpace(field[1]))
+		var match string
+		switch {
+		case value == keyword:
+			match = models.SearchMatchExact
+		case strings.HasPrefix(value, keyword):
+			match = models.SearchMatchPrefix
+		case strings.Contains(value, keyword):
+			match = models.SearchMatchContains
+		default:
+			continue
+		}
+		if rank := searchMatchRank[match]; rank < best {
+			best, hit.Field, hit.Match = rank, field[0], match
+		}
+	}
+	return hit, hit.Match != ""
+}
+
+// 重建全部搜索索引
+func (s *SearchService) Reindex(progress func(done, total int64) error) error {
+	s, span := s.startSpan("Reindex")
+	defer span.End()
+
+	return search.Reindex(s.db, progress)
+}
+
+// 索引为空而已有数据时（首次部署），加入重建索引的后台任务
+func (s *SearchService) Backfill() (*models.Job, error) {
+	s, span := s.startSpan("Backfill")
+	defer span.End()
+
+	var gram models.SearchGram
+	err := s.db.Take(&gram).Error
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	var pending int64
+	err = s.db.Model(&models.Job{}).
+		Where("kind = ? AND status IN ?", models.JobKindSearchReindex, []string{models.JobStatusQueued, models.JobStatusRunning}).
+		Count(&pending).Error
+	if err != nil || pending > 0 {
+		return nil, err
+	}
+	for _, entity := range search.Entities {
+		var id int64
+		err := s.db.Table(entity.Table).Where("deleted_at IS NULL").Limit(1).Pluck("id", &id).Error
+		if err != nil {
+			return nil, err
+		}
+		if id > 0 {
+			return enqueueJob(s.db, models.JobKindSearchReindex, struct{}{}, 0)
+		}
+	}
+	return nil, nil
+}
+
+func runSearchReindex(ctx context.Context, run *JobRun) error {
+	return search.Reindex(run.DB(), func(done, total int64) error {
+		run.Progress(done, total)
+		return ctx.Err()
+	})
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+type ShiftCalendarService struct {
+	db *gorm.DB
+}
+
+func NewShiftCalendarService(db *gorm.DB) (IShiftCalendarService, error) {
+	return &ShiftCalendarService{db: db}, nil
+}
+
+func (s *ShiftCalendarService) WithContext(ctx context.Context) IShiftCalendarService {
+	return &ShiftCalendarService{db: s.db.WithContext(ctx)}
+}
+
+func (s *ShiftCalendarService) startSpan(method string) (*ShiftCalendarService, trace.Span) {
+	db, span := serviceSpan(s.db, "ShiftCalendarService."+method)
+	return &ShiftCalendarService{db: db}, span
+}
+
+func (s *ShiftCalendarService) CreateShift(shift *models.Shift) error {
+	s, span := s.startSpan("CreateShift")
+	defer span.End()
+
+	if err := shift.Validate(); err != nil {
+		return err
+	}
+	return s.db.Create(shift).Error
+}
+
+func (s *ShiftCalendarService) GetShift(id int64) (*models.Shift, error) {
+	s, span := s.startSpan("GetShift")
+	defer span.End()
+
+	var shift models.Shift
+	err := s.db.First(&shift, id).Error
+	return &shift, err
+}
+
+func (s *ShiftCalendarService) GetShifts(query map[string]interface{}) ([]models.Shift, error) {
+	s, span := s.startSpan("GetShifts")
+	defer span.End()
+
+	var shifts []models.Shift
+	err := s.db.Preload("ProductLine").Where(query).Order("product_line_id ASC, sequence ASC").Find(&shifts).Error
+	return shifts, err
+}
+
+func (s *ShiftCalendarService) UpdateShift(shiftInstance *models.Shift, shift map[string]interface{}) error {
+	s, span := s.startSpan("UpdateShift")
+	defer span.End()
+
+	return s.db.Model(shiftInstance).Updates(shift).Error
+}
+
+func (s *ShiftCalendarService) DeleteShifts(ids []int64) error {
+	s, span := s.startSpan("DeleteShifts")
+	defer span.End()
+
+	return s.db.Delete(&models.Shift{}, ids).Error
+}
+
+func (s *ShiftCalendarService) CreateHoliday(holiday *models.Holiday) error {
+	s, span := s.startSpan("CreateHoliday")
+	defer span.End()
+
+	return s.db.Create(holiday).Error
+}
+
+func (s *ShiftCalendarService) GetHolidays(query map[string]interface{}) ([]models.Holiday, error) {
+	s, span := s.startSpan("GetHolidays")
+	defer span.End()
+
+	var holidays []models.Holiday
+	err := s.db.Where(query).Order("date ASC").Find(&holidays).Error
+	return holidays, err
+}
+
+func (s *ShiftCalendarService) DeleteHolidays(ids []int64) error {
+	s, span := s.startSpan("DeleteHolidays")
+	defer span.End()
+
+	return s.db.Delete(&models.Holiday{}, ids).Error
+}
+
+// 加载完整的班次日历（全部产线的班次、假日以及工厂时区）
+func (s *ShiftCalendarService) LoadCalendar() (*models.ShiftCalendar, error) {
+	s, span := s.startSpan("LoadCalendar")
+	defer span.End()
+
+	var shifts []models.Shift
+	if err := s.db.Find(&shifts).Error; err != nil {
+		return nil, err
+	}
+
+	var holidays []models.Holiday
+	if err := s.db.Find(&holidays).Error; err != nil {
+		return nil, err
+	}
+
+	return models.NewShiftCalendar(utils.PlantLocation(), shifts, holidays)
+}
@@ -1,11 +1,27 @@
 package services
 
 import (
+	"context"
+	"time"
+
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 )
 
+// 供应商列表可筛选、排序的字段
+var supplierFields = utils.Fields{
+	"id":        {Column: "suppliers.id", Type: utils.FieldInt, Sort: true},
+	"createdAt": {Column: "suppliers.created_at", Type: utils.FieldTime, Sort: true},
+	"updatedAt": {Column: "suppliers.updated_at", Type: utils.FieldTime, Sort: true},
+	"name":      {Column: "suppliers.name", Type: utils.FieldString, Sort: true},
+	"sap":       {Column: "suppliers.sap", Type: utils.FieldString, Sort: true},
+	"type":      {Column: "suppliers.type", Type: utils.FieldString, Sort: true},
+}
+
+var supplierKeyset = utils.KeysetColumns{Field: "createdAt", CreatedAt: "suppliers.created_at", ID: "suppliers.id"}
+
 type SupplierService struct {
 	db *gorm.DB
 }
@@ -14,19 +30,40 @@ func NewSupplierService(db *gorm.DB) (ISupplierService, error) {
 	return &SupplierService{db: db}, nil
 }
 
+func (s *SupplierService) WithContext(ctx context.Context) ISupplierService {
+	return &SupplierService{db: s.db.WithContext(ctx)}
+}
+
+func (s *SupplierService) startSpan(method string) (*SupplierService, trace.Span) {
+	db, span := serviceSpan(s.db, "SupplierService."+method)
+	return &SupplierService{db: db}, span
+}
+
 func (s *SupplierService) CreateSupplier(supplier *models.Supplier) error {
+	s, span := s.startSpan("CreateSupplier")
+	defer span.End()
+
 	return s.db.Create(supplier).Error
 }
 
 func (s *SupplierService) GetSupplier(id int64) (*models.Supplier, error) {
+	s, span := s.startSpan("GetSupplier")
+	defer span.End()
+
 	var supplier models.Supplier
 	err := s.db.First(&supplier, id).Error
 	return &supplier, err
 }
 
 func (s *SupplierService) GetSuppliers(query map[string]interface{}, paginate map[string]interface{}, sqlHandler ...func(*gorm.DB) *gorm.DB) ([]models.Supplier, models.PaginationResult, error) {
+	s, span := s.startSpan("GetSuppliers")
+	defer span.End()
+
 	var suppliers []models.Supplier
-	var pagination models.PaginationResult
+	page, err := utils.ParsePage(paginate, supplierFields, supplierKeyset)
+	if err != nil {
+		return []models.Supplier{}, models.PaginationResult{}, err
+	}
 	var model = s.db.Model(&models.Supplier{})
 
 	for _, handler := range sqlHandler {
@@ -34,23 +71,34 @@ func (s *SupplierService) GetSuppliers(query map[string]interface{}, paginate ma
 	}
 	model = model.Where(query)
 
-	model, pagination = utils.DoPagination(model, paginate)
-	model = utils.DoOrder(model, paginate)
+	model, pagination, err := page.Apply(model)
+	if err != nil {
+		return []models.Supplier{}, pagination, err
+	}
 
 	result := model.Find(&suppliers)
 	if result.Error != nil {
 		return []models.Supplier{}, pagination, result.Error
 	}
 
+	suppliers = suppliers[:page.Next(&pagination, len(suppliers), func(i int) (time.Time, int64) {
+		return suppliers[i].CreatedAt, suppliers[i].ID
+	})]
 	return suppliers, pagination, nil
 }
 
 func (s *SupplierService) UpdateSupplier(supplierInstance *models.Supplier, supplier map[string]interface{}) error {
+	s, span := s.startSpan("UpdateSupplier")
+	defer span.End()
+
 	result := s.db.Model(supplierInstance).Updates(supplier)
 	return result.Error
 }
 
 func (s *SupplierService) DeleteSuppliers(ids []int64) error {
+	s, span := s.startSpan("DeleteSuppliers")
+	defer span.End()
+
 	result := s.db.Delete(&models.Supplier{}, ids)
 	return result.Error
 }
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/databases"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+	"gorm.io/gorm"
+)
+
+const hourBucketLayout = "2006-01-02 15:04:05"
+
+// 将时间列截断到整点的 SQL 表达式，结果为 hourBucketLayout 格式的字符串
+func hourBucketSQL(db *gorm.DB, column string) string {
+	return databases.DialectOf(db).HourBucket(column)
+}
+
+// 将时间列截断到 minutes 分钟的 SQL 表达式，60 分钟即整点，结果格式与 hourBucketSQL 相同
+func bucketSQL(db *gorm.DB, column string, minutes int) string {
+	if minutes >= 60 {
+		return hourBucketSQL(db, column)
+	}
+	return databases.DialectOf(db).MinuteBucket(column, minutes)
+}
+
+// 未限定区间时检查时区偏移的范围
+var bucketCheckSince = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// 能无损归并到各时区日期和小时的最大分桶分钟数（60 的约数）：[start, end) 内各时区与存储时区的偏移差都是整小时时为 60，
+// 小时预聚合可用；否则为偏移差与 60 的最大公约数（例如 Asia/Kolkata 为 30），需按该粒度分桶读取原始数据。
+// 班次时刻均为整点，不影响分桶；start、end 为 nil 时检查 2000 年至今后一年
+func bucketMinutes(start, end *time.Time, locations ...*time.Location) int {
+	minutes := 60
+	from, to := bucketCheckSince, time.Now().AddDate(1, 0, 0)
+	if start != nil {
+		from = *start
+	}
+	if end != nil {
+		to = *end
+	}
+	zones := append([]*time.Location{utils.DBLocation()}, locations...)
+	for current := from; current.Before(to); {
+		_, stored := current.In(utils.DBLocation()).Zone()
+		for _, location := range locations {
+			_, local := current.In(location).Zone()
+			minutes = gcd(minutes, (local-stored)/60)
+		}
+		next := zoneTransition(current, zones...)
+		if next.IsZero() {
+			break
+		}
+		current = next
+	}
+	return minutes
+}
+
+func gcd(a, b int) int {
+	if a < 0 {
+		a = -a
+	}
+	if b < 0 {
+		b = -b
+	}
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// 时间分桶器：将 SQL 返回的小时桶按粒度、分桶方式和班次日历归并为桶标签
+// location 为请求时区，自然日模式按该时区分桶；生产日和班次始终按工厂时区的班次日历计算
+type timeBucketer struct {
+	granularity string
+	bucketBy    string
+	calendar    *models.ShiftCalendar
+	location    *time.Location
+}
+
+// 是否需要按产线区分班次日历
+func (b *timeBucketer) needsProductLine() bool {
+	return b.granularity == models.GranularityShift || b.bucketBy == models.BucketByProduction
+}
+
+// 小时桶为数据库存储时区下的时间字符串
+func parseHourBucket(hourBucket string) (time.Time, error) {
+	t, err := time.ParseInLocation(hourBucketLayout, hourBucket, utils.DBLocation())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid hour bucket %q: %w", hourBucket, err)
+	}
+	return t, nil
+}
+
+// 计算某个小时桶所属的日期（自然日或生产日）
+func (b *timeBucketer) day(t time.Time, productLineID *uint) time.Time {
+	if b.bucketBy == models.BucketByProduction {
+		slot := b.calendar.Locate(t, productLineID)
+		day, _ := time.ParseInLocation("2006-01-02", slot.ProductionDay, b.calendar.Location)
+		return day
+	}
+	local := t.In(b.location)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, b.location)
+}
+
+func (b *timeBucketer) label(hourBucket string, productLineID *uint) (string, error) {
+	if b.granularity == models.GranularityNone {
+		return "", nil
+	}
+
+	t, err := parseHourBucket(hourBucket)
+	if err != nil {
+		return "", err
+	}
+	return b.labelTime(t, productLineID)
+}
+
+// 日预聚合的日期（工厂时区）归并到目标粒度，仅适用于按自然日分桶的 day/week/month 粒度
+func (b *timeBucketer) labelDay(day string) (string, error) {
+	if b.granularity == models.GranularityNone {
+		return "", nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", day, b.location)
+	if err != nil {
+		return "", fmt.Errorf("invalid rollup day %q: %w", day, err)
+	}
+	return b.labelTime(t, nil)
+}
+
+func (b *timeBucketer) labelTime(t time.Time, productLineID *uint) (string, error) {
+	switch b.granularity {
+	case models.GranularityHour:
+		return t.In(b.location).Format("2006-01-02 15:00"), nil
+	case models.GranularityShift:
+		// 班次总是归属于其所在的生产日
+		slot := b.calendar.Locate(t, productLineID)
+		return fmt.Sprintf("%s %s", slot.ProductionDay, slot.Shift), nil
+	case models.GranularityDay:
+		return b.day(t, productLineID).Format("2006-01-02"), nil
+	case models.GranularityWeek:
+		// 以周一作为周的起始日期
+		day := b.day(t, productLineID)
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset).Format("2006-01-02"), nil
+	case models.GranularityMonth:
+		return b.day(t, productLineID).Format("2006-01"), nil
+	}
+	return "", fmt.Errorf("invalid granularity %q", b.granularity)
+}
+
+// 小时桶所属的日期是否落在 [startDay, endDay] 内，用于生产日模式下裁剪查询窗口
+func (b *timeBucketer) inDayRange(hourBucket string, productLineID *uint, startDay, endDay string) (bool, error) {
+	t, err := parseHourBucket(hourBucket)
+	if err != nil {
+		return false, err
+	}
+	day := b.day(t, productLineID).Format("2006-01-02")
+	return day >= startDay && day <= endDay, nil
+}
+
+// 区间 [start, end) 内按 start 所在时区逐日生成的日期标签
+func dayLabels(start, end time.Time) []string {
+	var days []string
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	for ; day.Before(end); day = day.AddDate(0, 0, 1) {
+		days = append(days, day.Format("2006-01-02"))
+	}
+	return days
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+type TraceService struct {
+	db *gorm.DB
+}
+
+func NewTraceService(db *gorm.DB) (ITraceService, error) {
+	return &TraceService{db: db}, nil
+}
+
+func (s *TraceService) WithContext(ctx context.Context) ITraceService {
+	return &TraceService{db: s.db.WithContext(ctx)}
+}
+
+func (s *TraceService) startSpan(method string) (*TraceService, trace.Span) {
+	db, span := serviceSpan(s.db, "TraceService."+method)
+	return &TraceService{db: db}, span
+}
+
+// 序列号的全部检测记录及其物料、供应商、批次、产线、托盘、同托盘产品、生产计划和事件，
+// 没有检测记录时返回 gorm.ErrRecordNotFound
+func (s *TraceService) TraceProduct(sn string) (*models.ProductTrace, error) {
+	s, span := s.startSpan("TraceProduct")
+	defer span.End()
+
+	var attempts []models.Product
+	err := s.db.Unscoped().
+		Preload("ProductModel.Supplier").Preload("ProductLine").Preload("Pallet").Preload("ProductionPlan").
+		Where("sn = ?", sn).Order("created_at, id").Find(&attempts).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(attempts) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	result := &models.ProductTrace{SN: sn, Attempts: attempts, Product: attempts[len(attempts)-1]}
+	var ids []int64
+	var palletIDs []uint
+	for _, attempt := range attempts {
+		ids = append(ids, attempt.ID)
+		if attempt.PalletID != nil && *attempt.PalletID != 0 {
+			palletIDs = append(palletIDs, *attempt.PalletID)
+		}
+		// 最近一次未删除的记录
+		if !attempt.DeletedAt.Valid {
+			result.Product = attempt
+		}
+	}
+
+	// 各检测记录所属的批次
+	batches := make(map[string]*models.Batch)
+	for _, attempt := range attempts {
+		if attempt.ProductModelID == nil || attempt.BatchNumber == "" {
+			continue
+		}
+		key := batchNodeID(*attempt.ProductModelID, attempt.BatchNumber)
+		if _, ok := batches[key]; ok {
+			continue
+		}
+		if batches[key], err = findBatch(s.db, *attempt.ProductModelID, attempt.BatchNumber); err != nil {
+			return nil, err
+		}
+	}
+
+	result.Dispositions = []models.BatchDisposition{}
+	if latest := result.Product; latest.ProductModelID != nil && latest.BatchNumber != "" {
+		err := s.db.Model(&models.Product{}).
+			Where("product_model_id = ? AND batch_number = ?", *latest.ProductModelID, latest.BatchNumber).
+			Count(&result.BatchSize).Error
+		if err != nil {
+			return nil, err
+		}
+		if result.Batch = batches[batchNodeID(*latest.ProductModelID, latest.BatchNumber)]; result.Batch != nil {
+			if err := s.db.Where("batch_id = ?", result.Batch.ID).Order("id").Find(&result.Dispositions).Error; err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	result.PalletMates = []models.TraceSerial{}
+	if len(palletIDs) > 0 {
+		if result.PalletMates, err = s.serials(func(db *gorm.DB) *gorm.DB {
+			return db.Where("products.pallet_id IN ? AND products.sn <> ?", palletIDs, sn)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.db.Where("product_id IN ? OR sn = ?", ids, sn).Order("id").Find(&result.Events).Error; err != nil {
+		return nil, err
+	}
+	result.Nodes, result.Edges = traceGraph(attempts, batches, result.PalletMates)
+	return result, nil
+}
+
+// 同物料同批次的全部产品，productModelID 为空时匹配所有物料的该批次号
+func (s *TraceService) TraceBatch(batchNumber string, productModelID *uint) (models.TraceSerials, error) {
+	s, span := s.startSpan("TraceBatch")
+	defer span.End()
+
+	items, err := s.serials(func(db *gorm.DB) *gorm.DB {
+		db = db.Where("products.batch_number = ?", batchNumber)
+		if productModelID != nil {
+			db = db.Where("products.product_model_id = ?", *productModelID)
+		}
+		return db
+	})
+	return models.NewTraceSerials(items), err
+}
+
+// 托盘上的全部产品
+func (s *TraceService) TracePallet(palletID int64) (models.TraceSerials, error) {
+	s, span := s.startSpan("TracePallet")
+	defer span.End()
+
+	items, err := s.serials(func(db *gorm.DB) *gorm.DB {
+		return db.Where("products.pallet_id = ?", palletID)
+	})
+	return models.NewTraceSerials(items), err
+}
+
+// 未删除的产品记录，带物料编码和托盘 SN，按 ID 排序
+func (s *TraceService) serials(sqlHandler ...func(*gorm.DB) *gorm.DB) ([]models.TraceSerial, error) {
+	model := s.db.Table("products").
+		Select("products.id, products.sn, products.batch_number, products.product_model_id, product_models.sn AS model_sn, " +
+			"products.pallet_id, pallets.sn AS pallet_sn, products.has_defect, products.defect_reason, products.created_at").
+		Joins("LEFT JOIN product_models ON product_models.id = products.product_model_id").
+		Joins("LEFT JOIN pallets ON pallets.id = products.pallet_id").
+		Where("products.deleted_at IS NULL")
+	for _, handler := range sqlHandler {
+		model = handler(model)
+	}
+	items := []models.TraceSerial{}
+	err := model.Order("products.id").Scan(&items).Error
+	return items, err
+}
+
+// 由检测记录和同托盘产品生成追溯图，相同实体只生成一个节点
+func traceGraph(attempts []models.Product, batches map[string]*models.Batch, mates []models.TraceSerial) ([]models.TraceNode, []models.TraceEdge) {
+	nodes := []models.TraceNode{}
+	edges := []models.TraceEdge{}
+	seen := make(map[string]bool)
+	addNode := func(node models.TraceNode) string {
+		if !seen[node.ID] {
+			seen[node.ID] = true
+			nodes = append(nodes, node)
+		}
+		return node.ID
+	}
+	addEdge := func(from, to, relation string) {
+		edges = append(edges, models.TraceEdge{From: from, To: to, Relation: relation})
+	}
+	nodeID := func(nodeType string, id int64) string {
+		return fmt.Sprintf("%s:%d", nodeType, id)
+	}
+
+	for _, attempt := range attempts {
+		data := attempt
+		data.ProductModel, data.ProductLine, data.Pallet, data.ProductionPlan = nil, nil, nil, nil
+		product := addNode(models.TraceNode{ID: nodeID(models.TraceNodeProduct, attempt.ID), Type: models.TraceNodeProduct, Label: attempt.SN, Data: data})
+
+		if m := attempt.ProductModel; m != nil {
+			modelData := *m
+			modelData.Supplier = nil
+			model := addNode(models.TraceNode{ID: nodeID(models.TraceNodeModel, m.ID), Type: models.TraceNodeModel, Label: m.SN, Data: modelData})
+			addEdge(product, model, models.TraceNodeModel)
+			if supplier := m.Supplier; supplier != nil {
+				addEdge(model, addNode(models.TraceNode{ID: nodeID(models.TraceNodeSupplier, supplier.ID), Type: models.TraceNodeSupplier, Label: supplier.Name, Data: *supplier}), models.TraceNodeSupplier)
+			}
+			if attempt.BatchNumber != "" {
+				id := batchNodeID(uint(m.ID), attempt.BatchNumber)
+				node := models.TraceNode{ID: id, Type: models.TraceNodeBatch, Label: attempt.BatchNumber}
+				if batch := batches[id]; batch != nil {
+					node.Data = *batch
+				}
+				addEdge(product, addNode(node), models.TraceNodeBatch)
+			}
+		}
+		if line := attempt.ProductLine; line != nil {
+			addEdge(product, addNode(models.TraceNode{ID: nodeID(models.TraceNodeLine, line.ID), Type: models.TraceNodeLine, Label: line.Name, Data: *line}), models.TraceNodeLine)
+		}
+		if pallet := attempt.Pallet; pallet != nil {
+			palletData := *pallet
+			palletData.ProductModel, palletData.ProductLine = nil, nil
+			addEdge(product, addNode(models.TraceNode{ID: nodeID(models.TraceNodePallet, pallet.ID), Type: models.TraceNodePallet, Label: pallet.SN, Data: palletData}), models.TraceNodePallet)
+		}
+		if plan := attempt.ProductionPlan; plan != nil {
+			addEdge(product, addNode(models.TraceNode{ID: nodeID(models.TraceNodePlan, plan.ID), Type: models.TraceNodePlan, Label: plan.MaterialCode, Data: *plan}), models.TraceNodePlan)
+		}
+	}
+
+	for _, mate := range mates {
+		pallet := nodeID(models.TraceNodePallet, int64(*mate.PalletID))
+		if !seen[pallet] {
+			continue
+		}
+		product := addNode(models.TraceNode{ID: nodeID(models.TraceNodeProduct, mate.ID), Type: models.TraceNodeProduct, Label: mate.SN, Data: mate})
+		addEdge(product, pallet, models.TraceNodePallet)
+	}
+	return nodes, edges
+}
+
+func batchNodeID(productModelID uint, batchNumber string) string {
+	return fmt.Sprintf("%s:%d:%s", models.TraceNodeBatch, productModelID, strings.TrimSpace(batchNumber))
+}
+
+// 产品记录中需要记录变化的字段
+func productFieldValues(p *models.Product) map[string]interface{} {
+	return map[string]interface{}{
+		"sn":               p.SN,
+		"batchNumber":      p.BatchNumber,
+		"productModelId":   uintValue(p.ProductModelID),
+		"productLineId":    uintValue(p.ProductLineID),
+		"productionPlanId": uintValue(p.ProductionPlanID),
+		"palletId":         uintValue(p.PalletID),
+		"hasDefect":        p.HasDefect,
+		"defectReason":     p.DefectReason,
+	}
+}
+
+func uintValue(v *uint) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+// 两个版本之间变化的字段
+func productChanges(before, after *models.Product) map[string]models.ProductChange {
+	from, to := productFieldValues(before), productFieldValues(after)
+	changes := make(map[string]models.ProductChange)
+	for field, value := range to {
+		if from[field] != value {
+			changes[field] = models.ProductChange{From: from[field], To: value}
+		}
+	}
+	return changes
+}
+
+func recordProductEvent(tx *gorm.DB, eventType string, product *models.Product, changes map[string]models.ProductChange) error {
+	event := models.ProductEvent{ProductID: product.ID, SN: product.SN, Type: eventType, Changes: changes}
+	return tx.Create(&event).Error
+}
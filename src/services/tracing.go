@@ -0,0 +1,13 @@
+package services
+
+import (
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/tracing"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// 服务方法的 span：返回的 db 使用 span 所在的 context，方法内执行的 SQL 成为它的子 span
+func serviceSpan(db *gorm.DB, name string) (*gorm.DB, trace.Span) {
+	ctx, span := tracing.Start(db.Statement.Context, name)
+	return db.WithContext(ctx), span
+}
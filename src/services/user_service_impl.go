@@ -1,13 +1,29 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 )
 
+// 用户列表可筛选、排序的字段
+var userFields = utils.Fields{
+	"id":        {Column: "users.id", Type: utils.FieldInt, Sort: true},
+	"createdAt": {Column: "users.created_at", Type: utils.FieldTime, Sort: true},
+	"updatedAt": {Column: "users.updated_at", Type: utils.FieldTime, Sort: true},
+	"username":  {Column: "users.username", Type: utils.FieldString, Sort: true},
+	"email":     {Column: "users.email", Type: utils.FieldString, Sort: true},
+	"mobile":    {Column: "users.mobile", Type: utils.FieldString},
+	"active":    {Column: "users.active", Type: utils.FieldBool},
+}
+
+var userKeyset = utils.KeysetColumns{Field: "createdAt", CreatedAt: "users.created_at", ID: "users.id"}
+
 type UserService struct {
 	db *gorm.DB
 }
@@ -16,7 +32,19 @@ func NewUserService(db *gorm.DB) (IUserService, error) {
 	return &UserService{db: db}, nil
 }
 
+func (s *UserService) WithContext(ctx context.Context) IUserService {
+	return &UserService{db: s.db.WithContext(ctx)}
+}
+
+func (s *UserService) startSpan(method string) (*UserService, trace.Span) {
+	db, span := serviceSpan(s.db, "UserService."+method)
+	return &UserService{db: db}, span
+}
+
 func (s *UserService) CreateUser(user *models.User) error {
+	s, span := s.startSpan("CreateUser")
+	defer span.End()
+
 	// Check if user exists
 	result := s.db.Where("email = ?", user.Email).First(user)
 	if result.Error == nil {
@@ -39,6 +67,9 @@ const (
 )
 
 func (s *UserService) GetUserBy(identifierType UserIdentifierType, value interface{}) (*models.User, error) {
+	s, span := s.startSpan("GetUserBy")
+	defer span.End()
+
 	var user models.User
 	query := s.db.Model(&models.User{})
 
@@ -62,8 +93,14 @@ func (s *UserService) GetUserBy(identifierType UserIdentifierType, value interfa
 }
 
 func (s *UserService) GetUsers(query map[string]interface{}, paginate map[string]interface{}, sqlHandler ...func(*gorm.DB) *gorm.DB) ([]models.User, models.PaginationResult, error) {
+	s, span := s.startSpan("GetUsers")
+	defer span.End()
+
 	var users []models.User = []models.User{}
-	var paginateResult models.PaginationResult
+	page, err := utils.ParsePage(paginate, userFields, userKeyset)
+	if err != nil {
+		return nil, models.PaginationResult{}, err
+	}
 	model := s.db.Model(&models.User{})
 
 	// Search
@@ -73,22 +110,33 @@ func (s *UserService) GetUsers(query map[string]interface{}, paginate map[string
 	delete(query, "keyword")
 	model = model.Where(query)
 
-	model, paginateResult = utils.DoPagination(model, paginate)
-	model = utils.DoOrder(model, paginate)
+	model, paginateResult, err := page.Apply(model)
+	if err != nil {
+		return nil, models.PaginationResult{}, err
+	}
 
 	result := model.Find(&users)
 	if result.Error != nil {
 		return nil, models.PaginationResult{}, result.Error
 	}
 
+	users = users[:page.Next(&paginateResult, len(users), func(i int) (time.Time, int64) {
+		return users[i].CreatedAt, users[i].ID
+	})]
 	return users, paginateResult, nil
 }
 
 func (s *UserService) UpdateUser(userObj *models.User, user map[string]interface{}) error {
+	s, span := s.startSpan("UpdateUser")
+	defer span.End()
+
 	return s.db.Model(userObj).Updates(user).Error
 }
 
 func (s *UserService) DeleteUsers(ids []int64) error {
+	s, span := s.startSpan("DeleteUsers")
+	defer span.End()
+
 	result := s.db.Delete(&models.User{}, ids)
 	if result.Error != nil {
 		return result.Error
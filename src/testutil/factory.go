@@ -0,0 +1,229 @@
+package testutil
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/services"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/utils"
+	"gorm.io/gorm"
+)
+
+// Factory 创建测试数据：传入的字段原样保留，未填写的必要字段补默认值
+// 时间戳默认取 Now，保证同一份数据在每次运行中得到相同的响应
+type Factory struct {
+	t   testing.TB
+	db  *gorm.DB
+	seq int
+	Now time.Time
+}
+
+func NewFactory(t testing.TB, db *gorm.DB) *Factory {
+	return &Factory{
+		t:   t,
+		db:  db,
+		Now: time.Date(2024, 3, 1, 8, 0, 0, 0, utils.PlantLocation()),
+	}
+}
+
+func (f *Factory) next() int {
+	f.seq++
+	return f.seq
+}
+
+func (f *Factory) stamp(fields *models.ModelFields) {
+	if fields.CreatedAt.IsZero() {
+		fields.CreatedAt = f.Now
+	}
+	if fields.UpdatedAt.IsZero() {
+		fields.UpdatedAt = fields.CreatedAt
+	}
+}
+
+func (f *Factory) create(value interface{}) {
+	f.t.Helper()
+	if err := f.db.Create(value).Error; err != nil {
+		f.t.Fatalf("create %T: %v", value, err)
+	}
+}
+
+func (f *Factory) Supplier(supplier models.Supplier) *models.Supplier {
+	f.t.Helper()
+	n := f.next()
+	if supplier.Name == "" {
+		supplier.Name = fmt.Sprintf("供应商%d", n)
+	}
+	if supplier.SAP == "" {
+		supplier.SAP = fmt.Sprintf("SAP%05d", n)
+	}
+	if supplier.Type == "" {
+		supplier.Type = models.SupplierTypeDirect
+	}
+	f.stamp(&supplier.ModelFields)
+	f.create(&supplier)
+	return &supplier
+}
+
+func (f *Factory) ProductModel(productModel models.ProductModel) *models.ProductModel {
+	f.t.Helper()
+	n := f.next()
+	if productModel.SN == "" {
+		productModel.SN = fmt.Sprintf("M%06d", n)
+	}
+	if productModel.Description == "" {
+		productModel.Description = fmt.Sprintf("PN%04d/电机", n)
+	}
+	f.stamp(&productModel.ModelFields)
+	f.create(&productModel)
+	return &productModel
+}
+
+func (f *Factory) ProductLine(line models.ProductLine) *models.ProductLine {
+	f.t.Helper()
+	n := f.next()
+	if line.Name == "" {
+		line.Name = fmt.Sprintf("产线%d", n)
+	}
+	if line.DeviceID == "" {
+		line.DeviceID = fmt.Sprintf("device-%d", n)
+	}
+	f.stamp(&line.ModelFields)
+	f.create(&line)
+	return &line
+}
+
+func (f *Factory) Pallet(pallet models.Pallet) *models.Pallet {
+	f.t.Helper()
+	if pallet.SN == "" {
+		pallet.SN = fmt.Sprintf("PALLET%06d", f.next())
+	}
+	f.stamp(&pallet.ModelFields)
+	f.create(&pallet)
+	return &pallet
+}
+
+// 产品经 ProductService 写入，同时维护质量预聚合
+func (f *Factory) Product(product models.Product) *models.Product {
+	f.t.Helper()
+	if product.SN == "" {
+		product.SN = fmt.Sprintf("P%06d00010001", f.next())
+	}
+	if product.BatchNumber == "" && len(product.SN) >= 11 {
+		product.BatchNumber = product.SN[7:11]
+	}
+	f.stamp(&product.ModelFields)
+	productService, _ := services.NewProductService(f.db)
+	if err := productService.CreateProduct(&product); err != nil {
+		f.t.Fatalf("create product: %v", err)
+	}
+	return &product
+}
+
+func (f *Factory) ProductionPlan(plan models.ProductionPlan) *models.ProductionPlan {
+	f.t.Helper()
+	n := f.next()
+	if plan.PartNumber == "" {
+		plan.PartNumber = fmt.Sprintf("PN%04d", n)
+	}
+	if plan.MaterialCode == "" {
+		plan.MaterialCode = fmt.Sprintf("MC%06d", n)
+	}
+	if plan.PlanDate.IsZero() {
+		plan.PlanDate = time.Date(f.Now.Year(), f.Now.Month(), f.Now.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	f.stamp(&plan.ModelFields)
+	f.create(&plan)
+	return &plan
+}
+
+func (f *Factory) Shift(shift models.Shift) *models.Shift {
+	f.t.Helper()
+	if shift.Name == "" {
+		shift.Name = fmt.Sprintf("班次%d", f.next())
+	}
+	f.stamp(&shift.ModelFields)
+	f.create(&shift)
+	return &shift
+}
+
+func uintPtr(id int64) *uint {
+	v := uint(id)
+	return &v
+}
+
+// Scenario 质量统计和报表测试使用的标准数据
+type Scenario struct {
+	Suppliers     []*models.Supplier
+	ProductModels []*models.ProductModel
+	ProductLines  []*models.ProductLine
+	Pallets       []*models.Pallet
+	Products      []*models.Product
+	Plans         []*models.ProductionPlan
+}
+
+// 两个供应商、两个型号、两条产线，2024-03-01 至 2024-03-03（工厂时区）的产品，
+// 其中包含跨零点的夜班数据、两种不良原因，以及部分供应商没有数据的日期（03-02 仅有一个零点后的产品）
+func (f *Factory) SeedScenario() *Scenario {
+	f.t.Helper()
+	s := &Scenario{}
+	location := utils.PlantLocation()
+
+	s.Suppliers = []*models.Supplier{
+		f.Supplier(models.Supplier{Name: "乙供应商", SAP: "SAP00002", Type: models.SupplierTypeTrader}),
+		f.Supplier(models.Supplier{Name: "甲供应商", SAP: "SAP00001"}),
+	}
+	s.ProductModels = []*models.ProductModel{
+		f.ProductModel(models.ProductModel{SN: "MA00001", Description: "PN1001/直流电机", SupplierID: uintPtr(s.Suppliers[0].ID)}),
+		f.ProductModel(models.ProductModel{SN: "MB00002", Description: "PN2002/交流电机", SupplierID: uintPtr(s.Suppliers[1].ID)}),
+	}
+	s.ProductLines = []*models.ProductLine{
+		f.ProductLine(models.ProductLine{Name: "一号线", DeviceID: "device-line-1"}),
+		f.ProductLine(models.ProductLine{Name: "二号线", DeviceID: "device-line-2"}),
+	}
+	for i, productModel := range s.ProductModels {
+		s.Pallets = append(s.Pallets, f.Pallet(models.Pallet{
+			SN:             fmt.Sprintf("PALLET-%d", i+1),
+			ProductModelID: uintPtr(productModel.ID),
+			ProductLineID:  uintPtr(s.ProductLines[i].ID),
+			Goal:           10,
+		}))
+	}
+
+	products := []struct {
+		model, line int
+		at          time.Time
+		reason      string
+	}{
+		{0, 0, time.Date(2024, 3, 1, 8, 15, 0, 0, location), ""},
+		{0, 0, time.Date(2024, 3, 1, 9, 30, 0, 0, location), "划伤"},
+		{0, 0, time.Date(2024, 3, 1, 10, 45, 0, 0, location), ""},
+		{1, 1, time.Date(2024, 3, 1, 14, 0, 0, 0, location), "异响"},
+		{1, 1, time.Date(2024, 3, 1, 23, 50, 0, 0, location), ""},
+		{1, 1, time.Date(2024, 3, 2, 0, 10, 0, 0, location), "划伤"},
+		{0, 0, time.Date(2024, 3, 3, 7, 59, 0, 0, location), ""},
+		{0, 1, time.Date(2024, 3, 3, 12, 0, 0, 0, location), "异响"},
+		{1, 0, time.Date(2024, 3, 3, 16, 30, 0, 0, location), ""},
+		{1, 1, time.Date(2024, 3, 3, 20, 0, 0, 0, location), "划伤"},
+	}
+	for i, p := range products {
+		// 第8-11位为批次号，与产线端上报的 SN 格式一致
+		product := models.Product{
+			SN:             fmt.Sprintf("%s%04d%05d", s.ProductModels[p.model].SN, 301+p.model, i+1),
+			ProductModelID: uintPtr(s.ProductModels[p.model].ID),
+			ProductLineID:  uintPtr(s.ProductLines[p.line].ID),
+			PalletID:       uintPtr(s.Pallets[p.model].ID),
+			HasDefect:      p.reason != "",
+			DefectReason:   p.reason,
+		}
+		product.CreatedAt = p.at
+		s.Products = append(s.Products, f.Product(product))
+	}
+
+	s.Plans = []*models.ProductionPlan{
+		f.ProductionPlan(models.ProductionPlan{PartNumber: "PN1001", Manufacturer: "乙供应商", TPlanned: 2, T1Planned: 2, TotalPlanned: 4}),
+		f.ProductionPlan(models.ProductionPlan{PartNumber: "PN2002", Manufacturer: "甲供应商", TPlanned: 5, TotalPlanned: 5}),
+	}
+	return s
+}
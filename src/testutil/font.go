@@ -0,0 +1,24 @@
+package testutil
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// PDF 报表测试使用的 TrueType 字体：fpdf 模块源码中自带的 DejaVu 字体
+// 不含中文字形，只用于验证生成流程
+func ReportFont(t testing.TB) string {
+	t.Helper()
+	pc := reflect.ValueOf(fpdf.New).Pointer()
+	file, _ := runtime.FuncForPC(pc).FileLine(pc)
+	path := filepath.Join(filepath.Dir(file), "font", "DejaVuSansCondensed.ttf")
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("report font: %v", err)
+	}
+	return path
+}
@@ -0,0 +1,155 @@
+// Package testutil 集成测试辅助：SQLite 内存数据库、完整路由和测试数据工厂
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/config"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/databases"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/metrics"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/migrations"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/routes"
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/services"
+	"github.com/dreamskynl/godi"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 每次调用创建一个独立的 SQLite 内存数据库并执行全部迁移
+func NewDB(t testing.TB) *gorm.DB {
+	t.Helper()
+	db, err := databases.Open(databases.DriverSQLite, "", "", "", "", ":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	db.Logger = logger.Discard
+	if err := migrations.New(db).Up(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// Harness 进程内的服务：与 main 相同的服务注册和路由，请求经 httptest 直接送入 gin
+type Harness struct {
+	DB        *gorm.DB
+	Container godi.IGoDI
+	Router    *gin.Engine
+	Factory   *Factory
+}
+
+func NewHarness(t testing.TB) *Harness {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db := NewDB(t)
+	container := godi.New()
+	if err := services.RegisterAll(container, db); err != nil {
+		t.Fatalf("register services: %v", err)
+	}
+	router := gin.New()
+	routes.RegisterRoute(router, container)
+	metrics.TrackOpenPallets(db)
+
+	return &Harness{
+		DB:        db,
+		Container: container,
+		Router:    router,
+		Factory:   NewFactory(t, db),
+	}
+}
+
+// 启动后台任务工作协程，产物写入临时目录；测试结束时在关闭数据库之前停止
+func (h *Harness) StartJobWorkers(t testing.TB) {
+	t.Helper()
+	services.ConfigureExports(t.TempDir(), config.Default().Storage.ExportSyncMaxRows)
+	jobs := config.Default().Jobs
+	jobs.PollInterval = 10 * time.Millisecond
+	jobs.RetryBackoff = 10 * time.Millisecond
+	jobs.MaxRetryBackoff = 50 * time.Millisecond
+	services.ConfigureJobs(jobs)
+
+	workers := services.StartJobWorkers(h.DB)
+	t.Cleanup(func() {
+		workers.Stop(context.Background())
+		services.ConfigureJobs(config.Default().Jobs)
+		services.ConfigureExports(config.Default().Storage.ExportDir, config.Default().Storage.ExportSyncMaxRows)
+	})
+}
+
+// 等待任务结束（完成、失败或取消），超时时测试失败
+func (h *Harness) WaitJob(t testing.TB, id int64) *models.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var job models.Job
+		if err := h.DB.First(&job, id).Error; err != nil {
+			t.Fatalf("load job %d: %v", id, err)
+		}
+		if job.Finished() {
+			return &job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %d still %s", id, job.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// 管理端令牌
+func (h *Harness) AdminToken() string {
+	jwtService, _ := services.NewJWTService()
+	return jwtService.GenerateToken("admin", 1, models.JwtServiceRoleAdmin)
+}
+
+// 产线端令牌
+func (h *Harness) LineToken(line *models.ProductLine) string {
+	jwtService, _ := services.NewJWTService()
+	return jwtService.GenerateToken(line.DeviceID, line.ID, models.JwtServiceRoleProductionLine)
+}
+
+// 发送请求，body 不为 nil 时编码为 JSON；token 为空时不带认证头
+func (h *Harness) Do(t testing.TB, method, path string, body interface{}, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("encode request body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	h.Router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// 以管理员身份发送 GET 请求，状态码不是 200 时测试失败
+func (h *Harness) Get(t testing.TB, path string) []byte {
+	t.Helper()
+	recorder := h.Do(t, http.MethodGet, path, nil, h.AdminToken())
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("GET %s: status %d, body %s", path, recorder.Code, recorder.Body.String())
+	}
+	return recorder.Body.Bytes()
+}
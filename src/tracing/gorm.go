@@ -0,0 +1,86 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanKey = "tracing:span"
+
+// GormPlugin 为每条 SQL 语句创建子 span，父 span 取自 db.WithContext 传入的 context
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	registrations := []func() error{
+		func() error {
+			return callback.Create().Before("gorm:create").Register("tracing:before_create", before("create"))
+		},
+		func() error { return callback.Create().After("gorm:create").Register("tracing:after_create", after) },
+		func() error {
+			return callback.Query().Before("gorm:query").Register("tracing:before_query", before("query"))
+		},
+		func() error { return callback.Query().After("gorm:query").Register("tracing:after_query", after) },
+		func() error {
+			return callback.Update().Before("gorm:update").Register("tracing:before_update", before("update"))
+		},
+		func() error { return callback.Update().After("gorm:update").Register("tracing:after_update", after) },
+		func() error {
+			return callback.Delete().Before("gorm:delete").Register("tracing:before_delete", before("delete"))
+		},
+		func() error { return callback.Delete().After("gorm:delete").Register("tracing:after_delete", after) },
+		func() error { return callback.Row().Before("gorm:row").Register("tracing:before_row", before("row")) },
+		func() error { return callback.Row().After("gorm:row").Register("tracing:after_row", after) },
+		func() error { return callback.Raw().Before("gorm:raw").Register("tracing:before_raw", before("raw")) },
+		func() error { return callback.Raw().After("gorm:raw").Register("tracing:after_raw", after) },
+	}
+	for _, register := range registrations {
+		if err := register(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		name := "gorm." + operation
+		if db.Statement.Table != "" {
+			name += " " + db.Statement.Table
+		}
+		_, span := Start(db.Statement.Context, name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemNameKey.String(db.Dialector.Name()),
+				semconv.DBOperationName(operation),
+				semconv.DBCollectionName(db.Statement.Table),
+			),
+		)
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+func after(db *gorm.DB) {
+	value, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+	span.SetAttributes(semconv.DBQueryText(db.Statement.SQL.String()))
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 筛选条件无效（字段不在白名单中、操作符或值不合法），接口返回 400
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// 单个 filter 参数中最多的条件数
+const maxFilterTerms = 20
+
+type FieldType int
+
+const (
+	FieldString FieldType = iota
+	FieldInt
+	FieldFloat
+	FieldBool
+	FieldTime // 时刻，值按工厂时区解析
+	FieldDate // 日期列，值为 YYYY-MM-DD
+)
+
+// 列表可筛选的字段，Sort 为 true 时也可用于 sort 参数
+type Field struct {
+	Column string
+	Type   FieldType
+	Sort   bool
+}
+
+// 字段白名单：参数中的字段名 -> 列
+type Fields map[string]Field
+
+func (f Fields) sortColumn(name string) (string, bool) {
+	field, ok := f[name]
+	if !ok || !field.Sort {
+		return "", false
+	}
+	return field.Column, true
+}
+
+// 比较操作符 -> SQL
+var filterOperators = map[string]string{
+	"eq":  "=",
+	"ne":  "<>",
+	"gt":  ">",
+	"gte": ">=",
+	"lt":  "<",
+	"lte": "<=",
+}
+
+func invalidFilter(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidFilter, fmt.Sprintf(format, args...))
+}
+
+// 解析 filter 参数：以 ; 分隔的 字段:操作符:值，多个条件之间为 AND，例如
+// hasDefect:eq:true;createdAt:gte:2025-01-01。
+// 操作符：eq、ne、gt、gte、lt、lte、like（包含）、in（值以 , 分隔）、null（值为 true/false）。
+// 字段和列名只取自白名单，值全部作为参数传入
+func ParseFilter(expr string, fields Fields) ([]func(*gorm.DB) *gorm.DB, error) {
+	var scopes []func(*gorm.DB) *gorm.DB
+	for _, term := range strings.Split(expr, ";") {
+		if strings.TrimSpace(term) == "" {
+			continue
+		}
+		if len(scopes) == maxFilterTerms {
+			return nil, invalidFilter("too many conditions, at most %d", maxFilterTerms)
+		}
+		// 值中可以包含冒号（例如时间）
+		parts := strings.SplitN(term, ":", 3)
+		if len(parts) != 3 {
+			return nil, invalidFilter("%q is not in field:op:value form", term)
+		}
+		name, operator, raw := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), parts[2]
+		field, ok := fields[name]
+		if !ok {
+			return nil, invalidFilter("field %q cannot be filtered", name)
+		}
+		scope, err := filterScope(field, operator, raw)
+		if err != nil {
+			return nil, invalidFilter("%s: %v", name, err)
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes, nil
+}
+
+func filterScope(field Field, operator, raw string) (func(*gorm.DB) *gorm.DB, error) {
+	column := field.Column
+	switch operator {
+	case "null":
+		isNull, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, errors.New("null expects true or false")
+		}
+		condition := column + " IS NOT NULL"
+		if isNull {
+			condition = column + " IS NULL"
+		}
+		return func(db *gorm.DB) *gorm.DB { return db.Where(condition) }, nil
+	case "like":
+		if field.Type != FieldString {
+			return nil, errors.New("like only applies to text fields")
+		}
+		// 以 ! 转义通配符，三种数据库都支持 ESCAPE '!'
+		pattern := "%" + strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(raw) + "%"
+		return func(db *gorm.DB) *gorm.DB { return db.Where(column+" LIKE ? ESCAPE '!'", pattern) }, nil
+	case "in":
+		var values []interface{}
+		for _, item := range strings.Split(raw, ",") {
+			value, err := filterValue(field.Type, item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return func(db *gorm.DB) *gorm.DB { return db.Where(column+" IN ?", values) }, nil
+	}
+
+	sqlOperator, ok := filterOperators[operator]
+	if !ok {
+		return nil, fmt.Errorf("unknown operator %q", operator)
+	}
+	if field.Type == FieldBool && sqlOperator != "=" && sqlOperator != "<>" {
+		return nil, fmt.Errorf("operator %q does not apply to boolean fields", operator)
+	}
+	value, err := filterValue(field.Type, raw)
+	if err != nil {
+		return nil, err
+	}
+	return func(db *gorm.DB) *gorm.DB { return db.Where(column+" "+sqlOperator+" ?", value) }, nil
+}
+
+// 按字段类型转换值，时间按工厂时区解析后转为数据库时区
+func filterValue(fieldType FieldType, raw string) (interface{}, error) {
+	switch fieldType {
+	case FieldInt:
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", raw)
+		}
+		return value, nil
+	case FieldFloat:
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", raw)
+		}
+		return value, nil
+	case FieldBool:
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", raw)
+		}
+		return value, nil
+	case FieldTime:
+		for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+			if value, err := time.ParseInLocation(layout, raw, PlantLocation()); err == nil {
+				return InDB(value), nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not a time, expected YYYY-MM-DD, YYYY-MM-DD HH:MM:SS or RFC 3339", raw)
+	case FieldDate:
+		if _, err := time.Parse("2006-01-02", raw); err != nil {
+			return nil, fmt.Errorf("%q is not a date, expected YYYY-MM-DD", raw)
+		}
+		return raw, nil
+	}
+	return raw, nil
+}
@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
+	"gorm.io/gorm"
+)
+
+// 分页参数无效（页码、排序字段或游标），接口返回 400
+var ErrInvalidPagination = errors.New("invalid pagination")
+
+// 游标分页的键：创建时间列和主键列，Field 为默认排序字段
+type KeysetColumns struct {
+	Field     string
+	CreatedAt string
+	ID        string
+}
+
+// 解析后的分页参数，偏移分页与游标分页共用
+type Page struct {
+	Size      int // 0 或负数表示不分页（仅偏移分页）
+	Num       int
+	Column    string
+	Desc      bool
+	Keyset    bool
+	WithTotal bool
+	keys      KeysetColumns
+	cursor    *pageCursor
+	filters   []func(*gorm.DB) *gorm.DB
+}
+
+// 游标内容：最后一条记录的 (created_at, id) 和排序方向，编码为 base64 后对客户端不透明
+type pageCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        int64     `json:"i"`
+	Desc      bool      `json:"d"`
+}
+
+func invalidPagination(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidPagination, fmt.Sprintf(format, args...))
+}
+
+// 解析分页参数（StructToMap(models.PaginationQuery) 的结果），filter 和 sort 只能使用白名单中的字段，
+// 游标分页只支持按创建时间排序
+func ParsePage(paginate map[string]interface{}, fields Fields, keys KeysetColumns) (Page, error) {
+	page := Page{keys: keys, Desc: !boolParam(paginate, "asc"), WithTotal: boolParam(paginate, "with_total")}
+
+	var err error
+	if page.filters, err = ParseFilter(stringParam(paginate, "filter"), fields); err != nil {
+		return page, err
+	}
+
+	var ok bool
+	if _, exists := paginate["page_size"]; exists {
+		if page.Size, ok = intParam(paginate, "page_size"); !ok {
+			return page, invalidPagination("pageSize must be an integer")
+		}
+	}
+	if _, exists := paginate["page_num"]; exists {
+		if page.Num, ok = intParam(paginate, "page_num"); !ok || page.Num < 0 {
+			return page, invalidPagination("pageNum must be a positive integer")
+		}
+	}
+
+	field := stringParam(paginate, "sort")
+	if field != "" {
+		page.Desc = strings.HasPrefix(field, "-")
+		field = strings.TrimPrefix(field, "-")
+	} else {
+		field = keys.Field
+	}
+	if page.Column, ok = fields.sortColumn(field); !ok {
+		return page, invalidPagination("unsupported sort field %q", field)
+	}
+
+	cursor := stringParam(paginate, "cursor")
+	page.Keyset = boolParam(paginate, "keyset") || cursor != ""
+	if !page.Keyset {
+		if page.Size == 0 && page.Num > 0 {
+			page.Size = models.DefaultPageSize
+		}
+		if page.Size > 0 && page.Num < 1 {
+			page.Num = 1
+		}
+		return page, nil
+	}
+
+	if page.Column != keys.CreatedAt {
+		return page, invalidPagination("cursor pagination only supports sorting by %s", keys.Field)
+	}
+	if page.Num > 1 {
+		return page, invalidPagination("pageNum cannot be used with cursor pagination")
+	}
+	page.Num = 0
+	if page.Size <= 0 {
+		page.Size = models.DefaultPageSize
+	}
+	if cursor != "" {
+		decoded, err := decodeCursor(cursor)
+		if err != nil {
+			return page, invalidPagination("malformed cursor")
+		}
+		if decoded.Desc != page.Desc {
+			return page, invalidPagination("cursor does not match the sort order")
+		}
+		page.cursor = decoded
+	}
+	return page, nil
+}
+
+// 追加筛选条件，统计总数（游标分页未要求时跳过）并追加分页和排序条件
+func (p Page) Apply(model *gorm.DB) (*gorm.DB, models.PaginationResult, error) {
+	for _, filter := range p.filters {
+		model = filter(model)
+	}
+	pagination := models.PaginationResult{Total: -1, PageNum: p.Num, PageSize: p.Size}
+	if !p.Keyset || p.WithTotal {
+		var total int64
+		if err := model.Count(&total).Error; err != nil {
+			return model, pagination, err
+		}
+		pagination.Total = int(total)
+	}
+
+	compare := ">"
+	if p.Desc {
+		compare = "<"
+	}
+	if p.Keyset {
+		if p.cursor != nil {
+			at := InDB(p.cursor.CreatedAt)
+			model = model.Where(fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))", p.keys.CreatedAt, compare, p.keys.CreatedAt, p.keys.ID, compare), at, at, p.cursor.ID)
+		}
+		// 多取一条用于判断是否还有下一页
+		model = model.Limit(p.Size + 1)
+	} else if p.Size > 0 {
+		model = model.Limit(p.Size).Offset((p.Num - 1) * p.Size)
+	}
+
+	return p.Order(model), pagination, nil
+}
+
+// 追加排序条件，以主键作为次级排序，保证相同排序值的记录顺序稳定
+func (p Page) Order(model *gorm.DB) *gorm.DB {
+	direction := "ASC"
+	if p.Desc {
+		direction = "DESC"
+	}
+	model = model.Order(p.Column + " " + direction)
+	if p.Column != p.keys.ID {
+		model = model.Order(p.keys.ID + " " + direction)
+	}
+	return model
+}
+
+// 游标分页时去掉多取的一条并生成下一页游标，返回本页记录数；key 返回第 i 条记录的创建时间和主键
+func (p Page) Next(pagination *models.PaginationResult, n int, key func(i int) (time.Time, int64)) int {
+	if !p.Keyset || n <= p.Size {
+		return n
+	}
+	createdAt, id := key(p.Size - 1)
+	pagination.NextCursor = encodeCursor(&pageCursor{CreatedAt: createdAt, ID: id, Desc: p.Desc})
+	return p.Size
+}
+
+func encodeCursor(cursor *pageCursor) string {
+	cursor.CreatedAt = cursor.CreatedAt.UTC()
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.CreatedAt.IsZero() || cursor.ID <= 0 {
+		return nil, errors.New("incomplete cursor")
+	}
+	return &cursor, nil
+}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"strconv"
+
 	"github.com/clutchtechnology/hisense-vmi-dataserver/src/models"
 	"gorm.io/gorm"
 )
@@ -9,16 +11,17 @@ func DoPagination(model *gorm.DB, paginate map[string]interface{}) (*gorm.DB, mo
 	var total int64
 	model.Count(&total)
 
-	var pageSize int
-	var pageNum int
-	if value, ok := paginate["page_size"]; ok {
-		pageSize = value.(int)
-		model = model.Limit(pageSize)
-
-		if value, ok := paginate["page_num"]; ok {
-			pageNum = value.(int)
-			model = model.Offset((pageNum - 1) * pageSize)
+	// 只传 pageNum 时使用默认页大小；pageSize 为负数表示不分页
+	pageSize, _ := intParam(paginate, "page_size")
+	pageNum, _ := intParam(paginate, "page_num")
+	if pageSize == 0 && pageNum > 0 {
+		pageSize = models.DefaultPageSize
+	}
+	if pageSize > 0 {
+		if pageNum < 1 {
+			pageNum = 1
 		}
+		model = model.Limit(pageSize).Offset((pageNum - 1) * pageSize)
 	}
 
 	pagination := models.PaginationResult{
@@ -32,14 +35,46 @@ func DoPagination(model *gorm.DB, paginate map[string]interface{}) (*gorm.DB, mo
 
 func DoOrder(model *gorm.DB, paginate map[string]interface{}) *gorm.DB {
 	var order string = "created_at DESC"
-	if value, ok := paginate["asc"]; ok {
-		if value.(bool) {
-			order = "created_at ASC"
-		} else {
-			order = "created_at DESC"
-		}
+	if boolParam(paginate, "asc") {
+		order = "created_at ASC"
 	}
 	model = model.Order(order)
 
 	return model
 }
+
+// 分页参数可能来自 StructToMap 或手工构造的 map，兼容常见的数值类型和字符串
+func intParam(paginate map[string]interface{}, key string) (int, bool) {
+	switch value := paginate[key].(type) {
+	case int:
+		return value, true
+	case int32:
+		return int(value), true
+	case int64:
+		return int(value), true
+	case uint:
+		return int(value), true
+	case float64:
+		return int(value), value == float64(int(value))
+	case string:
+		n, err := strconv.Atoi(value)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func boolParam(paginate map[string]interface{}, key string) bool {
+	switch value := paginate[key].(type) {
+	case bool:
+		return value
+	case string:
+		b, _ := strconv.ParseBool(value)
+		return b
+	}
+	return false
+}
+
+func stringParam(paginate map[string]interface{}, key string) string {
+	value, _ := paginate[key].(string)
+	return value
+}
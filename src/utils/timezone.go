@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPlantTimezone = "Asia/Shanghai"
+	defaultDBTimezone    = "UTC"
+	DateLayout           = "2006-01-02"
+)
+
+var (
+	plantLocation     *time.Location
+	plantLocationOnce sync.Once
+	dbLocation        *time.Location
+	dbLocationOnce    sync.Once
+)
+
+// 工厂所在时区，由环境变量 PLANT_TIMEZONE 配置，默认 Asia/Shanghai
+func PlantLocation() *time.Location {
+	plantLocationOnce.Do(func() {
+		plantLocation = loadLocation(os.Getenv("PLANT_TIMEZONE"), defaultPlantTimezone)
+	})
+	return plantLocation
+}
+
+// 数据库中时间字段的存储时区，由环境变量 DB_TIMEZONE 配置，默认 UTC
+func DBLocation() *time.Location {
+	dbLocationOnce.Do(func() {
+		dbLocation = loadLocation(os.Getenv("DB_TIMEZONE"), defaultDBTimezone)
+	})
+	return dbLocation
+}
+
+// 按配置设置工厂时区和数据库存储时区，须在首次使用时区之前调用；未调用时从环境变量读取
+func ConfigureTimezones(plant, db string) error {
+	plantLoc, err := time.LoadLocation(plant)
+	if err != nil {
+		return fmt.Errorf("invalid plant timezone %q", plant)
+	}
+	dbLoc, err := time.LoadLocation(db)
+	if err != nil {
+		return fmt.Errorf("invalid db timezone %q", db)
+	}
+	plantLocationOnce.Do(func() { plantLocation = plantLoc })
+	dbLocationOnce.Do(func() { dbLocation = dbLoc })
+	return nil
+}
+
+func loadLocation(name, fallback string) *time.Location {
+	if name == "" {
+		name = fallback
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		location, _ = time.LoadLocation(fallback)
+	}
+	return location
+}
+
+// 解析请求中的 tz 参数，为空时使用工厂时区
+func ResolveLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return PlantLocation(), nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tz %q", name)
+	}
+	return location, nil
+}
+
+// 按指定时区解析 YYYY-MM-DD 日期
+func ParseDate(value string, location *time.Location) (time.Time, error) {
+	return time.ParseInLocation(DateLayout, value, location)
+}
+
+// date 类型列的取值：保留 t 在其所在时区的日期，换为存储时区的零点，驱动按存储时区写入时日期不变
+func DateColumn(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, DBLocation())
+}
+
+// 将 [startDate, endDate] 日期区间转换为时刻区间 [start, end)
+func DateRange(startDate, endDate string, location *time.Location) (time.Time, time.Time, error) {
+	start, err := ParseDate(startDate, location)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid start date %q, expected YYYY-MM-DD", startDate)
+	}
+	end, err := ParseDate(endDate, location)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid end date %q, expected YYYY-MM-DD", endDate)
+	}
+	return start, end.AddDate(0, 0, 1), nil
+}
+
+// 换算到数据库存储时区后作为查询参数；SQLite 以文本比较时间，参数必须与存储时区一致
+func InDB(t time.Time) time.Time {
+	return t.In(DBLocation())
+}